@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/streaming"
+	"github.com/google/subcommands"
+	"go.uber.org/zap"
+)
+
+// CleanupCmd struct with flags.
+type CleanupCmd struct {
+	project     string
+	region      string
+	instance    string
+	migrationId string
+	all         bool
+	yes         bool
+	logLevel    string
+}
+
+// Name returns the name of operation.
+func (cmd *CleanupCmd) Name() string {
+	return "cleanup"
+}
+
+// Synopsis returns summary of operation.
+func (cmd *CleanupCmd) Synopsis() string {
+	return "delete the streams, Dataflow jobs, GCS files and databases left behind by migrations"
+}
+
+// Usage returns usage info of the command.
+func (cmd *CleanupCmd) Usage() string {
+	return fmt.Sprintf(`%v cleanup -region=[region] [-instance=[instance]] [-migration-id=[HB-...]]...
+
+Find the resources left behind by HarbourBridge migrations, list them and
+delete them after confirmation: the Datastream streams, the Dataflow jobs and
+the GCS output and session files of the migrations of the region whose stream
+or job failed, stopped or is done, the GCS buckets created by the web UI for
+migrations that don't run anymore and, if -instance is specified, the Spanner
+databases with generated names that aren't ready or have no tables. Streams
+and jobs are recognized by their migration label or by their generated name.
+With -all, the resources of running migrations are deleted as well. With
+-migration-id, only the resources of that migration are considered. The
+cleanup flags are:
+`, path.Base(os.Args[0]))
+}
+
+// SetFlags sets the flags.
+func (cmd *CleanupCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.project, "project", "", "Project of the resources, defaults to the project of gcloud")
+	f.StringVar(&cmd.region, "region", "", "Region of the Datastream streams and the Dataflow jobs")
+	f.StringVar(&cmd.instance, "instance", "", "Spanner instance whose databases with generated names are deleted")
+	f.StringVar(&cmd.migrationId, "migration-id", "", "Migration request id, e.g. HB-0f9e8d7c-1234-4abc-8def-0123456789ab, whose resources are deleted")
+	f.BoolVar(&cmd.all, "all", false, "Also delete the resources of running migrations and the databases that have tables")
+	f.BoolVar(&cmd.yes, "yes", false, "Delete the resources without asking for confirmation")
+	f.StringVar(&cmd.logLevel, "log-level", "INFO", "Configure the logging level for the command (INFO, DEBUG), defaults to INFO")
+}
+
+func (cmd *CleanupCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Fatal("FATAL error", zap.Error(err))
+		}
+	}()
+	err = logger.InitializeLogger(cmd.logLevel)
+	if err != nil {
+		fmt.Println("Error initialising logger, did you specify a valid log-level? [DEBUG, INFO, WARN, ERROR, FATAL]", err)
+		return subcommands.ExitFailure
+	}
+	defer logger.Log.Sync()
+
+	if cmd.region == "" {
+		err = fmt.Errorf("please specify -region")
+		return subcommands.ExitUsageError
+	}
+	if cmd.project == "" {
+		cmd.project, err = utils.GetProject()
+		if err != nil {
+			err = fmt.Errorf("can't get project, please specify -project: %v", err)
+			return subcommands.ExitUsageError
+		}
+	}
+	cleaner, err := streaming.NewGCloudCleaner(ctx, cmd.project, cmd.region, cmd.instance)
+	if err != nil {
+		err = fmt.Errorf("can't clean up the migration resources: %v", err)
+		return subcommands.ExitFailure
+	}
+	defer cleaner.Close()
+	listed, err := cleaner.List(ctx)
+	if err != nil {
+		err = fmt.Errorf("can't clean up the migration resources: %v", err)
+		return subcommands.ExitFailure
+	}
+	resources := streaming.SelectResources(listed, cmd.migrationId, cmd.all)
+	if len(resources) == 0 {
+		fmt.Println("No resources left behind by HarbourBridge were found.")
+		return subcommands.ExitSuccess
+	}
+	fmt.Printf("Found %d resources left behind by HarbourBridge in project %s:\n", len(resources), cmd.project)
+	streaming.PrintResources(os.Stdout, resources)
+	if !cmd.yes && !confirm(fmt.Sprintf("Delete these %d resources?", len(resources))) {
+		fmt.Println("No resources were deleted.")
+		return subcommands.ExitSuccess
+	}
+	err = streaming.CleanUp(ctx, cleaner, resources, os.Stdout)
+	if err != nil {
+		err = fmt.Errorf("can't clean up the migration resources: %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// confirm asks the question on stdout, and returns whether the answer read
+// from stdin is yes.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
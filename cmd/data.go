@@ -47,6 +47,7 @@ type DataCmd struct {
 	filePrefix      string // TODO: move filePrefix to global flags
 	WriteLimit      int64
 	dryRun          bool
+	checkResources  bool
 	logLevel        string
 	SkipForeignKeys bool
 }
@@ -82,6 +83,7 @@ func (cmd *DataCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.filePrefix, "prefix", "", "File prefix for generated files")
 	f.Int64Var(&cmd.WriteLimit, "write-limit", DefaultWritersLimit, "Write limit for writes to spanner")
 	f.BoolVar(&cmd.dryRun, "dry-run", false, "Flag for generating DDL and schema conversion report without creating a spanner database")
+	f.BoolVar(&cmd.checkResources, "check-resources", false, "With -dry-run, check that the resources referenced by the streaming config exist")
 	f.StringVar(&cmd.logLevel, "log-level", "INFO", "Configure the logging level for the command (INFO, DEBUG), defaults to INFO")
 	f.BoolVar(&cmd.SkipForeignKeys, "skip-foreign-keys", false, "Skip creating foreign keys after data migration is complete (ddl statements for foreign keys can still be found in the downloaded schema.ddl.txt file and the same can be applied separately)")
 }
@@ -108,6 +110,7 @@ func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
 		err = fmt.Errorf("error while preparing prerequisites for migration: %v", err)
 		return subcommands.ExitUsageError
 	}
+	sourceProfile.CheckResources = cmd.checkResources
 	var (
 		bw     *writer.BatchWriter
 		banner string
@@ -117,7 +120,9 @@ func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
 	conv.Audit.MigrationType = migration.MigrationData_DATA_ONLY.Enum()
 	dataCoversionStartTime := time.Now()
 
-	if !sourceProfile.UseTargetSchema() {
+	// CSV data can be loaded using the schema of the target database, or
+	// using a session file from a run of the schema subcommand.
+	if !sourceProfile.UseTargetSchema() || cmd.sessionJSON != "" {
 		err = conversion.ReadSessionFile(conv, cmd.sessionJSON)
 		if err != nil {
 			return subcommands.ExitUsageError
@@ -164,7 +169,8 @@ func (cmd *DataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface
 }
 
 // validateExistingDb validates that the existing spanner schema is in accordance with the one specified in the session file.
-func validateExistingDb(ctx context.Context, targetDb, dbURI string, adminClient *database.DatabaseAdminClient, client *sp.Client, conv *internal.Conv) error {
+// If requireEmpty is set, the tables must also be empty.
+func validateExistingDb(ctx context.Context, targetDb, dbURI string, adminClient *database.DatabaseAdminClient, client *sp.Client, conv *internal.Conv, requireEmpty bool) error {
 	dbExists, err := conversion.CheckExistingDb(ctx, adminClient, dbURI)
 	if err != nil {
 		err = fmt.Errorf("can't verify target database: %v", err)
@@ -174,10 +180,12 @@ func validateExistingDb(ctx context.Context, targetDb, dbURI string, adminClient
 		err = fmt.Errorf("target database doesn't exist")
 		return err
 	}
-	err = conversion.ValidateTables(ctx, client, targetDb)
-	if err != nil {
-		err = fmt.Errorf("error validating the tables: %v", err)
-		return err
+	if requireEmpty {
+		err = conversion.ValidateTables(ctx, client, targetDb)
+		if err != nil {
+			err = fmt.Errorf("error validating the tables: %v", err)
+			return err
+		}
 	}
 	spannerConv := internal.MakeConv()
 	spannerConv.TargetDb = targetDb
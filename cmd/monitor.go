@@ -0,0 +1,106 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/streaming"
+	"github.com/google/subcommands"
+	"go.uber.org/zap"
+)
+
+// MonitorCmd struct with flags.
+type MonitorCmd struct {
+	stream      string
+	jobId       string
+	jobLocation string
+	interval    time.Duration
+	logLevel    string
+}
+
+// Name returns the name of operation.
+func (cmd *MonitorCmd) Name() string {
+	return "monitor"
+}
+
+// Synopsis returns summary of operation.
+func (cmd *MonitorCmd) Synopsis() string {
+	return "monitor the Datastream stream and the Dataflow job of a streaming migration"
+}
+
+// Usage returns usage info of the command.
+func (cmd *MonitorCmd) Usage() string {
+	return fmt.Sprintf(`%v monitor -stream=projects/[project]/locations/[location]/streams/[id] -job-id=[id] -job-location=[location]...
+
+Monitor a streaming migration through Datastream and Dataflow: report the change
+events processed, the Datastream output files of each table, the backlog of
+files and the errors, and whether the moment is optimum for switching to Cloud
+Spanner. The stream and the job are printed when the migration starts. The
+monitor flags are:
+`, path.Base(os.Args[0]))
+}
+
+// SetFlags sets the flags.
+func (cmd *MonitorCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.stream, "stream", "", "Full name of the Datastream stream, e.g. projects/my-project/locations/us-central1/streams/my-stream")
+	f.StringVar(&cmd.jobId, "job-id", "", "Id of the Dataflow job")
+	f.StringVar(&cmd.jobLocation, "job-location", "", "Region of the Dataflow job")
+	f.DurationVar(&cmd.interval, "interval", time.Minute, "Time between two polls of the stream and the job")
+	f.StringVar(&cmd.logLevel, "log-level", "INFO", "Configure the logging level for the command (INFO, DEBUG), defaults to INFO")
+}
+
+func (cmd *MonitorCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Log.Fatal("FATAL error", zap.Error(err))
+		}
+	}()
+	err = logger.InitializeLogger(cmd.logLevel)
+	if err != nil {
+		fmt.Println("Error initialising logger, did you specify a valid log-level? [DEBUG, INFO, WARN, ERROR, FATAL]", err)
+		return subcommands.ExitFailure
+	}
+	defer logger.Log.Sync()
+
+	if cmd.stream == "" || cmd.jobId == "" || cmd.jobLocation == "" {
+		err = fmt.Errorf("please specify -stream, -job-id and -job-location")
+		return subcommands.ExitUsageError
+	}
+	if cmd.interval <= 0 {
+		err = fmt.Errorf("-interval must be positive")
+		return subcommands.ExitUsageError
+	}
+	poller, err := streaming.NewDataflowPoller(ctx, cmd.stream, cmd.jobId, cmd.jobLocation)
+	if err != nil {
+		err = fmt.Errorf("can't monitor the streaming migration: %v", err)
+		return subcommands.ExitFailure
+	}
+	defer poller.Close()
+	fmt.Printf("Monitoring the stream %s and the Dataflow job %s every %s. Use Ctrl+C to stop the process.\n", cmd.stream, cmd.jobId, cmd.interval)
+	err = streaming.RunMonitor(ctx, poller, cmd.interval, os.Stdout)
+	if err != nil {
+		err = fmt.Errorf("can't monitor the streaming migration: %v", err)
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
@@ -43,6 +43,8 @@ type SchemaCmd struct {
 	filePrefix    string // TODO: move filePrefix to global flags
 	logLevel      string
 	dryRun        bool
+	typeMapping   string
+	profileData   bool
 }
 
 // Name returns the name of operation.
@@ -75,6 +77,8 @@ func (cmd *SchemaCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.filePrefix, "prefix", "", "File prefix for generated files")
 	f.StringVar(&cmd.logLevel, "log-level", "INFO", "Configure the logging level for the command (INFO, DEBUG), defaults to INFO")
 	f.BoolVar(&cmd.dryRun, "dry-run", false, "Flag for generating DDL and schema conversion report without creating a spanner database")
+	f.StringVar(&cmd.typeMapping, "type-mapping", "", "YAML or JSON file with overrides of the mapping of source types to Spanner types")
+	f.BoolVar(&cmd.profileData, "profile-data", false, "Profile the data of the source database to choose narrower Spanner types (direct connections only)")
 }
 
 func (cmd *SchemaCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -98,6 +102,13 @@ func (cmd *SchemaCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interfa
 		err = fmt.Errorf("error while preparing prerequisites for migration: %v", err)
 		return subcommands.ExitUsageError
 	}
+	sourceProfile.ProfileData = cmd.profileData
+	if cmd.typeMapping != "" {
+		sourceProfile.TypeMapping, err = internal.LoadTypeMapping(cmd.typeMapping)
+		if err != nil {
+			return subcommands.ExitUsageError
+		}
+	}
 
 	// If filePrefix not explicitly set, use generated dbName.
 	if cmd.filePrefix == "" {
@@ -45,7 +45,10 @@ type SchemaAndDataCmd struct {
 	filePrefix      string // TODO: move filePrefix to global flags
 	WriteLimit      int64
 	dryRun          bool
+	checkResources  bool
 	logLevel        string
+	typeMapping     string
+	profileData     bool
 }
 
 // Name returns the name of operation.
@@ -79,7 +82,10 @@ func (cmd *SchemaAndDataCmd) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.filePrefix, "prefix", "", "File prefix for generated files")
 	f.Int64Var(&cmd.WriteLimit, "write-limit", DefaultWritersLimit, "Write limit for writes to spanner")
 	f.BoolVar(&cmd.dryRun, "dry-run", false, "Flag for generating DDL and schema conversion report without creating a spanner database")
+	f.BoolVar(&cmd.checkResources, "check-resources", false, "With -dry-run, check that the resources referenced by the streaming config exist")
 	f.StringVar(&cmd.logLevel, "log-level", "INFO", "Configure the logging level for the command (INFO, DEBUG), defaults to INFO")
+	f.StringVar(&cmd.typeMapping, "type-mapping", "", "YAML or JSON file with overrides of the mapping of source types to Spanner types")
+	f.BoolVar(&cmd.profileData, "profile-data", false, "Profile the data of the source database to choose narrower Spanner types (direct connections only)")
 }
 
 func (cmd *SchemaAndDataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
@@ -103,6 +109,14 @@ func (cmd *SchemaAndDataCmd) Execute(ctx context.Context, f *flag.FlagSet, _ ...
 		err = fmt.Errorf("error while preparing prerequisites for migration: %v", err)
 		return subcommands.ExitUsageError
 	}
+	sourceProfile.CheckResources = cmd.checkResources
+	sourceProfile.ProfileData = cmd.profileData
+	if cmd.typeMapping != "" {
+		sourceProfile.TypeMapping, err = internal.LoadTypeMapping(cmd.typeMapping)
+		if err != nil {
+			return subcommands.ExitUsageError
+		}
+	}
 	schemaConversionStartTime := time.Now()
 
 	// If filePrefix not explicitly set, use dbName as prefix.
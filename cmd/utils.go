@@ -141,8 +141,10 @@ func migrateData(ctx context.Context, targetProfile profiles.TargetProfile, sour
 		bw  *writer.BatchWriter
 		err error
 	)
-	if !sourceProfile.UseTargetSchema() {
-		err = validateExistingDb(ctx, conv.TargetDb, dbURI, adminClient, client, conv)
+	// The tables already have rows after the first incremental sync.
+	resync := conversion.IncrementalSyncStarted(sourceProfile)
+	if !sourceProfile.UseTargetSchema() || len(conv.SpSchema) != 0 {
+		err = validateExistingDb(ctx, conv.TargetDb, dbURI, adminClient, client, conv, !resync)
 		if err != nil {
 			err = fmt.Errorf("error while validating existing database: %v", err)
 			return nil, err
@@ -154,7 +156,8 @@ func migrateData(ctx context.Context, targetProfile profiles.TargetProfile, sour
 		return nil, err
 	}
 	conv.Audit.Progress.UpdateProgress("Data migration complete.", completionPercentage, internal.DataMigrationComplete)
-	if !cmd.SkipForeignKeys {
+	// Foreign keys are added by the first incremental sync.
+	if !cmd.SkipForeignKeys && !resync {
 		if err = conversion.UpdateDDLForeignKeys(ctx, adminClient, dbURI, conv, ioHelper.Out); err != nil {
 			err = fmt.Errorf("can't perform update schema on db %s with foreign keys: %v", dbURI, err)
 			return bw, err
@@ -44,6 +44,27 @@ const (
 	// This is an experimental driver; implementation in progress.
 	ORACLE string = "oracle"
 
+	// MONGODB is the driver name for MongoDB collections exported as JSON
+	// Lines (e.g. using mongoexport).
+	// This is an experimental driver; implementation in progress.
+	MONGODB string = "mongodb"
+
+	// CASSANDRA is the driver name for Cassandra keyspaces, whose schema is
+	// read from DESCRIBE KEYSPACE output and data from COPY TO exports.
+	// This is an experimental driver; implementation in progress.
+	CASSANDRA string = "cassandra"
+
+	// SPANNER is the driver name for Cloud Spanner databases used as the
+	// source of a migration, of either dialect.
+	SPANNER string = "spanner"
+
+	// Policies for MySQL zero dates (e.g. 0000-00-00) in data conversion,
+	// set with the zeroDates param of the source profile. A date in
+	// YYYY-MM-DD format can also be given, and is then used in place of zero
+	// dates.
+	ZeroDatesReject string = "reject"
+	ZeroDatesNull   string = "null"
+
 	// Target db for which schema is being generated.
 	TargetSpanner              string = "spanner"
 	TargetExperimentalPostgres string = "experimental_postgres"
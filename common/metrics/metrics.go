@@ -98,6 +98,10 @@ func getMigrationDataSourceDetails(driver string, migrationData *migration.Migra
 		return migration.MigrationData_DIRECT_CONNECTION.Enum(), migration.MigrationData_SQL_SERVER.Enum()
 	case constants.CSV:
 		return migration.MigrationData_FILE.Enum(), migration.MigrationData_CSV.Enum()
+	case constants.SPANNER:
+		return migration.MigrationData_DIRECT_CONNECTION.Enum(), migration.MigrationData_SOURCE_UNSPECIFIED.Enum()
+	case constants.MONGODB, constants.CASSANDRA:
+		return migration.MigrationData_FILE.Enum(), migration.MigrationData_SOURCE_UNSPECIFIED.Enum()
 	default:
 		return migration.MigrationData_SOURCE_CONNECTION_MECHANISM_UNSPECIFIED.Enum(), migration.MigrationData_SOURCE_UNSPECIFIED.Enum()
 	}
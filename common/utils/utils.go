@@ -27,6 +27,7 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"reflect"
 	"sort"
@@ -56,9 +57,27 @@ type IOStreams struct {
 }
 
 // Harbourbridge accepts a manifest file in the form of a json which unmarshalls into the ManifestTables struct.
+// File patterns can be local paths or gs:// URLs, and can contain glob
+// wildcards. The remaining fields are optional and describe how the table's
+// files are formatted. Unset fields fall back to the source profile settings.
 type ManifestTable struct {
 	Table_name    string   `json:"table_name"`
 	File_patterns []string `json:"file_patterns"`
+	// Columns lists the column names in the order they appear in the files.
+	// An empty name skips that field. Unless Header is true, files with a
+	// column list are assumed not to have a header row.
+	Columns []string `json:"columns,omitempty"`
+	// Header says whether the first row of each file is a header. If unset,
+	// it is detected by comparing the first row with the column names.
+	Header *bool `json:"header,omitempty"`
+	// Delimiter, Quote and Escape are single characters. Escape defaults to
+	// Quote, i.e. quotes inside quoted fields are doubled.
+	Delimiter        string  `json:"delimiter,omitempty"`
+	Quote            string  `json:"quote,omitempty"`
+	Escape           string  `json:"escape,omitempty"`
+	Null_str         *string `json:"null_str,omitempty"`
+	Date_format      string  `json:"date_format,omitempty"`
+	Timestamp_format string  `json:"timestamp_format,omitempty"`
 }
 
 // NewIOStreams returns a new IOStreams struct such that input stream is set
@@ -146,6 +165,76 @@ func DownloadFromGCS(bucketName, filePath, tmpFile string) (*os.File, error) {
 	return tmpfile, nil
 }
 
+// ExpandFilePatterns replaces the glob patterns in the manifest with the
+// files they match. Patterns without wildcards are kept as they are, so that
+// missing files are reported when they are read.
+func ExpandFilePatterns(tables []ManifestTable) ([]ManifestTable, error) {
+	for i, table := range tables {
+		var files []string
+		for _, pattern := range table.File_patterns {
+			if !strings.ContainsAny(pattern, "*?[") {
+				files = append(files, pattern)
+				continue
+			}
+			var matches []string
+			var err error
+			if strings.HasPrefix(pattern, constants.GCS_SCHEME+"://") {
+				matches, err = MatchGCSFiles(pattern)
+			} else {
+				matches, err = filepath.Glob(pattern)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("can't expand file pattern %s for table %s: %v", pattern, table.Table_name, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match pattern %s for table %s", pattern, table.Table_name)
+			}
+			sort.Strings(matches)
+			files = append(files, matches...)
+		}
+		tables[i].File_patterns = files
+	}
+	return tables, nil
+}
+
+// MatchGCSFiles lists the objects matching a gs://bucket/pattern glob. The
+// objects are listed using the part of the pattern before the first
+// wildcard as prefix, and matched with path.Match, so `*` doesn't match `/`.
+func MatchGCSFiles(pattern string) ([]string, error) {
+	u, err := url.Parse(pattern)
+	if err != nil || u.Host == "" || len(u.Path) < 2 {
+		return nil, fmt.Errorf("not a valid GCS path: %s", pattern)
+	}
+	bucketName := u.Host
+	objPattern := u.Path[1:] // removes "/" from beginning of path
+	if _, err := path.Match(objPattern, ""); err != nil {
+		return nil, err
+	}
+	prefix := objPattern[:strings.IndexAny(objPattern, "*?[")]
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client for bucket %q: %v", bucketName, err)
+	}
+	defer client.Close()
+	var files []string
+	it := client.Bucket(bucketName).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't list objects in bucket %s: %v", bucketName, err)
+		}
+		if ok, _ := path.Match(objPattern, attrs.Name); ok {
+			files = append(files, fmt.Sprintf("%s://%s/%s", constants.GCS_SCHEME, bucketName, attrs.Name))
+		}
+	}
+	return files, nil
+}
+
 // PreloadGCSFiles downloads gcs files to tmp and updates the file paths in manifest with the local path.
 func PreloadGCSFiles(tables []ManifestTable) ([]ManifestTable, error) {
 	for i, table := range tables {
@@ -51,9 +51,11 @@ import (
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/logger"
 	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/cassandra"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/csv"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/dynamodb"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/mongodb"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/mysql"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/oracle"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/postgres"
@@ -61,6 +63,7 @@ import (
 	"github.com/cloudspannerecosystem/harbourbridge/sources/sqlserver"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/writer"
+	"github.com/cloudspannerecosystem/harbourbridge/streaming"
 	"go.uber.org/zap"
 	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
 	"google.golang.org/grpc/metadata"
@@ -79,10 +82,12 @@ var (
 // The SourceProfile param provides the connection details to use the go SQL library.
 func SchemaConv(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, ioHelper *utils.IOStreams) (*internal.Conv, error) {
 	switch sourceProfile.Driver {
-	case constants.POSTGRES, constants.MYSQL, constants.DYNAMODB, constants.SQLSERVER, constants.ORACLE:
+	case constants.POSTGRES, constants.MYSQL, constants.DYNAMODB, constants.SQLSERVER, constants.ORACLE, constants.MONGODB, constants.CASSANDRA, constants.SPANNER:
 		return schemaFromDatabase(sourceProfile, targetProfile)
 	case constants.PGDUMP, constants.MYSQLDUMP:
-		return schemaFromDump(sourceProfile.Driver, targetProfile.TargetDb, ioHelper)
+		return schemaFromDump(sourceProfile.Driver, targetProfile.TargetDb, sourceProfile.TypeMapping, ioHelper)
+	case constants.CSV:
+		return schemaFromCSV(sourceProfile, targetProfile)
 	default:
 		return nil, fmt.Errorf("schema conversion for driver %s not supported", sourceProfile.Driver)
 	}
@@ -96,9 +101,13 @@ func DataConv(ctx context.Context, sourceProfile profiles.SourceProfile, targetP
 		WriteLimit: writeLimit,
 		RetryLimit: 1000,
 		Verbose:    internal.Verbose(),
+		// Pages re-read after resuming a DynamoDB scan may already be in Spanner,
+		// and so may the rows read by an incremental sync.
+		Upsert: sourceProfile.Conn.Dydb.ScanCheckpoint != "" || sourceProfile.IncrementalSync(),
 	}
+	conv.ZeroDates = sourceProfile.ZeroDates
 	switch sourceProfile.Driver {
-	case constants.POSTGRES, constants.MYSQL, constants.DYNAMODB, constants.SQLSERVER, constants.ORACLE:
+	case constants.POSTGRES, constants.MYSQL, constants.DYNAMODB, constants.SQLSERVER, constants.ORACLE, constants.MONGODB, constants.CASSANDRA, constants.SPANNER:
 		return dataFromDatabase(ctx, sourceProfile, targetProfile, config, conv, client)
 	case constants.PGDUMP, constants.MYSQLDUMP:
 		if conv.SpSchema.CheckInterleaved() {
@@ -141,6 +150,10 @@ func connectionConfig(sourceProfile profiles.SourceProfile) (interface{}, error)
 		return profiles.GetSQLConnectionStr(sourceProfile), nil
 	case constants.ORACLE:
 		return profiles.GetSQLConnectionStr(sourceProfile), nil
+	case constants.MONGODB, constants.CASSANDRA:
+		return sourceProfile.File.Path, nil
+	case constants.SPANNER:
+		return sourceProfile.Conn.Spanner.DbURI(), nil
 	default:
 		return "", fmt.Errorf("driver %s not supported", sourceProfile.Driver)
 	}
@@ -168,11 +181,44 @@ func getDbNameFromSQLConnectionStr(driver, sqlConnectionStr string) string {
 func schemaFromDatabase(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile) (*internal.Conv, error) {
 	conv := internal.MakeConv()
 	conv.TargetDb = targetProfile.TargetDb
+	conv.TypeMapping = sourceProfile.TypeMapping
+	conv.ProfileData = sourceProfile.ProfileData
 	infoSchema, err := GetInfoSchema(sourceProfile, targetProfile)
 	if err != nil {
 		return conv, err
 	}
-	return conv, common.ProcessSchema(conv, infoSchema, common.DefaultWorkers)
+	err = common.ProcessSchema(conv, infoSchema, common.DefaultWorkers)
+	if err != nil {
+		return conv, err
+	}
+	if isi, ok := infoSchema.(spanner.InfoSchemaImpl); ok {
+		setInterleaving(conv, isi)
+		conv.SrcReadTimestamp = isi.ReadTimestamp
+	}
+	return conv, nil
+}
+
+// setInterleaving interleaves the tables of conv like the tables of the
+// Spanner database read by isi.
+func setInterleaving(conv *internal.Conv, isi spanner.InfoSchemaImpl) {
+	parentTables, err := isi.GetInterleaveTables()
+	if err != nil {
+		// As in utils.ReadSpannerSchema, the emulator doesn't support the
+		// interleave_type column, so this isn't fatal.
+		conv.Unexpected(fmt.Sprintf("error trying to fetch interleave table info from schema: %v", err))
+		return
+	}
+	for table, parent := range parentTables {
+		spTable, err1 := internal.GetSpannerTable(conv, table)
+		spParent, err2 := internal.GetSpannerTable(conv, parent)
+		if err1 != nil || err2 != nil {
+			conv.Unexpected(fmt.Sprintf("Can't interleave table %s in %s: err1=%s, err2=%s", table, parent, err1, err2))
+			continue
+		}
+		ct := conv.SpSchema[spTable]
+		ct.Parent = spParent
+		conv.SpSchema[spTable] = ct
+	}
 }
 
 func performSnapshotMigration(config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client, infoSchema common.InfoSchema) *writer.BatchWriter {
@@ -187,12 +233,20 @@ func performSnapshotMigration(config writer.BatchWriterConfig, conv *internal.Co
 	return batchWriter
 }
 
-func snapshotMigrationHandler(sourceProfile profiles.SourceProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client, infoSchema common.InfoSchema) (*writer.BatchWriter, error) {
+func snapshotMigrationHandler(ctx context.Context, sourceProfile profiles.SourceProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client, infoSchema common.InfoSchema, streamInfo map[string]interface{}) (*writer.BatchWriter, error) {
 	switch sourceProfile.Driver {
 	// Skip snapshot migration via harbourbridge for mysql and oracle since dataflow job will job will handle this from backfilled data.
+	// Unless the streaming config asks HarbourBridge to read a consistent snapshot.
 	case constants.MYSQL, constants.ORACLE, constants.POSTGRES:
+		if streamingCfg, _ := streamInfo["streamingCfg"].(streaming.StreamingCfg); streamingCfg.SnapshotCfg.PositionFile != "" {
+			return performConsistentSnapshotMigration(ctx, config, conv, client, infoSchema, streamingCfg.SnapshotCfg)
+		}
 		return &writer.BatchWriter{}, nil
 	case constants.DYNAMODB:
+		// The snapshot was loaded before the streaming migration that is resumed.
+		if sourceProfile.Conn.Dydb.StreamResume {
+			return &writer.BatchWriter{}, nil
+		}
 		return performSnapshotMigration(config, conv, client, infoSchema), nil
 	default:
 		return &writer.BatchWriter{}, fmt.Errorf("streaming migration not supported for driver %s", sourceProfile.Driver)
@@ -200,17 +254,30 @@ func snapshotMigrationHandler(sourceProfile profiles.SourceProfile, config write
 }
 
 func dataFromDatabase(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client) (*writer.BatchWriter, error) {
+	// The data is read at the timestamp at which the schema was read.
+	if sourceProfile.Driver == constants.SPANNER && sourceProfile.Conn.Spanner.ReadTimestamp.IsZero() {
+		sourceProfile.Conn.Spanner.ReadTimestamp = conv.SrcReadTimestamp
+	}
 	infoSchema, err := GetInfoSchema(sourceProfile, targetProfile)
 	if err != nil {
 		return nil, err
 	}
+	if sourceProfile.IncrementalSync() {
+		return incrementalSync(ctx, sourceProfile, config, conv, client, infoSchema)
+	}
 	var streamInfo map[string]interface{}
+	if sourceProfile.Conn.Streaming && conv.Audit.DryRun && streamsThroughDatastream(sourceProfile.Driver) {
+		if err := planStreamingMigration(ctx, sourceProfile, targetProfile, conv, infoSchema); err != nil {
+			return nil, err
+		}
+		return performSnapshotMigration(config, conv, client, infoSchema), nil
+	}
 	if sourceProfile.Conn.Streaming {
 		streamInfo, err = infoSchema.StartChangeDataCapture(ctx, conv)
 		if err != nil {
 			return nil, err
 		}
-		bw, err := snapshotMigrationHandler(sourceProfile, config, conv, client, infoSchema)
+		bw, err := snapshotMigrationHandler(ctx, sourceProfile, config, conv, client, infoSchema, streamInfo)
 		if err != nil {
 			return nil, err
 		}
@@ -223,6 +290,35 @@ func dataFromDatabase(ctx context.Context, sourceProfile profiles.SourceProfile,
 	return performSnapshotMigration(config, conv, client, infoSchema), nil
 }
 
+// streamsThroughDatastream returns whether the streaming migrations of driver
+// use Datastream.
+func streamsThroughDatastream(driver string) bool {
+	switch driver {
+	case constants.MYSQL, constants.ORACLE, constants.POSTGRES:
+		return true
+	}
+	return false
+}
+
+// planStreamingMigration prints what the streaming migration would launch in
+// a dry run, instead of launching it, and fails if the streaming config is
+// invalid.
+func planStreamingMigration(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, conv *internal.Conv, infoSchema common.InfoSchema) error {
+	var source streaming.StreamedTables
+	if s, ok := infoSchema.(streaming.StreamedTables); ok {
+		source = s
+	}
+	plan, err := streaming.PlanStreamingMigration(ctx, sourceProfile, targetProfile, conv, source)
+	if err != nil {
+		return fmt.Errorf("can't plan streaming migration: %v", err)
+	}
+	plan.Print(os.Stdout)
+	if len(plan.Errors) > 0 {
+		return fmt.Errorf("the streaming config has %d errors", len(plan.Errors))
+	}
+	return nil
+}
+
 func getDynamoDBClientConfig() (*aws.Config, error) {
 	cfg := aws.Config{}
 	endpointOverride := os.Getenv("DYNAMODB_ENDPOINT_OVERRIDE")
@@ -232,7 +328,7 @@ func getDynamoDBClientConfig() (*aws.Config, error) {
 	return &cfg, nil
 }
 
-func schemaFromDump(driver string, targetDb string, ioHelper *utils.IOStreams) (*internal.Conv, error) {
+func schemaFromDump(driver string, targetDb string, typeMapping *internal.TypeMapping, ioHelper *utils.IOStreams) (*internal.Conv, error) {
 	f, n, err := getSeekable(ioHelper.In)
 	if err != nil {
 		utils.PrintSeekError(driver, err, ioHelper.Out)
@@ -242,6 +338,7 @@ func schemaFromDump(driver string, targetDb string, ioHelper *utils.IOStreams) (
 	ioHelper.BytesRead = n
 	conv := internal.MakeConv()
 	conv.TargetDb = targetDb
+	conv.TypeMapping = typeMapping
 	p := internal.NewProgress(n, "Generating schema", internal.Verbose(), false, int(internal.SchemaCreationInProgress))
 	r := internal.NewReader(bufio.NewReader(f), p)
 	conv.SetSchemaMode() // Build schema and ignore data in dump.
@@ -287,30 +384,61 @@ func dataFromDump(driver string, config writer.BatchWriterConfig, ioHelper *util
 	return batchWriter, nil
 }
 
-func dataFromCSV(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client) (*writer.BatchWriter, error) {
-	if targetProfile.Conn.Sp.Dbname == "" {
-		return nil, fmt.Errorf("dbName is mandatory in target-profile for csv source")
+// schemaFromCSV infers the schema from the CSV files, for use when there is
+// no existing Spanner database to read the schema from.
+func schemaFromCSV(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile) (*internal.Conv, error) {
+	conv := internal.MakeConv()
+	conv.TargetDb = targetProfile.TargetDb
+	conv.TypeMapping = sourceProfile.TypeMapping
+	delimiter, err := getCSVDelimiter(sourceProfile)
+	if err != nil {
+		return nil, err
 	}
-	conv.TargetDb = targetProfile.ToLegacyTargetDb()
-	dialect, err := targetProfile.FetchTargetDialect(ctx)
+	tables, err := csv.GetCSVFiles(conv, sourceProfile)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch dialect: %v", err)
+		return nil, fmt.Errorf("error finding csv files: %v", err)
 	}
-
-	if utils.DialectToTarget(dialect) != conv.TargetDb {
-		return nil, fmt.Errorf("dialect specified in target profile does not match spanner dialect")
+	infoSchema, err := csv.NewInfoSchemaImpl(tables, sourceProfile.Csv.NullStr, delimiter, profiles.GetSchemaSampleSize(sourceProfile))
+	if err != nil {
+		return nil, err
 	}
+	return conv, common.ProcessSchema(conv, infoSchema, common.DefaultWorkers)
+}
 
+func getCSVDelimiter(sourceProfile profiles.SourceProfile) (rune, error) {
 	delimiterStr := sourceProfile.Csv.Delimiter
 	if len(delimiterStr) != 1 {
-		return nil, fmt.Errorf("delimiter should only be a single character long, found '%s'", delimiterStr)
+		return 0, fmt.Errorf("delimiter should only be a single character long, found '%s'", delimiterStr)
 	}
+	return rune(delimiterStr[0]), nil
+}
 
-	delimiter := rune(delimiterStr[0])
-
-	err = utils.ReadSpannerSchema(ctx, conv, client)
+func dataFromCSV(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client) (*writer.BatchWriter, error) {
+	delimiter, err := getCSVDelimiter(sourceProfile)
 	if err != nil {
-		return nil, fmt.Errorf("error trying to read and convert spanner schema: %v", err)
+		return nil, err
+	}
+
+	// If the schema wasn't inferred from the CSV files or read from a
+	// session file, use the schema of the target database.
+	if len(conv.SpSchema) == 0 {
+		if targetProfile.Conn.Sp.Dbname == "" {
+			return nil, fmt.Errorf("dbName is mandatory in target-profile for csv source")
+		}
+		conv.TargetDb = targetProfile.ToLegacyTargetDb()
+		dialect, err := targetProfile.FetchTargetDialect(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch dialect: %v", err)
+		}
+
+		if utils.DialectToTarget(dialect) != conv.TargetDb {
+			return nil, fmt.Errorf("dialect specified in target profile does not match spanner dialect")
+		}
+
+		err = utils.ReadSpannerSchema(ctx, conv, client)
+		if err != nil {
+			return nil, fmt.Errorf("error trying to read and convert spanner schema: %v", err)
+		}
 	}
 
 	tables, err := csv.GetCSVFiles(conv, sourceProfile)
@@ -360,6 +488,13 @@ func populateDataConv(conv *internal.Conv, config writer.BatchWriterConfig, clie
 		conv.DataFlush = func() {
 			batchWriter.Flush()
 		}
+		conv.DataDropped = func() int64 {
+			var n int64
+			for _, dropped := range batchWriter.DroppedRowsByTable() {
+				n += dropped
+			}
+			return n
+		}
 	}
 
 	return batchWriter
@@ -548,7 +683,9 @@ func CreateDatabase(ctx context.Context, adminClient *database.DatabaseAdminClie
 		req.DatabaseDialect = adminpb.DatabaseDialect_POSTGRESQL
 	} else {
 		req.CreateStatement = "CREATE DATABASE `" + dbName + "`"
-		req.ExtraStatements = conv.SpSchema.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: false, TargetDb: conv.TargetDb})
+		// Sequences are created first, since columns may refer to them.
+		cfg := ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: false, TargetDb: conv.TargetDb}
+		req.ExtraStatements = append(ddl.GetSequenceDDL(conv.SpSequences, cfg), conv.SpSchema.GetDDL(cfg)...)
 	}
 
 	op, err := adminClient.CreateDatabase(ctx, req)
@@ -574,7 +711,8 @@ func UpdateDatabase(ctx context.Context, adminClient *database.DatabaseAdminClie
 	// Spanner DDL doesn't accept them), and protects table and col names
 	// using backticks (to avoid any issues with Spanner reserved words).
 	// Foreign Keys are set to false since we create them post data migration.
-	schema := conv.SpSchema.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: false, TargetDb: conv.TargetDb})
+	cfg := ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: false, TargetDb: conv.TargetDb}
+	schema := append(ddl.GetSequenceDDL(conv.SpSequences, cfg), conv.SpSchema.GetDDL(cfg)...)
 	req := &adminpb.UpdateDatabaseDdlRequest{
 		Database:   dbURI,
 		Statements: schema,
@@ -679,7 +817,8 @@ func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.Fi
 	// and doesn't add backticks around table and column names. This file is
 	// intended for explanatory and documentation purposes, and is not strictly
 	// legal Cloud Spanner DDL (Cloud Spanner doesn't currently support comments).
-	spDDL := conv.SpSchema.GetDDL(ddl.Config{Comments: true, ProtectIds: false, Tables: true, ForeignKeys: true, TargetDb: conv.TargetDb})
+	cfg := ddl.Config{Comments: true, ProtectIds: false, Tables: true, ForeignKeys: true, TargetDb: conv.TargetDb}
+	spDDL := append(ddl.GetSequenceDDL(conv.SpSequences, cfg), conv.SpSchema.GetDDL(cfg)...)
 	if len(spDDL) == 0 {
 		spDDL = []string{"\n-- Schema is empty -- no tables found\n"}
 	}
@@ -706,7 +845,8 @@ func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.Fi
 
 	// We change 'Comments' to false and 'ProtectIds' to true below to write out a
 	// schema file that is a legal Cloud Spanner DDL.
-	spDDL = conv.SpSchema.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: true, TargetDb: conv.TargetDb})
+	cfg = ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: true, TargetDb: conv.TargetDb}
+	spDDL = append(ddl.GetSequenceDDL(conv.SpSequences, cfg), conv.SpSchema.GetDDL(cfg)...)
 	if len(spDDL) == 0 {
 		spDDL = []string{"\n-- Schema is empty -- no tables found\n"}
 	}
@@ -945,9 +1085,24 @@ func GetInfoSchema(sourceProfile profiles.SourceProfile, targetProfile profiles.
 			newSession := session.Must(session.NewSession())
 			dydbStreamsClient = dynamodbstreams.New(newSession, connectionConfig.(*aws.Config))
 		}
+		var splitter *dynamodb.Splitter
+		if sourceProfile.Conn.Dydb.SplitRules != "" {
+			var err error
+			splitter, err = dynamodb.LoadSplitRules(sourceProfile.Conn.Dydb.SplitRules)
+			if err != nil {
+				return nil, err
+			}
+		}
 		return dynamodb.InfoSchemaImpl{
 			DynamoClient:        dydbClient,
 			SampleSize:          profiles.GetSchemaSampleSize(sourceProfile),
+			ScanSegments:        sourceProfile.Conn.Dydb.ScanSegments,
+			ScanCheckpoint:      sourceProfile.Conn.Dydb.ScanCheckpoint,
+			Splitter:            splitter,
+			StreamCheckpoint:    sourceProfile.Conn.Dydb.StreamCheckpoint,
+			StreamResume:        sourceProfile.Conn.Dydb.StreamResume,
+			StopFile:            sourceProfile.Conn.Dydb.StopFile,
+			StatusFile:          sourceProfile.Conn.Dydb.StatusFile,
 			DynamoStreamsClient: dydbStreamsClient,
 		}, nil
 	case constants.SQLSERVER:
@@ -956,7 +1111,7 @@ func GetInfoSchema(sourceProfile profiles.SourceProfile, targetProfile profiles.
 		if err != nil {
 			return nil, err
 		}
-		return sqlserver.InfoSchemaImpl{DbName: dbName, Db: db}, nil
+		return sqlserver.InfoSchemaImpl{DbName: dbName, Db: db, KeepOffset: sourceProfile.KeepOffset}, nil
 	case constants.ORACLE:
 		db, err := sql.Open(driver, connectionConfig.(string))
 		dbName := getDbNameFromSQLConnectionStr(driver, connectionConfig.(string))
@@ -964,6 +1119,17 @@ func GetInfoSchema(sourceProfile profiles.SourceProfile, targetProfile profiles.
 			return nil, err
 		}
 		return oracle.InfoSchemaImpl{DbName: strings.ToUpper(dbName), Db: db, SourceProfile: sourceProfile, TargetProfile: targetProfile}, nil
+	case constants.MONGODB:
+		return mongodb.NewInfoSchemaImpl(connectionConfig.(string), profiles.GetSchemaSampleSize(sourceProfile))
+	case constants.CASSANDRA:
+		return cassandra.NewInfoSchemaImpl(connectionConfig.(string))
+	case constants.SPANNER:
+		ctx := context.Background()
+		client, err := utils.NewSpannerClient(ctx, connectionConfig.(string))
+		if err != nil {
+			return nil, fmt.Errorf("can't create client for source database %s: %v", connectionConfig, err)
+		}
+		return spanner.NewInfoSchemaImpl(ctx, client, sourceProfile.Conn.Spanner.ReadTimestamp)
 	default:
 		return nil, fmt.Errorf("driver %s not supported", driver)
 	}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	sp "cloud.google.com/go/spanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/writer"
+)
+
+// IncrementalSyncStarted returns true if a previous incremental sync saved
+// its watermarks: the Spanner tables then already have rows.
+func IncrementalSyncStarted(sourceProfile profiles.SourceProfile) bool {
+	if !sourceProfile.IncrementalSync() {
+		return false
+	}
+	_, err := os.Stat(sourceProfile.Conn.SqlServer.WatermarkState)
+	return err == nil
+}
+
+// syncTracker records the tables whose data was processed successfully,
+// i.e. without error and without rows that failed to convert.
+type syncTracker struct {
+	common.InfoSchema
+	synced map[string]bool
+}
+
+func (t *syncTracker) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	badRows := conv.Stats.BadRows[srcTable]
+	err := t.InfoSchema.ProcessData(conv, srcTable, srcSchema, spTable, spCols, spSchema)
+	if err == nil && conv.Stats.BadRows[srcTable] == badRows {
+		t.synced[srcTable] = true
+	}
+	return err
+}
+
+// incrementalSync upserts the rows that changed since the previous sync,
+// using the watermark columns of the tables, and optionally deletes the rows
+// no longer in the source. The watermark of a table is only advanced if all
+// its rows were converted and written.
+func incrementalSync(ctx context.Context, sourceProfile profiles.SourceProfile, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client, infoSchema common.InfoSchema) (*writer.BatchWriter, error) {
+	is, ok := infoSchema.(common.IncrementalInfoSchema)
+	if !ok {
+		return nil, fmt.Errorf("incremental sync not supported for driver %s", sourceProfile.Driver)
+	}
+	cfg := sourceProfile.Conn.SqlServer
+	def, perTable, err := common.ParseWatermarkColumns(cfg.Watermark)
+	if err != nil {
+		return nil, err
+	}
+	state, err := common.LoadWatermarkState(cfg.WatermarkState)
+	if err != nil {
+		return nil, err
+	}
+	predicates, next, err := common.PlanIncrementalSync(conv, is, state, def, perTable)
+	if err != nil {
+		return nil, err
+	}
+	tracker := &syncTracker{InfoSchema: is.WithPredicates(predicates), synced: make(map[string]bool)}
+	bw := performSnapshotMigration(config, conv, client, tracker)
+	if conv.Audit.DryRun {
+		return bw, nil
+	}
+	dropped := bw.DroppedRowsByTable()
+	for srcTable, wm := range next {
+		spTable, err := internal.GetSpannerTable(conv, srcTable)
+		if err != nil || !tracker.synced[srcTable] || dropped[spTable] > 0 {
+			fmt.Printf("Table %s wasn't synced entirely: its watermark is unchanged.\n", srcTable)
+			continue
+		}
+		state.Tables[srcTable] = wm
+	}
+	if err := state.Save(cfg.WatermarkState); err != nil {
+		return bw, fmt.Errorf("can't save watermark state: %v", err)
+	}
+	if cfg.DetectDeletes {
+		write := func(m []*sp.Mutation) error {
+			_, err := client.Apply(ctx, m)
+			return err
+		}
+		deleted, err := common.SyncDeletes(conv, is, spannerKeyReader(ctx, client), write)
+		for srcTable, n := range deleted {
+			fmt.Printf("Deleted %d rows of table %s that are no longer in the source.\n", n, srcTable)
+		}
+		if err != nil {
+			return bw, err
+		}
+	}
+	return bw, nil
+}
+
+// spannerKeyReader reads the INT64 and STRING primary keys of Spanner tables.
+func spannerKeyReader(ctx context.Context, client *sp.Client) common.KeyReader {
+	return func(spTable string, cols []string, f func(key []interface{}) error) error {
+		iter := client.Single().Read(ctx, spTable, sp.AllKeys(), cols)
+		return iter.Do(func(row *sp.Row) error {
+			key := make([]interface{}, row.Size())
+			for i := range key {
+				var v sp.GenericColumnValue
+				if err := row.Column(i, &v); err != nil {
+					return err
+				}
+				if v.Type.Code == sppb.TypeCode_INT64 {
+					var n sp.NullInt64
+					if err := v.Decode(&n); err != nil {
+						return err
+					}
+					if n.Valid {
+						key[i] = n.Int64
+					}
+				} else {
+					var s sp.NullString
+					if err := v.Decode(&s); err != nil {
+						return err
+					}
+					if s.Valid {
+						key[i] = s.StringVal
+					}
+				}
+			}
+			return f(key)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/writer"
+	"github.com/cloudspannerecosystem/harbourbridge/streaming"
+)
+
+// performConsistentSnapshotMigration reads the data of the source with
+// parallel readers sharing a consistent snapshot, and records the position
+// of the change log at the snapshot in cfg.PositionFile, where the change
+// data capture starts. The position is only recorded once all the rows were
+// read.
+func performConsistentSnapshotMigration(ctx context.Context, config writer.BatchWriterConfig, conv *internal.Conv, client *sp.Client, infoSchema common.InfoSchema, cfg streaming.SnapshotCfg) (*writer.BatchWriter, error) {
+	is, ok := infoSchema.(common.SnapshotInfoSchema)
+	if !ok {
+		return nil, fmt.Errorf("consistent snapshots are only supported for MySQL and PostgreSQL")
+	}
+	snap, err := is.BeginSnapshot(ctx, cfg.Readers)
+	if err != nil {
+		return nil, fmt.Errorf("can't begin consistent snapshot: %v", err)
+	}
+	defer snap.Close()
+	fmt.Printf("Reading the snapshot at %s with %d readers.\n", snap.Position, len(snap.Readers))
+	common.SetRowStats(conv, snap.Readers[0])
+	if !conv.Audit.DryRun {
+		conv.Audit.Progress = *internal.NewProgress(conv.Rows(), "Writing data to Spanner", internal.Verbose(), false, int(internal.DataWriteInProgress))
+	}
+	bw := populateDataConv(conv, config, client)
+	common.ProcessSnapshotData(conv, snap)
+	bw.Flush()
+	if err := snap.Close(); err != nil {
+		return bw, fmt.Errorf("can't end consistent snapshot: %v", err)
+	}
+	if err := snap.Position.Save(cfg.PositionFile); err != nil {
+		return bw, fmt.Errorf("can't save snapshot position: %v", err)
+	}
+	fmt.Printf("Saved the snapshot position to %s.\n", cfg.PositionFile)
+	return bw, nil
+}
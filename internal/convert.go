@@ -38,6 +38,7 @@ type Conv struct {
 	UsedNames      map[string]bool                     // Map storing the names that are already assigned to tables, indices or foreign key contraints.
 	dataSink       func(table string, cols []string, values []interface{})
 	DataFlush      func()         `json:"-"` // Data flush is used to flush out remaining writes and wait for them to complete.
+	DataDropped    func() int64   `json:"-"` // Data dropped returns the number of rows that the writer failed to write so far.
 	Location       *time.Location // Timezone (for timestamp conversion).
 	sampleBadRows  rowSamples     // Rows that generated errors during conversion.
 	Stats          stats
@@ -46,6 +47,37 @@ type Conv struct {
 	UniquePKey     map[string][]string // Maps Spanner table name to unique column name being used as primary key (if needed).
 	Audit          Audit               // Stores the audit information for the database conversion
 	Rules          []Rule              // Stores applied rules during schema conversion
+
+	// TypeMapping holds user-defined overrides of the type mapping (if any),
+	// and TypeOverrides maps source-DB table/col to the override applied to it.
+	TypeMapping   *TypeMapping `json:"-"`
+	TypeOverrides map[string]map[string]TypeOverride
+
+	// ProfileData enables the data profiling pass of schema conversion, and
+	// Profiles maps source-DB table/col to the profile of its data.
+	ProfileData bool `json:"-"`
+	Profiles    map[string]map[string]*ColumnProfile
+
+	// ZeroDates is the policy for MySQL zero dates in data conversion (see
+	// constants.ZeroDatesReject).
+	ZeroDates string `json:"-"`
+
+	// UserTypes maps the name of user-defined types of the source database
+	// to their definition, for sources that define them as separate
+	// statements (e.g. pg_dump).
+	UserTypes map[string]schema.UserType `json:",omitempty"`
+
+	// SrcSequences maps the name of source sequences to their definition,
+	// and SpSequences maps it to the schema of their Spanner sequence.
+	SrcSequences map[string]schema.Sequence `json:",omitempty"`
+	SpSequences  map[string]ddl.Sequence    `json:",omitempty"`
+	// Synonyms lists the synonyms of the source database, which Spanner
+	// doesn't support. They are kept for reporting purposes.
+	Synonyms []schema.Synonym `json:",omitempty"`
+	// SrcReadTimestamp is the timestamp at which schema conversion read a
+	// source database that is read at a timestamp (Spanner), so that data
+	// conversion reads the data at the same timestamp.
+	SrcReadTimestamp time.Time `json:"-"`
 }
 
 type mode int
@@ -96,6 +128,21 @@ const (
 	InterleavedAddColumn
 	IllegalName
 	InterleavedRenameColumn
+	MixedType
+	Counter
+	TypeOverridden
+	TypeOverrideRejected
+	ProfileNarrowed
+	ProfileNotNull
+	UnsignedBigint
+	SourceCheck
+	ComputedColumn
+	ComputedNotTranslated
+	Identity
+	DatetimeOffset
+	SampledType
+	SampledFloat
+	SourceCheckDropped
 )
 
 // NameAndCols contains the name of a table and its columns.
@@ -158,6 +205,17 @@ type Audit struct {
 	DryRun                   bool                                   `json:"-"` // Flag to identify if the migration is a dry run.
 	StreamingStats           streamingStats                         `json:"-"` // Stores information related to streaming migration process.
 	Progress                 Progress                               `json:"-"` // Stores information related to progress of the migration progress
+	ScanStats                map[string][]SegmentStats              `json:"-"` // Maps source table name to the stats of each of its parallel scan segments.
+	SkippedItems             map[string]map[string]int64            `json:"-"` // Maps the name of a table split by entity type to the number of its items that weren't migrated, by entity ("" for items matching no entity).
+}
+
+// SegmentStats describes the work done by one segment of a parallel scan.
+type SegmentStats struct {
+	Segment          int64   // Segment number, from 0 to TotalSegments - 1.
+	Items            int64   // Number of items read.
+	Pages            int64   // Number of pages read.
+	ConsumedCapacity float64 // Read capacity units consumed.
+	Resumed          bool    // True if the segment was resumed from a checkpoint.
 }
 
 // Stores information related to the streaming migration process.
@@ -168,6 +226,11 @@ type streamingStats struct {
 	DroppedRecords   map[string]map[string]int64 // Tablewise count of records successfully converted but failed to written on Spanner, broken down by record type.
 	SampleBadRecords []string                    // Records that generated errors during conversion.
 	SampleBadWrites  []string                    // Records that faced errors while writing to Cloud Spanner.
+	Batches          int64                       // Count of batches committed to Cloud Spanner.
+	CollapsedRecords int64                       // Count of records merged with a later change to the same key in their batch.
+	LastLag          time.Duration               // Time between the creation of the last committed record and its commit.
+	MaxLag           time.Duration               // Maximum lag observed.
+	Duration         time.Duration               // Duration of the processing of the streams.
 	DataStreamName   string
 	DataflowJobId    string
 }
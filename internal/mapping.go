@@ -174,6 +174,19 @@ func ToSpannerIndexName(conv *Conv, srcID string) string {
 	return getSpannerID(conv, srcID)
 }
 
+// ToSpannerCheckName maps the name of a check constraint to a legal Spanner
+// name that doesn't clash with other Spanner names. Like other constraint
+// names, check constraint names have to be unique across the database.
+func ToSpannerCheckName(conv *Conv, srcID string) string {
+	return getSpannerID(conv, srcID)
+}
+
+// ToSpannerSequenceName maps the name of a source sequence to a legal
+// Spanner name that doesn't clash with other Spanner names.
+func ToSpannerSequenceName(conv *Conv, srcID string) string {
+	return getSpannerID(conv, srcID)
+}
+
 // conv.UsedNames tracks Spanner names that have been used for table names, foreign key constraints
 // and indexes. We use this to ensure we generate unique names when
 // we map from source dbs to Spanner since Spanner requires all these names to be
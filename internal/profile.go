@@ -0,0 +1,121 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ColumnProfile holds statistics about the data of a source column,
+// collected by the optional data profiling pass.
+type ColumnProfile struct {
+	Rows  int64
+	Nulls int64
+	// MaxLength is the maximum length in characters of the values.
+	MaxLength int64
+	// Numeric is true if all non-NULL values are decimal numbers, in which
+	// case Min and Max are set, and MaxScale for profiles built with Add.
+	Numeric bool
+	// Integer is true if all non-NULL values are integers that fit in an
+	// INT64 (written without a fractional part, for profiles built with Add).
+	Integer bool
+	Min     string
+	Max     string
+	// MaxScale is the maximum number of significant digits after the
+	// decimal point.
+	MaxScale int64
+
+	min, max *big.Rat
+}
+
+// decimalRegexp matches decimal numbers. Unlike big.Rat.SetString, it
+// rejects fractions, exponents and base prefixes.
+var decimalRegexp = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)$`)
+
+// NewColumnProfile returns an empty ColumnProfile, ready to Add values to.
+func NewColumnProfile() *ColumnProfile {
+	return &ColumnProfile{Numeric: true, Integer: true}
+}
+
+// Add adds a value to the profile, where nil is NULL.
+func (p *ColumnProfile) Add(v *string) {
+	p.Rows++
+	if v == nil {
+		p.Nulls++
+		return
+	}
+	s := *v
+	if l := int64(utf8.RuneCountInString(s)); l > p.MaxLength {
+		p.MaxLength = l
+	}
+	if !p.Numeric {
+		return
+	}
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || !decimalRegexp.MatchString(s) {
+		p.Numeric, p.Integer = false, false
+		p.Min, p.Max, p.min, p.max, p.MaxScale = "", "", nil, nil, 0
+		return
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+		p.Integer = false
+	}
+	if sc := scale(s); sc > p.MaxScale {
+		p.MaxScale = sc
+	}
+	if p.min == nil || r.Cmp(p.min) < 0 {
+		p.min, p.Min = r, s
+	}
+	if p.max == nil || r.Cmp(p.max) > 0 {
+		p.max, p.Max = r, s
+	}
+}
+
+// Valid returns true if the profile has at least one non-NULL value, so
+// that the properties of its values can be relied on.
+func (p *ColumnProfile) Valid() bool {
+	return p != nil && p.Rows > p.Nulls
+}
+
+// String summarizes the profile for the report.
+func (p *ColumnProfile) String() string {
+	s := fmt.Sprintf("%d rows, %d nulls", p.Rows, p.Nulls)
+	if !p.Valid() {
+		return s
+	}
+	if p.Numeric {
+		return s + fmt.Sprintf(", min %s, max %s", p.Min, p.Max)
+	}
+	if p.MaxLength > 0 {
+		return s + fmt.Sprintf(", max length %d", p.MaxLength)
+	}
+	return s
+}
+
+// scale returns the number of significant digits after the decimal point
+// of a decimal number.
+func scale(s string) int64 {
+	i := strings.Index(s, ".")
+	if i == -1 {
+		return 0
+	}
+	return int64(len(strings.TrimRight(s[i+1:], "0")))
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func profileOf(vals ...interface{}) *ColumnProfile {
+	p := NewColumnProfile()
+	for _, v := range vals {
+		if v == nil {
+			p.Add(nil)
+			continue
+		}
+		s := v.(string)
+		p.Add(&s)
+	}
+	return p
+}
+
+func TestColumnProfile(t *testing.T) {
+	p := profileOf("12", nil, "-3", "9223372036854775807")
+	assert.Equal(t, int64(4), p.Rows)
+	assert.Equal(t, int64(1), p.Nulls)
+	assert.True(t, p.Valid())
+	assert.True(t, p.Numeric)
+	assert.True(t, p.Integer)
+	assert.Equal(t, "-3", p.Min)
+	assert.Equal(t, "9223372036854775807", p.Max)
+	assert.Equal(t, int64(19), p.MaxLength)
+	assert.Equal(t, "4 rows, 1 nulls, min -3, max 9223372036854775807", p.String())
+
+	// Too big for INT64.
+	p = profileOf("9223372036854775808")
+	assert.True(t, p.Numeric)
+	assert.False(t, p.Integer)
+
+	// Trailing zeros don't count towards the scale, but values with a
+	// fractional part aren't integers.
+	p = profileOf("1.50", "2.000", "10")
+	assert.True(t, p.Numeric)
+	assert.False(t, p.Integer)
+	assert.Equal(t, int64(1), p.MaxScale)
+	assert.Equal(t, "1.50", p.Min)
+	assert.Equal(t, "10", p.Max)
+
+	for _, s := range []string{"abc", "1e5", "1/2", "0x10", ""} {
+		p = profileOf("1", s)
+		assert.False(t, p.Numeric, s)
+		assert.False(t, p.Integer, s)
+		assert.Equal(t, "", p.Min, s)
+	}
+
+	p = profileOf("héllo", "hi")
+	assert.Equal(t, int64(5), p.MaxLength)
+	assert.Equal(t, "2 rows, 0 nulls, max length 5", p.String())
+
+	p = profileOf(nil, nil)
+	assert.False(t, p.Valid())
+	assert.Equal(t, "2 rows, 2 nulls", p.String())
+	var nilProfile *ColumnProfile
+	assert.False(t, nilProfile.Valid())
+}
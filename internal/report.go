@@ -20,6 +20,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/proto/migration"
@@ -69,6 +70,8 @@ func GenerateReport(driverName string, conv *Conv, w *bufio.Writer, badWrites ma
 		writeStmtStats(driverName, conv, w)
 	}
 	reportNameChanges(conv, w)
+	reportSequences(conv, w)
+	reportSynonyms(conv, w)
 
 	if printTableReports {
 		for _, t := range reports {
@@ -89,6 +92,10 @@ func GenerateReport(driverName string, conv *Conv, w *bufio.Writer, badWrites ma
 		}
 	}
 
+	if len(conv.Audit.ScanStats) > 0 {
+		writeScanReport(conv, w)
+	}
+
 	if printUnexpecteds {
 		writeUnexpectedConditions(driverName, conv, w)
 	}
@@ -113,8 +120,12 @@ func getStreamName(driver string) (string, error) {
 	switch driver {
 	case constants.DYNAMODB:
 		return "DynamoDB Streams", nil
+	case constants.MYSQL, constants.POSTGRES, constants.ORACLE:
+		// Only printed when the Datastream or Debezium change event files are
+		// applied by HarbourBridge.
+		return "change event files", nil
 	default:
-		return "", fmt.Errorf("streaming migration report only printed for DynamoDB currently")
+		return "", fmt.Errorf("streaming migration report only printed for DynamoDB, Datastream and Debezium currently")
 	}
 }
 
@@ -122,6 +133,8 @@ func getRecordTypes(driver string) []string {
 	switch driver {
 	case constants.DYNAMODB:
 		return []string{"INSERT", "REMOVE", "MODIFY"}
+	case constants.MYSQL, constants.POSTGRES, constants.ORACLE:
+		return []string{"INSERT", "UPDATE", "DELETE"}
 	default:
 		return []string{}
 	}
@@ -151,6 +164,15 @@ func writeStreamingMigrationReport(driverName string, conv *Conv, w *bufio.Write
 	totalWrittenRecords := totalReadRecords - sumNestedMapValues(stats.BadRecords) - sumNestedMapValues(stats.DroppedRecords)
 	w.WriteString(fmt.Sprintf("Count of records written to Cloud Spanner successfully: %s\n", strconv.FormatInt(totalWrittenRecords, 10)))
 
+	if stats.Batches > 0 {
+		w.WriteString(fmt.Sprintf("Count of batches committed to Cloud Spanner: %d (%.1f records per batch on average, %d records collapsed)\n",
+			stats.Batches, float64(totalReadRecords)/float64(stats.Batches), stats.CollapsedRecords))
+		if stats.Duration > 0 {
+			w.WriteString(fmt.Sprintf("Throughput: %.1f records/s over %s\n", float64(totalWrittenRecords)/stats.Duration.Seconds(), stats.Duration.Round(time.Second)))
+		}
+		w.WriteString(fmt.Sprintf("Replication lag: %s (last), %s (max)\n", stats.LastLag.Round(time.Millisecond), stats.MaxLag.Round(time.Millisecond)))
+	}
+
 	recordTypes := getRecordTypes(driverName)
 
 	w.WriteString(fmt.Sprintf("\nTablewise summary of processing of %s (Written records / Total records)\nbroken down by record type.\n\n", streamName))
@@ -276,7 +298,12 @@ func buildTableReport(conv *Conv, srcTable string, badWrites map[string]int64) t
 		} else {
 			tr.Body = buildTableReportBody(conv, srcTable, issues, spSchema, srcSchema, nil, nil)
 		}
-
+		if profiles, ok := conv.Profiles[srcTable]; ok {
+			tr.Body = append(tr.Body, buildProfileReportBody(profiles, srcSchema))
+		}
+		if len(srcSchema.Partitions) > 0 {
+			tr.Body = append(tr.Body, buildPartitionReportBody(srcSchema))
+		}
 	}
 	if !conv.SchemaMode() {
 		fillRowStats(conv, srcTable, badWrites, &tr)
@@ -452,6 +479,22 @@ func buildTableReportBody(conv *Conv, srcTable string, issues map[string][]Schem
 
 				case IllegalName:
 					l = append(l, fmt.Sprintf("%s, Column '%s' is mapped to '%s'", IssueDB[i].Brief, srcName, spName))
+				case TypeOverridden:
+					l = append(l, fmt.Sprintf("Column '%s': type %s is mapped to %s by type mapping rule '%s'", srcCol, srcType, spType, conv.TypeOverrides[srcTable][srcCol].Rule))
+				case TypeOverrideRejected:
+					o := conv.TypeOverrides[srcTable][srcCol]
+					l = append(l, fmt.Sprintf("Column '%s': type mapping rule '%s' can't map type %s to %s, so it is mapped to %s", srcCol, o.Rule, srcType, o.Type, spType))
+				case ProfileNarrowed:
+					l = append(l, fmt.Sprintf("Column '%s': type %s is mapped to %s based on its data (%s). %s", srcCol, srcType, spType, conv.Profiles[srcTable][srcCol], IssueDB[i].Brief))
+				case ProfileNotNull, SourceCheck, SourceCheckDropped, ComputedColumn, ComputedNotTranslated, DatetimeOffset:
+					l = append(l, fmt.Sprintf("Column '%s': %s", srcCol, IssueDB[i].Brief))
+				case SampledType, SampledFloat:
+					l = append(l, fmt.Sprintf("Column '%s': type %s is mapped to %s based on a sample of %d values. %s", srcCol, srcType, spType, srcSchema.ColDefs[srcCol].Sample.Rows, IssueDB[i].Brief))
+				case Identity:
+					id := srcSchema.ColDefs[srcCol].Identity
+					l = append(l, fmt.Sprintf("Column '%s' is an IDENTITY(%d, %d) column. %s", srcCol, id.Seed, id.Increment, IssueDB[i].Brief))
+				case MixedType:
+					l = append(l, fmt.Sprintf("Column '%s' is mapped to %s. %s (observed: %s)", srcCol, spType, IssueDB[i].Brief, formatObservedTypes(srcSchema.ColDefs[srcCol].ObservedTypes)))
 				default:
 					l = append(l, fmt.Sprintf("Column '%s': type %s is mapped to %s. %s", srcCol, srcType, spType, IssueDB[i].Brief))
 				}
@@ -470,6 +513,58 @@ func buildTableReportBody(conv *Conv, srcTable string, issues map[string][]Schem
 	return body
 }
 
+// buildProfileReportBody lists the statistics collected by data profiling
+// for the columns of a table, in column order.
+func buildProfileReportBody(profiles map[string]*ColumnProfile, srcSchema schema.Table) tableReportBody {
+	var l []string
+	for _, c := range srcSchema.ColNames {
+		if p, ok := profiles[c]; ok {
+			l = append(l, fmt.Sprintf("Column '%s': %s", c, p))
+		}
+	}
+	return tableReportBody{Heading: "Data Profile", Lines: l}
+}
+
+// buildPartitionReportBody describes the partitioning (or inheritance)
+// scheme of a table whose partitions were merged into a single Spanner
+// table, and lists the partitions.
+func buildPartitionReportBody(srcSchema schema.Table) tableReportBody {
+	var l []string
+	if srcSchema.PartitionScheme == "INHERITS" {
+		l = append(l, fmt.Sprintf("Table has %d child tables, which were merged into a single Spanner table. "+
+			"Columns only found in child tables are nullable, and uniqueness was not enforced across child tables in the source", len(srcSchema.Partitions)))
+		for _, p := range srcSchema.Partitions {
+			l = append(l, fmt.Sprintf("Child table '%s'", p.Name))
+		}
+	} else {
+		l = append(l, fmt.Sprintf("Table is partitioned by %s. Its %d partitions were merged into a single Spanner table", srcSchema.PartitionScheme, len(srcSchema.Partitions)))
+		for _, p := range srcSchema.Partitions {
+			l = append(l, fmt.Sprintf("Partition '%s': %s", p.Name, p.Bound))
+		}
+	}
+	return tableReportBody{Heading: "Partitioning", Lines: l}
+}
+
+// formatObservedTypes prints the distribution of types observed for a
+// column in decreasing order of frequency e.g. "string: 70, long: 30".
+func formatObservedTypes(observed map[string]int64) string {
+	var types []string
+	for t := range observed {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if observed[types[i]] != observed[types[j]] {
+			return observed[types[i]] > observed[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	var l []string
+	for _, t := range types {
+		l = append(l, fmt.Sprintf("%s: %d", t, observed[t]))
+	}
+	return strings.Join(l, ", ")
+}
+
 func getFkAndReferColumn(spSchema ddl.CreateTable, col string) (fkName string, referCol string) {
 	for _, fk := range spSchema.Fks {
 		for k, v := range fk.Columns {
@@ -582,6 +677,21 @@ var IssueDB = map[SchemaIssue]struct {
 	InterleavedAddColumn:    {Brief: "Candidate for Interleaved Table", severity: suggestion},
 	IllegalName:             {Brief: "Names must adhere to the spanner regular expression {a-z|A-Z}[{a-z|A-Z|0-9|_}+]", severity: warning},
 	InterleavedRenameColumn: {Brief: "Candidate for Interleaved Table", severity: suggestion},
+	MixedType:               {Brief: "Values of this column have different types across rows", severity: warning},
+	Counter:                 {Brief: "Spanner has no counter type, so increments must be done with read-write transactions", severity: warning},
+	TypeOverridden:          {Brief: "Type mapping overridden by the type mapping file", severity: note},
+	TypeOverrideRejected:    {Brief: "Type mapping override isn't one of the allowed alternatives for this type, so the default mapping is used", severity: warning},
+	ProfileNarrowed:         {Brief: "Type narrowed to fit the values found by data profiling. Values written later must also fit", severity: note},
+	ProfileNotNull:          {Brief: "Column made NOT NULL since data profiling found no NULL values. NULLs written later will be rejected", severity: note},
+	UnsignedBigint:          {Brief: "Spanner has no unsigned 64-bit integer type, so this type is mapped to numeric to fit values above 2^63-1", severity: note},
+	SourceCheck:             {Brief: "CHECK constraint carried over from the source type. Verify that Spanner supports its expression", severity: warning},
+	ComputedColumn:          {Brief: "Computed column is mapped to a generated column. Verify that its expression computes the same values in Spanner", severity: note},
+	ComputedNotTranslated:   {Brief: "Expression of computed column can't be translated for Spanner, so it is mapped to a regular column holding the computed values", severity: warning},
+	Identity:                {Brief: "Spanner doesn't generate values for identity columns. The seed and increment are kept to create a sequence", severity: warning},
+	DatetimeOffset:          {Brief: "Values are converted to UTC, and their time zone offsets are dropped unless the keepOffset param is set", severity: note},
+	SampledType:             {Brief: "The type is chosen from a sample of the column's values. Verify that all values fit in it", severity: note},
+	SampledFloat:            {Brief: "Some sampled values don't fit in NUMERIC, so FLOAT64 is used, which may lose precision", severity: warning},
+	SourceCheckDropped:      {Brief: "CHECK constraint of the source type is dropped, since its PostgreSQL expression can only be used with the PostgreSQL dialect", severity: warning},
 }
 
 type severity int
@@ -784,6 +894,98 @@ func reportNameChanges(conv *Conv, w *bufio.Writer) {
 
 }
 
+// reportSequences describes how the source sequences are mapped to Spanner.
+func reportSequences(conv *Conv, w *bufio.Writer) {
+	if len(conv.SrcSequences) == 0 {
+		return
+	}
+	writeHeading(w, "Sequences")
+	var names []string
+	for n := range conv.SrcSequences {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	i := 1
+	for _, n := range names {
+		seq := conv.SrcSequences[n]
+		sp, ok := conv.SpSequences[n]
+		if !ok {
+			justifyLines(w, fmt.Sprintf("%d) Sequence '%s' can't be mapped to a Spanner sequence.\n", i, n), 80, 3)
+			i++
+			continue
+		}
+		l := fmt.Sprintf("Sequence '%s' is mapped to Spanner sequence '%s'", n, sp.Name)
+		if sp.StartWithCounter > 0 {
+			l += fmt.Sprintf(", whose counter starts at %d", sp.StartWithCounter)
+		}
+		l += ". Spanner sequences generate bit-reversed positive values, which don't follow the order of the source values"
+		if seq.Increment != 1 {
+			l += fmt.Sprintf(". Its increment of %d isn't supported", seq.Increment)
+		}
+		if seq.Cycle {
+			l += ". Spanner sequences don't cycle"
+		}
+		justifyLines(w, fmt.Sprintf("%d) %s.\n", i, l), 80, 3)
+		i++
+	}
+	w.WriteString("\n")
+}
+
+// reportSynonyms lists the synonyms of the source database.
+func reportSynonyms(conv *Conv, w *bufio.Writer) {
+	if len(conv.Synonyms) == 0 {
+		return
+	}
+	writeHeading(w, "Synonyms")
+	justifyLines(w, "Spanner doesn't support synonyms. Applications that use "+
+		"the following synonyms must be updated to use the objects they refer to.", 80, 0)
+	w.WriteString("\n")
+	for i, s := range conv.Synonyms {
+		justifyLines(w, fmt.Sprintf("%d) Synonym '%s.%s' refers to '%s'.\n", i+1, s.Owner, s.Name, s.Target), 80, 3)
+	}
+	w.WriteString("\n")
+}
+
+// writeScanReport lists the items read and the capacity consumed by each
+// segment of the parallel scans used to read the source tables.
+func writeScanReport(conv *Conv, w *bufio.Writer) {
+	writeHeading(w, "Parallel Scan")
+	var tables []string
+	for t := range conv.Audit.ScanStats {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		segs := conv.Audit.ScanStats[t]
+		var total float64
+		for _, s := range segs {
+			total += s.ConsumedCapacity
+		}
+		fmt.Fprintf(w, "Table %s: %d segment(s), %.1f read capacity units consumed\n", t, len(segs), total)
+		for _, s := range segs {
+			l := fmt.Sprintf("Segment %d: %d items in %d pages, %.1f read capacity units", s.Segment, s.Items, s.Pages, s.ConsumedCapacity)
+			if s.Resumed {
+				l += " (resumed from checkpoint)"
+			}
+			fmt.Fprintf(w, "   %s\n", l)
+		}
+		var entities []string
+		for e := range conv.Audit.SkippedItems[t] {
+			entities = append(entities, e)
+		}
+		sort.Strings(entities)
+		for _, e := range entities {
+			n := conv.Audit.SkippedItems[t][e]
+			if e == "" {
+				fmt.Fprintf(w, "   %d items match no entity and were skipped\n", n)
+			} else {
+				fmt.Fprintf(w, "   %d items of entity %s were skipped: the entity has no items in the schema sample, so it has no table\n", n, e)
+			}
+		}
+	}
+	w.WriteString("\n")
+}
+
 func writeStmtStats(driverName string, conv *Conv, w *bufio.Writer) {
 	type stat struct {
 		statement string
@@ -0,0 +1,295 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"gopkg.in/yaml.v3"
+)
+
+// TypeMapping holds user-defined overrides of the source-to-Spanner type
+// mapping, read from a YAML or JSON file such as
+//
+//	overrides:
+//	  - source: decimal(p<=18,s=0)
+//	    type: INT64
+//	  - column: orders.*_id
+//	    type: STRING(36)
+//	  - "tinyint(1) -> BOOL"
+//
+// The first rule matching a column is used.
+type TypeMapping struct {
+	Rules []TypeMappingRule `yaml:"overrides"`
+}
+
+// TypeMappingRule overrides the Spanner type of the columns it matches. A
+// rule matches a column if both its source type pattern and its column
+// pattern match; an empty pattern matches all columns.
+type TypeMappingRule struct {
+	// Source is a source type, optionally followed by conditions on its
+	// modifiers e.g. decimal(p<=18,s=0). The i-th condition applies to the
+	// i-th modifier, and may be a number, '*', or a comparison with a number
+	// using <, <=, =, !=, >= or >, optionally preceded by a name for
+	// readability.
+	Source string `yaml:"source"`
+	// Column is a table.column pattern, using path.Match syntax.
+	Column string `yaml:"column"`
+	// Type is the Spanner type e.g. INT64 or STRING(50).
+	Type string `yaml:"type"`
+
+	typeName string
+	mods     []modCondition
+	spType   ddl.Type
+}
+
+// TypeOverride records the type mapping rule applied to a column, and the
+// Spanner type it asked for.
+type TypeOverride struct {
+	Rule string
+	Type string
+}
+
+type modCondition struct {
+	op  string // Empty for '*'.
+	val int64
+}
+
+var (
+	modConditionRegexp = regexp.MustCompile(`^([A-Za-z_]\w*)?\s*(<=|>=|!=|=|<|>)?\s*(-?\d+)$`)
+	spannerTypeRegexp  = regexp.MustCompile(`^([A-Za-z0-9]+)\s*(?:\(\s*(\w+)\s*\))?$`)
+)
+
+// LoadTypeMapping reads and validates the type mapping file at filePath.
+// JSON files are accepted since JSON is a subset of YAML.
+func LoadTypeMapping(filePath string) (*TypeMapping, error) {
+	b, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read type mapping file %s: %v", filePath, err)
+	}
+	tm, err := ParseTypeMapping(b)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse type mapping file %s: %v", filePath, err)
+	}
+	return tm, nil
+}
+
+// ParseTypeMapping parses and validates a type mapping.
+func ParseTypeMapping(b []byte) (*TypeMapping, error) {
+	tm := &TypeMapping{}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(tm); err != nil {
+		return nil, err
+	}
+	for i := range tm.Rules {
+		if err := tm.Rules[i].init(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %v", i+1, tm.Rules[i], err)
+		}
+	}
+	return tm, nil
+}
+
+// UnmarshalYAML accepts both rules given as maps and rules given in the
+// short form "<source type or table.column> -> <Spanner type>". In the
+// short form, patterns with a '.' outside parentheses are column patterns.
+func (r *TypeMappingRule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		parts := strings.Split(value.Value, "->")
+		if len(parts) != 2 {
+			return fmt.Errorf("line %d: expected '<pattern> -> <type>', got %q", value.Line, value.Value)
+		}
+		pattern := strings.TrimSpace(parts[0])
+		r.Type = strings.TrimSpace(parts[1])
+		if strings.Contains(strings.SplitN(pattern, "(", 2)[0], ".") {
+			r.Column = pattern
+		} else {
+			r.Source = pattern
+		}
+		return nil
+	}
+	type plain TypeMappingRule
+	return value.Decode((*plain)(r))
+}
+
+func (r *TypeMappingRule) init() error {
+	if r.Source == "" && r.Column == "" {
+		return fmt.Errorf("a rule needs a source type or a column pattern")
+	}
+	if r.Column != "" {
+		if _, err := path.Match(r.Column, ""); err != nil {
+			return fmt.Errorf("bad column pattern %q: %v", r.Column, err)
+		}
+		if !strings.Contains(r.Column, ".") {
+			return fmt.Errorf("column pattern %q must have the form table.column", r.Column)
+		}
+	}
+	if r.Source != "" {
+		name, mods, err := parseTypePattern(r.Source)
+		if err != nil {
+			return err
+		}
+		r.typeName, r.mods = name, mods
+	}
+	ty, err := parseSpannerType(r.Type)
+	if err != nil {
+		return err
+	}
+	r.spType = ty
+	return nil
+}
+
+// parseTypePattern parses a source type pattern e.g. decimal(p<=18,s=0).
+func parseTypePattern(s string) (string, []modCondition, error) {
+	idx := strings.Index(s, "(")
+	if idx == -1 {
+		return strings.TrimSpace(s), nil, nil
+	}
+	if !strings.HasSuffix(s, ")") {
+		return "", nil, fmt.Errorf("bad source type %q: missing ')'", s)
+	}
+	var mods []modCondition
+	for _, c := range strings.Split(s[idx+1:len(s)-1], ",") {
+		c = strings.TrimSpace(c)
+		if c == "*" {
+			mods = append(mods, modCondition{})
+			continue
+		}
+		m := modConditionRegexp.FindStringSubmatch(c)
+		if m == nil {
+			return "", nil, fmt.Errorf("bad condition %q in source type %q", c, s)
+		}
+		op := m[2]
+		if op == "" {
+			op = "="
+		}
+		val, err := strconv.ParseInt(m[3], 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("bad condition %q in source type %q: %v", c, s, err)
+		}
+		mods = append(mods, modCondition{op: op, val: val})
+	}
+	return strings.TrimSpace(s[:idx]), mods, nil
+}
+
+// parseSpannerType parses a scalar Spanner type e.g. INT64 or STRING(50).
+func parseSpannerType(s string) (ddl.Type, error) {
+	m := spannerTypeRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return ddl.Type{}, fmt.Errorf("bad Spanner type %q", s)
+	}
+	ty := ddl.Type{Name: strings.ToUpper(m[1])}
+	switch ty.Name {
+	case ddl.Bool, ddl.Date, ddl.Float64, ddl.Int64, ddl.JSON, ddl.Numeric, ddl.Timestamp:
+		if m[2] != "" {
+			return ddl.Type{}, fmt.Errorf("type %s doesn't take a length", ty.Name)
+		}
+	case ddl.String, ddl.Bytes:
+		switch {
+		case m[2] == "" || strings.ToUpper(m[2]) == "MAX":
+			ty.Len = ddl.MaxLength
+		default:
+			l, err := strconv.ParseInt(m[2], 10, 64)
+			if err != nil || l <= 0 {
+				return ddl.Type{}, fmt.Errorf("bad length %q for Spanner type %s", m[2], ty.Name)
+			}
+			ty.Len = l
+		}
+	default:
+		return ddl.Type{}, fmt.Errorf("unknown Spanner type %q", s)
+	}
+	return ty, nil
+}
+
+// Match returns the first rule matching column col of table, whose source
+// type is srcType, or nil if there is none. It can be called on a nil
+// TypeMapping.
+func (tm *TypeMapping) Match(table, col string, srcType schema.Type) *TypeMappingRule {
+	if tm == nil {
+		return nil
+	}
+	for i := range tm.Rules {
+		if tm.Rules[i].matches(table, col, srcType) {
+			return &tm.Rules[i]
+		}
+	}
+	return nil
+}
+
+func (r *TypeMappingRule) matches(table, col string, srcType schema.Type) bool {
+	if r.Column != "" {
+		if ok, _ := path.Match(r.Column, table+"."+col); !ok {
+			return false
+		}
+	}
+	if r.Source == "" {
+		return true
+	}
+	if !strings.EqualFold(r.typeName, srcType.Name) {
+		return false
+	}
+	if len(r.mods) > len(srcType.Mods) {
+		return false
+	}
+	for i, c := range r.mods {
+		if !c.matches(srcType.Mods[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c modCondition) matches(v int64) bool {
+	switch c.op {
+	case "<":
+		return v < c.val
+	case "<=":
+		return v <= c.val
+	case "=":
+		return v == c.val
+	case "!=":
+		return v != c.val
+	case ">=":
+		return v >= c.val
+	case ">":
+		return v > c.val
+	}
+	return true
+}
+
+// SpannerType returns the Spanner type the rule maps columns to.
+func (r *TypeMappingRule) SpannerType() ddl.Type {
+	return r.spType
+}
+
+// String returns the rule in its short form.
+func (r TypeMappingRule) String() string {
+	var lhs []string
+	if r.Column != "" {
+		lhs = append(lhs, r.Column)
+	}
+	if r.Source != "" {
+		lhs = append(lhs, r.Source)
+	}
+	return strings.Join(lhs, " ") + " -> " + r.Type
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTypeMapping(t *testing.T) {
+	tm, err := ParseTypeMapping([]byte(`
+overrides:
+  - column: orders.*_id
+    type: string(36)
+  - source: decimal(p<=18,s=0)
+    type: INT64
+  - "tinyint(1) -> BOOL"
+  - "orders.note -> STRING(MAX)"
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(tm.Rules))
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: 36}, tm.Rules[0].SpannerType())
+	assert.Equal(t, "tinyint(1)", tm.Rules[2].Source)
+	assert.Equal(t, "orders.note", tm.Rules[3].Column)
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, tm.Rules[3].SpannerType())
+	assert.Equal(t, "decimal(p<=18,s=0) -> INT64", tm.Rules[1].String())
+
+	// JSON is accepted too.
+	tm, err = ParseTypeMapping([]byte(`{"overrides": [{"source": "text", "type": "BYTES"}]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, tm.Rules[0].SpannerType())
+
+	for _, bad := range []string{
+		`overrides: [{type: INT64}]`,
+		`overrides: [{source: int, type: INT32}]`,
+		`overrides: [{source: int, type: INT64(10)}]`,
+		`overrides: [{source: "decimal(p~18)", type: INT64}]`,
+		`overrides: [{column: orders, type: INT64}]`,
+		`overrides: [{source: int, target: INT64}]`,
+		`overrides: ["int => INT64"]`,
+	} {
+		_, err := ParseTypeMapping([]byte(bad))
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestTypeMappingMatch(t *testing.T) {
+	tm, err := ParseTypeMapping([]byte(`
+overrides:
+  - "orders.*_id -> STRING(36)"
+  - "decimal(p<=18,s=0) -> INT64"
+  - "varchar(*) -> STRING(MAX)"
+  - "INT -> STRING"
+`))
+	assert.Nil(t, err)
+	tc := []struct {
+		table, col string
+		srcType    schema.Type
+		rule       int // -1 if no rule matches.
+	}{
+		{"orders", "customer_id", schema.Type{Name: "bigint"}, 0},
+		{"orders", "id", schema.Type{Name: "bigint"}, -1},
+		{"orders", "total", schema.Type{Name: "decimal", Mods: []int64{10, 0}}, 1},
+		{"orders", "total", schema.Type{Name: "DECIMAL", Mods: []int64{18, 0}}, 1},
+		{"orders", "total", schema.Type{Name: "decimal", Mods: []int64{19, 0}}, -1},
+		{"orders", "total", schema.Type{Name: "decimal", Mods: []int64{10, 2}}, -1},
+		{"orders", "total", schema.Type{Name: "decimal", Mods: []int64{10}}, -1},
+		{"orders", "total", schema.Type{Name: "decimal"}, -1},
+		{"orders", "name", schema.Type{Name: "varchar", Mods: []int64{20}}, 2},
+		{"orders", "name", schema.Type{Name: "varchar"}, -1},
+		{"orders", "qty", schema.Type{Name: "int"}, 3},
+	}
+	for _, c := range tc {
+		r := tm.Match(c.table, c.col, c.srcType)
+		if c.rule == -1 {
+			assert.Nil(t, r, c.table+"."+c.col)
+		} else {
+			assert.Equal(t, &tm.Rules[c.rule], r, c.table+"."+c.col)
+		}
+	}
+	var nilMapping *TypeMapping
+	assert.Nil(t, nilMapping.Match("orders", "id", schema.Type{Name: "int"}))
+}
@@ -140,6 +140,9 @@ func GetSchemaSampleSize(sourceProfile SourceProfile) int64 {
 			}
 		}
 	}
+	if sourceProfile.Ty == SourceProfileTypeCsv && sourceProfile.Csv.SchemaSampleSize != 0 {
+		schemaSampleSize = sourceProfile.Csv.SchemaSampleSize
+	}
 	return schemaSampleSize
 }
 
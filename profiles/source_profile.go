@@ -19,9 +19,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"cloud.google.com/go/civil"
 	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
 )
 
 type SourceProfileType int
@@ -63,6 +66,7 @@ const (
 	SourceProfileConnectionTypeDynamoDB
 	SourceProfileConnectionTypeSqlServer
 	SourceProfileConnectionTypeOracle
+	SourceProfileConnectionTypeSpanner
 )
 
 type SourceProfileConnectionMySQL struct {
@@ -195,11 +199,14 @@ func NewSourceProfileConnectionPostgreSQL(params map[string]string) (SourceProfi
 }
 
 type SourceProfileConnectionSqlServer struct {
-	Host string
-	Port string
-	User string
-	Db   string
-	Pwd  string
+	Host           string
+	Port           string
+	User           string
+	Db             string
+	Pwd            string
+	Watermark      string // Watermark columns of the tables, for incremental syncs (see common.ParseWatermarkColumns)
+	WatermarkState string // File where the watermarks of the last incremental sync are saved; enables incremental syncs
+	DetectDeletes  bool   // If true, incremental syncs delete the rows no longer in the source tables
 }
 
 func NewSourceProfileConnectionSqlServer(params map[string]string) (SourceProfileConnectionSqlServer, error) {
@@ -257,6 +264,18 @@ func NewSourceProfileConnectionSqlServer(params map[string]string) (SourceProfil
 		ss.Pwd = utils.GetPassword()
 	}
 
+	ss.Watermark = params["watermark"]
+	ss.WatermarkState = params["watermarkState"]
+	if detectDeletes, ok := params["detectDeletes"]; ok {
+		b, err := strconv.ParseBool(detectDeletes)
+		if err != nil {
+			return ss, fmt.Errorf("detectDeletes must be true or false, got %q", detectDeletes)
+		}
+		ss.DetectDeletes = b
+	}
+	if (ss.Watermark != "" || ss.DetectDeletes) && ss.WatermarkState == "" {
+		return ss, fmt.Errorf("watermark and detectDeletes require watermarkState")
+	}
 	return ss, nil
 }
 
@@ -269,6 +288,13 @@ type SourceProfileConnectionDynamoDB struct {
 	AwsRegion          string // Same as AWS_REGION environment variable
 	DydbEndpoint       string // Same as DYNAMODB_ENDPOINT_OVERRIDE environment variable
 	SchemaSampleSize   int64  // Number of rows to use for inferring schema (default 100,000)
+	ScanSegments       int64  // Number of parallel scan segments used for the snapshot load (default 1)
+	ScanCheckpoint     string // File where the progress of each scan segment is saved, so an interrupted load can resume
+	SplitRules         string // JSON file of rules that split single-table-design tables into one table per entity type
+	StreamCheckpoint   string // File where the progress of each stream shard is saved during a streaming migration
+	StreamResume       bool   // If true, a streaming migration resumes from StreamCheckpoint, without a new snapshot load
+	StopFile           string // A streaming migration stops once a file exists at this path
+	StatusFile         string // File where the cutover status of a streaming migration is written, as JSON
 	enableStreaming    string // Used for confirming streaming migration (valid options: `yes`,`no`,`true`,`false`)
 }
 
@@ -281,6 +307,31 @@ func NewSourceProfileConnectionDynamoDB(params map[string]string) (SourceProfile
 		}
 		dydb.SchemaSampleSize = int64(schemaSampleSizeInt)
 	}
+	if scanSegments, ok := params["scan-segments"]; ok {
+		scanSegmentsInt, err := strconv.Atoi(scanSegments)
+		if err != nil || scanSegmentsInt < 1 {
+			return dydb, fmt.Errorf("could not parse scan-segments = %v as a valid positive int64", scanSegments)
+		}
+		dydb.ScanSegments = int64(scanSegmentsInt)
+	}
+	dydb.ScanCheckpoint = params["scan-checkpoint"]
+	dydb.SplitRules = params["split-rules"]
+	dydb.StreamCheckpoint = params["stream-checkpoint"]
+	if streamResume, ok := params["stream-resume"]; ok {
+		switch streamResume {
+		case "yes", "true":
+			dydb.StreamResume = true
+		case "no", "false":
+			dydb.StreamResume = false
+		default:
+			return dydb, fmt.Errorf("please specify a valid choice for stream-resume: available choices(yes, no, true, false)")
+		}
+		if dydb.StreamResume && dydb.StreamCheckpoint == "" {
+			return dydb, fmt.Errorf("stream-resume requires stream-checkpoint")
+		}
+	}
+	dydb.StopFile = params["stop-file"]
+	dydb.StatusFile = params["status-file"]
 	// For DynamoDB, the preferred way to provide connection params is through env variables.
 	// Unlike postgres and mysql, there may not be deprecation of env variables, hence it
 	// is better to override env variables optionally via source profile params.
@@ -356,6 +407,49 @@ func NewSourceProfileConnectionOracle(params map[string]string) (SourceProfileCo
 	return ss, nil
 }
 
+// SourceProfileConnectionSpanner is a Spanner database used as the source of
+// a migration.
+type SourceProfileConnectionSpanner struct {
+	Project  string // Same as GCLOUD_PROJECT environment variable
+	Instance string
+	Dbname   string
+	// ReadTimestamp is the timestamp at which the source database is read.
+	// If it is zero, the time of the first read is used.
+	ReadTimestamp time.Time
+}
+
+func NewSourceProfileConnectionSpanner(params map[string]string) (SourceProfileConnectionSpanner, error) {
+	sp := SourceProfileConnectionSpanner{}
+	instance, instanceOk := params["instance"]
+	dbName, dbOk := params["dbName"]
+	if !instanceOk || !dbOk || instance == "" || dbName == "" {
+		return sp, fmt.Errorf("please specify instance and dbName in the source-profile")
+	}
+	sp.Instance, sp.Dbname = instance, dbName
+	if project, ok := params["project"]; ok && project != "" {
+		sp.Project = project
+	} else {
+		project, err := utils.GetProject()
+		if err != nil {
+			return sp, fmt.Errorf("can't get project: %v", err)
+		}
+		sp.Project = project
+	}
+	if readTimestamp, ok := params["readTimestamp"]; ok {
+		t, err := time.Parse(time.RFC3339Nano, readTimestamp)
+		if err != nil {
+			return sp, fmt.Errorf("could not parse readTimestamp = %v as an RFC 3339 timestamp", readTimestamp)
+		}
+		sp.ReadTimestamp = t
+	}
+	return sp, nil
+}
+
+// DbURI returns the URI of the source database.
+func (sp SourceProfileConnectionSpanner) DbURI() string {
+	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", sp.Project, sp.Instance, sp.Dbname)
+}
+
 type SourceProfileConnection struct {
 	Ty        SourceProfileConnectionType
 	Streaming bool
@@ -364,6 +458,7 @@ type SourceProfileConnection struct {
 	Dydb      SourceProfileConnectionDynamoDB
 	SqlServer SourceProfileConnectionSqlServer
 	Oracle    SourceProfileConnectionOracle
+	Spanner   SourceProfileConnectionSpanner
 }
 
 func NewSourceProfileConnection(source string, params map[string]string) (SourceProfileConnection, error) {
@@ -423,6 +518,14 @@ func NewSourceProfileConnection(source string, params map[string]string) (Source
 				conn.Streaming = true
 			}
 		}
+	case "spanner":
+		{
+			conn.Ty = SourceProfileConnectionTypeSpanner
+			conn.Spanner, err = NewSourceProfileConnectionSpanner(params)
+			if err != nil {
+				return conn, err
+			}
+		}
 	default:
 		return conn, fmt.Errorf("please specify a valid source database using -source flag, received source = %v", source)
 	}
@@ -438,12 +541,13 @@ func NewSourceProfileConfig(path string) SourceProfileConfig {
 }
 
 type SourceProfileCsv struct {
-	Manifest  string
-	Delimiter string
-	NullStr   string
+	Manifest         string
+	Delimiter        string
+	NullStr          string
+	SchemaSampleSize int64 // Number of rows sampled per table when inferring the schema.
 }
 
-func NewSourceProfileCsv(params map[string]string) SourceProfileCsv {
+func NewSourceProfileCsv(params map[string]string) (SourceProfileCsv, error) {
 	csvProfile := SourceProfileCsv{}
 	csvProfile.Manifest = params["manifest"]
 	csvProfile.Delimiter = ","
@@ -454,7 +558,14 @@ func NewSourceProfileCsv(params map[string]string) SourceProfileCsv {
 	if nullStr, ok := params["nullStr"]; ok {
 		csvProfile.NullStr = nullStr
 	}
-	return csvProfile
+	if schemaSampleSize, ok := params["schema-sample-size"]; ok {
+		schemaSampleSizeInt, err := strconv.Atoi(schemaSampleSize)
+		if err != nil {
+			return csvProfile, fmt.Errorf("could not parse schema-sample-size = %v as a valid int64", schemaSampleSize)
+		}
+		csvProfile.SchemaSampleSize = int64(schemaSampleSizeInt)
+	}
+	return csvProfile, nil
 }
 
 type SourceProfile struct {
@@ -464,10 +575,34 @@ type SourceProfile struct {
 	Conn   SourceProfileConnection
 	Config SourceProfileConfig
 	Csv    SourceProfileCsv
+	// TypeMapping holds user-defined overrides of the mapping of source
+	// types to Spanner types (if any), set with the -type-mapping flag.
+	TypeMapping *internal.TypeMapping
+	// ProfileData enables profiling of the source data to choose narrower
+	// Spanner types, set with the -profile-data flag.
+	ProfileData bool
+	// CheckResources checks, in the dry run of a streaming migration, that
+	// the resources referenced by the streaming config exist, set with the
+	// -check-resources flag.
+	CheckResources bool
+	// ZeroDates is the policy for MySQL zero dates, set with the zeroDates
+	// param (see constants.ZeroDatesReject).
+	ZeroDates string
+	// KeepOffset keeps the time zone offsets of SQL Server datetimeoffset
+	// values in separate columns, set with the keepOffset param.
+	KeepOffset bool
+}
+
+// IncrementalSync returns true if the data is synced incrementally, using
+// watermark columns: each run only reads the rows that changed since the
+// previous one, and upserts them.
+func (src SourceProfile) IncrementalSync() bool {
+	return src.Conn.SqlServer.WatermarkState != ""
 }
 
-// UseTargetSchema returns true if the driver expects an existing schema
-// to use in the target database.
+// UseTargetSchema returns true if the driver can load data into the existing
+// schema of the target database instead of a schema from a session file,
+// which is the case for CSV.
 func (src SourceProfile) UseTargetSchema() bool {
 	return (src.Driver == constants.CSV)
 }
@@ -487,6 +622,10 @@ func (src SourceProfile) ToLegacyDriver(source string) (string, error) {
 				return constants.PGDUMP, nil
 			case "dynamodb":
 				return "", fmt.Errorf("dump files are not supported with DynamoDB")
+			case "mongodb", "mongo", "jsonl":
+				return constants.MONGODB, nil
+			case "cassandra":
+				return constants.CASSANDRA, nil
 			default:
 				return "", fmt.Errorf("please specify a valid source database using -source flag, received source = %v", source)
 			}
@@ -505,6 +644,8 @@ func (src SourceProfile) ToLegacyDriver(source string) (string, error) {
 				return constants.SQLSERVER, nil
 			case "oracle":
 				return constants.ORACLE, nil
+			case "spanner":
+				return constants.SPANNER, nil
 			default:
 				return "", fmt.Errorf("please specify a valid source database using -source flag, received source = %v", source)
 			}
@@ -542,13 +683,22 @@ func NewSourceProfile(s string, source string) (SourceProfile, error) {
 	if err != nil {
 		return SourceProfile{}, fmt.Errorf("could not parse source-profile, error = %v", err)
 	}
+	zeroDates, err := parseZeroDates(source, params)
+	if err != nil {
+		return SourceProfile{}, err
+	}
+	keepOffset, err := parseKeepOffset(source, params)
+	if err != nil {
+		return SourceProfile{}, err
+	}
 	if strings.ToLower(source) == constants.CSV {
-		return SourceProfile{Ty: SourceProfileTypeCsv, Csv: NewSourceProfileCsv(params)}, nil
+		csv, err := NewSourceProfileCsv(params)
+		return SourceProfile{Ty: SourceProfileTypeCsv, Csv: csv}, err
 	}
 
 	if _, ok := params["file"]; ok || filePipedToStdin() {
 		profile := NewSourceProfileFile(params)
-		return SourceProfile{Ty: SourceProfileTypeFile, File: profile}, nil
+		return SourceProfile{Ty: SourceProfileTypeFile, File: profile, ZeroDates: zeroDates}, nil
 	} else if format, ok := params["format"]; ok {
 		// File is not passed in from stdin or specified using "file" flag.
 		return SourceProfile{Ty: SourceProfileTypeFile}, fmt.Errorf("file not specified, but format set to %v", format)
@@ -560,8 +710,46 @@ func NewSourceProfile(s string, source string) (SourceProfile, error) {
 		// connection parameters could be specified as part of environment
 		// variables.
 		conn, err := NewSourceProfileConnection(source, params)
-		return SourceProfile{Ty: SourceProfileTypeConnection, Conn: conn}, err
+		return SourceProfile{Ty: SourceProfileTypeConnection, Conn: conn, ZeroDates: zeroDates, KeepOffset: keepOffset}, err
+	}
+}
+
+// parseZeroDates validates the zeroDates param, which is only supported for
+// MySQL (for both mysqldump files and direct connections).
+func parseZeroDates(source string, params map[string]string) (string, error) {
+	zeroDates, ok := params["zeroDates"]
+	if !ok {
+		return "", nil
+	}
+	if strings.ToLower(source) != constants.MYSQL {
+		return "", fmt.Errorf("zeroDates is only supported for MySQL")
+	}
+	switch strings.ToLower(zeroDates) {
+	case constants.ZeroDatesReject, constants.ZeroDatesNull:
+		return strings.ToLower(zeroDates), nil
+	}
+	d, err := civil.ParseDate(zeroDates)
+	if err != nil || d.Year < 1 {
+		return "", fmt.Errorf("zeroDates must be %q, %q or a date in YYYY-MM-DD format, got %q", constants.ZeroDatesReject, constants.ZeroDatesNull, zeroDates)
+	}
+	return zeroDates, nil
+}
+
+// parseKeepOffset validates the keepOffset param, which is only supported
+// for SQL Server.
+func parseKeepOffset(source string, params map[string]string) (bool, error) {
+	keepOffset, ok := params["keepOffset"]
+	if !ok {
+		return false, nil
+	}
+	if s := strings.ToLower(source); s != constants.SQLSERVER && s != "mssql" {
+		return false, fmt.Errorf("keepOffset is only supported for SQL Server")
+	}
+	b, err := strconv.ParseBool(keepOffset)
+	if err != nil {
+		return false, fmt.Errorf("keepOffset must be true or false, got %q", keepOffset)
 	}
+	return b, nil
 }
 
 var filePipedToStdin = func() bool {
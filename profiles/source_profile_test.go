@@ -17,6 +17,7 @@ package profiles
 import (
 	"testing"
 
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -142,6 +143,31 @@ func TestNewSourceProfileConnectionDynamoDB(t *testing.T) {
 			params:        map[string]string{"schema-sample-size": "a"},
 			errorExpected: true,
 		},
+		{
+			name:          "valid scan segments and checkpoint",
+			params:        map[string]string{"scan-segments": "8", "scan-checkpoint": "/tmp/scan.json"},
+			errorExpected: false,
+		},
+		{
+			name:          "invalid scan segments",
+			params:        map[string]string{"scan-segments": "0"},
+			errorExpected: true,
+		},
+		{
+			name:          "resume from stream checkpoint",
+			params:        map[string]string{"stream-checkpoint": "/tmp/stream.json", "stream-resume": "yes"},
+			errorExpected: false,
+		},
+		{
+			name:          "resume without stream checkpoint",
+			params:        map[string]string{"stream-resume": "yes"},
+			errorExpected: true,
+		},
+		{
+			name:          "stop and status files",
+			params:        map[string]string{"stop-file": "/tmp/stop", "status-file": "/tmp/status.json"},
+			errorExpected: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -149,3 +175,148 @@ func TestNewSourceProfileConnectionDynamoDB(t *testing.T) {
 		assert.Equal(t, tc.errorExpected, err != nil)
 	}
 }
+
+func TestNewSourceProfileConnectionSpanner(t *testing.T) {
+	// Project is always specified to avoid calling gcloud in the unit tests.
+	testCases := []struct {
+		name          string
+		params        map[string]string
+		errorExpected bool
+	}{
+		{
+			name:          "no params",
+			params:        map[string]string{},
+			errorExpected: true,
+		},
+		{
+			name:          "no dbName",
+			params:        map[string]string{"project": "p", "instance": "i"},
+			errorExpected: true,
+		},
+		{
+			name:          "all params",
+			params:        map[string]string{"project": "p", "instance": "i", "dbName": "d"},
+			errorExpected: false,
+		},
+		{
+			name:          "valid read timestamp",
+			params:        map[string]string{"project": "p", "instance": "i", "dbName": "d", "readTimestamp": "2022-10-01T10:00:00Z"},
+			errorExpected: false,
+		},
+		{
+			name:          "invalid read timestamp",
+			params:        map[string]string{"project": "p", "instance": "i", "dbName": "d", "readTimestamp": "yesterday"},
+			errorExpected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewSourceProfileConnectionSpanner(tc.params)
+		assert.Equal(t, tc.errorExpected, err != nil, tc.name)
+	}
+	sp, _ := NewSourceProfileConnectionSpanner(map[string]string{"project": "p", "instance": "i", "dbName": "d"})
+	assert.Equal(t, "projects/p/instances/i/databases/d", sp.DbURI())
+}
+
+func TestParseZeroDates(t *testing.T) {
+	testCases := []struct {
+		name          string
+		source        string
+		params        map[string]string
+		want          string
+		errorExpected bool
+	}{
+		{name: "not set", source: "mysql", params: map[string]string{}, want: ""},
+		{name: "reject", source: "mysql", params: map[string]string{"zeroDates": "reject"}, want: "reject"},
+		{name: "null", source: "MySQL", params: map[string]string{"zeroDates": "NULL"}, want: "null"},
+		{name: "sentinel", source: "mysql", params: map[string]string{"zeroDates": "1970-01-01"}, want: "1970-01-01"},
+		{name: "year zero sentinel", source: "mysql", params: map[string]string{"zeroDates": "0000-01-01"}, errorExpected: true},
+		{name: "invalid", source: "mysql", params: map[string]string{"zeroDates": "drop"}, errorExpected: true},
+		{name: "not mysql", source: "postgres", params: map[string]string{"zeroDates": "null"}, errorExpected: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseZeroDates(tc.source, tc.params)
+		assert.Equal(t, tc.errorExpected, err != nil, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+func TestToLegacyDriverFile(t *testing.T) {
+	src := SourceProfile{Ty: SourceProfileTypeFile}
+	for source, want := range map[string]string{
+		"mysql":     constants.MYSQLDUMP,
+		"pg":        constants.PGDUMP,
+		"mongodb":   constants.MONGODB,
+		"jsonl":     constants.MONGODB,
+		"cassandra": constants.CASSANDRA,
+	} {
+		driver, err := src.ToLegacyDriver(source)
+		assert.Nil(t, err, source)
+		assert.Equal(t, want, driver, source)
+	}
+	_, err := src.ToLegacyDriver("dynamodb")
+	assert.NotNil(t, err)
+}
+
+func TestParseKeepOffset(t *testing.T) {
+	testCases := []struct {
+		name          string
+		source        string
+		params        map[string]string
+		want          bool
+		errorExpected bool
+	}{
+		{name: "not set", source: "sqlserver", params: map[string]string{}, want: false},
+		{name: "true", source: "sqlserver", params: map[string]string{"keepOffset": "true"}, want: true},
+		{name: "false", source: "mssql", params: map[string]string{"keepOffset": "false"}, want: false},
+		{name: "invalid", source: "sqlserver", params: map[string]string{"keepOffset": "yes"}, errorExpected: true},
+		{name: "not sqlserver", source: "mysql", params: map[string]string{"keepOffset": "true"}, errorExpected: true},
+	}
+	for _, tc := range testCases {
+		got, err := parseKeepOffset(tc.source, tc.params)
+		assert.Equal(t, tc.errorExpected, err != nil, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+func TestNewSourceProfileConnectionSqlServer_Incremental(t *testing.T) {
+	conn := map[string]string{"host": "a", "user": "b", "dbName": "c", "password": "e"}
+	testCases := []struct {
+		name          string
+		params        map[string]string
+		want          SourceProfileConnectionSqlServer
+		errorExpected bool
+	}{
+		{
+			name:   "watermarks",
+			params: map[string]string{"watermark": "updated_at;dbo.orders:modified", "watermarkState": "/tmp/wm.json", "detectDeletes": "true"},
+			want:   SourceProfileConnectionSqlServer{Watermark: "updated_at;dbo.orders:modified", WatermarkState: "/tmp/wm.json", DetectDeletes: true},
+		},
+		{
+			name:          "no state file",
+			params:        map[string]string{"watermark": "updated_at"},
+			errorExpected: true,
+		},
+		{
+			name:          "invalid detectDeletes",
+			params:        map[string]string{"watermarkState": "/tmp/wm.json", "detectDeletes": "yes"},
+			errorExpected: true,
+		},
+	}
+	for _, tc := range testCases {
+		params := make(map[string]string)
+		for k, v := range conn {
+			params[k] = v
+		}
+		for k, v := range tc.params {
+			params[k] = v
+		}
+		ss, err := NewSourceProfileConnectionSqlServer(params)
+		assert.Equal(t, tc.errorExpected, err != nil, tc.name)
+		if !tc.errorExpected {
+			assert.Equal(t, tc.want.Watermark, ss.Watermark, tc.name)
+			assert.Equal(t, tc.want.WatermarkState, ss.WatermarkState, tc.name)
+			assert.Equal(t, tc.want.DetectDeletes, ss.DetectDeletes, tc.name)
+		}
+	}
+}
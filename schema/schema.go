@@ -41,6 +41,21 @@ type Table struct {
 	ForeignKeys []ForeignKey
 	Indexes     []Index
 	Id          string
+	// PartitionScheme describes how rows are distributed among Partitions,
+	// e.g. "RANGE (logdate)", or is "INHERITS" for child tables.
+	PartitionScheme string `json:",omitempty"`
+	// Partitions lists the partitions (or child tables) whose schema and
+	// data are merged into this table.
+	Partitions []Partition `json:",omitempty"`
+}
+
+// Partition represents a partition or child table merged into its root
+// table.
+type Partition struct {
+	Name     string   // Source table name of the partition.
+	Schema   string   // Schema of the partition.
+	ColNames []string // Columns of the partition, in its own order.
+	Bound    string   // Partition bound e.g. "FOR VALUES IN (1, 2)". Empty for child tables.
 }
 
 // Column represents a database column.
@@ -51,6 +66,70 @@ type Column struct {
 	NotNull bool
 	Ignored Ignored
 	Id      string
+	// ObservedTypes is only populated for sources whose schema is inferred
+	// from sampled data, and only when values of more than one type were
+	// seen for this column. It maps each observed type to its count and is
+	// kept for reporting purposes.
+	ObservedTypes map[string]int64 `json:",omitempty"`
+	// EnumValues lists the values allowed in columns of enum types, which
+	// are enforced in Spanner with a CHECK constraint.
+	EnumValues []string `json:",omitempty"`
+	// Fields lists the attribute names of columns of composite types,
+	// whose values are converted to JSON objects.
+	Fields []string `json:",omitempty"`
+	// Checks lists CHECK constraint expressions that only involve this
+	// column, such as those of a PostgreSQL domain.
+	Checks []string `json:",omitempty"`
+	// Generated is the expression of a computed column, in the syntax of
+	// the source database.
+	Generated string `json:",omitempty"`
+	// Identity holds the seed and increment of identity columns, so that a
+	// sequence can be created for them.
+	Identity *Identity `json:",omitempty"`
+	// Sample summarizes a sample of the values of columns whose type
+	// doesn't tell enough to choose a Spanner type, such as Oracle's
+	// unconstrained NUMBER.
+	Sample *Sample `json:",omitempty"`
+}
+
+// Identity represents the value generation of an identity column.
+type Identity struct {
+	Seed      int64
+	Increment int64
+}
+
+// Sample summarizes the non-NULL values found in a sample of a column.
+type Sample struct {
+	Rows int64
+	// Integer is true if all values are integers that fit in an INT64.
+	Integer bool
+	// Numeric is true if all values fit in a Spanner NUMERIC, i.e. have at
+	// most 29 digits before and 9 digits after the decimal point.
+	Numeric bool
+	// Time is true if some date values have a time of day other than
+	// midnight.
+	Time bool
+}
+
+// Sequence represents a sequence of the source database.
+type Sequence struct {
+	Name      string
+	Schema    string
+	Increment int64
+	// MinValue and MaxValue are kept as strings since they may not fit in
+	// an int64 (e.g. in Oracle).
+	MinValue string
+	MaxValue string
+	Cycle    bool
+	// Next is a lower bound of the next value of the sequence.
+	Next string
+}
+
+// Synonym represents an alias of a table or another database object.
+type Synonym struct {
+	Name   string
+	Owner  string
+	Target string // The aliased object, as owner.name.
 }
 
 // ForeignKey represents a foreign key.
@@ -103,6 +182,20 @@ type Type struct {
 	ArrayBounds []int64 // Empty for scalar types.
 }
 
+// UserType represents a user-defined type, such as a PostgreSQL enum,
+// domain, composite or range type.
+type UserType struct {
+	Name string
+	Kind string // One of "enum", "domain", "composite" or "range".
+	// Base, NotNull and Checks describe domains. Checks refer to the value
+	// being checked as VALUE.
+	Base       Type
+	NotNull    bool
+	Checks     []string
+	EnumValues []string
+	Fields     []string // Attribute names of composite types.
+}
+
 // Ignored represents column properties/constraints that are not
 // represented. We drop the details, but retain presence/absence for
 // reporting purposes.
@@ -0,0 +1,451 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandra
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// keyspace holds the definitions parsed from DESCRIBE KEYSPACE output.
+type keyspace struct {
+	tables    map[string]*cqlTable
+	userTypes map[string]bool
+}
+
+// cqlTable is a CQL table definition.
+type cqlTable struct {
+	name     string
+	colNames []string
+	colTypes map[string]string // Column types, normalized by parseType.
+	// The primary key is made of the partition key columns followed by the
+	// clustering columns.
+	partitionKeys  []string
+	clusteringKeys []string
+	desc           map[string]bool // Clustering columns in DESC order.
+	indexes        []schema.Index
+	// skippedIndexes describes secondary indexes that can't be migrated.
+	skippedIndexes []string
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokQuotedIdent
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// is returns true if t is the unquoted keyword or punctuation s.
+func (t token) is(s string) bool {
+	return (t.kind == tokIdent || t.kind == tokPunct) && strings.EqualFold(t.text, s)
+}
+
+// name returns the identifier named by t. Unquoted identifiers are case
+// insensitive in CQL and are stored in lower case.
+func (t token) name() string {
+	if t.kind == tokIdent {
+		return strings.ToLower(t.text)
+	}
+	return t.text
+}
+
+// tokenize splits CQL text into tokens, dropping whitespace and comments.
+// Numbers are split at '.', which is fine since we don't need their values.
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		c := r[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(r) && r[i+1] == '-', c == '/' && i+1 < len(r) && r[i+1] == '/':
+			for i < len(r) && r[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(r) && r[i+1] == '*':
+			end := indexRunes(r, i+2, "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i = end + 2
+		case c == '$' && i+1 < len(r) && r[i+1] == '$':
+			// Function bodies are quoted with $$.
+			end := indexRunes(r, i+2, "$$")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated $$ string")
+			}
+			toks = append(toks, token{kind: tokString, text: string(r[i+2 : end])})
+			i = end + 2
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(r); j++ {
+				if r[j] == c {
+					// Quotes are escaped by doubling them.
+					if j+1 < len(r) && r[j+1] == c {
+						sb.WriteRune(c)
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteRune(r[j])
+			}
+			if j >= len(r) {
+				return nil, fmt.Errorf("unterminated quoted string")
+			}
+			kind := tokString
+			if c == '"' {
+				kind = tokQuotedIdent
+			}
+			toks = append(toks, token{kind: kind, text: sb.String()})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			j := i
+			for j < len(r) && (r[j] == '_' || unicode.IsLetter(r[j]) || unicode.IsDigit(r[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(r[i:j])})
+			i = j
+		default:
+			toks = append(toks, token{kind: tokPunct, text: string(c)})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+// indexRunes returns the index of the first occurrence of sep in r at or
+// after from, or -1.
+func indexRunes(r []rune, from int, sep string) int {
+	s := []rune(sep)
+	for i := from; i+len(s) <= len(r); i++ {
+		if string(r[i:i+len(s)]) == sep {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseKeyspace parses the output of cqlsh DESCRIBE KEYSPACE. Only CREATE
+// TABLE, CREATE TYPE and CREATE INDEX statements are used; other
+// statements (e.g. keyspace options, materialized views and functions) are
+// ignored.
+func parseKeyspace(s string) (keyspace, error) {
+	ks := keyspace{tables: make(map[string]*cqlTable), userTypes: make(map[string]bool)}
+	toks, err := tokenize(s)
+	if err != nil {
+		return ks, err
+	}
+	for _, stmt := range splitStatements(toks) {
+		if len(stmt) < 2 || !stmt[0].is("CREATE") {
+			continue
+		}
+		switch {
+		case stmt[1].is("TABLE"):
+			t, err := parseCreateTable(stmt[2:])
+			if err != nil {
+				return ks, err
+			}
+			if _, ok := ks.tables[t.name]; ok {
+				return ks, fmt.Errorf("table %s is defined more than once", t.name)
+			}
+			ks.tables[t.name] = t
+		case stmt[1].is("TYPE"):
+			rest := skipIfNotExists(stmt[2:])
+			if name, _, ok := parseQualifiedName(rest); ok {
+				ks.userTypes[name] = true
+			}
+		case stmt[1].is("INDEX"), stmt[1].is("CUSTOM") && len(stmt) > 2 && stmt[2].is("INDEX"):
+			if stmt[1].is("CUSTOM") {
+				stmt = stmt[1:]
+			}
+			if err := parseCreateIndex(ks, stmt[2:]); err != nil {
+				return ks, err
+			}
+		}
+	}
+	return ks, nil
+}
+
+func splitStatements(toks []token) [][]token {
+	var stmts [][]token
+	start := 0
+	for i, t := range toks {
+		if t.is(";") {
+			stmts = append(stmts, toks[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(toks) {
+		stmts = append(stmts, toks[start:])
+	}
+	return stmts
+}
+
+func skipIfNotExists(toks []token) []token {
+	if len(toks) >= 3 && toks[0].is("IF") && toks[1].is("NOT") && toks[2].is("EXISTS") {
+		return toks[3:]
+	}
+	return toks
+}
+
+// parseQualifiedName parses an optionally keyspace qualified name, and
+// returns the name without the keyspace and the remaining tokens.
+func parseQualifiedName(toks []token) (string, []token, bool) {
+	if len(toks) == 0 || (toks[0].kind != tokIdent && toks[0].kind != tokQuotedIdent) {
+		return "", toks, false
+	}
+	if len(toks) >= 3 && toks[1].is(".") {
+		return toks[2].name(), toks[3:], true
+	}
+	return toks[0].name(), toks[1:], true
+}
+
+// parseCreateTable parses a CREATE TABLE statement, starting after the
+// TABLE keyword.
+func parseCreateTable(toks []token) (*cqlTable, error) {
+	name, toks, ok := parseQualifiedName(skipIfNotExists(toks))
+	if !ok || len(toks) == 0 || !toks[0].is("(") {
+		return nil, fmt.Errorf("can't parse CREATE TABLE statement")
+	}
+	t := &cqlTable{name: name, colTypes: make(map[string]string), desc: make(map[string]bool)}
+	end := matchingParen(toks, 0)
+	if end < 0 {
+		return nil, fmt.Errorf("can't parse CREATE TABLE statement for table %s: missing ')'", name)
+	}
+	for _, def := range splitTopLevel(toks[1:end]) {
+		if len(def) == 0 {
+			continue
+		}
+		if len(def) >= 2 && def[0].is("PRIMARY") && def[1].is("KEY") {
+			if err := t.parsePrimaryKey(def[2:]); err != nil {
+				return nil, fmt.Errorf("can't parse primary key of table %s: %v", name, err)
+			}
+			continue
+		}
+		col := def[0].name()
+		typ := def[1:]
+		// Strip column options.
+		if n := len(typ); n >= 2 && typ[n-2].is("PRIMARY") && typ[n-1].is("KEY") {
+			typ = typ[:n-2]
+			t.partitionKeys = []string{col}
+		}
+		if n := len(typ); n >= 1 && typ[n-1].is("STATIC") {
+			typ = typ[:n-1]
+		}
+		if len(typ) == 0 {
+			return nil, fmt.Errorf("column %s of table %s has no type", col, name)
+		}
+		t.colNames = append(t.colNames, col)
+		t.colTypes[col] = parseType(typ)
+	}
+	if len(t.partitionKeys) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key", name)
+	}
+	// Parse WITH CLUSTERING ORDER BY (c1 DESC, c2 ASC).
+	rest := toks[end+1:]
+	for i := 0; i+3 < len(rest); i++ {
+		if rest[i].is("CLUSTERING") && rest[i+1].is("ORDER") && rest[i+2].is("BY") && rest[i+3].is("(") {
+			close := matchingParen(rest, i+3)
+			if close < 0 {
+				return nil, fmt.Errorf("can't parse clustering order of table %s", name)
+			}
+			for _, o := range splitTopLevel(rest[i+4 : close]) {
+				if len(o) == 2 && o[1].is("DESC") {
+					t.desc[o[0].name()] = true
+				}
+			}
+			break
+		}
+	}
+	return t, nil
+}
+
+// parsePrimaryKey parses the column list of a PRIMARY KEY definition
+// e.g. ((a, b), c, d).
+func (t *cqlTable) parsePrimaryKey(toks []token) error {
+	if len(toks) < 2 || !toks[0].is("(") || matchingParen(toks, 0) != len(toks)-1 {
+		return fmt.Errorf("expected a column list")
+	}
+	for i, part := range splitTopLevel(toks[1 : len(toks)-1]) {
+		var cols []string
+		if len(part) > 0 && part[0].is("(") {
+			// Composite partition key.
+			for _, c := range splitTopLevel(part[1 : len(part)-1]) {
+				if len(c) != 1 {
+					return fmt.Errorf("unexpected partition key column")
+				}
+				cols = append(cols, c[0].name())
+			}
+		} else if len(part) == 1 {
+			cols = []string{part[0].name()}
+		} else {
+			return fmt.Errorf("unexpected key column")
+		}
+		if i == 0 {
+			t.partitionKeys = cols
+		} else {
+			t.clusteringKeys = append(t.clusteringKeys, cols...)
+		}
+	}
+	return nil
+}
+
+// parseCreateIndex parses a CREATE INDEX statement, starting after the
+// INDEX keyword, and adds the index to its table. Indexes on the keys,
+// values or entries of collections can't be represented in Spanner and are
+// recorded as skipped.
+func parseCreateIndex(ks keyspace, toks []token) error {
+	toks = skipIfNotExists(toks)
+	var name string
+	if len(toks) > 0 && !toks[0].is("ON") {
+		name = toks[0].name()
+		toks = toks[1:]
+	}
+	if len(toks) == 0 || !toks[0].is("ON") {
+		return fmt.Errorf("can't parse CREATE INDEX statement")
+	}
+	table, toks, ok := parseQualifiedName(toks[1:])
+	if !ok || len(toks) == 0 || !toks[0].is("(") {
+		return fmt.Errorf("can't parse CREATE INDEX statement")
+	}
+	t, ok := ks.tables[table]
+	if !ok {
+		return fmt.Errorf("index %s is on unknown table %s", name, table)
+	}
+	end := matchingParen(toks, 0)
+	if end < 0 {
+		return fmt.Errorf("can't parse CREATE INDEX statement for table %s", table)
+	}
+	target := toks[1:end]
+	if len(target) != 1 {
+		t.skippedIndexes = append(t.skippedIndexes, fmt.Sprintf("index %s on %s", name, renderTokens(target)))
+		return nil
+	}
+	col := target[0].name()
+	if name == "" {
+		// Cassandra's default index name.
+		name = fmt.Sprintf("%s_%s_idx", table, col)
+	}
+	t.indexes = append(t.indexes, schema.Index{Name: name, Keys: []schema.Key{{Column: col}}})
+	return nil
+}
+
+// parseType returns the normalized form of a CQL type e.g.
+// "map<text, frozen<list<int>>>". Type names are in lower case, except
+// quoted user defined type names.
+func parseType(toks []token) string {
+	var sb strings.Builder
+	for _, t := range toks {
+		switch {
+		case t.is(","):
+			sb.WriteString(", ")
+		case t.kind == tokString:
+			// Custom types are given as a quoted Java class name.
+			sb.WriteString("'" + t.text + "'")
+		default:
+			sb.WriteString(t.name())
+		}
+	}
+	return sb.String()
+}
+
+func renderTokens(toks []token) string {
+	var l []string
+	for _, t := range toks {
+		l = append(l, t.text)
+	}
+	return strings.Join(l, "")
+}
+
+// matchingParen returns the index of the ')' matching the '(' at toks[i],
+// or -1.
+func matchingParen(toks []token, i int) int {
+	depth := 0
+	for j := i; j < len(toks); j++ {
+		switch {
+		case toks[j].is("("):
+			depth++
+		case toks[j].is(")"):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel splits toks on commas that aren't nested in (), <> or {}.
+func splitTopLevel(toks []token) [][]token {
+	var parts [][]token
+	depth, start := 0, 0
+	for i, t := range toks {
+		switch {
+		case t.is("("), t.is("<"), t.is("{"):
+			depth++
+		case t.is(")"), t.is(">"), t.is("}"):
+			depth--
+		case t.is(",") && depth == 0:
+			parts = append(parts, toks[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, toks[start:])
+}
+
+// splitType splits a normalized type into its name and type arguments e.g.
+// "map<text, list<int>>" into "map" and ["text", "list<int>"]. frozen<...>
+// is unwrapped since it doesn't affect the type of the values.
+func splitType(typ string) (string, []string) {
+	i := strings.Index(typ, "<")
+	if i < 0 || !strings.HasSuffix(typ, ">") {
+		return typ, nil
+	}
+	name := typ[:i]
+	inner := typ[i+1 : len(typ)-1]
+	var args []string
+	depth, start := 0, 0
+	for j, c := range inner {
+		switch c {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(inner[start:j]))
+				start = j + 1
+			}
+		}
+	}
+	args = append(args, strings.TrimSpace(inner[start:]))
+	if name == "frozen" && len(args) == 1 {
+		return splitType(args[0])
+	}
+	return name, args
+}
@@ -0,0 +1,273 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandra
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Timestamp layouts used by cqlsh COPY TO. The default datetimeformat is
+// %Y-%m-%d %H:%M:%S.%f%z, and older versions print milliseconds or no
+// fractional seconds.
+var timestampLayouts = []string{"2006-01-02 15:04:05.999999999-0700", time.RFC3339Nano}
+
+// normalizeValue rewrites a value exported by COPY TO for a column of CQL
+// type srcType into the format the CSV source expects for a column of
+// Spanner type spType.
+func normalizeValue(srcType string, spType ddl.Type, val string) (string, error) {
+	name, args := splitType(srcType)
+	switch {
+	case spType.Name == ddl.JSON:
+		l, err := parseLiteral(val)
+		if err != nil {
+			return "", fmt.Errorf("can't convert %q to JSON: %v", val, err)
+		}
+		b, err := json.Marshal(l.toJSON(name == "list" || name == "set" || name == "tuple"))
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case spType.IsArray:
+		elemType := ""
+		if len(args) > 0 {
+			elemType = args[0]
+		}
+		l, err := parseLiteral(val)
+		if err != nil {
+			return "", fmt.Errorf("can't convert %q to an array: %v", val, err)
+		}
+		if l.kind != litList && !(l.kind == litMap && len(l.elems) == 0) {
+			return "", fmt.Errorf("can't convert %q to an array", val)
+		}
+		// The CSV source splits array elements on commas, so elements are
+		// double quoted.
+		var elems []string
+		for _, e := range l.elems {
+			if e.kind == litBare && e.text == "null" {
+				elems = append(elems, "NULL")
+				continue
+			}
+			s, err := normalizeScalar(elemType, e.text)
+			if err != nil {
+				return "", err
+			}
+			elems = append(elems, strconv.Quote(s))
+		}
+		return "[" + strings.Join(elems, ",") + "]", nil
+	default:
+		return normalizeScalar(srcType, val)
+	}
+}
+
+// normalizeScalar rewrites blobs and timestamps, which COPY TO prints as
+// 0x-prefixed hex and with a +0000 style zone offset.
+func normalizeScalar(srcType, val string) (string, error) {
+	name, _ := splitType(srcType)
+	switch name {
+	case "blob":
+		b, err := hex.DecodeString(strings.TrimPrefix(val, "0x"))
+		if err != nil {
+			return "", fmt.Errorf("can't convert %q to bytes: %v", val, err)
+		}
+		return string(b), nil
+	case "timestamp":
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t.UTC().Format(time.RFC3339Nano), nil
+			}
+		}
+		return "", fmt.Errorf("can't convert to timestamp: %s", val)
+	}
+	return val, nil
+}
+
+type literalKind int
+
+const (
+	litString literalKind = iota // Quoted string.
+	litBare                      // Number, boolean, uuid, blob or null.
+	litList                      // List, set or tuple.
+	litMap                       // Map or user defined type value.
+)
+
+// literal is a CQL value as printed by COPY TO e.g. ['a', 'b'] or
+// {'k': 1, 'l': 2}.
+type literal struct {
+	kind  literalKind
+	text  string
+	elems []literal // Elements of lists, and values of maps.
+	keys  []literal // Keys of maps.
+}
+
+// parseLiteral parses a CQL value.
+func parseLiteral(s string) (literal, error) {
+	p := &literalParser{s: s}
+	l, err := p.parse()
+	if err != nil {
+		return l, err
+	}
+	p.skipSpaces()
+	if p.i != len(p.s) {
+		return l, fmt.Errorf("unexpected %q at position %d", p.s[p.i:], p.i)
+	}
+	return l, nil
+}
+
+type literalParser struct {
+	s string
+	i int
+}
+
+func (p *literalParser) skipSpaces() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t' || p.s[p.i] == '\n' || p.s[p.i] == '\r') {
+		p.i++
+	}
+}
+
+func (p *literalParser) parse() (literal, error) {
+	p.skipSpaces()
+	if p.i >= len(p.s) {
+		return literal{}, fmt.Errorf("unexpected end of value")
+	}
+	switch c := p.s[p.i]; c {
+	case '\'':
+		var sb strings.Builder
+		for p.i++; p.i < len(p.s); p.i++ {
+			if p.s[p.i] == '\'' {
+				// Quotes are escaped by doubling them.
+				if p.i+1 < len(p.s) && p.s[p.i+1] == '\'' {
+					sb.WriteByte('\'')
+					p.i++
+					continue
+				}
+				p.i++
+				return literal{kind: litString, text: sb.String()}, nil
+			}
+			sb.WriteByte(p.s[p.i])
+		}
+		return literal{}, fmt.Errorf("unterminated string")
+	case '[', '(', '{':
+		return p.parseCollection(c)
+	default:
+		start := p.i
+		for p.i < len(p.s) && !strings.ContainsRune(",:]}) \t\r\n", rune(p.s[p.i])) {
+			p.i++
+		}
+		if p.i == start {
+			return literal{}, fmt.Errorf("unexpected %q at position %d", p.s[p.i:], p.i)
+		}
+		return literal{kind: litBare, text: p.s[start:p.i]}, nil
+	}
+}
+
+// parseCollection parses lists [...], tuples (...), and sets, maps and user
+// defined type values {...}. A {...} value is a map if its first element is
+// followed by ':'.
+func (p *literalParser) parseCollection(open byte) (literal, error) {
+	close := map[byte]byte{'[': ']', '(': ')', '{': '}'}[open]
+	l := literal{kind: litList}
+	if open == '{' {
+		l.kind = litMap
+	}
+	p.i++
+	for first := true; ; first = false {
+		p.skipSpaces()
+		if p.i < len(p.s) && p.s[p.i] == close {
+			p.i++
+			return l, nil
+		}
+		if !first {
+			if p.i >= len(p.s) || p.s[p.i] != ',' {
+				return l, fmt.Errorf("expected ',' at position %d", p.i)
+			}
+			p.i++
+		}
+		e, err := p.parse()
+		if err != nil {
+			return l, err
+		}
+		p.skipSpaces()
+		isPair := p.i < len(p.s) && p.s[p.i] == ':'
+		if first && open == '{' && !isPair {
+			// A set.
+			l.kind = litList
+		}
+		if l.kind == litMap {
+			if !isPair {
+				return l, fmt.Errorf("expected ':' at position %d", p.i)
+			}
+			p.i++
+			v, err := p.parse()
+			if err != nil {
+				return l, err
+			}
+			l.keys = append(l.keys, e)
+			l.elems = append(l.elems, v)
+		} else {
+			l.elems = append(l.elems, e)
+		}
+	}
+}
+
+// toJSON returns the value of l for encoding as JSON. Numbers and booleans
+// are kept as such; other bare values (e.g. uuids) become strings. Map keys
+// are always strings in JSON. isList is used for empty collections, which
+// are printed as {} for both sets and maps.
+func (l literal) toJSON(isList bool) interface{} {
+	switch l.kind {
+	case litString:
+		return l.text
+	case litBare:
+		switch strings.ToLower(l.text) {
+		case "null":
+			return nil
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		if _, err := strconv.ParseFloat(l.text, 64); err == nil && !strings.ContainsAny(l.text, "xXnN") {
+			return json.Number(l.text)
+		}
+		return l.text
+	case litList:
+		a := []interface{}{}
+		for _, e := range l.elems {
+			a = append(a, e.toJSON(false))
+		}
+		return a
+	default:
+		if len(l.elems) == 0 && isList {
+			return []interface{}{}
+		}
+		m := make(map[string]interface{})
+		for i, k := range l.keys {
+			key := k.text
+			if k.kind == litList || k.kind == litMap {
+				b, _ := json.Marshal(k.toJSON(false))
+				key = string(b)
+			}
+			m[key] = l.elems[i].toJSON(false)
+		}
+		return m
+	}
+}
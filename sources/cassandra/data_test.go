@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandra
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+type spannerData struct {
+	table string
+	cols  []string
+	vals  []interface{}
+}
+
+func TestNormalizeValue(t *testing.T) {
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	tc := []struct {
+		srcType string
+		spType  ddl.Type
+		in      string
+		out     string
+	}{
+		{"text", str, "it's", "it's"},
+		{"blob", ddl.Type{Name: ddl.Bytes}, "0x0102ff", "\x01\x02\xff"},
+		{"timestamp", ddl.Type{Name: ddl.Timestamp}, "2020-05-06 07:08:09.123000+0200", "2020-05-06T05:08:09.123Z"},
+		{"timestamp", ddl.Type{Name: ddl.Timestamp}, "2020-05-06 07:08:09+0000", "2020-05-06T07:08:09Z"},
+		{"list<text>", ddl.Type{Name: ddl.String, IsArray: true}, "['a, b', 'it''s']", `["a, b","it's"]`},
+		{"set<int>", ddl.Type{Name: ddl.Int64, IsArray: true}, "{1, 2}", `["1","2"]`},
+		{"set<int>", ddl.Type{Name: ddl.Int64, IsArray: true}, "{}", `[]`},
+		{"list<timestamp>", ddl.Type{Name: ddl.Timestamp, IsArray: true}, "['2020-01-01 00:00:00.000000+0000']", `["2020-01-01T00:00:00Z"]`},
+		{"map<text, double>", ddl.Type{Name: ddl.JSON}, "{'x': 1.5, 'y': -2}", `{"x":1.5,"y":-2}`},
+		{"frozen<address>", ddl.Type{Name: ddl.JSON}, "{street: '1 Main St', city: null}", `{"city":null,"street":"1 Main St"}`},
+		{"tuple<int, uuid, boolean>", ddl.Type{Name: ddl.JSON}, "(1, 5b6962dd-3f90-4c93-8f61-eabfa4a803e2, True)", `[1,"5b6962dd-3f90-4c93-8f61-eabfa4a803e2",true]`},
+		{"list<frozen<list<int>>>", ddl.Type{Name: ddl.JSON}, "[[1, 2], []]", `[[1,2],[]]`},
+		{"set<frozen<map<text, int>>>", ddl.Type{Name: ddl.JSON}, "{}", `[]`},
+	}
+	for _, c := range tc {
+		out, err := normalizeValue(c.srcType, c.spType, c.in)
+		assert.Nil(t, err, c.in)
+		assert.Equal(t, c.out, out, c.in)
+	}
+
+	errCases := []struct {
+		srcType string
+		spType  ddl.Type
+		in      string
+	}{
+		{"blob", ddl.Type{Name: ddl.Bytes}, "0xzz"},
+		{"timestamp", ddl.Type{Name: ddl.Timestamp}, "yesterday"},
+		{"list<text>", ddl.Type{Name: ddl.String, IsArray: true}, "['a'"},
+		{"map<text, int>", ddl.Type{Name: ddl.JSON}, "{'a': 1, 'b'}"},
+	}
+	for _, c := range errCases {
+		_, err := normalizeValue(c.srcType, c.spType, c.in)
+		assert.NotNil(t, err, c.in)
+	}
+}
+
+func TestProcessData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shop.cql")
+	assert.Nil(t, os.WriteFile(path, []byte(`
+CREATE TABLE shop.events (
+    id int,
+    at timestamp,
+    day date,
+    tags set<text>,
+    payload blob,
+    PRIMARY KEY (id, at)
+) WITH CLUSTERING ORDER BY (at DESC);`), 0644))
+	// COPY TO writes columns in schema order, without a header by default.
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, "events.csv"), []byte(strings.Join([]string{
+		`1,2020-01-01 10:00:00.000000+0000,2020-01-01,"{'a', 'b,c'}",0x0a0b`,
+		`2,2020-01-02 10:00:00.000000+0000,,,`,
+		`3,not a timestamp,,,`,
+	}, "\n")), 0644))
+	isi, err := NewInfoSchemaImpl(path)
+	assert.Nil(t, err)
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	common.SetRowStats(conv, isi)
+	assert.Equal(t, int64(3), conv.Stats.Rows["events"])
+	common.ProcessData(conv, isi)
+	assert.Equal(t, []spannerData{
+		{table: "events", cols: []string{"id", "at", "day", "tags", "payload"}, vals: []interface{}{
+			int64(1), time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC), civil.Date{Year: 2020, Month: 1, Day: 1},
+			[]spanner.NullString{{StringVal: "a", Valid: true}, {StringVal: "b,c", Valid: true}}, []byte{0x0a, 0x0b}}},
+		{table: "events", cols: []string{"id", "at"}, vals: []interface{}{int64(2), time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)}},
+	}, rows)
+	assert.Equal(t, int64(1), conv.BadRows())
+}
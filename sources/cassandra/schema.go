@@ -0,0 +1,161 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandra
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/csv"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// InfoSchemaImpl reads a Cassandra keyspace. The schema is parsed from the
+// output of cqlsh DESCRIBE KEYSPACE, and the data of each table is read
+// from a CSV file exported with cqlsh COPY TO, named <table>.csv and placed
+// in the same directory as the schema file.
+type InfoSchemaImpl struct {
+	Keyspace keyspace
+	DataDir  string
+}
+
+// NewInfoSchemaImpl parses the keyspace schema in the file at path.
+func NewInfoSchemaImpl(path string) (InfoSchemaImpl, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return InfoSchemaImpl{}, fmt.Errorf("can't read schema file %s: %v", path, err)
+	}
+	ks, err := parseKeyspace(string(b))
+	if err != nil {
+		return InfoSchemaImpl{}, fmt.Errorf("can't parse schema file %s: %v", path, err)
+	}
+	if len(ks.tables) == 0 {
+		return InfoSchemaImpl{}, fmt.Errorf("no CREATE TABLE statements found in schema file %s", path)
+	}
+	return InfoSchemaImpl{Keyspace: ks, DataDir: filepath.Dir(path)}, nil
+}
+
+func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
+	return ToDdlImpl{UserTypes: isi.Keyspace.userTypes}
+}
+
+func (isi InfoSchemaImpl) GetTableName(schema string, tableName string) string {
+	return tableName
+}
+
+func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
+	var tables []common.SchemaAndName
+	for name := range isi.Keyspace.tables {
+		tables = append(tables, common.SchemaAndName{Name: name})
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables, nil
+}
+
+// GetColumns returns the columns of a table. Cassandra columns are all
+// nullable except those of the primary key.
+func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	t := isi.Keyspace.tables[table.Name]
+	colDefs := make(map[string]schema.Column)
+	for _, col := range t.colNames {
+		colDefs[col] = schema.Column{Name: col, Type: schema.Type{Name: t.colTypes[col]}, NotNull: contains(primaryKeys, col)}
+	}
+	return colDefs, t.colNames, nil
+}
+
+func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string) (interface{}, error) {
+	return nil, fmt.Errorf("can't get rows for table %s: COPY TO exports are only read by ProcessData", srcTable)
+}
+
+func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
+	return csv.CountRows(isi.manifestTable(table.Name), ',', isi.Keyspace.tables[table.Name].colNames)
+}
+
+// GetConstraints returns the primary key of a table: its partition key
+// columns followed by its clustering columns.
+func (isi InfoSchemaImpl) GetConstraints(conv *internal.Conv, table common.SchemaAndName) (primaryKeys []string, constraints map[string][]string, err error) {
+	t := isi.Keyspace.tables[table.Name]
+	primaryKeys = append(primaryKeys, t.partitionKeys...)
+	primaryKeys = append(primaryKeys, t.clusteringKeys...)
+	return primaryKeys, constraints, nil
+}
+
+// GetDescKeys returns the clustering columns in DESC clustering order.
+func (isi InfoSchemaImpl) GetDescKeys(table common.SchemaAndName) map[string]bool {
+	return isi.Keyspace.tables[table.Name].desc
+}
+
+// GetForeignKeys returns no foreign keys: Cassandra doesn't have them.
+func (isi InfoSchemaImpl) GetForeignKeys(conv *internal.Conv, table common.SchemaAndName) (foreignKeys []schema.ForeignKey, err error) {
+	return foreignKeys, err
+}
+
+func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAndName) ([]schema.Index, error) {
+	t := isi.Keyspace.tables[table.Name]
+	for _, s := range t.skippedIndexes {
+		conv.Unexpected(fmt.Sprintf("Table %s: skipped %s: indexes on collections aren't supported", table.Name, s))
+	}
+	return t.indexes, nil
+}
+
+// ProcessData performs data conversion for a Cassandra table, reading the
+// table's COPY TO export with the CSV source. Values whose COPY TO format
+// differs from the format expected for CSV files (e.g. blobs, timestamps and
+// collections) are normalized first.
+func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	table := isi.manifestTable(srcTable)
+	if _, err := os.Stat(table.File_patterns[0]); err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		return nil
+	}
+	spTypes := make(map[string]ddl.Type)
+	for i, srcCol := range srcSchema.ColNames {
+		spTypes[srcCol] = spSchema.ColDefs[spCols[i]].T
+	}
+	normalize := func(srcCol, val string) (string, error) {
+		return normalizeValue(srcSchema.ColDefs[srcCol].Type.Name, spTypes[srcCol], val)
+	}
+	return csv.ProcessTable(conv, table, "", ',', normalize)
+}
+
+func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("streaming migration is not supported for Cassandra")
+}
+
+func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamInfo map[string]interface{}) error {
+	return fmt.Errorf("streaming migration is not supported for Cassandra")
+}
+
+// manifestTable returns the COPY TO export of a table.
+func (isi InfoSchemaImpl) manifestTable(table string) utils.ManifestTable {
+	return utils.ManifestTable{Table_name: table, File_patterns: []string{filepath.Join(isi.DataDir, table+".csv")}}
+}
+
+func contains(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cassandra
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+const testKeyspace = `
+CREATE KEYSPACE shop WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '1'}  AND durable_writes = true;
+
+CREATE TYPE shop.address (
+    street text,
+    city text
+);
+
+-- Orders are clustered by time, newest first.
+CREATE TABLE shop.orders (
+    tenant text,
+    day date,
+    placed_at timestamp,
+    "OrderId" timeuuid,
+    items list<text>,
+    tags set<int>,
+    attrs map<text, double>,
+    ship_to frozen<address>,
+    history list<frozen<list<int>>>,
+    note text static,
+    PRIMARY KEY ((tenant, day), placed_at, "OrderId")
+) WITH CLUSTERING ORDER BY (placed_at DESC, "OrderId" ASC)
+    AND bloom_filter_fp_chance = 0.01
+    AND caching = {'keys': 'ALL', 'rows_per_partition': 'NONE'}
+    AND comment = 'it''s a comment; with a semicolon';
+
+CREATE INDEX orders_note_idx ON shop.orders (note);
+CREATE INDEX ON shop.orders (keys(attrs));
+
+CREATE TABLE shop.page_views (
+    page text PRIMARY KEY,
+    views counter
+) WITH comment = '';
+
+/* Functions have bodies with semicolons. */
+CREATE FUNCTION shop.f(x int) RETURNS NULL ON NULL INPUT RETURNS int LANGUAGE java AS $$ return x; $$;
+`
+
+func TestParseKeyspace(t *testing.T) {
+	ks, err := parseKeyspace(testKeyspace)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"address": true}, ks.userTypes)
+	assert.Equal(t, 2, len(ks.tables))
+
+	orders := ks.tables["orders"]
+	assert.Equal(t, []string{"tenant", "day", "placed_at", "OrderId", "items", "tags", "attrs", "ship_to", "history", "note"}, orders.colNames)
+	assert.Equal(t, map[string]string{
+		"tenant":    "text",
+		"day":       "date",
+		"placed_at": "timestamp",
+		"OrderId":   "timeuuid",
+		"items":     "list<text>",
+		"tags":      "set<int>",
+		"attrs":     "map<text, double>",
+		"ship_to":   "frozen<address>",
+		"history":   "list<frozen<list<int>>>",
+		"note":      "text",
+	}, orders.colTypes)
+	assert.Equal(t, []string{"tenant", "day"}, orders.partitionKeys)
+	assert.Equal(t, []string{"placed_at", "OrderId"}, orders.clusteringKeys)
+	assert.Equal(t, map[string]bool{"placed_at": true}, orders.desc)
+	assert.Equal(t, []schema.Index{{Name: "orders_note_idx", Keys: []schema.Key{{Column: "note"}}}}, orders.indexes)
+	assert.Equal(t, 1, len(orders.skippedIndexes))
+
+	views := ks.tables["page_views"]
+	assert.Equal(t, []string{"page"}, views.partitionKeys)
+	assert.Equal(t, map[string]string{"page": "text", "views": "counter"}, views.colTypes)
+
+	_, err = parseKeyspace("CREATE TABLE t (a int, b int);")
+	assert.NotNil(t, err)
+	_, err = parseKeyspace("CREATE TABLE t (a int PRIMARY KEY, b text")
+	assert.NotNil(t, err)
+}
+
+func TestSplitType(t *testing.T) {
+	name, args := splitType("map<text, frozen<list<int>>>")
+	assert.Equal(t, "map", name)
+	assert.Equal(t, []string{"text", "frozen<list<int>>"}, args)
+	name, args = splitType("frozen<set<uuid>>")
+	assert.Equal(t, "set", name)
+	assert.Equal(t, []string{"uuid"}, args)
+	name, args = splitType("bigint")
+	assert.Equal(t, "bigint", name)
+	assert.Nil(t, args)
+}
+
+func TestToSpannerType(t *testing.T) {
+	tdi := ToDdlImpl{UserTypes: map[string]bool{"address": true}}
+	conv := internal.MakeConv()
+	tc := []struct {
+		srcType string
+		spType  ddl.Type
+		issues  []internal.SchemaIssue
+	}{
+		{"text", ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil},
+		{"int", ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Widened}},
+		{"bigint", ddl.Type{Name: ddl.Int64}, nil},
+		{"counter", ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Counter}},
+		{"varint", ddl.Type{Name: ddl.Numeric}, []internal.SchemaIssue{internal.Numeric}},
+		{"decimal", ddl.Type{Name: ddl.Numeric}, []internal.SchemaIssue{internal.Decimal}},
+		{"double", ddl.Type{Name: ddl.Float64}, nil},
+		{"boolean", ddl.Type{Name: ddl.Bool}, nil},
+		{"blob", ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, nil},
+		{"timestamp", ddl.Type{Name: ddl.Timestamp}, nil},
+		{"uuid", ddl.Type{Name: ddl.String, Len: 36}, nil},
+		{"time", ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Time}},
+		{"list<text>", ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}, nil},
+		{"frozen<set<bigint>>", ddl.Type{Name: ddl.Int64, IsArray: true}, nil},
+		{"vector<float, 3>", ddl.Type{Name: ddl.Float64, IsArray: true}, []internal.SchemaIssue{internal.Widened}},
+		{"list<frozen<list<int>>>", ddl.Type{Name: ddl.JSON}, nil},
+		{"map<text, int>", ddl.Type{Name: ddl.JSON}, nil},
+		{"tuple<int, text>", ddl.Type{Name: ddl.JSON}, nil},
+		{"frozen<address>", ddl.Type{Name: ddl.JSON}, nil},
+		{"list<frozen<address>>", ddl.Type{Name: ddl.JSON}, nil},
+		{"duration", ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}},
+	}
+	for _, c := range tc {
+		ty, issues := tdi.ToSpannerType(conv, "", schema.Type{Name: c.srcType})
+		assert.Equal(t, c.spType, ty, c.srcType)
+		assert.Equal(t, c.issues, issues, c.srcType)
+	}
+}
+
+func TestProcessSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shop.cql")
+	assert.Nil(t, os.WriteFile(path, []byte(testKeyspace), 0644))
+	isi, err := NewInfoSchemaImpl(path)
+	assert.Nil(t, err)
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+
+	orders := conv.SpSchema["orders"]
+	assert.Equal(t, []ddl.IndexKey{{Col: "tenant"}, {Col: "day"}, {Col: "placed_at", Desc: true}, {Col: "OrderId"}}, orders.Pks)
+	assert.True(t, orders.ColDefs["tenant"].NotNull)
+	assert.False(t, orders.ColDefs["note"].NotNull)
+	assert.Equal(t, ddl.Type{Name: ddl.JSON}, orders.ColDefs["ship_to"].T)
+	assert.Equal(t, 1, len(orders.Indexes))
+	assert.Contains(t, conv.Issues["page_views"]["views"], internal.Counter)
+}
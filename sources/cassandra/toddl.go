@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cassandra handles schema and data migrations from Cassandra
+// keyspaces, using the schema printed by cqlsh DESCRIBE KEYSPACE and data
+// exported with cqlsh COPY TO.
+package cassandra
+
+import (
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ToDdl implementation for Cassandra.
+type ToDdlImpl struct {
+	// UserTypes holds the names of the user defined types of the keyspace.
+	UserTypes map[string]bool
+}
+
+// Functions below implement the common.ToDdl interface
+// toSpannerType maps a scalar source schema type (defined by id and
+// mods) into a Spanner type. This is the core source-to-Spanner type
+// mapping.  toSpannerType returns the Spanner type and a list of type
+// conversion issues encountered.
+func (tdi ToDdlImpl) ToSpannerType(conv *internal.Conv, spType string, srcType schema.Type) (ddl.Type, []internal.SchemaIssue) {
+	name, args := splitType(srcType.Name)
+	switch name {
+	case "list", "set", "vector":
+		// Collections of scalars map to arrays. Spanner doesn't support
+		// nested arrays, so other collections are stored as JSON.
+		if len(args) >= 1 {
+			elem, issues := tdi.toSpannerScalar(args[0])
+			if elem.Name != ddl.JSON {
+				elem.IsArray = true
+				return elem, issues
+			}
+		}
+		return ddl.Type{Name: ddl.JSON}, nil
+	case "map", "tuple":
+		return ddl.Type{Name: ddl.JSON}, nil
+	}
+	return tdi.toSpannerScalar(srcType.Name)
+}
+
+func (tdi ToDdlImpl) toSpannerScalar(typ string) (ddl.Type, []internal.SchemaIssue) {
+	name, args := splitType(typ)
+	switch name {
+	case "ascii", "text", "varchar", "inet":
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+	case "tinyint", "smallint", "int":
+		return ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Widened}
+	case "bigint":
+		return ddl.Type{Name: ddl.Int64}, nil
+	case "counter":
+		return ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Counter}
+	case "varint":
+		return ddl.Type{Name: ddl.Numeric}, []internal.SchemaIssue{internal.Numeric}
+	case "decimal":
+		return ddl.Type{Name: ddl.Numeric}, []internal.SchemaIssue{internal.Decimal}
+	case "float":
+		return ddl.Type{Name: ddl.Float64}, []internal.SchemaIssue{internal.Widened}
+	case "double":
+		return ddl.Type{Name: ddl.Float64}, nil
+	case "boolean":
+		return ddl.Type{Name: ddl.Bool}, nil
+	case "blob":
+		return ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, nil
+	case "date":
+		return ddl.Type{Name: ddl.Date}, nil
+	case "timestamp":
+		return ddl.Type{Name: ddl.Timestamp}, nil
+	case "time":
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Time}
+	case "uuid", "timeuuid":
+		return ddl.Type{Name: ddl.String, Len: 36}, nil
+	}
+	if len(args) > 0 || tdi.UserTypes[name] {
+		// Collections, tuples and user defined types.
+		return ddl.Type{Name: ddl.JSON}, nil
+	}
+	// Includes duration and custom types.
+	return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
+}
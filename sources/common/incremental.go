@@ -0,0 +1,267 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// deleteCheckChunk is the number of primary keys of a Spanner table looked
+// up in the source table at once, to find deleted rows.
+const deleteCheckChunk = 1000
+
+// IncrementalInfoSchema is implemented by InfoSchemas of sources that
+// support watermark-based incremental syncs, for sources without change
+// data capture: each sync only reads the rows whose watermark column (e.g.
+// an updated_at column) changed since the previous sync.
+type IncrementalInfoSchema interface {
+	// WithPredicates returns a copy of the InfoSchema whose ProcessData and
+	// GetRowCount only read the rows of each table matching its predicate.
+	WithPredicates(predicates map[string]WatermarkPredicate) InfoSchema
+	// GetMaxWatermark returns the highest value of column col of a table,
+	// as text, or "" if the column has no value.
+	GetMaxWatermark(conv *internal.Conv, srcTable, col string) (string, error)
+	// GetExistingKeys returns, for each key of keys, whether the table has a
+	// row with this key. keyCols are the source columns of the key.
+	GetExistingKeys(conv *internal.Conv, srcTable string, keyCols []string, keys [][]interface{}) ([]bool, error)
+}
+
+// WatermarkPredicate restricts the rows of a table to those whose watermark
+// column Col is in (Low, High]. All rows are read if Low is empty, i.e. for
+// the first sync of the table.
+type WatermarkPredicate struct {
+	Col  string
+	Low  string
+	High string
+}
+
+// WatermarkState is the progress of the incremental syncs, saved to a local
+// file between runs.
+type WatermarkState struct {
+	Tables map[string]TableWatermark // Keyed by source table name.
+}
+
+// TableWatermark is the highest value of the watermark column of a table
+// that was synced.
+type TableWatermark struct {
+	Col       string
+	Watermark string
+	SyncedAt  time.Time
+}
+
+// LoadWatermarkState reads the state of the incremental syncs from path. The
+// state is empty if the file doesn't exist, i.e. before the first sync.
+func LoadWatermarkState(path string) (*WatermarkState, error) {
+	state := &WatermarkState{Tables: make(map[string]TableWatermark)}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read watermark state %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, fmt.Errorf("can't parse watermark state %s: %v", path, err)
+	}
+	if state.Tables == nil {
+		state.Tables = make(map[string]TableWatermark)
+	}
+	return state, nil
+}
+
+// Save writes the state to path, atomically.
+func (s *WatermarkState) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ParseWatermarkColumns parses the watermark columns of the tables, given as
+// a list separated by ';' of table:column entries, and of at most one column
+// name used for the tables that have this column, e.g.
+// "updated_at;dbo.orders:modified_on".
+func ParseWatermarkColumns(spec string) (string, map[string]string, error) {
+	def := ""
+	perTable := make(map[string]string)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		i := strings.LastIndex(entry, ":")
+		if i < 0 {
+			if def != "" {
+				return "", nil, fmt.Errorf("more than one default watermark column: %s and %s", def, entry)
+			}
+			def = entry
+			continue
+		}
+		table, col := entry[:i], entry[i+1:]
+		if table == "" || col == "" {
+			return "", nil, fmt.Errorf("invalid watermark column %q, expected table:column", entry)
+		}
+		perTable[table] = col
+	}
+	return def, perTable, nil
+}
+
+// PlanIncrementalSync returns the predicates of the next sync of the tables of
+// conv, and the watermarks to save once a table is synced. The watermark
+// column of a table is its entry of perTable, or def if the table has such a
+// column. Tables without watermark column are read entirely by each sync.
+func PlanIncrementalSync(conv *internal.Conv, is IncrementalInfoSchema, state *WatermarkState, def string, perTable map[string]string) (map[string]WatermarkPredicate, map[string]TableWatermark, error) {
+	for table := range perTable {
+		if _, ok := conv.SrcSchema[table]; !ok {
+			return nil, nil, fmt.Errorf("watermark column specified for table %s, which isn't in the schema", table)
+		}
+	}
+	var tables []string
+	for table := range conv.SrcSchema {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	predicates := make(map[string]WatermarkPredicate)
+	next := make(map[string]TableWatermark)
+	now := time.Now()
+	for _, table := range tables {
+		srcSchema := conv.SrcSchema[table]
+		col, ok := perTable[table]
+		if ok {
+			if _, found := srcSchema.ColDefs[col]; !found {
+				return nil, nil, fmt.Errorf("watermark column %s of table %s doesn't exist", col, table)
+			}
+		} else if _, found := srcSchema.ColDefs[def]; def != "" && found {
+			col = def
+		}
+		if col == "" {
+			fmt.Printf("Table %s has no watermark column: all its rows will be synced.\n", table)
+			continue
+		}
+		high, err := is.GetMaxWatermark(conv, table, col)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't get watermark of table %s: %v", table, err)
+		}
+		low := ""
+		// A new watermark column starts a full sync of the table.
+		if prev, ok := state.Tables[table]; ok && prev.Col == col {
+			low = prev.Watermark
+		}
+		if high == "" {
+			// Rows can't be newer than the previous sync.
+			high = low
+		}
+		predicates[table] = WatermarkPredicate{Col: col, Low: low, High: high}
+		next[table] = TableWatermark{Col: col, Watermark: high, SyncedAt: now}
+	}
+	return predicates, next, nil
+}
+
+// KeyReader calls f with the primary key of each row of a Spanner table.
+type KeyReader func(spTable string, cols []string, f func(key []interface{}) error) error
+
+// SyncDeletes deletes the rows of the Spanner tables whose primary key no
+// longer exists in the source table, by looking up the keys of each Spanner
+// table in the source table in chunks. Child tables are processed before
+// their parents. It returns the number of rows deleted per source table.
+// Only tables with an INT64 or STRING primary key are supported.
+func SyncDeletes(conv *internal.Conv, is IncrementalInfoSchema, readKeys KeyReader, write func(m []*sp.Mutation) error) (map[string]int64, error) {
+	deleted := make(map[string]int64)
+	tables := ddl.OrderTables(conv.SpSchema)
+	for i := len(tables) - 1; i >= 0; i-- {
+		spTable := tables[i]
+		srcTable, err := internal.GetSourceTable(conv, spTable)
+		if err != nil {
+			continue
+		}
+		spSchema := conv.SpSchema[spTable]
+		if _, ok := conv.SyntheticPKeys[spTable]; ok {
+			conv.Unexpected(fmt.Sprintf("Can't find deleted rows of table %s: it has no primary key", srcTable))
+			continue
+		}
+		var spCols, srcCols []string
+		supported := true
+		for _, pk := range spSchema.Pks {
+			switch spSchema.ColDefs[pk.Col].T.Name {
+			case ddl.Int64, ddl.String:
+			default:
+				supported = false
+			}
+			srcCol, ok := conv.ToSource[spTable].Cols[pk.Col]
+			if !ok {
+				supported = false
+			}
+			spCols = append(spCols, pk.Col)
+			srcCols = append(srcCols, srcCol)
+		}
+		if !supported || len(spCols) == 0 {
+			conv.Unexpected(fmt.Sprintf("Can't find deleted rows of table %s: only INT64 and STRING primary keys are supported", srcTable))
+			continue
+		}
+		var chunk [][]interface{}
+		flush := func() error {
+			if len(chunk) == 0 {
+				return nil
+			}
+			exists, err := is.GetExistingKeys(conv, srcTable, srcCols, chunk)
+			if err != nil {
+				return fmt.Errorf("can't look up keys of table %s: %v", srcTable, err)
+			}
+			var ms []*sp.Mutation
+			for j, key := range chunk {
+				if !exists[j] {
+					ms = append(ms, sp.Delete(spTable, sp.Key(key)))
+				}
+			}
+			if len(ms) > 0 {
+				if err := write(ms); err != nil {
+					return fmt.Errorf("can't delete rows of table %s: %v", spTable, err)
+				}
+				deleted[srcTable] += int64(len(ms))
+			}
+			chunk = nil
+			return nil
+		}
+		err = readKeys(spTable, spCols, func(key []interface{}) error {
+			chunk = append(chunk, key)
+			if len(chunk) < deleteCheckChunk {
+				return nil
+			}
+			return flush()
+		})
+		if err == nil {
+			err = flush()
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// fakeIncrementalInfoSchema has fixed watermarks, and the source keys of
+// each table.
+type fakeIncrementalInfoSchema struct {
+	InfoSchema
+	watermarks map[string]string
+	keys       map[string]map[string]bool
+}
+
+func (f fakeIncrementalInfoSchema) WithPredicates(predicates map[string]WatermarkPredicate) InfoSchema {
+	return f
+}
+
+func (f fakeIncrementalInfoSchema) GetMaxWatermark(conv *internal.Conv, srcTable, col string) (string, error) {
+	return f.watermarks[srcTable], nil
+}
+
+func (f fakeIncrementalInfoSchema) GetExistingKeys(conv *internal.Conv, srcTable string, keyCols []string, keys [][]interface{}) ([]bool, error) {
+	exists := make([]bool, len(keys))
+	for i, k := range keys {
+		exists[i] = f.keys[srcTable][fmt.Sprint(k...)]
+	}
+	return exists, nil
+}
+
+func incrementalConv() *internal.Conv {
+	conv := internal.MakeConv()
+	for _, name := range []string{"orders", "items", "logs"} {
+		conv.SrcSchema[name] = schema.Table{
+			Name:     name,
+			ColNames: []string{"id", "updated_at"},
+			ColDefs: map[string]schema.Column{
+				"id":         {Name: "id", Type: schema.Type{Name: "int"}},
+				"updated_at": {Name: "updated_at", Type: schema.Type{Name: "datetime2"}},
+			},
+		}
+		conv.SpSchema[name] = ddl.CreateTable{
+			Name:     name,
+			ColNames: []string{"id", "updated_at"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":         {Name: "id", T: ddl.Type{Name: ddl.Int64}},
+				"updated_at": {Name: "updated_at", T: ddl.Type{Name: ddl.Timestamp}},
+			},
+			Pks: []ddl.IndexKey{{Col: "id"}},
+		}
+		conv.ToSpanner[name] = internal.NameAndCols{Name: name, Cols: map[string]string{"id": "id", "updated_at": "updated_at"}}
+		conv.ToSource[name] = internal.NameAndCols{Name: name, Cols: map[string]string{"id": "id", "updated_at": "updated_at"}}
+	}
+	// logs has no watermark column.
+	logs := conv.SrcSchema["logs"]
+	logs.ColNames = []string{"id"}
+	delete(logs.ColDefs, "updated_at")
+	conv.SrcSchema["logs"] = logs
+	// items is interleaved in orders.
+	items := conv.SpSchema["items"]
+	items.Parent = "orders"
+	conv.SpSchema["items"] = items
+	return conv
+}
+
+func TestParseWatermarkColumns(t *testing.T) {
+	def, perTable, err := ParseWatermarkColumns("updated_at; dbo.orders:modified_on;sales.items:version")
+	assert.Nil(t, err)
+	assert.Equal(t, "updated_at", def)
+	assert.Equal(t, map[string]string{"dbo.orders": "modified_on", "sales.items": "version"}, perTable)
+
+	_, _, err = ParseWatermarkColumns("updated_at;modified_on")
+	assert.NotNil(t, err)
+	_, _, err = ParseWatermarkColumns("orders:")
+	assert.NotNil(t, err)
+}
+
+func TestWatermarkState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watermarks.json")
+	state, err := LoadWatermarkState(path)
+	assert.Nil(t, err)
+	assert.Empty(t, state.Tables)
+
+	syncedAt := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	state.Tables["orders"] = TableWatermark{Col: "updated_at", Watermark: "2022-11-01T09:59:59.123", SyncedAt: syncedAt}
+	assert.Nil(t, state.Save(path))
+	loaded, err := LoadWatermarkState(path)
+	assert.Nil(t, err)
+	assert.Equal(t, state, loaded)
+}
+
+func TestPlanIncrementalSync(t *testing.T) {
+	conv := incrementalConv()
+	is := fakeIncrementalInfoSchema{watermarks: map[string]string{"orders": "2022-11-02T00:00:00", "items": ""}}
+	state := &WatermarkState{Tables: map[string]TableWatermark{
+		"orders": {Col: "updated_at", Watermark: "2022-11-01T00:00:00"},
+		"items":  {Col: "updated_at", Watermark: "2022-10-01T00:00:00"},
+	}}
+
+	predicates, next, err := PlanIncrementalSync(conv, is, state, "updated_at", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]WatermarkPredicate{
+		"orders": {Col: "updated_at", Low: "2022-11-01T00:00:00", High: "2022-11-02T00:00:00"},
+		// No row has a watermark: nothing changed since the previous sync.
+		"items": {Col: "updated_at", Low: "2022-10-01T00:00:00", High: "2022-10-01T00:00:00"},
+	}, predicates)
+	assert.Equal(t, "2022-11-02T00:00:00", next["orders"].Watermark)
+	assert.Equal(t, "2022-10-01T00:00:00", next["items"].Watermark)
+	assert.NotContains(t, next, "logs")
+
+	// A table with a different watermark column is synced entirely.
+	is.watermarks["orders"] = "42"
+	predicates, _, err = PlanIncrementalSync(conv, is, state, "", map[string]string{"orders": "id"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]WatermarkPredicate{"orders": {Col: "id", High: "42"}}, predicates)
+
+	_, _, err = PlanIncrementalSync(conv, is, state, "", map[string]string{"orders": "missing"})
+	assert.NotNil(t, err)
+	_, _, err = PlanIncrementalSync(conv, is, state, "", map[string]string{"unknown": "id"})
+	assert.NotNil(t, err)
+}
+
+func TestSyncDeletes(t *testing.T) {
+	conv := incrementalConv()
+	conv.SyntheticPKeys["logs"] = internal.SyntheticPKey{Col: "synth_id"}
+	is := fakeIncrementalInfoSchema{keys: map[string]map[string]bool{
+		"orders": {"1": true, "3": true},
+		"items":  {"10": true},
+	}}
+	spannerKeys := map[string][]int64{
+		"orders": {1, 2, 3},
+		"items":  {10, 11, 12},
+		"logs":   {1},
+	}
+	var tables []string
+	readKeys := func(spTable string, cols []string, f func(key []interface{}) error) error {
+		tables = append(tables, spTable)
+		assert.Equal(t, []string{"id"}, cols)
+		for _, k := range spannerKeys[spTable] {
+			if err := f([]interface{}{k}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var written []*sp.Mutation
+	write := func(m []*sp.Mutation) error {
+		written = append(written, m...)
+		return nil
+	}
+
+	deleted, err := SyncDeletes(conv, is, readKeys, write)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int64{"orders": 1, "items": 2}, deleted)
+	// Child rows are deleted before their parent.
+	assert.Equal(t, []string{"items", "orders"}, tables)
+	assert.Equal(t, []*sp.Mutation{
+		sp.Delete("items", sp.Key{int64(11)}),
+		sp.Delete("items", sp.Key{int64(12)}),
+		sp.Delete("orders", sp.Key{int64(2)}),
+	}, written)
+	assert.Equal(t, int64(1), conv.Stats.Unexpected["Can't find deleted rows of table logs: it has no primary key"])
+}
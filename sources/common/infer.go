@@ -0,0 +1,104 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"log"
+	"sort"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+const (
+	// InferErrThreshold is the fraction of sampled rows below which an
+	// observed type is assumed to have been inserted by mistake and is
+	// discarded.
+	InferErrThreshold = float64(0.001)
+	// InferConflictThreshold is the fraction of present values above which
+	// an observed type is considered a candidate type for a column.
+	InferConflictThreshold = float64(0.05)
+)
+
+// InferColumns infers column definitions for sources without a fixed
+// schema (e.g. DynamoDB, MongoDB exports) from sampled data. stats maps
+// each column name to a count of the types observed for it, and rows is
+// the number of sampled rows. Columns with exactly one candidate type get
+// that type; columns with zero or several candidate types get
+// conflictType. Column names are returned in increasing order.
+func InferColumns(stats map[string]map[string]int64, rows int64, primaryKeys []string, conflictType string) (map[string]schema.Column, []string) {
+	colDefs := make(map[string]schema.Column)
+	var colNames []string
+
+	for col, countMap := range stats {
+		var statItems, candidates []statItem
+		var presentRows int64
+		for k, v := range countMap {
+			presentRows += v
+			if float64(v)/float64(rows) <= InferErrThreshold {
+				// If the percentage is less than the error threshold, then
+				// this data type has a high chance to be mistakenly inserted
+				// and we should discard it.
+				continue
+			}
+			statItems = append(statItems, statItem{Type: k, Count: v})
+		}
+		if len(statItems) == 0 {
+			log.Printf("Skip column %v with no data records", col)
+			continue
+		}
+
+		// Check if the column is a part of a primary key.
+		isPKey := false
+		for _, pk := range primaryKeys {
+			if pk == col {
+				isPKey = true
+				break
+			}
+		}
+
+		// If this column is in the primary key, then it cannot be null.
+		nullable := false
+		if !isPKey {
+			nullable = float64(rows-presentRows)/float64(rows) > InferErrThreshold
+		}
+
+		for _, si := range statItems {
+			if float64(si.Count)/float64(presentRows) > InferConflictThreshold {
+				// If the normalized percentage is greater than the conflicting
+				// threshold, we should consider this data type as a candidate.
+				candidates = append(candidates, si)
+			}
+		}
+
+		colNames = append(colNames, col)
+		if len(candidates) == 1 {
+			colDefs[col] = schema.Column{Name: col, Type: schema.Type{Name: candidates[0].Type}, NotNull: !nullable}
+		} else {
+			// If there is no any candidate or more than a single candidate,
+			// this column has a significant conflict on data types and then
+			// defaults to conflictType.
+			colDefs[col] = schema.Column{Name: col, Type: schema.Type{Name: conflictType}, NotNull: !nullable}
+		}
+	}
+	// Sort column names in increasing order, because the source may return
+	// them in a random order.
+	sort.Strings(colNames)
+	return colDefs, colNames
+}
+
+type statItem struct {
+	Type  string
+	Count int64
+}
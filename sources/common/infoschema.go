@@ -44,6 +44,13 @@ type InfoSchema interface {
 	StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamInfo map[string]interface{}) error
 }
 
+// DescKeysInfoSchema is implemented by InfoSchemas of sources whose primary
+// keys can have descending columns. GetDescKeys returns the set of primary
+// key columns of a table that are in descending order.
+type DescKeysInfoSchema interface {
+	GetDescKeys(table SchemaAndName) map[string]bool
+}
+
 // SchemaAndName contains the schema and name for a table
 type SchemaAndName struct {
 	Schema string
@@ -87,7 +94,11 @@ func ProcessSchema(conv *internal.Conv, infoSchema InfoSchema, numWorkers int) e
 		fmt.Println("exiting due to error while processing schema for table", res)
 		return err
 	}
+	processSequences(conv, infoSchema)
 
+	if conv.ProfileData {
+		profileTables(conv, infoSchema, tables, numWorkers)
+	}
 	SchemaToSpannerDDL(conv, infoSchema.GetToDdl())
 	conv.AddPrimaryKeys()
 	fmt.Println("loaded schema")
@@ -105,18 +116,11 @@ func ProcessData(conv *internal.Conv, infoSchema InfoSchema) {
 	orderTableNames := ddl.OrderTables(conv.SpSchema)
 
 	for _, spannerTable := range orderTableNames {
-		srcTable, _ := internal.GetSourceTable(conv, spannerTable)
-		srcSchema := conv.SrcSchema[srcTable]
-		spTable, err1 := internal.GetSpannerTable(conv, srcTable)
-		spCols, err2 := internal.GetSpannerCols(conv, srcTable, srcSchema.ColNames)
-		spSchema, ok := conv.SpSchema[spTable]
-		if err1 != nil || err2 != nil || !ok {
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
-			conv.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: err1=%s, err2=%s, ok=%t",
-				srcTable, err1, err2, ok))
+		t, ok := getTableData(conv, spannerTable)
+		if !ok {
 			continue
 		}
-		err := infoSchema.ProcessData(conv, srcTable, srcSchema, spTable, spCols, spSchema)
+		err := infoSchema.ProcessData(conv, t.srcTable, t.srcSchema, t.spTable, t.spCols, t.spSchema)
 		if err != nil {
 			return
 		}
@@ -126,6 +130,33 @@ func ProcessData(conv *internal.Conv, infoSchema InfoSchema) {
 	}
 }
 
+// tableData has the source and Spanner names and schemas of a table, to
+// process its data.
+type tableData struct {
+	srcTable  string
+	srcSchema schema.Table
+	spTable   string
+	spCols    []string
+	spSchema  ddl.CreateTable
+}
+
+// getTableData returns the tableData of spannerTable. If they can't be
+// found, the rows of the table are counted as bad rows.
+func getTableData(conv *internal.Conv, spannerTable string) (tableData, bool) {
+	srcTable, _ := internal.GetSourceTable(conv, spannerTable)
+	srcSchema := conv.SrcSchema[srcTable]
+	spTable, err1 := internal.GetSpannerTable(conv, srcTable)
+	spCols, err2 := internal.GetSpannerCols(conv, srcTable, srcSchema.ColNames)
+	spSchema, ok := conv.SpSchema[spTable]
+	if err1 != nil || err2 != nil || !ok {
+		conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+		conv.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: err1=%s, err2=%s, ok=%t",
+			srcTable, err1, err2, ok))
+		return tableData{}, false
+	}
+	return tableData{srcTable, srcSchema, spTable, spCols, spSchema}, true
+}
+
 // SetRowStats populates conv with the number of rows in each table.
 func SetRowStats(conv *internal.Conv, infoSchema InfoSchema) {
 	tables, err := infoSchema.GetTables()
@@ -164,9 +195,13 @@ func processTable(conv *internal.Conv, table SchemaAndName, infoSchema InfoSchem
 		return t, fmt.Errorf("couldn't get schema for table %s.%s: %s", table.Schema, table.Name, err)
 	}
 	name := infoSchema.GetTableName(table.Schema, table.Name)
+	var descKeys map[string]bool
+	if dk, ok := infoSchema.(DescKeysInfoSchema); ok {
+		descKeys = dk.GetDescKeys(table)
+	}
 	var schemaPKeys []schema.Key
 	for _, k := range primaryKeys {
-		schemaPKeys = append(schemaPKeys, schema.Key{Column: k})
+		schemaPKeys = append(schemaPKeys, schema.Key{Column: k, Desc: descKeys[k]})
 	}
 	t = schema.Table{
 		Name:        name,
@@ -176,5 +211,10 @@ func processTable(conv *internal.Conv, table SchemaAndName, infoSchema InfoSchem
 		PrimaryKeys: schemaPKeys,
 		Indexes:     indexes,
 		ForeignKeys: foreignKeys}
+	if pis, ok := infoSchema.(PartitionedInfoSchema); ok {
+		if err := mergePartitions(conv, pis, infoSchema, table, &t); err != nil {
+			return t, err
+		}
+	}
 	return t, nil
 }
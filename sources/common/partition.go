@@ -0,0 +1,82 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// PartitionedInfoSchema is implemented by InfoSchemas of databases whose
+// tables can be partitioned or inherited. Such hierarchies are merged into
+// a single table: GetTables only returns their root tables, and
+// GetPartitions returns how a root table is partitioned and its partitions
+// (or child tables) at all levels.
+type PartitionedInfoSchema interface {
+	GetPartitions(conv *internal.Conv, table SchemaAndName) (scheme string, partitions []PartitionInfo, err error)
+}
+
+// PartitionInfo describes a partition returned by GetPartitions.
+type PartitionInfo struct {
+	Table SchemaAndName
+	Bound string // The partition bound, if any.
+}
+
+// MergePartition merges partition p, whose columns are colDefs, into table
+// t. Columns that only exist in the partition (e.g. in child tables of
+// PostgreSQL inheritance) are added to t, and are nullable since the other
+// rows of t don't have them.
+func MergePartition(t *schema.Table, p schema.Partition, colDefs map[string]schema.Column) {
+	if t.ColDefs == nil {
+		t.ColDefs = make(map[string]schema.Column)
+	}
+	for _, c := range p.ColNames {
+		if _, ok := t.ColDefs[c]; ok {
+			continue
+		}
+		col := colDefs[c]
+		col.NotNull = false
+		t.ColNames = append(t.ColNames, c)
+		t.ColDefs[c] = col
+	}
+	t.Partitions = append(t.Partitions, p)
+}
+
+// mergePartitions fetches the partitions of table t and merges them into t.
+func mergePartitions(conv *internal.Conv, pis PartitionedInfoSchema, infoSchema InfoSchema, table SchemaAndName, t *schema.Table) error {
+	scheme, partitions, err := pis.GetPartitions(conv, table)
+	if err != nil {
+		return fmt.Errorf("couldn't get partitions for table %s.%s: %s", table.Schema, table.Name, err)
+	}
+	if len(partitions) == 0 {
+		return nil
+	}
+	t.PartitionScheme = scheme
+	for _, p := range partitions {
+		colDefs, colNames, err := infoSchema.GetColumns(conv, p.Table, nil, nil)
+		if err != nil {
+			return fmt.Errorf("couldn't get schema for partition %s.%s: %s", p.Table.Schema, p.Table.Name, err)
+		}
+		MergePartition(t, schema.Partition{
+			Name:     infoSchema.GetTableName(p.Table.Schema, p.Table.Name),
+			Schema:   p.Table.Schema,
+			ColNames: colNames,
+			Bound:    p.Bound,
+		}, colDefs)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ProfilingInfoSchema is implemented by InfoSchemas that can profile the
+// data of a table. ProfileTable returns the profile of the columns cols of
+// srcTable (whose schema is in conv.SrcSchema).
+type ProfilingInfoSchema interface {
+	ProfileTable(conv *internal.Conv, srcTable string, cols []ProfileColumn) (map[string]*internal.ColumnProfile, error)
+}
+
+// ProfileColumn is a column to profile. The NULLs of all columns are
+// counted; Numeric columns also get their minimum and maximum values and
+// whether they are all integers, and Text columns the maximum length of
+// their values.
+type ProfileColumn struct {
+	Name    string
+	Numeric bool
+	Text    bool
+}
+
+// ProfileQuery returns a query that profiles the columns cols of table (a
+// quoted table reference) with aggregate functions, so that the rows are
+// scanned by the source database instead of being read by HarbourBridge.
+// quote quotes a column name, and length is the expression of the length in
+// characters of a value, with %s for the quoted column. The result is read
+// with ScanProfile.
+func ProfileQuery(table string, cols []ProfileColumn, quote func(string) string, length string) string {
+	aggs := []string{"COUNT(*)"}
+	for _, c := range cols {
+		col := quote(c.Name)
+		aggs = append(aggs, fmt.Sprintf("SUM(CASE WHEN %s IS NULL THEN 1 ELSE 0 END)", col))
+		switch {
+		case c.Numeric:
+			aggs = append(aggs, fmt.Sprintf("MIN(%s)", col), fmt.Sprintf("MAX(%s)", col),
+				fmt.Sprintf("SUM(CASE WHEN %s <> FLOOR(%s) THEN 1 ELSE 0 END)", col, col))
+		case c.Text:
+			aggs = append(aggs, "MAX("+fmt.Sprintf(length, col)+")")
+		}
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(aggs, ", "), table)
+}
+
+// ScanProfile reads the result of a query built by ProfileQuery for cols.
+func ScanProfile(rows *sql.Rows, cols []ProfileColumn) (map[string]*internal.ColumnProfile, error) {
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no result")
+	}
+	var count int64
+	nulls := make([]sql.NullInt64, len(cols))
+	mins := make([]sql.NullString, len(cols))
+	maxs := make([]sql.NullString, len(cols))
+	// other holds the number of non-integers of Numeric columns, and the
+	// maximum length of Text columns.
+	other := make([]sql.NullInt64, len(cols))
+	ptrs := []interface{}{&count}
+	for i, c := range cols {
+		ptrs = append(ptrs, &nulls[i])
+		switch {
+		case c.Numeric:
+			ptrs = append(ptrs, &mins[i], &maxs[i], &other[i])
+		case c.Text:
+			ptrs = append(ptrs, &other[i])
+		}
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]*internal.ColumnProfile)
+	for i, c := range cols {
+		p := &internal.ColumnProfile{Rows: count, Nulls: nulls[i].Int64}
+		switch {
+		case c.Numeric:
+			p.Numeric = true
+			p.Min, p.Max = mins[i].String, maxs[i].String
+			p.Integer = other[i].Int64 == 0 && fitsInt64(p.Min) && fitsInt64(p.Max)
+		case c.Text:
+			p.MaxLength = other[i].Int64
+		}
+		profiles[c.Name] = p
+	}
+	return profiles, rows.Err()
+}
+
+// fitsInt64 returns true if s is a decimal number with an integer value
+// that fits in an INT64, e.g. "12" or "12.00".
+func fitsInt64(s string) bool {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || !r.IsInt() {
+		return false
+	}
+	return r.Num().IsInt64()
+}
+
+// ProfileSQLRows profiles the rows returned by a SQL query. Values are
+// scanned as strings, which all database/sql drivers support.
+func ProfileSQLRows(rows *sql.Rows) (map[string]*internal.ColumnProfile, error) {
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	profiles := make(map[string]*internal.ColumnProfile)
+	for _, c := range cols {
+		profiles[c] = internal.NewColumnProfile()
+	}
+	vals := make([]sql.NullString, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, c := range cols {
+			if vals[i].Valid {
+				profiles[c].Add(&vals[i].String)
+			} else {
+				profiles[c].Add(nil)
+			}
+		}
+	}
+	return profiles, rows.Err()
+}
+
+// profileTables runs the data profiling pass over tables, and stores the
+// profiles in conv.Profiles. Only the columns whose type can be narrowed are
+// fully profiled, and only the NULLs of the others are counted. Tables that
+// can't be profiled are reported and skipped, so their columns keep their
+// default types.
+func profileTables(conv *internal.Conv, infoSchema InfoSchema, tables []SchemaAndName, numWorkers int) {
+	pis, ok := infoSchema.(ProfilingInfoSchema)
+	if !ok {
+		conv.Unexpected("Data profiling isn't supported for this source")
+		return
+	}
+	conv.Profiles = make(map[string]map[string]*internal.ColumnProfile)
+	toddl := infoSchema.GetToDdl()
+	cols := make(map[string][]ProfileColumn)
+	for _, t := range tables {
+		name := infoSchema.GetTableName(t.Schema, t.Name)
+		srcTable := conv.SrcSchema[name]
+		for _, colName := range srcTable.ColNames {
+			ty, _ := toddl.ToSpannerType(conv, "", srcTable.ColDefs[colName].Type)
+			cols[name] = append(cols[name], ProfileColumn{
+				Name:    colName,
+				Numeric: !ty.IsArray && ty.Name == ddl.Numeric,
+				Text:    !ty.IsArray && ty.Name == ddl.String && ty.Len == ddl.MaxLength,
+			})
+		}
+	}
+	asyncProfileTable := func(t SchemaAndName, mutex *sync.Mutex) TaskResult[SchemaAndName] {
+		name := infoSchema.GetTableName(t.Schema, t.Name)
+		profiles, err := pis.ProfileTable(conv, name, cols[name])
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't profile data of table %s: %s", name, err))
+		} else {
+			conv.Profiles[name] = profiles
+		}
+		return TaskResult[SchemaAndName]{t, nil}
+	}
+	RunParallelTasks(tables, numWorkers, asyncProfileTable, false)
+}
+
+// narrowType narrows the default Spanner type ty of column srcCol using the
+// profile of its data: numerics holding only integers become INT64, and
+// unbounded strings become STRING(n), where n leaves room for longer values
+// than the maximum length found (see profiledLength).
+func narrowType(conv *internal.Conv, srcTable string, srcCol schema.Column, ty ddl.Type, issues []internal.SchemaIssue) (ddl.Type, []internal.SchemaIssue) {
+	p := conv.Profiles[srcTable][srcCol.Name]
+	if !p.Valid() || ty.IsArray {
+		return ty, issues
+	}
+	switch {
+	case ty.Name == ddl.Numeric && p.Integer:
+		// The issues about numerics don't apply to INT64.
+		var kept []internal.SchemaIssue
+		for _, i := range issues {
+			if i != internal.Numeric && i != internal.NumericThatFits && i != internal.Decimal && i != internal.DecimalThatFits {
+				kept = append(kept, i)
+			}
+		}
+		return ddl.Type{Name: ddl.Int64}, append(kept, internal.ProfileNarrowed)
+	case ty.Name == ddl.String && ty.Len == ddl.MaxLength && p.MaxLength > 0:
+		if n := profiledLength(p.MaxLength); n != ddl.MaxLength {
+			return ddl.Type{Name: ddl.String, Len: n}, append(issues, internal.ProfileNarrowed)
+		}
+	}
+	return ty, issues
+}
+
+// profiledLength returns the length of a STRING column whose longest value
+// has maxLen characters: the next power of two, and at least 16, so that
+// values a little longer than those profiled still fit.
+func profiledLength(maxLen int64) int64 {
+	n := int64(16)
+	for n < maxLen {
+		n *= 2
+	}
+	if n > ddl.StringMaxLength {
+		return ddl.MaxLength
+	}
+	return n
+}
+
+// neverNull returns true if data profiling found no NULLs in a column of a
+// non-empty table.
+func neverNull(conv *internal.Conv, srcTable, srcCol string) bool {
+	p := conv.Profiles[srcTable][srcCol]
+	return p.Valid() && p.Nulls == 0
+}
+
+// sampledType chooses the Spanner type of column srcCol, whose default
+// Spanner type is ty, using the sample of its values collected by the
+// source: numerics holding only integers become INT64 and those that don't
+// fit in NUMERIC become FLOAT64, and dates with a time of day become
+// TIMESTAMP. The type must be one of the alternatives the source allows for
+// the column's type.
+func sampledType(conv *internal.Conv, toddl ToDdl, srcCol schema.Column, ty ddl.Type, issues []internal.SchemaIssue) (ddl.Type, []internal.SchemaIssue) {
+	s := srcCol.Sample
+	want, issue := ty.Name, internal.SampledType
+	switch {
+	case ty.Name == ddl.Numeric && s.Integer:
+		want = ddl.Int64
+	case ty.Name == ddl.Numeric && !s.Numeric:
+		want, issue = ddl.Float64, internal.SampledFloat
+	case ty.Name == ddl.Numeric:
+	case ty.Name == ddl.Date && s.Time:
+		want = ddl.Timestamp
+	default:
+		return ty, issues
+	}
+	if want == ty.Name {
+		return ty, append(issues, issue)
+	}
+	alt, altIssues := toddl.ToSpannerType(conv, want, srcCol.Type)
+	if alt.Name != want {
+		return ty, issues
+	}
+	return alt, append(altIssues, issue)
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileQuery(t *testing.T) {
+	quote := func(s string) string { return `"` + s + `"` }
+	cols := []ProfileColumn{{Name: "id"}, {Name: "n", Numeric: true}, {Name: "s", Text: true}}
+	assert.Equal(t, `SELECT COUNT(*), SUM(CASE WHEN "id" IS NULL THEN 1 ELSE 0 END), `+
+		`SUM(CASE WHEN "n" IS NULL THEN 1 ELSE 0 END), MIN("n"), MAX("n"), SUM(CASE WHEN "n" <> FLOOR("n") THEN 1 ELSE 0 END), `+
+		`SUM(CASE WHEN "s" IS NULL THEN 1 ELSE 0 END), MAX(LENGTH("s")) FROM "t"`,
+		ProfileQuery(`"t"`, cols, quote, "LENGTH(%s)"))
+}
+
+func TestFitsInt64(t *testing.T) {
+	for _, s := range []string{"0", "-12", "12.00", "9223372036854775807", "-9223372036854775808"} {
+		assert.True(t, fitsInt64(s), s)
+	}
+	for _, s := range []string{"", "1.5", "9223372036854775808", "abc"} {
+		assert.False(t, fitsInt64(s), s)
+	}
+}
+
+func TestProfiledLength(t *testing.T) {
+	assert.Equal(t, int64(16), profiledLength(1))
+	assert.Equal(t, int64(16), profiledLength(16))
+	assert.Equal(t, int64(32), profiledLength(17))
+	assert.Equal(t, int64(2097152), profiledLength(2000000))
+	assert.Equal(t, int64(ddl.MaxLength), profiledLength(2500000))
+}
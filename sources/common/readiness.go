@@ -0,0 +1,57 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+// ReadinessTracker decides whether the current moment is optimum for
+// switching to Cloud Spanner, from the number of records processed each
+// minute: it is when the last minute had no records, or when the last five
+// minutes had at most 5% of the records of the first five minutes. It is
+// used by the streaming migrations of DynamoDB and by the monitor of the
+// Datastream and Dataflow streaming migrations.
+type ReadinessTracker struct {
+	minutes      int64
+	firstFiveMin int64
+	lastFiveMin  int64
+	tillLastMin  int64
+	arr          [5]int64
+}
+
+// Start records the total count of records processed before the first
+// minute, so that they aren't counted in it.
+func (r *ReadinessTracker) Start(recordsProcessed int64) {
+	r.tillLastMin = recordsProcessed
+}
+
+// Update records the total count of records processed at the end of a
+// minute. It returns whether the moment is optimum for cutover, and the
+// count of records of the minute.
+func (r *ReadinessTracker) Update(recordsProcessed int64) (bool, int64) {
+	counter := r.minutes % 5
+	r.lastFiveMin -= r.arr[counter]
+	r.arr[counter] = recordsProcessed - r.tillLastMin
+	r.tillLastMin += r.arr[counter]
+	r.lastFiveMin += r.arr[counter]
+	if r.minutes < 5 {
+		r.firstFiveMin += r.arr[counter]
+	}
+	r.minutes++
+	lastMin := r.arr[counter]
+	return (r.lastFiveMin*100 <= 5*r.firstFiveMin) || (lastMin == 0), lastMin
+}
+
+// Total returns the total count of records processed at the last update.
+func (r *ReadinessTracker) Total() int64 {
+	return r.tillLastMin
+}
@@ -0,0 +1,38 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessTracker(t *testing.T) {
+	var r ReadinessTracker
+	// Totals of records processed at the end of each minute.
+	totals := []int64{100, 200, 300, 400, 500, 502, 502, 503, 504, 505}
+	var got []bool
+	for _, total := range totals {
+		ready, _ := r.Update(total)
+		got = append(got, ready)
+	}
+	// Ready when a minute has no records, or once the last five minutes have
+	// at most 5% of the records of the first five.
+	assert.Equal(t, []bool{false, false, false, false, false, false, true, false, false, true}, got)
+	assert.Equal(t, int64(505), r.Total())
+	_, lastMin := r.Update(510)
+	assert.Equal(t, int64(5), lastMin)
+}
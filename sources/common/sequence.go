@@ -0,0 +1,83 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// SequenceInfoSchema is implemented by InfoSchemas of databases whose
+// sequences are standalone objects. GetSequences returns the sequences of
+// the database, which are recreated in Spanner.
+type SequenceInfoSchema interface {
+	GetSequences(conv *internal.Conv) ([]schema.Sequence, error)
+}
+
+// SynonymInfoSchema is implemented by InfoSchemas of databases that support
+// synonyms. Spanner doesn't, so they are only reported.
+type SynonymInfoSchema interface {
+	GetSynonyms(conv *internal.Conv) ([]schema.Synonym, error)
+}
+
+// processSequences fetches the sequences and synonyms of the source
+// database, if infoSchema supports them. Errors are not fatal: the tables
+// can be migrated without them.
+func processSequences(conv *internal.Conv, infoSchema InfoSchema) {
+	if sis, ok := infoSchema.(SequenceInfoSchema); ok {
+		seqs, err := sis.GetSequences(conv)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get sequences: %s", err))
+		}
+		for _, s := range seqs {
+			if conv.SrcSequences == nil {
+				conv.SrcSequences = make(map[string]schema.Sequence)
+			}
+			conv.SrcSequences[s.Name] = s
+		}
+	}
+	if sis, ok := infoSchema.(SynonymInfoSchema); ok {
+		syns, err := sis.GetSynonyms(conv)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get synonyms: %s", err))
+		}
+		conv.Synonyms = syns
+	}
+}
+
+// cvtSequences maps the source sequences in conv.SrcSequences to Spanner
+// bit-reversed sequences. Spanner sequences only generate positive values
+// in an order that is not preserved, so only the point where the source
+// sequence stopped is kept: the counter starts after it.
+func cvtSequences(conv *internal.Conv) {
+	if len(conv.SrcSequences) == 0 {
+		return
+	}
+	conv.SpSequences = make(map[string]ddl.Sequence)
+	for n, s := range conv.SrcSequences {
+		sq := ddl.Sequence{
+			Name:    internal.ToSpannerSequenceName(conv, n),
+			Comment: "From: " + quoteIfNeeded(n),
+		}
+		if next, err := strconv.ParseInt(s.Next, 10, 64); err == nil && next > 0 {
+			sq.StartWithCounter = next
+		}
+		conv.SpSequences[n] = sq
+	}
+}
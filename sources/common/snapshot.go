@@ -0,0 +1,254 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// SnapshotInfoSchema is implemented by the sources whose data can be read
+// under a consistent snapshot, at a known position of their change log, so
+// that the change data capture of a streaming migration starts exactly where
+// the snapshot ends.
+type SnapshotInfoSchema interface {
+	// BeginSnapshot starts n transactions that all see the same snapshot of
+	// the database. The caller must Close the snapshot.
+	BeginSnapshot(ctx context.Context, n int) (*Snapshot, error)
+}
+
+// Snapshot is a consistent snapshot of a source database, read by parallel
+// readers.
+type Snapshot struct {
+	Position SnapshotPosition
+	Readers  []InfoSchema // Read the rows of the tables in the snapshot, each through its own transaction.
+	Lock     *sync.Mutex  // Shared by the readers: conv and the BatchWriter aren't thread-safe.
+	close    func() error
+}
+
+// NewSnapshot returns a snapshot whose readers share lock. close ends their
+// transactions.
+func NewSnapshot(pos SnapshotPosition, readers []InfoSchema, lock *sync.Mutex, close func() error) *Snapshot {
+	return &Snapshot{Position: pos, Readers: readers, Lock: lock, close: close}
+}
+
+// Close ends the transactions of the readers.
+func (s *Snapshot) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	err := s.close()
+	s.close = nil
+	return err
+}
+
+// Querier runs queries, e.g. in a transaction or on a connection.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// SnapshotReader is the transaction through which a reader of a snapshot
+// reads the rows of the tables. The InfoSchemas of the sources have a nil
+// SnapshotReader outside of snapshots: its methods then use the database.
+type SnapshotReader struct {
+	Tx   Querier
+	Lock *sync.Mutex // Held while the rows read are processed.
+}
+
+// Query runs q in the transaction of r, or in db if r is nil.
+func (r *SnapshotReader) Query(db *sql.DB, q string, args ...interface{}) (*sql.Rows, error) {
+	if r == nil {
+		return db.Query(q, args...)
+	}
+	return r.Tx.QueryContext(context.Background(), q, args...)
+}
+
+// Do runs f, which processes rows, under the lock of r.
+func (r *SnapshotReader) Do(f func()) {
+	if r != nil {
+		r.Lock.Lock()
+		defer r.Lock.Unlock()
+	}
+	f()
+}
+
+// SnapshotPosition is the position of the change log of a source database at
+// a consistent snapshot. For MySQL, it is the binlog position. For
+// PostgreSQL, the transactions visible in the snapshot are recorded, since
+// WAL positions don't follow the commit order.
+type SnapshotPosition struct {
+	Driver  string
+	LogFile string   `json:",omitempty"` // MySQL binlog file.
+	LogPos  int64    `json:",omitempty"` // MySQL binlog position in LogFile.
+	Lsn     string   `json:",omitempty"` // PostgreSQL WAL position at the snapshot, for reference: the txid snapshot below tells the changes in the snapshot.
+	Xmin    uint64   `json:",omitempty"` // PostgreSQL transactions before Xmin are visible in the snapshot...
+	Xmax    uint64   `json:",omitempty"` // ...and those from Xmax aren't...
+	Xip     []uint64 `json:",omitempty"` // ...nor those in progress at the snapshot.
+	TakenAt time.Time
+}
+
+func (p SnapshotPosition) String() string {
+	if p.LogFile != "" {
+		return fmt.Sprintf("binlog position %s:%d", p.LogFile, p.LogPos)
+	}
+	return fmt.Sprintf("txid snapshot %d:%d (LSN %s)", p.Xmin, p.Xmax, p.Lsn)
+}
+
+// ChangeSource is where a change event comes from in the change log. Its
+// fields are zero if the event doesn't have them.
+type ChangeSource struct {
+	LogFile string // MySQL binlog file.
+	LogPos  int64  // MySQL binlog position of the event.
+	TxId    uint64 // PostgreSQL transaction id, of 32 or 64 bits.
+}
+
+// Includes returns true if the change is already in the snapshot, i.e. if
+// it must be skipped when the changes are applied after the snapshot.
+func (p SnapshotPosition) Includes(s ChangeSource) bool {
+	switch {
+	case p.LogFile != "" && s.LogFile != "":
+		if c := compareBinlogFiles(s.LogFile, p.LogFile); c != 0 {
+			return c < 0
+		}
+		return s.LogPos < p.LogPos
+	case p.Xmax != 0 && s.TxId != 0:
+		txid := s.TxId
+		// Change events can have 32-bit transaction ids, which wrap around:
+		// they are in the epoch of the snapshot, or the previous one for
+		// the ids much greater than Xmax.
+		if txid < 1<<32 && p.Xmax >= 1<<32 {
+			txid |= p.Xmax >> 32 << 32
+			if txid > p.Xmax+1<<31 {
+				txid -= 1 << 32
+			}
+		}
+		if txid < p.Xmin {
+			return true
+		}
+		if txid >= p.Xmax {
+			return false
+		}
+		for _, x := range p.Xip {
+			if x == txid {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compareBinlogFiles compares binlog files by their sequence number, e.g.
+// mysql-bin.000009 < mysql-bin.000010.
+func compareBinlogFiles(a, b string) int {
+	na, errA := strconv.ParseInt(a[strings.LastIndex(a, ".")+1:], 10, 64)
+	nb, errB := strconv.ParseInt(b[strings.LastIndex(b, ".")+1:], 10, 64)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	switch {
+	case na < nb:
+		return -1
+	case na > nb:
+		return 1
+	}
+	return 0
+}
+
+// LoadSnapshotPosition reads a position saved by SnapshotPosition.Save.
+func LoadSnapshotPosition(path string) (SnapshotPosition, error) {
+	var p SnapshotPosition
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return p, fmt.Errorf("can't read snapshot position %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return p, fmt.Errorf("can't parse snapshot position %s: %v", path, err)
+	}
+	return p, nil
+}
+
+// Save writes the position to path, atomically.
+func (p SnapshotPosition) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ProcessSnapshotData is like ProcessData, but the tables are read in
+// parallel by the readers of snap. Interleaved tables are read once the rows
+// of their parent tables are flushed.
+func ProcessSnapshotData(conv *internal.Conv, snap *Snapshot) {
+	var levels [][]string
+	for _, spTable := range ddl.OrderTables(conv.SpSchema) {
+		depth := 0
+		for p := conv.SpSchema[spTable].Parent; p != ""; p = conv.SpSchema[p].Parent {
+			depth++
+		}
+		for len(levels) <= depth {
+			levels = append(levels, nil)
+		}
+		levels[depth] = append(levels[depth], spTable)
+	}
+	for _, tables := range levels {
+		readers := make(chan InfoSchema, len(snap.Readers))
+		for _, r := range snap.Readers {
+			readers <- r
+		}
+		failed := false
+		wg := &sync.WaitGroup{}
+		for _, spTable := range tables {
+			snap.Lock.Lock()
+			t, ok := getTableData(conv, spTable)
+			snap.Lock.Unlock()
+			if !ok {
+				continue
+			}
+			r := <-readers
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := r.ProcessData(conv, t.srcTable, t.srcSchema, t.spTable, t.spCols, t.spSchema)
+				snap.Lock.Lock()
+				failed = failed || err != nil
+				snap.Lock.Unlock()
+				readers <- r
+			}()
+		}
+		wg.Wait()
+		if conv.DataFlush != nil {
+			conv.DataFlush()
+		}
+		if failed {
+			return
+		}
+	}
+}
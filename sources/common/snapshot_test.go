@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func TestSnapshotPositionIncludes(t *testing.T) {
+	binlog := SnapshotPosition{LogFile: "mysql-bin.000010", LogPos: 154}
+	assert.True(t, binlog.Includes(ChangeSource{LogFile: "mysql-bin.000009", LogPos: 9000}))
+	assert.True(t, binlog.Includes(ChangeSource{LogFile: "mysql-bin.000010", LogPos: 120}))
+	assert.False(t, binlog.Includes(ChangeSource{LogFile: "mysql-bin.000010", LogPos: 154}))
+	assert.False(t, binlog.Includes(ChangeSource{LogFile: "mysql-bin.1000000", LogPos: 4}))
+	assert.False(t, binlog.Includes(ChangeSource{}))
+
+	txids := SnapshotPosition{Xmin: 740, Xmax: 746, Xip: []uint64{740, 743}}
+	for txid, included := range map[uint64]bool{739: true, 740: false, 741: true, 743: false, 745: true, 746: false, 900: false} {
+		assert.Equal(t, included, txids.Includes(ChangeSource{TxId: txid}), txid)
+	}
+	// 32-bit transaction ids are compared in the epoch of the snapshot.
+	epoch := SnapshotPosition{Xmin: 1<<32 + 10, Xmax: 1<<32 + 20}
+	assert.True(t, epoch.Includes(ChangeSource{TxId: 5}))
+	assert.False(t, epoch.Includes(ChangeSource{TxId: 30}))
+	assert.True(t, epoch.Includes(ChangeSource{TxId: 1<<32 - 5}))
+}
+
+func TestSnapshotPositionSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "position.json")
+	_, err := LoadSnapshotPosition(path)
+	assert.NotNil(t, err)
+	pos := SnapshotPosition{Driver: "postgres", Lsn: "0/16B3748", Xmin: 740, Xmax: 746, Xip: []uint64{743}, TakenAt: time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)}
+	assert.Nil(t, pos.Save(path))
+	loaded, err := LoadSnapshotPosition(path)
+	assert.Nil(t, err)
+	assert.Equal(t, pos, loaded)
+}
+
+// fakeSnapshotReader records the tables it processes.
+type fakeSnapshotReader struct {
+	InfoSchema
+	mu     *sync.Mutex
+	events *[]string
+}
+
+func (r fakeSnapshotReader) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*r.events = append(*r.events, spTable)
+	return nil
+}
+
+func TestProcessSnapshotData(t *testing.T) {
+	conv := internal.MakeConv()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		conv.SrcSchema[name] = schema.Table{Name: name, ColNames: []string{"id"}}
+		conv.SpSchema[name] = ddl.CreateTable{Name: name, ColNames: []string{"id"}}
+		conv.ToSpanner[name] = internal.NameAndCols{Name: name, Cols: map[string]string{"id": "id"}}
+		conv.ToSource[name] = internal.NameAndCols{Name: name, Cols: map[string]string{"id": "id"}}
+	}
+	// c is interleaved in a, and d in c.
+	c := conv.SpSchema["c"]
+	c.Parent = "a"
+	conv.SpSchema["c"] = c
+	d := conv.SpSchema["d"]
+	d.Parent = "c"
+	conv.SpSchema["d"] = d
+
+	var events []string
+	lock := &sync.Mutex{}
+	conv.DataFlush = func() { events = append(events, "flush") }
+	var readers []InfoSchema
+	for i := 0; i < 2; i++ {
+		readers = append(readers, fakeSnapshotReader{mu: lock, events: &events})
+	}
+	ProcessSnapshotData(conv, NewSnapshot(SnapshotPosition{}, readers, lock, nil))
+	// Tables of the same level are read in any order.
+	assert.Equal(t, 7, len(events))
+	assert.ElementsMatch(t, []string{"a", "b"}, events[:2])
+	assert.Equal(t, []string{"flush", "c", "flush", "d", "flush"}, events[2:])
+}
@@ -32,8 +32,10 @@ package common
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
 
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
@@ -47,6 +49,13 @@ type ToDdl interface {
 	ToSpannerType(conv *internal.Conv, spType string, srcType schema.Type) (ddl.Type, []internal.SchemaIssue)
 }
 
+// ExprToDdl is implemented by sources that can translate the expressions of
+// computed columns. ToSpannerExpr returns the expression for a column of
+// Spanner type ty in srcTable, or an error if it can't be translated.
+type ExprToDdl interface {
+	ToSpannerExpr(conv *internal.Conv, srcTable string, expr string, ty ddl.Type) (string, error)
+}
+
 // SchemaToSpannerDDL performs schema conversion from the source DB schema to
 // Spanner. It uses the source schema in conv.SrcSchema, and writes
 // the Spanner schema to conv.SpSchema.
@@ -54,6 +63,7 @@ func SchemaToSpannerDDL(conv *internal.Conv, toddl ToDdl) error {
 	for _, srcTable := range conv.SrcSchema {
 		SchemaToSpannerDDLHelper(conv, toddl, srcTable, false)
 	}
+	cvtSequences(conv)
 	internal.ResolveRefs(conv)
 	return nil
 }
@@ -65,6 +75,7 @@ func SchemaToSpannerDDLHelper(conv *internal.Conv, toddl ToDdl, srcTable schema.
 		return err
 	}
 	var spColNames []string
+	var checks []ddl.CheckConstraint
 	spColDef := make(map[string]ddl.ColumnDef)
 	conv.Issues[srcTable.Name] = make(map[string][]internal.SchemaIssue)
 	// Iterate over columns using ColNames order.
@@ -77,6 +88,45 @@ func SchemaToSpannerDDLHelper(conv *internal.Conv, toddl ToDdl, srcTable schema.
 		}
 		spColNames = append(spColNames, colName)
 		ty, issues := toddl.ToSpannerType(conv, "", srcCol.Type)
+		if srcCol.Sample != nil && !ty.IsArray {
+			ty, issues = sampledType(conv, toddl, srcCol, ty, issues)
+		}
+		if conv.Profiles != nil {
+			ty, issues = narrowType(conv, srcTable.Name, srcCol, ty, issues)
+		}
+		rule := conv.TypeMapping.Match(srcTable.Name, srcCol.Name, srcCol.Type)
+		if rule != nil {
+			ty, issues = overrideType(conv, toddl, srcTable.Name, srcCol, rule, ty, issues)
+		}
+		notNull := srcCol.NotNull
+		if !notNull && neverNull(conv, srcTable.Name, srcCol.Name) {
+			notNull, issues = true, append(issues, internal.ProfileNotNull)
+		}
+		// The PostgreSQL dialect maps arrays to strings, which can't be
+		// checked like their elements.
+		isArray := ty.IsArray || len(srcCol.Type.ArrayBounds) > 0
+		if len(srcCol.EnumValues) > 0 && ty.Name == ddl.String && !isArray {
+			checks = append(checks, ddl.CheckConstraint{
+				Name: internal.ToSpannerCheckName(conv, fmt.Sprintf("CK_%s_%s", spTableName, colName)),
+				Expr: enumCheckExpr(conv.TargetDb, colName, srcCol.EnumValues),
+			})
+		}
+		// Checks refer to the source column name, so they can't be kept if
+		// the column is renamed or mapped to a different type. They are
+		// PostgreSQL expressions, which aren't translated to GoogleSQL.
+		if len(srcCol.Checks) > 0 && colName == srcCol.Name && rule == nil && !isArray {
+			if conv.TargetDb == constants.TargetExperimentalPostgres {
+				for _, expr := range srcCol.Checks {
+					checks = append(checks, ddl.CheckConstraint{
+						Name: internal.ToSpannerCheckName(conv, fmt.Sprintf("CK_%s_%s", spTableName, colName)),
+						Expr: expr,
+					})
+				}
+				issues = append(issues, internal.SourceCheck)
+			} else {
+				issues = append(issues, internal.SourceCheckDropped)
+			}
+		}
 		// TODO(hengfeng): add issues for all elements of srcCol.Ignored.
 		if srcCol.Ignored.ForeignKey {
 			issues = append(issues, internal.ForeignKey)
@@ -91,14 +141,27 @@ func SchemaToSpannerDDLHelper(conv *internal.Conv, toddl ToDdl, srcTable schema.
 		if srcCol.Ignored.AutoIncrement { //TODO(adibh) - check why this is not there in postgres
 			issues = append(issues, internal.AutoIncrement)
 		}
+		// Sources that infer their schema from sampled data record the
+		// types observed for columns whose type varies.
+		if len(srcCol.ObservedTypes) > 1 && !hasIssue(issues, internal.MixedType) {
+			issues = append(issues, internal.MixedType)
+		}
+		if srcCol.Identity != nil {
+			issues = append(issues, internal.Identity)
+		}
+		var generated string
+		if srcCol.Generated != "" {
+			generated, issues = generatedExpr(conv, toddl, srcTable.Name, srcCol, ty, issues)
+		}
 		if len(issues) > 0 {
 			conv.Issues[srcTable.Name][srcCol.Name] = issues
 		}
 		spColDef[colName] = ddl.ColumnDef{
-			Name:    colName,
-			T:       ty,
-			NotNull: srcCol.NotNull,
-			Comment: "From: " + quoteIfNeeded(srcCol.Name) + " " + srcCol.Type.Print(),
+			Name:      colName,
+			T:         ty,
+			NotNull:   notNull,
+			Comment:   "From: " + quoteIfNeeded(srcCol.Name) + " " + srcCol.Type.Print(),
+			Generated: generated,
 		}
 	}
 	comment := "Spanner schema for source table " + quoteIfNeeded(srcTable.Name)
@@ -108,11 +171,98 @@ func SchemaToSpannerDDLHelper(conv *internal.Conv, toddl ToDdl, srcTable schema.
 		ColDefs:  spColDef,
 		Pks:      cvtPrimaryKeys(conv, srcTable.Name, srcTable.PrimaryKeys),
 		Fks:      cvtForeignKeys(conv, spTableName, srcTable.Name, srcTable.ForeignKeys, isRestore),
+		Checks:   checks,
 		Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes),
 		Comment:  comment}
 	return nil
 }
 
+// generatedExpr returns the Spanner expression of computed column srcCol,
+// whose Spanner type is ty. If the expression can't be translated, the
+// column is kept as a regular column (holding the computed values) and an
+// empty expression is returned.
+func generatedExpr(conv *internal.Conv, toddl ToDdl, srcTable string, srcCol schema.Column, ty ddl.Type, issues []internal.SchemaIssue) (string, []internal.SchemaIssue) {
+	etd, ok := toddl.(ExprToDdl)
+	if !ok || conv.TargetDb == constants.TargetExperimentalPostgres {
+		return "", append(issues, internal.ComputedNotTranslated)
+	}
+	expr, err := etd.ToSpannerExpr(conv, srcTable, srcCol.Generated, ty)
+	if err != nil {
+		return "", append(issues, internal.ComputedNotTranslated)
+	}
+	return expr, append(issues, internal.ComputedColumn)
+}
+
+// hasIssue returns true if issues contains issue.
+func hasIssue(issues []internal.SchemaIssue, issue internal.SchemaIssue) bool {
+	for _, i := range issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
+// overrideType applies a type mapping rule to a column whose default Spanner
+// type is ty. The Spanner type of the rule must be one of the alternatives
+// the source allows for the column's type (those ToSpannerType returns when
+// asked for it), or an exact narrowing (see isExactNarrowing). Otherwise the
+// default type is kept. Both cases are recorded for the report.
+func overrideType(conv *internal.Conv, toddl ToDdl, srcTable string, srcCol schema.Column, rule *internal.TypeMappingRule, ty ddl.Type, issues []internal.SchemaIssue) (ddl.Type, []internal.SchemaIssue) {
+	want := rule.SpannerType()
+	if conv.TypeOverrides == nil {
+		conv.TypeOverrides = make(map[string]map[string]internal.TypeOverride)
+	}
+	if conv.TypeOverrides[srcTable] == nil {
+		conv.TypeOverrides[srcTable] = make(map[string]internal.TypeOverride)
+	}
+	conv.TypeOverrides[srcTable][srcCol.Name] = internal.TypeOverride{Rule: rule.String(), Type: want.PrintColumnDefType()}
+	alt, altIssues := toddl.ToSpannerType(conv, want.Name, srcCol.Type)
+	switch {
+	case alt.Name == want.Name:
+		ty, issues = alt, altIssues
+	case isExactNarrowing(ty, want, srcCol.Type):
+		ty, issues = ddl.Type{Name: want.Name, IsArray: ty.IsArray}, nil
+	default:
+		return ty, append(issues, internal.TypeOverrideRejected)
+	}
+	if want.Name == ddl.String || want.Name == ddl.Bytes {
+		ty.Len = want.Len
+	}
+	return ty, append(issues, internal.TypeOverridden)
+}
+
+// isExactNarrowing returns true if all values of a source type mapped by
+// default to Spanner type ty can be stored without loss in Spanner type
+// want. This is the case for numerics with a scale of 0 and a precision of
+// at most 18, which always fit in an INT64.
+func isExactNarrowing(ty, want ddl.Type, srcType schema.Type) bool {
+	if ty.Name == ddl.Numeric && want.Name == ddl.Int64 {
+		mods := srcType.Mods
+		return len(mods) > 0 && mods[0] <= 18 && (len(mods) < 2 || mods[1] == 0)
+	}
+	return false
+}
+
+// enumCheckExpr returns the expression of a CHECK constraint restricting
+// column col to the values of an enum, in the syntax of targetDb.
+func enumCheckExpr(targetDb, col string, vals []string) string {
+	var l []string
+	for _, v := range vals {
+		if targetDb == constants.TargetExperimentalPostgres {
+			l = append(l, "'"+strings.ReplaceAll(v, "'", "''")+"'")
+		} else {
+			l = append(l, "'"+strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(v)+"'")
+		}
+	}
+	if targetDb == constants.TargetExperimentalPostgres {
+		col = `"` + strings.ReplaceAll(col, `"`, `""`) + `"`
+	} else {
+		col = "`" + col + "`"
+	}
+	return fmt.Sprintf("%s IN (%s)", col, strings.Join(l, ", "))
+}
+
 func quoteIfNeeded(s string) string {
 	for _, r := range s {
 		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) {
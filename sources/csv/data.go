@@ -15,15 +15,16 @@
 package csv
 
 import (
-	csvReader "encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
-	"os"
+	"math/bits"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -32,16 +33,31 @@ import (
 	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 )
 
 // GetCSVFiles finds the appropriate files paths and downloads gcs files in any.
+// If conv doesn't have a schema yet (i.e. the schema is going to be inferred
+// from the CSV files), tables aren't checked against it.
 func GetCSVFiles(conv *internal.Conv, sourceProfile profiles.SourceProfile) (tables []utils.ManifestTable, err error) {
 	// If manifest file not provided, we assume the csvs exist in the same directory
 	// in table_name.csv format.
-	if sourceProfile.Csv.Manifest == "" {
+	if sourceProfile.Csv.Manifest == "" && len(conv.SrcSchema) == 0 {
+		fmt.Println("Manifest file not provided, using files named `[table_name].csv` in current working directory...")
+		files, err := filepath.Glob("*.csv")
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no csv files found in current working directory")
+		}
+		for _, f := range files {
+			tables = append(tables, utils.ManifestTable{Table_name: strings.TrimSuffix(f, ".csv"), File_patterns: []string{f}})
+		}
+	} else if sourceProfile.Csv.Manifest == "" {
 		fmt.Println("Manifest file not provided, checking for files named `[table_name].csv` in current working directory...")
-		for t := range conv.SpSchema {
+		for t := range conv.SrcSchema {
 			tables = append(tables, utils.ManifestTable{Table_name: t, File_patterns: []string{fmt.Sprintf("%s.csv", t)}})
 		}
 	} else {
@@ -53,7 +69,11 @@ func GetCSVFiles(conv *internal.Conv, sourceProfile profiles.SourceProfile) (tab
 		}
 	}
 
-	// Download gcs files if any.
+	// Expand glob patterns and download gcs files if any.
+	tables, err = utils.ExpandFilePatterns(tables)
+	if err != nil {
+		return nil, err
+	}
 	tables, err = utils.PreloadGCSFiles(tables)
 	if err != nil {
 		return nil, fmt.Errorf("gcs file download error: %v", err)
@@ -73,6 +93,12 @@ func loadManifest(conv *internal.Conv, manifestFile string) ([]utils.ManifestTab
 	if err != nil {
 		return nil, fmt.Errorf("unable to unmarshall json due to: %v", err)
 	}
+	if len(conv.SrcSchema) == 0 {
+		if len(tables) == 0 {
+			return nil, fmt.Errorf("manifest is incomplete: no tables found")
+		}
+		return tables, nil
+	}
 	err = VerifyManifest(conv, tables)
 	if err != nil {
 		return nil, fmt.Errorf("manifest is incomplete: %v", err)
@@ -114,6 +140,9 @@ func VerifyManifest(conv *internal.Conv, tables []utils.ManifestTable) error {
 		if len(table.File_patterns) == 0 {
 			return fmt.Errorf("no file path provided for table %s", name)
 		}
+		if _, err := getFileFormat(table, "", ','); err != nil {
+			return fmt.Errorf("invalid settings for table %s: %v", name, err)
+		}
 	}
 	return nil
 }
@@ -121,14 +150,12 @@ func VerifyManifest(conv *internal.Conv, tables []utils.ManifestTable) error {
 // SetRowStats calculates the number of rows per table.
 func SetRowStats(conv *internal.Conv, tables []utils.ManifestTable, delimiter rune) error {
 	for _, table := range tables {
+		ff, err := getFileFormat(table, "", delimiter)
+		if err != nil {
+			return fmt.Errorf("invalid manifest entry for table %s: %v", table.Table_name, err)
+		}
 		for _, filePath := range table.File_patterns {
-			csvFile, err := os.Open(filePath)
-			if err != nil {
-				return fmt.Errorf("can't read csv file: %s due to: %v", filePath, err)
-			}
-			r := csvReader.NewReader(csvFile)
-			r.Comma = delimiter
-			count, err := getCSVDataRowCount(r, conv.SpSchema[table.Table_name].ColNames)
+			count, err := getCSVDataRowCount(filePath, ff, getSrcColNames(conv, table.Table_name))
 			if err != nil {
 				return fmt.Errorf("error reading file %s for table %s: %v", filePath, table.Table_name, err)
 			}
@@ -142,30 +169,43 @@ func SetRowStats(conv *internal.Conv, tables []utils.ManifestTable, delimiter ru
 	return nil
 }
 
-// getCSVDataRowCount returns the number of data rows in the CSV file. This excludes the headers if present.
-func getCSVDataRowCount(r *csvReader.Reader, colNames []string) (int64, error) {
-	count := int64(0)
-	srcCols, err := r.Read()
-	if err == io.EOF {
-		return count, nil
-	}
+// CountRows returns the number of data rows across the CSV files of a table.
+// colNames are the columns of the table in schema order.
+func CountRows(table utils.ManifestTable, delimiter rune, colNames []string) (int64, error) {
+	ff, err := getFileFormat(table, "", delimiter)
 	if err != nil {
-		return count, fmt.Errorf("can't read csv headers for col names due to: %v", err)
+		return 0, fmt.Errorf("invalid manifest entry for table %s: %v", table.Table_name, err)
 	}
-	if len(srcCols) != len(colNames) {
-		return 0, fmt.Errorf("found %d columns in csv, expected %d as per Spanner schema", len(srcCols), len(colNames))
+	var count int64
+	for _, filePath := range table.File_patterns {
+		n, err := getCSVDataRowCount(filePath, ff, colNames)
+		if err != nil {
+			return 0, fmt.Errorf("error reading file %s for table %s: %v", filePath, table.Table_name, err)
+		}
+		count += n
+	}
+	return count, nil
+}
+
+// getCSVDataRowCount returns the number of data rows in the CSV file. This excludes the headers if present.
+func getCSVDataRowCount(filePath string, ff fileFormat, colNames []string) (int64, error) {
+	cf, err := openCSV(filePath, ff, colNames)
+	if err != nil {
+		return 0, err
 	}
-	// If the row read was not a header, increase count.
-	if !utils.CheckEqualSets(srcCols, colNames) {
-		count += 1
+	defer cf.Close()
+	count := int64(0)
+	// If the first row read was not a header, count it.
+	if cf.firstRow != nil {
+		count++
 	}
 	for {
-		_, err := r.Read()
+		_, err := cf.Read()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return 0, fmt.Errorf("can't read row")
+			return 0, fmt.Errorf("can't read row: %v", err)
 		}
 		count++
 	}
@@ -175,91 +215,130 @@ func getCSVDataRowCount(r *csvReader.Reader, colNames []string) (int64, error) {
 // ProcessCSV writes data across the tables provided in the manifest file. Each table's data can be provided
 // across multiple CSV files hence, the manifest accepts a list of file paths in the input.
 func ProcessCSV(conv *internal.Conv, tables []utils.ManifestTable, nullStr string, delimiter rune) error {
-	orderedTableNames := ddl.OrderTables(conv.SpSchema)
-	nameToFiles := map[string][]string{}
+	nameToTable := map[string]utils.ManifestTable{}
 	for _, table := range tables {
-		nameToFiles[table.Table_name] = table.File_patterns
+		nameToTable[table.Table_name] = table
 	}
-	orderedTables := []utils.ManifestTable{}
-	for _, name := range orderedTableNames {
-		orderedTables = append(orderedTables, utils.ManifestTable{name, nameToFiles[name]})
+	for _, spTable := range ddl.OrderTables(conv.SpSchema) {
+		name := getSrcTable(conv, spTable)
+		table, ok := nameToTable[name]
+		if !ok {
+			table = utils.ManifestTable{Table_name: name}
+		}
+		if err := ProcessTable(conv, table, nullStr, delimiter, nil); err != nil {
+			return err
+		}
+		if conv.DataFlush != nil {
+			conv.DataFlush()
+		}
 	}
+	return nil
+}
 
-	for _, table := range orderedTables {
-		for _, filePath := range table.File_patterns {
-			csvFile, err := os.Open(filePath)
-			if err != nil {
-				return fmt.Errorf(fmt.Sprintf("can't read csv file: %s due to: %v\n", filePath, err))
-			}
-			r := csvReader.NewReader(csvFile)
-			r.Comma = delimiter
+// Normalizer rewrites a non-null value of a source column into the format
+// expected by the CSV conversion code. Sources whose data is exported as CSV
+// use it for value formats that differ from the ones documented for CSV files.
+type Normalizer func(srcCol, val string) (string, error)
 
-			// Default column order is same as in Spanner schema.
-			colNames := conv.SpSchema[table.Table_name].ColNames
-			srcCols, err := r.Read()
-			if err == io.EOF {
-				conv.Unexpected(fmt.Sprintf("error processing table %s: file %s is empty.", table.Table_name, filePath))
-				continue
-			}
-			if err != nil {
-				return fmt.Errorf("can't read row for %s due to: %v", filePath, err)
-			}
-			// If first row is some permutation of Spanner schema columns, we assume the first row is headers.
-			if utils.CheckEqualSets(srcCols, colNames) {
-				colNames = srcCols
-			} else {
-				// Write the first row since it was not a column header.
-				processDataRow(conv, nullStr, table.Table_name, colNames, srcCols)
-			}
+// ProcessTable writes the data of all the CSV files of a table. Files are
+// read and converted in parallel, and writes to conv are serialized.
+// normalize can be nil.
+func ProcessTable(conv *internal.Conv, table utils.ManifestTable, nullStr string, delimiter rune, normalize Normalizer) error {
+	ff, err := getFileFormat(table, nullStr, delimiter)
+	if err != nil {
+		return fmt.Errorf("invalid manifest entry for table %s: %v", table.Table_name, err)
+	}
+	ff.normalize = normalize
+	colNames := getSrcColNames(conv, table.Table_name)
+	task := func(filePath string, mutex *sync.Mutex) common.TaskResult[string] {
+		return common.TaskResult[string]{Result: filePath, Err: processFile(conv, ff, table.Table_name, filePath, colNames, mutex)}
+	}
+	numWorkers := len(table.File_patterns)
+	if numWorkers > common.DefaultWorkers {
+		numWorkers = common.DefaultWorkers
+	}
+	_, err = common.RunParallelTasks(table.File_patterns, numWorkers, task, true)
+	return err
+}
 
-			for {
-				values, err := r.Read()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					return fmt.Errorf("can't read row for %s due to: %v", filePath, err)
-				}
-				processDataRow(conv, nullStr, table.Table_name, colNames, values)
-			}
+// processFile writes the data of a CSV file. The mutex guards conv.
+func processFile(conv *internal.Conv, ff fileFormat, tableName, filePath string, colNames []string, mutex *sync.Mutex) error {
+	cf, err := openCSV(filePath, ff, colNames)
+	if err != nil {
+		return err
+	}
+	defer cf.Close()
+	if cf.cols == nil {
+		mutex.Lock()
+		conv.Unexpected(fmt.Sprintf("error processing table %s: file %s is empty.", tableName, filePath))
+		mutex.Unlock()
+		return nil
+	}
+	if cf.firstRow != nil {
+		// Write the first row since it was not a column header.
+		processDataRow(conv, ff, tableName, cf.cols, cf.firstRow, mutex)
+	}
+	for {
+		values, err := cf.Read()
+		if err == io.EOF {
+			break
 		}
-		if conv.DataFlush != nil {
-			conv.DataFlush()
+		if err != nil {
+			return fmt.Errorf("can't read row for %s due to: %v", filePath, err)
 		}
+		processDataRow(conv, ff, tableName, cf.cols, values, mutex)
 	}
 	return nil
 }
 
 // processDataRow converts a row into go data types as per the client libs.
-func processDataRow(conv *internal.Conv, nullStr, tableName string, srcCols []string, values []string) {
-	// Pass nullStr from source-profile.
-	cvtCols, cvtVals, err := convertData(conv, nullStr, tableName, srcCols, values)
+func processDataRow(conv *internal.Conv, ff fileFormat, tableName string, srcCols []string, values []string, mutex *sync.Mutex) {
+	cvtCols, cvtVals, err := convertData(conv, ff, tableName, srcCols, values)
+	mutex.Lock()
+	defer mutex.Unlock()
 	if err != nil {
 		conv.Unexpected(fmt.Sprintf("Error while converting data: %s\n", err))
 		conv.StatsAddBadRow(tableName, conv.DataMode())
 		conv.CollectBadRow(tableName, srcCols, values)
-	} else {
-		conv.WriteRow(tableName, tableName, cvtCols, cvtVals)
+		return
+	}
+	spTable := getSpTable(conv, tableName)
+	if aux, ok := conv.SyntheticPKeys[spTable]; ok {
+		cvtCols = append(cvtCols, aux.Col)
+		cvtVals = append(cvtVals, fmt.Sprintf("%d", int64(bits.Reverse64(uint64(aux.Sequence)))))
+		aux.Sequence++
+		conv.SyntheticPKeys[spTable] = aux
 	}
+	conv.WriteRow(tableName, spTable, cvtCols, cvtVals)
 }
 
-// convertData currently only supports scalar data types.
-func convertData(conv *internal.Conv, nullStr, tableName string, srcCols []string, values []string) ([]string, []interface{}, error) {
+// convertData currently only supports scalar data types. Fields whose
+// column is "" are skipped.
+func convertData(conv *internal.Conv, ff fileFormat, tableName string, srcCols []string, values []string) ([]string, []interface{}, error) {
+	if len(values) != len(srcCols) {
+		return nil, nil, fmt.Errorf("found %d values, expected %d", len(values), len(srcCols))
+	}
 	var v []interface{}
 	var cvtCols []string
-	colDefs := conv.SpSchema[tableName].ColDefs
+	spTable := getSpTable(conv, tableName)
+	colDefs := conv.SpSchema[spTable].ColDefs
 	for i, val := range values {
-		if val == nullStr {
+		if val == ff.nullStr || srcCols[i] == "" {
 			continue
 		}
-		colName := srcCols[i]
+		colName := getSpCol(conv, tableName, srcCols[i])
 		spColDef := colDefs[colName]
 		var x interface{}
 		var err error
+		if ff.normalize != nil {
+			if val, err = ff.normalize(srcCols[i], val); err != nil {
+				return nil, nil, err
+			}
+		}
 		if spColDef.T.IsArray {
-			x, err = convArray(spColDef.T, val)
+			x, err = convArray(ff, spColDef.T, val)
 		} else {
-			x, err = convScalar(conv, spColDef.T, val)
+			x, err = convScalar(conv, ff, spColDef.T, val)
 		}
 		if err != nil {
 			return nil, nil, err
@@ -270,7 +349,46 @@ func convertData(conv *internal.Conv, nullStr, tableName string, srcCols []strin
 	return cvtCols, v, nil
 }
 
-func convArray(spannerType ddl.Type, val string) (interface{}, error) {
+// When the schema is read from the target database, CSV files use the
+// Spanner table and column names. When it is inferred from the CSV files,
+// names that aren't legal in Spanner are changed, so we look them up in
+// conv's mappings. The helpers below fall back to using the same name.
+
+// getSpTable returns the Spanner table for a CSV table.
+func getSpTable(conv *internal.Conv, srcTable string) string {
+	if sp, ok := conv.ToSpanner[srcTable]; ok {
+		return sp.Name
+	}
+	return srcTable
+}
+
+// getSrcTable returns the CSV table for a Spanner table.
+func getSrcTable(conv *internal.Conv, spTable string) string {
+	if src, ok := conv.ToSource[spTable]; ok {
+		return src.Name
+	}
+	return spTable
+}
+
+// getSpCol returns the Spanner column for a column of a CSV table.
+func getSpCol(conv *internal.Conv, srcTable, srcCol string) string {
+	if sp, ok := conv.ToSpanner[srcTable]; ok {
+		if spCol, ok := sp.Cols[srcCol]; ok {
+			return spCol
+		}
+	}
+	return srcCol
+}
+
+// getSrcColNames returns the columns expected in the CSV files of a table.
+func getSrcColNames(conv *internal.Conv, srcTable string) []string {
+	if src, ok := conv.SrcSchema[srcTable]; ok {
+		return src.ColNames
+	}
+	return conv.SpSchema[getSpTable(conv, srcTable)].ColNames
+}
+
+func convArray(ff fileFormat, spannerType ddl.Type, val string) (interface{}, error) {
 	val = strings.TrimSpace(val)
 	// Handle empty array. Note that we use an empty NullString array
 	// for all Spanner array types since this will be converted to the
@@ -282,7 +400,7 @@ func convArray(spannerType ddl.Type, val string) (interface{}, error) {
 	if braces != "{}" && braces != "[]" {
 		return []interface{}{}, fmt.Errorf("unrecognized data format for array: expected {v1, v2, ...} or [v1, v2, ...]")
 	}
-	a := strings.Split(val[1:len(val)-1], ",")
+	a := splitArray(val[1 : len(val)-1])
 
 	// The Spanner client for go does not accept []interface{} for arrays.
 	// Instead it only accepts slices of a specific type e.g. []int64, []string.
@@ -335,7 +453,7 @@ func convArray(spannerType ddl.Type, val string) (interface{}, error) {
 			if err != nil {
 				return []spanner.NullDate{}, err
 			}
-			date, err := convDate(s)
+			date, err := convDate(s, ff.dateFormat)
 			if err != nil {
 				return []spanner.NullDate{}, err
 			}
@@ -421,7 +539,7 @@ func convArray(spannerType ddl.Type, val string) (interface{}, error) {
 			if err != nil {
 				return []spanner.NullTime{}, err
 			}
-			t, err := convTimestamp(s)
+			t, err := convTimestamp(s, ff.timestampFormat)
 			if err != nil {
 				return []spanner.NullTime{}, err
 			}
@@ -432,14 +550,14 @@ func convArray(spannerType ddl.Type, val string) (interface{}, error) {
 	return []interface{}{}, fmt.Errorf("array type conversion not implemented for type []%v", spannerType.Name)
 }
 
-func convScalar(conv *internal.Conv, spannerType ddl.Type, val string) (interface{}, error) {
+func convScalar(conv *internal.Conv, ff fileFormat, spannerType ddl.Type, val string) (interface{}, error) {
 	switch spannerType.Name {
 	case ddl.Bool:
 		return convBool(val)
 	case ddl.Bytes:
 		return convBytes(val)
 	case ddl.Date:
-		return convDate(val)
+		return convDate(val, ff.dateFormat)
 	case ddl.Float64:
 		return convFloat64(val)
 	case ddl.Int64:
@@ -452,7 +570,7 @@ func convScalar(conv *internal.Conv, spannerType ddl.Type, val string) (interfac
 	case ddl.String:
 		return val, nil
 	case ddl.Timestamp:
-		return convTimestamp(val)
+		return convTimestamp(val, ff.timestampFormat)
 	case ddl.JSON:
 		return val, nil
 	default:
@@ -474,7 +592,16 @@ func convBytes(val string) ([]byte, error) {
 	return b, nil
 }
 
-func convDate(val string) (civil.Date, error) {
+// convDate parses a date using layout, a Go time layout such as
+// "02/01/2006". If layout is empty, dates must be in YYYY-MM-DD format.
+func convDate(val, layout string) (civil.Date, error) {
+	if layout != "" {
+		t, err := time.Parse(layout, val)
+		if err != nil {
+			return civil.Date{}, fmt.Errorf("can't convert to date: %w", err)
+		}
+		return civil.DateOf(t), nil
+	}
 	d, err := civil.ParseDate(val)
 	if err != nil {
 		return d, fmt.Errorf("can't convert to date: %w", err)
@@ -508,14 +635,50 @@ func convNumeric(val string) (big.Rat, error) {
 	return *r, nil
 }
 
-func convTimestamp(val string) (t time.Time, err error) {
+// convTimestamp parses a timestamp using layout, a Go time layout. If layout
+// is empty, "2006-01-02 15:04:05" and RFC 3339 timestamps are accepted.
+func convTimestamp(val, layout string) (t time.Time, err error) {
+	if layout != "" {
+		t, err = time.Parse(layout, val)
+		if err != nil {
+			return t, fmt.Errorf("can't convert to timestamp: %s", val)
+		}
+		return t, nil
+	}
 	t, err = time.Parse("2006-01-02 15:04:05", val)
+	if err != nil {
+		// Also accept ISO 8601 timestamps e.g. 2006-01-02T15:04:05.999Z.
+		t, err = time.Parse(time.RFC3339Nano, val)
+	}
 	if err != nil {
 		return t, fmt.Errorf("can't convert to timestamp: %s", val)
 	}
 	return t, err
 }
 
+// splitArray splits the elements of an array on commas, except for commas
+// within double quoted elements.
+func splitArray(s string) []string {
+	var a []string
+	start, inQuotes := 0, false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if inQuotes {
+				i++
+			}
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				a = append(a, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(a, s[start:])
+}
+
 func processQuote(s string) (string, error) {
 	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
 		return strconv.Unquote(s)
@@ -163,7 +163,7 @@ func TestConvertData(t *testing.T) {
 		conv := buildConv([]ddl.CreateTable{{
 			Name:    tableName,
 			ColDefs: map[string]ddl.ColumnDef{col: ddl.ColumnDef{Name: col, T: tc.ty}}}})
-		_, av, err := convertData(conv, "", tableName, []string{col}, []string{tc.in})
+		_, av, err := convertData(conv, defaultFileFormat("", ','), tableName, []string{col}, []string{tc.in})
 		// NULL scenario.
 		if tc.ev == nil {
 			var empty []interface{}
@@ -202,7 +202,7 @@ func TestConvertData(t *testing.T) {
 	}
 	for _, tc := range errorTests {
 		conv := buildConv([]ddl.CreateTable{spTable})
-		_, _, err := convertData(conv, "", tableName, cols, tc.vals)
+		_, _, err := convertData(conv, defaultFileFormat("", ','), tableName, cols, tc.vals)
 		assert.NotNil(t, err, tc.name)
 	}
 }
@@ -0,0 +1,400 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Inferred column types. They use the names of the corresponding Spanner
+// types so that ToSpannerType can map them.
+const (
+	typeBool      = "BOOL"
+	typeInt64     = "INT64"
+	typeNumeric   = "NUMERIC"
+	typeFloat64   = "FLOAT64"
+	typeDate      = "DATE"
+	typeTimestamp = "TIMESTAMP"
+	typeString    = "STRING"
+)
+
+// Spanner NUMERIC has a precision of 38 and a scale of 9.
+var numericRegexp = regexp.MustCompile(`^[+-]?[0-9]{1,29}(\.[0-9]{1,9})?$`)
+
+// InfoSchemaImpl infers a schema from CSV files, for use when there is no
+// existing Spanner database to read the schema from. Each table's header
+// row provides the column names, and a sample of its rows is used to
+// choose column types, nullability and the primary key.
+type InfoSchemaImpl struct {
+	Tables    []utils.ManifestTable
+	NullStr   string
+	Delimiter rune
+	samples   map[string]tableSample
+}
+
+// tableSample holds what we learnt about a table from its sampled rows.
+type tableSample struct {
+	colNames   []string
+	colDefs    map[string]schema.Column
+	primaryKey string
+	rows       int64
+	// complete is true if the sample holds every row of the table.
+	complete bool
+	ff       fileFormat
+}
+
+// colStats accumulates facts about the sampled values of a column.
+type colStats struct {
+	nulls  int64
+	maxLen int
+	// not[t] is true once a value that can't be converted to type t has
+	// been seen.
+	not map[string]bool
+	// seen holds the values seen so far, to detect unique columns. It is
+	// set to nil once a duplicate has been seen.
+	seen map[string]bool
+}
+
+// NewInfoSchemaImpl samples up to sampleSize rows of each table and
+// infers its schema.
+func NewInfoSchemaImpl(tables []utils.ManifestTable, nullStr string, delimiter rune, sampleSize int64) (InfoSchemaImpl, error) {
+	isi := InfoSchemaImpl{Tables: tables, NullStr: nullStr, Delimiter: delimiter, samples: make(map[string]tableSample)}
+	for _, table := range tables {
+		ts, err := sampleTable(table, nullStr, delimiter, sampleSize)
+		if err != nil {
+			return isi, fmt.Errorf("can't infer schema for table %s: %v", table.Table_name, err)
+		}
+		isi.samples[table.Table_name] = ts
+	}
+	return isi, nil
+}
+
+func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
+	return ToDdlImpl{}
+}
+
+func (isi InfoSchemaImpl) GetTableName(schema string, tableName string) string {
+	return tableName
+}
+
+func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
+	var tables []common.SchemaAndName
+	for _, table := range isi.Tables {
+		tables = append(tables, common.SchemaAndName{Name: table.Table_name})
+	}
+	return tables, nil
+}
+
+func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	ts, ok := isi.samples[table.Name]
+	if !ok {
+		return nil, nil, fmt.Errorf("no sample data for table %s", table.Name)
+	}
+	return ts.colDefs, ts.colNames, nil
+}
+
+func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string) (interface{}, error) {
+	return nil, fmt.Errorf("can't get rows for table %s: csv files are only read by ProcessData", srcTable)
+}
+
+func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
+	var count int64
+	for _, t := range isi.Tables {
+		if t.Table_name != table.Name {
+			continue
+		}
+		for _, filePath := range t.File_patterns {
+			ts := isi.samples[table.Name]
+			n, err := getCSVDataRowCount(filePath, ts.ff, ts.colNames)
+			if err != nil {
+				return 0, fmt.Errorf("error reading file %s for table %s: %v", filePath, table.Name, err)
+			}
+			count += n
+		}
+	}
+	return count, nil
+}
+
+// GetConstraints returns the column chosen as primary key from the sampled
+// rows, if any. Tables without one get a synthetic primary key.
+func (isi InfoSchemaImpl) GetConstraints(conv *internal.Conv, table common.SchemaAndName) (primaryKeys []string, constraints map[string][]string, err error) {
+	if pk := isi.samples[table.Name].primaryKey; pk != "" {
+		primaryKeys = append(primaryKeys, pk)
+	}
+	return primaryKeys, constraints, nil
+}
+
+func (isi InfoSchemaImpl) GetForeignKeys(conv *internal.Conv, table common.SchemaAndName) (foreignKeys []schema.ForeignKey, err error) {
+	return foreignKeys, err
+}
+
+func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAndName) (indexes []schema.Index, err error) {
+	return indexes, err
+}
+
+// ProcessData writes the data of a table's CSV files to Spanner.
+func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	for _, t := range isi.Tables {
+		if t.Table_name == srcTable {
+			return ProcessTable(conv, t, isi.NullStr, isi.Delimiter, nil)
+		}
+	}
+	return nil
+}
+
+func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("streaming migration is not supported for csv")
+}
+
+func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamInfo map[string]interface{}) error {
+	return fmt.Errorf("streaming migration is not supported for csv")
+}
+
+// sampleTable reads the header and up to sampleSize data rows across the
+// files of a table and infers its schema. All files of a table must have
+// the same columns.
+func sampleTable(table utils.ManifestTable, nullStr string, delimiter rune, sampleSize int64) (tableSample, error) {
+	var ts tableSample
+	var stats []*colStats
+	ff, err := getFileFormat(table, nullStr, delimiter)
+	if err != nil {
+		return ts, err
+	}
+	ts.ff = ff
+	ts.complete = true
+	for _, filePath := range table.File_patterns {
+		if sampleSize > 0 && ts.rows >= sampleSize {
+			ts.complete = false
+			break
+		}
+		cf, err := openCSV(filePath, ff, nil)
+		if err != nil {
+			return ts, err
+		}
+		if cf.cols == nil {
+			cf.Close()
+			continue
+		}
+		var names []string
+		for _, col := range cf.cols {
+			if col != "" {
+				names = append(names, col)
+			}
+		}
+		if ts.colNames == nil {
+			ts.colNames = names
+			for range names {
+				stats = append(stats, &colStats{not: make(map[string]bool), seen: make(map[string]bool)})
+			}
+		} else if !utils.CheckEqualSets(names, ts.colNames) || len(names) != len(ts.colNames) {
+			cf.Close()
+			return ts, fmt.Errorf("header of %s doesn't match the header of %s", filePath, table.File_patterns[0])
+		}
+		values := cf.firstRow
+		for sampleSize <= 0 || ts.rows < sampleSize {
+			if values == nil {
+				values, err = cf.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					cf.Close()
+					return ts, fmt.Errorf("can't read row for %s due to: %v", filePath, err)
+				}
+			}
+			if len(values) != len(cf.cols) {
+				cf.Close()
+				return ts, fmt.Errorf("found %d values in a row of %s, expected %d", len(values), filePath, len(cf.cols))
+			}
+			for i, val := range values {
+				// Files can list columns in different orders.
+				if cf.cols[i] != "" {
+					stats[indexOf(ts.colNames, cf.cols[i])].add(val, ff)
+				}
+			}
+			ts.rows++
+			values = nil
+		}
+		if sampleSize > 0 && ts.rows >= sampleSize {
+			if _, err := cf.Read(); err != io.EOF {
+				ts.complete = false
+			}
+		}
+		cf.Close()
+	}
+	if ts.colNames == nil {
+		return ts, fmt.Errorf("all files are empty")
+	}
+	ts.colDefs = make(map[string]schema.Column)
+	var candidates []string
+	for i, col := range ts.colNames {
+		ty := stats[i].inferType()
+		ts.colDefs[col] = schema.Column{Name: col, Type: ty, NotNull: ts.rows > 0 && stats[i].nulls == 0}
+		if stats[i].isKeyCandidate(ts.rows) {
+			candidates = append(candidates, col)
+		}
+	}
+	ts.primaryKey = choosePrimaryKey(candidates, ts.complete)
+	if ts.primaryKey != "" {
+		pk := ts.colDefs[ts.primaryKey]
+		pk.NotNull = true
+		ts.colDefs[ts.primaryKey] = pk
+	}
+	return ts, nil
+}
+
+func (cs *colStats) add(val string, ff fileFormat) {
+	if val == ff.nullStr {
+		cs.nulls++
+		return
+	}
+	if n := utf8.RuneCountInString(val); n > cs.maxLen {
+		cs.maxLen = n
+	}
+	if cs.seen != nil {
+		if cs.seen[val] {
+			cs.seen = nil
+		} else {
+			cs.seen[val] = true
+		}
+	}
+	if !cs.not[typeBool] {
+		// Only accept true/false spelt out, so that 0/1 columns are inferred
+		// as integers.
+		if _, err := convBool(val); err != nil || len(val) < 4 {
+			cs.not[typeBool] = true
+		}
+	}
+	if !cs.not[typeInt64] {
+		if _, err := convInt64(val); err != nil {
+			cs.not[typeInt64] = true
+		}
+	}
+	if !cs.not[typeNumeric] && !numericRegexp.MatchString(val) {
+		cs.not[typeNumeric] = true
+	}
+	if !cs.not[typeFloat64] {
+		if _, err := convFloat64(val); err != nil {
+			cs.not[typeFloat64] = true
+		}
+	}
+	if !cs.not[typeDate] {
+		if _, err := convDate(val, ff.dateFormat); err != nil {
+			cs.not[typeDate] = true
+		}
+	}
+	if !cs.not[typeTimestamp] {
+		if _, err := convTimestamp(val, ff.timestampFormat); err != nil {
+			cs.not[typeTimestamp] = true
+		}
+	}
+}
+
+// inferType returns the most specific type that all sampled values can be
+// converted to. Columns with no sampled values default to STRING(MAX).
+func (cs *colStats) inferType() schema.Type {
+	if cs.maxLen == 0 {
+		return schema.Type{Name: typeString}
+	}
+	for _, t := range []string{typeBool, typeInt64, typeNumeric, typeFloat64, typeDate, typeTimestamp} {
+		if !cs.not[t] {
+			return schema.Type{Name: t}
+		}
+	}
+	return schema.Type{Name: typeString, Mods: []int64{stringLength(cs.maxLen)}}
+}
+
+// isKeyCandidate returns true if the sampled values of the column are
+// non-null and unique, and have a type that makes a sensible key.
+func (cs *colStats) isKeyCandidate(rows int64) bool {
+	if rows == 0 || cs.nulls > 0 || cs.seen == nil {
+		return false
+	}
+	switch cs.inferType().Name {
+	case typeInt64, typeString, typeDate, typeTimestamp:
+		return true
+	}
+	return false
+}
+
+// stringLength returns the length to use for a STRING column whose longest
+// sampled value has maxLen characters. We round up to the next power of two
+// to leave room for longer values in rows that were not sampled.
+func stringLength(maxLen int) int64 {
+	n := int64(16)
+	for n < int64(maxLen) {
+		n *= 2
+	}
+	if n > maxStringLength {
+		return ddl.MaxLength
+	}
+	return n
+}
+
+// maxStringLength is the largest length Spanner allows for STRING columns.
+const maxStringLength = 2621440
+
+// choosePrimaryKey picks a primary key among candidate columns, preferring
+// columns that look like an id (e.g. "id", "user_id", "UserId"). Values that
+// are unique in a sample can repeat in the rest of the table, so other
+// columns are only picked if the sample holds every row.
+func choosePrimaryKey(candidates []string, complete bool) string {
+	var sorted []string
+	for _, col := range candidates {
+		if complete || idRank(col) < 2 {
+			sorted = append(sorted, col)
+		}
+	}
+	if len(sorted) == 0 {
+		return ""
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return idRank(sorted[i]) < idRank(sorted[j])
+	})
+	return sorted[0]
+}
+
+func idRank(col string) int {
+	switch {
+	case strings.ToLower(col) == "id":
+		return 0
+	case strings.HasSuffix(strings.ToLower(col), "_id") || strings.HasSuffix(col, "Id") || strings.HasSuffix(col, "ID"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func indexOf(l []string, s string) int {
+	for i, x := range l {
+		if x == s {
+			return i
+		}
+	}
+	return -1
+}
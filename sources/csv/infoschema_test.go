@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+func writeTempCSV(t *testing.T, dir, name string, lines ...string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Could not write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestSampleTable(t *testing.T) {
+	dir := t.TempDir()
+	f1 := writeTempCSV(t, dir, "users_1.csv",
+		"name,user_id,active,score,balance,big,born,created,flag,empty",
+		"alice,1,true,1.5e3,10.25,123456789012345678901,2019-10-29,2019-10-29 05:30:00,0,",
+		"bob,2,False,2,11,1,2019-10-30,2019-10-29T05:30:00Z,1,",
+	)
+	// Columns can be in a different order across files.
+	f2 := writeTempCSV(t, dir, "users_2.csv",
+		"user_id,name,active,score,balance,big,born,created,flag,empty",
+		"3,alice,TRUE,3,,2,2019-10-31,2020-01-01 00:00:00,1,",
+	)
+	ts, err := sampleTable(utils.ManifestTable{Table_name: "users", File_patterns: []string{f1, f2}}, "", ',', 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), ts.rows)
+	assert.True(t, ts.complete)
+	assert.Equal(t, "user_id", ts.primaryKey)
+	assert.Equal(t, []string{"name", "user_id", "active", "score", "balance", "big", "born", "created", "flag", "empty"}, ts.colNames)
+	assert.Equal(t, map[string]schema.Column{
+		"name":    {Name: "name", Type: schema.Type{Name: typeString, Mods: []int64{16}}, NotNull: true},
+		"user_id": {Name: "user_id", Type: schema.Type{Name: typeInt64}, NotNull: true},
+		"active":  {Name: "active", Type: schema.Type{Name: typeBool}, NotNull: true},
+		"score":   {Name: "score", Type: schema.Type{Name: typeFloat64}, NotNull: true},
+		"balance": {Name: "balance", Type: schema.Type{Name: typeNumeric}},
+		"big":     {Name: "big", Type: schema.Type{Name: typeNumeric}, NotNull: true},
+		"born":    {Name: "born", Type: schema.Type{Name: typeDate}, NotNull: true},
+		"created": {Name: "created", Type: schema.Type{Name: typeTimestamp}, NotNull: true},
+		"flag":    {Name: "flag", Type: schema.Type{Name: typeInt64}, NotNull: true},
+		"empty":   {Name: "empty", Type: schema.Type{Name: typeString}},
+	}, ts.colDefs)
+
+	// Sampling stops after sampleSize rows.
+	ts, err = sampleTable(utils.ManifestTable{Table_name: "users", File_patterns: []string{f1, f2}}, "", ',', 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), ts.rows)
+	assert.False(t, ts.complete)
+	// The sample holds every row when the last sampled row is the last row.
+	ts, err = sampleTable(utils.ManifestTable{Table_name: "users", File_patterns: []string{f1, f2}}, "", ',', 3)
+	assert.Nil(t, err)
+	assert.True(t, ts.complete)
+
+	bad := writeTempCSV(t, dir, "users_3.csv", "user_id,other", "1,2")
+	_, err = sampleTable(utils.ManifestTable{Table_name: "users", File_patterns: []string{f1, bad}}, "", ',', 0)
+	assert.NotNil(t, err)
+}
+
+func TestStringLength(t *testing.T) {
+	assert.Equal(t, int64(16), stringLength(1))
+	assert.Equal(t, int64(16), stringLength(16))
+	assert.Equal(t, int64(32), stringLength(17))
+	assert.Equal(t, int64(2097152), stringLength(2000000))
+	assert.Equal(t, int64(ddl.MaxLength), stringLength(3000000))
+}
+
+func TestChoosePrimaryKey(t *testing.T) {
+	assert.Equal(t, "", choosePrimaryKey(nil, true))
+	assert.Equal(t, "name", choosePrimaryKey([]string{"name", "email"}, true))
+	assert.Equal(t, "SingerId", choosePrimaryKey([]string{"name", "SingerId"}, true))
+	assert.Equal(t, "ID", choosePrimaryKey([]string{"user_id", "ID"}, true))
+	// Columns unique in a partial sample are only picked if they look like
+	// an id.
+	assert.Equal(t, "", choosePrimaryKey([]string{"name", "email"}, false))
+	assert.Equal(t, "SingerId", choosePrimaryKey([]string{"name", "SingerId"}, false))
+}
+
+func TestInferSchemaAndProcessCSV(t *testing.T) {
+	dir := t.TempDir()
+	singers := writeTempCSV(t, dir, "singers.csv",
+		"SingerId,First Name",
+		"1,fn1",
+		"2,fn2",
+	)
+	// No column is unique, so the table gets a synthetic primary key.
+	plays := writeTempCSV(t, dir, "plays.csv",
+		"SingerId,count",
+		"1,10",
+		"1,10",
+	)
+	tables := []utils.ManifestTable{
+		{Table_name: "singers", File_patterns: []string{singers}},
+		{Table_name: "plays", File_patterns: []string{plays}},
+	}
+	isi, err := NewInfoSchemaImpl(tables, "", ',', 100)
+	assert.Nil(t, err)
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+
+	assert.Equal(t, []ddl.IndexKey{{Col: "SingerId"}}, conv.SpSchema["singers"].Pks)
+	assert.Equal(t, ddl.ColumnDef{Name: "First_Name", T: ddl.Type{Name: ddl.String, Len: 16}, NotNull: true},
+		dropComment(conv.SpSchema["singers"].ColDefs["First_Name"]))
+	pk, ok := conv.SyntheticPKeys["plays"]
+	assert.True(t, ok)
+
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	assert.Nil(t, SetRowStats(conv, tables, ','))
+	assert.Equal(t, map[string]int64{"singers": 2, "plays": 2}, conv.Stats.Rows)
+	assert.Nil(t, ProcessCSV(conv, tables, "", ','))
+	assert.Equal(t, []spannerData{
+		{table: "plays", cols: []string{"SingerId", "count", pk.Col}, vals: []interface{}{int64(1), int64(10), "0"}},
+		{table: "plays", cols: []string{"SingerId", "count", pk.Col}, vals: []interface{}{int64(1), int64(10), "-9223372036854775808"}},
+		{table: "singers", cols: []string{"SingerId", "First_Name"}, vals: []interface{}{int64(1), "fn1"}},
+		{table: "singers", cols: []string{"SingerId", "First_Name"}, vals: []interface{}{int64(2), "fn2"}},
+	}, rows)
+}
+
+func dropComment(cd ddl.ColumnDef) ddl.ColumnDef {
+	cd.Comment = ""
+	return cd
+}
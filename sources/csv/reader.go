@@ -0,0 +1,251 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+)
+
+// fileFormat describes how the CSV files of a table are formatted. It is
+// built from the source profile settings and the table's manifest entry.
+type fileFormat struct {
+	delimiter rune
+	quote     rune
+	escape    rune
+	nullStr   string
+	// header is nil if the header row should be detected.
+	header *bool
+	// columns lists the column of each field, "" for fields to skip. It is
+	// nil if columns are given by the header or the schema.
+	columns         []string
+	dateFormat      string
+	timestampFormat string
+	normalize       Normalizer
+}
+
+// defaultFileFormat returns the format used for tables that don't override
+// any setting in the manifest.
+func defaultFileFormat(nullStr string, delimiter rune) fileFormat {
+	return fileFormat{delimiter: delimiter, quote: '"', escape: '"', nullStr: nullStr}
+}
+
+// getFileFormat applies the settings of a manifest entry to the default
+// format.
+func getFileFormat(table utils.ManifestTable, nullStr string, delimiter rune) (fileFormat, error) {
+	ff := defaultFileFormat(nullStr, delimiter)
+	var err error
+	if ff.delimiter, err = getChar("delimiter", table.Delimiter, ff.delimiter); err != nil {
+		return ff, err
+	}
+	if ff.quote, err = getChar("quote", table.Quote, ff.quote); err != nil {
+		return ff, err
+	}
+	if ff.escape, err = getChar("escape", table.Escape, ff.quote); err != nil {
+		return ff, err
+	}
+	if ff.delimiter == ff.quote || ff.delimiter == '\n' || ff.delimiter == '\r' {
+		return ff, fmt.Errorf("invalid delimiter %q", ff.delimiter)
+	}
+	if table.Null_str != nil {
+		ff.nullStr = *table.Null_str
+	}
+	ff.header = table.Header
+	if len(table.Columns) > 0 {
+		ff.columns = table.Columns
+		if ff.header == nil {
+			no := false
+			ff.header = &no
+		}
+	}
+	ff.dateFormat = table.Date_format
+	ff.timestampFormat = table.Timestamp_format
+	return ff, nil
+}
+
+// getChar parses a single character setting, returning def if it is unset.
+func getChar(name, s string, def rune) (rune, error) {
+	if s == "" {
+		return def, nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("%s must be a single character, got %q", name, s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
+
+// reader reads records from a CSV file. Unlike encoding/csv, the quote and
+// escape characters are configurable. Empty lines are skipped.
+type reader struct {
+	r  *bufio.Reader
+	ff fileFormat
+}
+
+func newReader(r io.Reader, ff fileFormat) *reader {
+	return &reader{r: bufio.NewReader(r), ff: ff}
+}
+
+// Read returns the next record, or io.EOF if there are no more records.
+func (r *reader) Read() ([]string, error) {
+	var record []string
+	var field strings.Builder
+	inQuotes, quoted, empty := false, false, true
+	for {
+		c, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			if inQuotes {
+				return nil, fmt.Errorf("unterminated quoted field")
+			}
+			if empty {
+				return nil, io.EOF
+			}
+			return append(record, field.String()), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if inQuotes {
+			switch {
+			case c == r.ff.escape && r.ff.escape != r.ff.quote:
+				next, _, err := r.r.ReadRune()
+				if err != nil {
+					return nil, fmt.Errorf("unterminated quoted field")
+				}
+				field.WriteRune(next)
+			case c == r.ff.quote:
+				// With the default escape, a doubled quote is a literal quote.
+				if r.ff.escape == r.ff.quote {
+					if next, _, err := r.r.ReadRune(); err == nil {
+						if next == r.ff.quote {
+							field.WriteRune(next)
+							continue
+						}
+						r.r.UnreadRune()
+					}
+				}
+				inQuotes = false
+			default:
+				field.WriteRune(c)
+			}
+			continue
+		}
+		switch {
+		case c == r.ff.quote && field.Len() == 0 && !quoted:
+			inQuotes, quoted, empty = true, true, false
+		case c == r.ff.delimiter:
+			record = append(record, field.String())
+			field.Reset()
+			quoted, empty = false, false
+		case c == '\r' || c == '\n':
+			if c == '\r' {
+				if next, _, err := r.r.ReadRune(); err == nil && next != '\n' {
+					r.r.UnreadRune()
+				}
+			}
+			if empty {
+				// Skip empty lines.
+				continue
+			}
+			return append(record, field.String()), nil
+		default:
+			field.WriteRune(c)
+			empty = false
+		}
+	}
+}
+
+// csvFile is an open CSV file whose header, if any, has been read.
+type csvFile struct {
+	*reader
+	f *os.File
+	// cols holds the column of each field, "" for skipped fields.
+	cols []string
+	// firstRow is the first data row if it was read while looking for a
+	// header.
+	firstRow []string
+}
+
+// openCSV opens a CSV file and works out its columns. schemaCols are the
+// columns of the table in schema order, or nil if the schema is being
+// inferred, in which case files must have a header or a column list. An
+// empty file is returned with no columns.
+func openCSV(filePath string, ff fileFormat, schemaCols []string) (*csvFile, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv file: %s due to: %v", filePath, err)
+	}
+	cf := &csvFile{reader: newReader(f, ff), f: f}
+	if err := cf.readHeader(schemaCols); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("can't read csv headers for col names in %s due to: %v", filePath, err)
+	}
+	return cf, nil
+}
+
+func (cf *csvFile) readHeader(schemaCols []string) error {
+	ff := cf.ff
+	if ff.header != nil && !*ff.header && ff.columns == nil {
+		if schemaCols == nil {
+			return fmt.Errorf("files without a header need a column list in the manifest")
+		}
+		cf.cols = schemaCols
+		return nil
+	}
+	row, err := cf.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case ff.columns != nil:
+		cf.cols = ff.columns
+		if ff.header == nil || !*ff.header {
+			cf.firstRow = row
+		}
+	case ff.header != nil || schemaCols == nil:
+		cf.cols = row
+	case utils.CheckEqualSets(row, schemaCols):
+		// If first row is some permutation of the schema columns, we assume
+		// the first row is headers.
+		cf.cols = row
+	default:
+		cf.cols = schemaCols
+		cf.firstRow = row
+	}
+	if schemaCols != nil {
+		for _, col := range cf.cols {
+			if col != "" && indexOf(schemaCols, col) < 0 {
+				return fmt.Errorf("column %s is not in the schema", col)
+			}
+		}
+	}
+	if cf.firstRow != nil && len(cf.firstRow) != len(cf.cols) {
+		return fmt.Errorf("found %d columns in csv, expected %d", len(cf.firstRow), len(cf.cols))
+	}
+	return nil
+}
+
+func (cf *csvFile) Close() error {
+	return cf.f.Close()
+}
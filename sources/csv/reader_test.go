@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csv
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func readAll(t *testing.T, in string, ff fileFormat) [][]string {
+	r := newReader(strings.NewReader(in), ff)
+	var records [][]string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return records
+		}
+		assert.Nil(t, err)
+		if err != nil {
+			return records
+		}
+		records = append(records, rec)
+	}
+}
+
+func TestReader(t *testing.T) {
+	ff := defaultFileFormat("", ',')
+	assert.Equal(t, [][]string{{"a", "b", ""}, {"x,y", `say "hi"`, "line1\nline2"}},
+		readAll(t, "a,b,\r\n\n\"x,y\",\"say \"\"hi\"\"\",\"line1\nline2\"", ff))
+
+	ff.delimiter, ff.quote, ff.escape = '|', '\'', '\\'
+	assert.Equal(t, [][]string{{"1", "it's", `a\b`}, {"2", "", "c"}},
+		readAll(t, "1|'it\\'s'|'a\\\\b'\n2||c\n", ff))
+
+	_, err := newReader(strings.NewReader(`"abc`), defaultFileFormat("", ',')).Read()
+	assert.NotNil(t, err)
+}
+
+func TestGetFileFormat(t *testing.T) {
+	null := "NULL"
+	ff, err := getFileFormat(utils.ManifestTable{Delimiter: ";", Quote: "'", Null_str: &null, Columns: []string{"a", "", "b"}}, "", ',')
+	assert.Nil(t, err)
+	assert.Equal(t, ';', ff.delimiter)
+	assert.Equal(t, '\'', ff.quote)
+	// Escape defaults to the quote character.
+	assert.Equal(t, '\'', ff.escape)
+	assert.Equal(t, "NULL", ff.nullStr)
+	// Files with a column list have no header by default.
+	assert.False(t, *ff.header)
+
+	ff, err = getFileFormat(utils.ManifestTable{}, "N", '|')
+	assert.Nil(t, err)
+	assert.Equal(t, defaultFileFormat("N", '|'), ff)
+
+	_, err = getFileFormat(utils.ManifestTable{Delimiter: "||"}, "", ',')
+	assert.NotNil(t, err)
+	_, err = getFileFormat(utils.ManifestTable{Delimiter: `"`}, "", ',')
+	assert.NotNil(t, err)
+}
+
+func TestExpandFilePatterns(t *testing.T) {
+	dir := t.TempDir()
+	f1 := writeTempCSV(t, dir, "part-1.csv", "1")
+	f2 := writeTempCSV(t, dir, "part-2.csv", "2")
+	writeTempCSV(t, dir, "other.csv", "3")
+	missing := filepath.Join(dir, "missing.csv")
+	tables, err := utils.ExpandFilePatterns([]utils.ManifestTable{{Table_name: "t", File_patterns: []string{filepath.Join(dir, "part-*.csv"), missing}}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{f1, f2, missing}, tables[0].File_patterns)
+
+	_, err = utils.ExpandFilePatterns([]utils.ManifestTable{{Table_name: "t", File_patterns: []string{filepath.Join(dir, "none-*.csv")}}})
+	assert.NotNil(t, err)
+}
+
+func TestProcessCSVWithManifestOptions(t *testing.T) {
+	dir := t.TempDir()
+	// Headerless files with a column list that reorders and skips fields,
+	// and custom dialect, null string and date formats.
+	var files []string
+	for i := 0; i < 4; i++ {
+		files = append(files, writeTempCSV(t, dir, fmt.Sprintf("events_%d.csv", i),
+			fmt.Sprintf("ignored;%d;'a;b';31/12/2020;2020-12-31 23:59", 2*i),
+			fmt.Sprintf("ignored;%d;-;-;-", 2*i+1),
+		))
+	}
+	tableName := "events"
+	conv := buildConv([]ddl.CreateTable{{
+		Name:     tableName,
+		ColNames: []string{"id", "name", "day", "at"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":   {Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"name": {Name: "name", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"day":  {Name: "day", T: ddl.Type{Name: ddl.Date}},
+			"at":   {Name: "at", T: ddl.Type{Name: ddl.Timestamp}},
+		},
+		Pks: []ddl.IndexKey{{Col: "id"}},
+	}})
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	null := "-"
+	tables := []utils.ManifestTable{{
+		Table_name:       tableName,
+		File_patterns:    []string{filepath.Join(dir, "events_*.csv")},
+		Columns:          []string{"", "id", "name", "day", "at"},
+		Delimiter:        ";",
+		Quote:            "'",
+		Null_str:         &null,
+		Date_format:      "02/01/2006",
+		Timestamp_format: "2006-01-02 15:04",
+	}}
+	tables, err := utils.ExpandFilePatterns(tables)
+	assert.Nil(t, err)
+	assert.Equal(t, files, tables[0].File_patterns)
+	assert.Nil(t, SetRowStats(conv, tables, ','))
+	assert.Equal(t, int64(8), conv.Stats.Rows[tableName])
+
+	assert.Nil(t, ProcessCSV(conv, tables, "", ','))
+	var expected []spannerData
+	for i := 0; i < 4; i++ {
+		expected = append(expected,
+			spannerData{table: tableName, cols: []string{"id", "name", "day", "at"},
+				vals: []interface{}{int64(2 * i), "a;b", getDate("2020-12-31"), time.Date(2020, 12, 31, 23, 59, 0, 0, time.UTC)}},
+			spannerData{table: tableName, cols: []string{"id"}, vals: []interface{}{int64(2*i + 1)}})
+	}
+	// Files are loaded in parallel, so rows from different files interleave.
+	assert.ElementsMatch(t, expected, rows)
+	assert.Equal(t, int64(0), conv.BadRows())
+}
+
+func TestProcessCSVHeaderless(t *testing.T) {
+	dir := t.TempDir()
+	// The first row matches the column names but header is false, so it is
+	// loaded as data.
+	f := writeTempCSV(t, dir, "singers.csv", "SingerId,FirstName,LastName", "1,fn,ln")
+	conv := buildConv(getCreateTable())
+	no := false
+	tables := []utils.ManifestTable{{Table_name: SINGERS_TABLE, File_patterns: []string{f}, Header: &no}}
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	assert.Nil(t, ProcessCSV(conv, tables, "", ','))
+	// "SingerId" is not an INT64, so the first row is a bad row.
+	assert.Equal(t, []spannerData{
+		{table: SINGERS_TABLE, cols: []string{"SingerId", "FirstName", "LastName"}, vals: []interface{}{int64(1), "fn", "ln"}},
+	}, rows)
+	assert.Equal(t, int64(1), conv.BadRows())
+
+	// Headerless files need a column list when the schema is inferred.
+	_, err := NewInfoSchemaImpl(tables, "", ',', 10)
+	assert.NotNil(t, err)
+}
@@ -19,9 +19,29 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 )
 
+// ToDdl implementation for schemas inferred from CSV files.
+type ToDdlImpl struct {
+}
+
+// ToSpannerType maps an inferred CSV column type into a Spanner type.
+// Inferred types are named after Spanner types, and STRING columns carry
+// their inferred length as a modifier.
+func (tdi ToDdlImpl) ToSpannerType(conv *internal.Conv, spType string, srcType schema.Type) (ddl.Type, []internal.SchemaIssue) {
+	ty, err := ToSpannerType(srcType.Name)
+	if err != nil {
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
+	}
+	if ty.Name == ddl.String && len(srcType.Mods) > 0 {
+		ty.Len = srcType.Mods[0]
+	}
+	return ty, nil
+}
+
 func ToSpannerType(columnType string) (ddl.Type, error) {
 	ty := strings.ToUpper(columnType)
 	switch {
@@ -0,0 +1,91 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// cutoverStatusInterval is how often the cutover status file is written.
+	cutoverStatusInterval = 10 * time.Second
+	// stopFileInterval is how often the stop file is checked for.
+	stopFileInterval = 5 * time.Second
+)
+
+// States of a streaming migration, reported in the cutover status.
+const (
+	streamingState = "streaming" // Records are being processed.
+	drainingState  = "draining"  // A stop was requested: the records already read are being written.
+	stoppedState   = "stopped"   // All shards are drained, the migration is over.
+)
+
+// cutoverStatus is the machine-readable state of a streaming migration,
+// written to the status file so that scripts can decide when to cut over.
+type cutoverStatus struct {
+	State                    string
+	ReadyForCutover          bool    // Same as the "Optimum time for switching to Cloud Spanner" output.
+	RecordsProcessed         int64   // Count of records processed since the start.
+	RecordsLastMinute        int64   // Count of records processed in the last complete minute.
+	ReplicationLagSeconds    float64 // Time between the creation of the last committed record and its commit.
+	MaxReplicationLagSeconds float64
+	UpdatedAt                time.Time
+}
+
+// watchStopFile stops the streaming migration once a file exists at path,
+// so that it can be stopped without a terminal. It checks every interval,
+// until done is closed.
+func watchStopFile(wg *sync.WaitGroup, streamInfo *StreamingInfo, path string, interval time.Duration, done <-chan struct{}) {
+	defer wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if _, err := os.Stat(path); err == nil {
+				fmt.Printf("Found stop file %s: stopping the processing of DynamoDB Streams...\n", path)
+				streamInfo.SetUserExit()
+				return
+			}
+		}
+	}
+}
+
+// reportCutoverStatus writes the cutover status to path every interval,
+// until done is closed.
+func reportCutoverStatus(wg *sync.WaitGroup, streamInfo *StreamingInfo, path string, interval time.Duration, done <-chan struct{}) {
+	defer wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			state := streamingState
+			if streamInfo.UserExited() {
+				state = drainingState
+			}
+			if err := streamInfo.SaveCutoverStatus(path, state); err != nil {
+				streamInfo.Unexpected(fmt.Sprintf("Couldn't save cutover status: %s", err))
+			}
+		}
+	}
+}
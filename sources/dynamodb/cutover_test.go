@@ -0,0 +1,89 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchStopFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stop")
+	streamInfo := MakeStreamingInfo()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		watchStopFile(wg, streamInfo, path, time.Millisecond, done)
+		close(stopped)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.False(t, streamInfo.UserExited())
+
+	assert.Nil(t, os.WriteFile(path, nil, 0644))
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stop file not detected")
+	}
+	assert.True(t, streamInfo.UserExited())
+	close(done)
+	wg.Wait()
+}
+
+func TestReportCutoverStatus(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status.json")
+	streamInfo := MakeStreamingInfo()
+	streamInfo.StatsAddRecordProcessed()
+	streamInfo.SetCutoverReadiness(true, 1)
+	created := time.Now().Add(-2 * time.Second)
+	streamInfo.StatsAddBatch(0, &created)
+	streamInfo.UserExit = true
+
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go reportCutoverStatus(wg, streamInfo, path, time.Millisecond, done)
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	var status cutoverStatus
+	assert.Nil(t, json.Unmarshal(b, &status))
+	assert.Equal(t, drainingState, status.State)
+	assert.True(t, status.ReadyForCutover)
+	assert.Equal(t, int64(1), status.RecordsProcessed)
+	assert.Equal(t, int64(1), status.RecordsLastMinute)
+	assert.InDelta(t, 2, status.ReplicationLagSeconds, 1)
+	assert.Equal(t, status.ReplicationLagSeconds, status.MaxReplicationLagSeconds)
+
+	// The status file isn't written anymore once reportCutoverStatus returns,
+	// so the final state isn't overwritten.
+	assert.Nil(t, streamInfo.SaveCutoverStatus(path, stoppedState))
+	time.Sleep(10 * time.Millisecond)
+	b, err = os.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(b, &status))
+	assert.Equal(t, stoppedState, status.State)
+}
@@ -120,12 +120,14 @@ func convScalar(attrVal *dynamodb.AttributeValue, srcType string, spType string)
 			return attrVal.B, nil
 		}
 	case ddl.String:
-		switch srcType {
-		case typeString:
+		// Columns whose type varies between items are mapped to String, so
+		// the value may not have the column's type.
+		switch {
+		case attrVal.S != nil:
 			return *attrVal.S, nil
-		case typeNumberString:
+		case attrVal.N != nil:
 			return *attrVal.N, nil
-		case typeMap, typeList, typeStringSet, typeNumberStringSet, typeNumberSet, typeBinarySet:
+		default:
 			// For typeMap and typeList, attrVal is a very verbose data
 			// structure that contains null entries for unused type cases. We
 			// strip these out using stripNull. If it is important that the
@@ -144,6 +146,17 @@ func convScalar(attrVal *dynamodb.AttributeValue, srcType string, spType string)
 			}
 			return string(b), nil
 		}
+	case ddl.JSON:
+		// JSON columns hold Maps and Lists, but any value can be stored.
+		val, err := toJSONValue(attrVal)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %v to a go struct", attrVal.GoString())
+		}
+		b, err := json.Marshal(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert %v to a json string", attrVal.GoString())
+		}
+		return string(b), nil
 	case ddl.Numeric:
 		switch srcType {
 		case typeNumber:
@@ -208,3 +221,72 @@ func stripNull(a *dynamodb.AttributeValue) (interface{}, error) {
 		return nil, fmt.Errorf("unknown type of AttributeValue: %v", a)
 	}
 }
+
+// toJSONValue converts a dynamodb.AttributeValue to a Go value that is
+// encoded as the equivalent JSON value. Unlike stripNull, it keeps the
+// types of values: numbers are encoded as JSON numbers, binary values as
+// base64 strings, NULL as null, and sets as arrays.
+func toJSONValue(a *dynamodb.AttributeValue) (interface{}, error) {
+	switch {
+	case a.M != nil:
+		m := make(map[string]interface{}, len(a.M))
+		for k, v := range a.M {
+			c, err := toJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = c
+		}
+		return m, nil
+	case a.L != nil:
+		l := make([]interface{}, 0, len(a.L))
+		for _, v := range a.L {
+			c, err := toJSONValue(v)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, c)
+		}
+		return l, nil
+	case a.B != nil:
+		// encoding/json encodes []byte as a base64 string.
+		return a.B, nil
+	case a.BOOL != nil:
+		return *a.BOOL, nil
+	case a.BS != nil:
+		return a.BS, nil
+	case a.N != nil:
+		return jsonNumber(*a.N)
+	case a.NS != nil:
+		l := make([]json.Number, 0, len(a.NS))
+		for _, n := range a.NS {
+			c, err := jsonNumber(*n)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, c)
+		}
+		return l, nil
+	case a.NULL != nil:
+		return nil, nil
+	case a.S != nil:
+		return *a.S, nil
+	case a.SS != nil:
+		l := make([]string, 0, len(a.SS))
+		for _, s := range a.SS {
+			l = append(l, *s)
+		}
+		return l, nil
+	default:
+		return nil, fmt.Errorf("unknown type of AttributeValue: %v", a)
+	}
+}
+
+// jsonNumber returns DynamoDB number n as a JSON number. DynamoDB numbers
+// have up to 38 digits of precision, which are kept.
+func jsonNumber(n string) (json.Number, error) {
+	if _, ok := new(big.Rat).SetString(n); !ok {
+		return "", fmt.Errorf("invalid number %q", n)
+	}
+	return json.Number(n), nil
+}
@@ -186,6 +186,13 @@ func TestConvScalar(t *testing.T) {
 		{"number string set", typeNumberStringSet, ddl.String, &dynamodb.AttributeValue{NS: []*string{&numStr}}, "[\"1234.56789\"]"},
 		{"number", typeNumber, ddl.Numeric, &dynamodb.AttributeValue{N: &numStr}, *numVal},
 		{"number set", typeNumberSet, ddl.String, &dynamodb.AttributeValue{NS: []*string{&numStr}}, "[\"1234.56789\"]"},
+		{"map json", typeMap, ddl.JSON, &dynamodb.AttributeValue{M: mapVal}, `{"list":["str-1",1234.56789]}`},
+		{"list json", typeList, ddl.JSON, &dynamodb.AttributeValue{L: listVal}, `["str-1",1234.56789]`},
+		{"empty list json", typeList, ddl.JSON, &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{}}, `[]`},
+		// Columns whose type varies between items hold values of other types.
+		{"mixed number", typeString, ddl.String, &dynamodb.AttributeValue{N: &numStr}, numStr},
+		{"mixed map", typeString, ddl.String, &dynamodb.AttributeValue{M: mapVal}, "{\"list\":[\"str-1\",\"1234.56789\"]}"},
+		{"mixed string json", typeMap, ddl.JSON, &dynamodb.AttributeValue{S: &str}, `"str-1"`},
 	}
 
 	for _, tc := range testcases {
@@ -251,6 +258,47 @@ func TestStripNull(t *testing.T) {
 	}
 }
 
+func TestToJSONValue(t *testing.T) {
+	str := "str-1"
+	numStr := "1234.56789"
+	bigNumStr := "12345678901234567890123456789012345678"
+	boolTrue := true
+	binaryVal := []byte("ABC")
+	mapVal := map[string]*dynamodb.AttributeValue{
+		"list": {L: []*dynamodb.AttributeValue{
+			{B: binaryVal},
+			{S: &str},
+			{N: &numStr},
+			{BOOL: &boolTrue},
+			{SS: []*string{&str}},
+			{BS: [][]byte{binaryVal}},
+			{NS: []*string{&numStr, &bigNumStr}},
+			{NULL: &boolTrue},
+			{M: map[string]*dynamodb.AttributeValue{}},
+		}},
+	}
+	testcases := []struct {
+		name string
+		in   *dynamodb.AttributeValue // Input value for conversion.
+		want string                   // Expected result.
+	}{
+		{"binary", &dynamodb.AttributeValue{B: binaryVal}, `"QUJD"`},
+		{"number", &dynamodb.AttributeValue{N: &bigNumStr}, bigNumStr},
+		{"null", &dynamodb.AttributeValue{NULL: &boolTrue}, `null`},
+		{"map", &dynamodb.AttributeValue{M: mapVal}, `{"list":["QUJD","str-1",1234.56789,true,["str-1"],["QUJD"],[1234.56789,12345678901234567890123456789012345678],null,{}]}`},
+	}
+	for _, tc := range testcases {
+		v, err := toJSONValue(tc.in)
+		assert.Nil(t, err, tc.name)
+		b, err := json.Marshal(v)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.want, string(b), tc.name)
+	}
+	bad := "1,5"
+	_, err := toJSONValue(&dynamodb.AttributeValue{N: &bad})
+	assert.NotNil(t, err)
+}
+
 func buildConv(spTable ddl.CreateTable, srcTable schema.Table) *internal.Conv {
 	conv := internal.MakeConv()
 	conv.SpSchema[spTable.Name] = spTable
@@ -0,0 +1,237 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// scanCheckpoint records the progress of the parallel scans of the source
+// tables, so that an interrupted data load can be resumed. It is keyed by
+// source table name.
+type scanCheckpoint map[string]*tableCheckpoint
+
+type tableCheckpoint struct {
+	TotalSegments int64
+	Segments      []segmentCheckpoint
+}
+
+// segmentCheckpoint is the progress of one scan segment. LastEvaluatedKey
+// is the key of the last item of the last page that was written to Spanner.
+type segmentCheckpoint struct {
+	LastEvaluatedKey map[string]*dynamodb.AttributeValue `json:",omitempty"`
+	Done             bool
+	Items            int64
+	Pages            int64
+	ConsumedCapacity float64
+}
+
+// loadScanCheckpoint reads the checkpoint file at path. A missing file
+// gives an empty checkpoint.
+func loadScanCheckpoint(path string) (scanCheckpoint, error) {
+	cp := make(scanCheckpoint)
+	if path == "" {
+		return cp, nil
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read scan checkpoint %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, fmt.Errorf("can't parse scan checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint to path.
+func (cp scanCheckpoint) save(path string) error {
+	return writeJSONFile(path, cp)
+}
+
+// writeJSONFile writes v as JSON to path. The file is replaced atomically
+// so a crash never leaves a partially written checkpoint.
+func writeJSONFile(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, b)
+}
+
+// writeFileAtomic writes b to path through a temporary file that is renamed
+// over path.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// parallelScan reads table using totalSegments concurrent scan segments,
+// each running its own pagination loop. handlePage is called for each page
+// of items, one page at a time, so it doesn't need to be thread-safe. If it
+// returns an error, e.g. because some rows of the page couldn't be written,
+// the scan stops and the page's segment isn't advanced past the page.
+//
+// If checkpointFile is set, the LastEvaluatedKey of each segment is saved
+// after each page has been handled, and segments resume from the saved
+// checkpoint: only the pages in flight when the scan was interrupted are
+// read again. The number of segments of a resumed scan is the one it was
+// started with, since DynamoDB segments can't be split or merged.
+func parallelScan(client dynamodbiface.DynamoDBAPI, table string, totalSegments int64, checkpointFile string, handlePage func([]map[string]*dynamodb.AttributeValue) error) ([]internal.SegmentStats, error) {
+	cp, err := loadScanCheckpoint(checkpointFile)
+	if err != nil {
+		return nil, err
+	}
+	if totalSegments < 1 {
+		totalSegments = 1
+	}
+	tcp, ok := cp[table]
+	if !ok {
+		tcp = &tableCheckpoint{TotalSegments: totalSegments, Segments: make([]segmentCheckpoint, totalSegments)}
+		cp[table] = tcp
+	}
+	resumed := make([]bool, tcp.TotalSegments)
+	for i, s := range tcp.Segments {
+		resumed[i] = s.Pages > 0
+	}
+
+	var (
+		pageMu   sync.Mutex // Serializes handlePage.
+		mu       sync.Mutex // Protects tcp and firstErr.
+		saveMu   sync.Mutex // Serializes writes of the checkpoint file.
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+	// save writes a snapshot of the checkpoint. Saves are serialized so the
+	// last snapshot taken is the last one written.
+	save := func() error {
+		saveMu.Lock()
+		defer saveMu.Unlock()
+		mu.Lock()
+		b, err := json.MarshalIndent(cp, "", "  ")
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+		return writeFileAtomic(checkpointFile, b)
+	}
+	for seg := int64(0); seg < tcp.TotalSegments; seg++ {
+		if tcp.Segments[seg].Done {
+			continue
+		}
+		wg.Add(1)
+		go func(seg int64, startKey map[string]*dynamodb.AttributeValue) {
+			defer wg.Done()
+			for {
+				params := &dynamodb.ScanInput{
+					TableName:              aws.String(table),
+					ExclusiveStartKey:      startKey,
+					ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+				}
+				if tcp.TotalSegments > 1 {
+					params.Segment = aws.Int64(seg)
+					params.TotalSegments = aws.Int64(tcp.TotalSegments)
+				}
+				result, err := client.Scan(params)
+				if err != nil {
+					fail(fmt.Errorf("failed to scan segment %d of table %v: %v", seg, table, err))
+					return
+				}
+				if failed() {
+					// Another segment failed: stop here, its checkpoint is preserved.
+					return
+				}
+
+				pageMu.Lock()
+				err = handlePage(result.Items)
+				pageMu.Unlock()
+				if err != nil {
+					fail(fmt.Errorf("can't write page of segment %d of table %v: %v", seg, table, err))
+					return
+				}
+
+				mu.Lock()
+				s := &tcp.Segments[seg]
+				s.Items += int64(len(result.Items))
+				s.Pages++
+				if result.ConsumedCapacity != nil && result.ConsumedCapacity.CapacityUnits != nil {
+					s.ConsumedCapacity += *result.ConsumedCapacity.CapacityUnits
+				}
+				s.LastEvaluatedKey = result.LastEvaluatedKey
+				s.Done = result.LastEvaluatedKey == nil
+				mu.Unlock()
+				if checkpointFile != "" {
+					if err := save(); err != nil {
+						fail(fmt.Errorf("can't save scan checkpoint for table %v: %v", table, err))
+						return
+					}
+				}
+				if result.LastEvaluatedKey == nil {
+					return
+				}
+				startKey = result.LastEvaluatedKey
+			}
+		}(seg, tcp.Segments[seg].LastEvaluatedKey)
+	}
+	wg.Wait()
+
+	var stats []internal.SegmentStats
+	for i, s := range tcp.Segments {
+		stats = append(stats, internal.SegmentStats{
+			Segment:          int64(i),
+			Items:            s.Items,
+			Pages:            s.Pages,
+			ConsumedCapacity: s.ConsumedCapacity,
+			Resumed:          resumed[i],
+		})
+	}
+	return stats, firstErr
+}
@@ -0,0 +1,263 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// mockSegmentClient serves the pages of each scan segment. The key of a
+// page is its position in the segment, so scans can resume from any page.
+type mockSegmentClient struct {
+	mu       sync.Mutex
+	pages    map[int64][][]string // Maps segment to the values of the items of each page.
+	failAt   map[int64]int        // Maps segment to the page whose read fails.
+	requests []string             // Segment and page of each Scan request.
+	dynamodbiface.DynamoDBAPI
+}
+
+func (m *mockSegmentClient) Scan(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seg := aws.Int64Value(input.Segment)
+	page := 0
+	if input.ExclusiveStartKey != nil {
+		page, _ = strconv.Atoi(*input.ExclusiveStartKey["page"].N)
+	}
+	m.requests = append(m.requests, fmt.Sprintf("%d/%d", seg, page))
+	if p, ok := m.failAt[seg]; ok && p == page {
+		return nil, fmt.Errorf("throttled")
+	}
+	out := &dynamodb.ScanOutput{
+		ConsumedCapacity: &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)},
+	}
+	for _, v := range m.pages[seg][page] {
+		out.Items = append(out.Items, map[string]*dynamodb.AttributeValue{"a": {S: aws.String(v)}})
+	}
+	if page+1 < len(m.pages[seg]) {
+		out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"page": {N: aws.String(strconv.Itoa(page + 1))}}
+	}
+	return out, nil
+}
+
+func itemValues(items []map[string]*dynamodb.AttributeValue) []string {
+	var l []string
+	for _, item := range items {
+		l = append(l, *item["a"].S)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func TestParallelScan(t *testing.T) {
+	client := &mockSegmentClient{pages: map[int64][][]string{
+		0: {{"a", "b"}, {"c"}},
+		1: {{"d"}},
+		2: {{"e"}, {"f"}, {"g"}},
+	}}
+	var items []map[string]*dynamodb.AttributeValue
+	stats, err := parallelScan(client, "t", 3, "", func(page []map[string]*dynamodb.AttributeValue) error {
+		items = append(items, page...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f", "g"}, itemValues(items))
+	assert.Equal(t, []internal.SegmentStats{
+		{Segment: 0, Items: 3, Pages: 2, ConsumedCapacity: 1},
+		{Segment: 1, Items: 1, Pages: 1, ConsumedCapacity: 0.5},
+		{Segment: 2, Items: 3, Pages: 3, ConsumedCapacity: 1.5},
+	}, stats)
+}
+
+func TestParallelScan_Error(t *testing.T) {
+	client := &mockSegmentClient{
+		pages:  map[int64][][]string{0: {{"a"}}},
+		failAt: map[int64]int{0: 0},
+	}
+	_, err := parallelScan(client, "t", 1, "", func(page []map[string]*dynamodb.AttributeValue) error { return nil })
+	assert.NotNil(t, err)
+}
+
+func TestParallelScan_Resume(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scan.json")
+	// The scan of table t was started with 2 segments. Segment 0 is done and
+	// segment 1 has read its first page.
+	cp := scanCheckpoint{"t": &tableCheckpoint{
+		TotalSegments: 2,
+		Segments: []segmentCheckpoint{
+			{Done: true, Items: 1, Pages: 1, ConsumedCapacity: 0.5},
+			{LastEvaluatedKey: map[string]*dynamodb.AttributeValue{"page": {N: aws.String("1")}}, Items: 1, Pages: 1, ConsumedCapacity: 0.5},
+		},
+	}}
+	assert.Nil(t, cp.save(file))
+
+	client := &mockSegmentClient{pages: map[int64][][]string{
+		0: {{"a"}},
+		1: {{"b"}, {"c"}, {"d"}},
+	}}
+	var items []map[string]*dynamodb.AttributeValue
+	// The segment count of the checkpoint is used, not the requested one.
+	stats, err := parallelScan(client, "t", 8, file, func(page []map[string]*dynamodb.AttributeValue) error {
+		items = append(items, page...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1/1", "1/2"}, client.requests)
+	assert.Equal(t, []string{"c", "d"}, itemValues(items))
+	assert.Equal(t, []internal.SegmentStats{
+		{Segment: 0, Items: 1, Pages: 1, ConsumedCapacity: 0.5, Resumed: true},
+		{Segment: 1, Items: 3, Pages: 3, ConsumedCapacity: 1.5, Resumed: true},
+	}, stats)
+
+	saved, err := loadScanCheckpoint(file)
+	assert.Nil(t, err)
+	assert.True(t, saved["t"].Segments[1].Done)
+	assert.Nil(t, saved["t"].Segments[1].LastEvaluatedKey)
+}
+
+func TestParallelScan_CheckpointAfterFailure(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scan.json")
+	client := &mockSegmentClient{
+		pages:  map[int64][][]string{0: {{"a"}, {"b"}, {"c"}}},
+		failAt: map[int64]int{0: 2},
+	}
+	_, err := parallelScan(client, "t", 1, file, func(page []map[string]*dynamodb.AttributeValue) error { return nil })
+	assert.NotNil(t, err)
+
+	// Only the page that failed is read again.
+	client.failAt = nil
+	client.requests = nil
+	var items []map[string]*dynamodb.AttributeValue
+	_, err = parallelScan(client, "t", 1, file, func(page []map[string]*dynamodb.AttributeValue) error {
+		items = append(items, page...)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0/2"}, client.requests)
+	assert.Equal(t, []string{"c"}, itemValues(items))
+}
+
+func TestParallelScan_PageError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "scan.json")
+	client := &mockSegmentClient{pages: map[int64][][]string{0: {{"a"}, {"b"}, {"c"}}}}
+	_, err := parallelScan(client, "t", 1, file, func(page []map[string]*dynamodb.AttributeValue) error {
+		if itemValues(page)[0] == "b" {
+			return fmt.Errorf("dropped")
+		}
+		return nil
+	})
+	assert.NotNil(t, err)
+
+	// The segment isn't advanced past the page that failed.
+	saved, err := loadScanCheckpoint(file)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), saved["t"].Segments[0].Pages)
+	assert.Equal(t, "1", *saved["t"].Segments[0].LastEvaluatedKey["page"].N)
+}
+
+func TestProcessData_ParallelScan(t *testing.T) {
+	client := &mockSegmentClient{pages: map[int64][][]string{
+		0: {{"a", "b"}, {"c"}},
+		1: {{"d"}},
+	}}
+	file := filepath.Join(t.TempDir(), "scan.json")
+	isi := InfoSchemaImpl{DynamoClient: client, ScanSegments: 2, ScanCheckpoint: file}
+	spSchema := ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"a"},
+		ColDefs:  map[string]ddl.ColumnDef{"a": {Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}},
+		Pks:      []ddl.IndexKey{{Col: "a"}},
+	}
+	conv := buildConv(spSchema, schema.Table{
+		Name:        "t",
+		ColNames:    []string{"a"},
+		ColDefs:     map[string]schema.Column{"a": {Name: "a", Type: schema.Type{Name: typeString}}},
+		PrimaryKeys: []schema.Key{{Column: "a"}},
+	})
+	var rows []string
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		rows = append(rows, vals[0].(string))
+	})
+	flushes := 0
+	conv.DataFlush = func() { flushes++ }
+
+	assert.Nil(t, isi.ProcessData(conv, "t", conv.SrcSchema["t"], "t", []string{"a"}, spSchema))
+	sort.Strings(rows)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, rows)
+	// Each page is flushed before its segment's progress is saved.
+	assert.Equal(t, 3, flushes)
+	assert.Equal(t, []internal.SegmentStats{
+		{Segment: 0, Items: 3, Pages: 2, ConsumedCapacity: 1},
+		{Segment: 1, Items: 1, Pages: 1, ConsumedCapacity: 0.5},
+	}, conv.Audit.ScanStats["t"])
+
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.DYNAMODB, conv, w, nil, false, false)
+	w.Flush()
+	assert.Contains(t, buf.String(), "Table t: 2 segment(s), 1.5 read capacity units consumed")
+	assert.Contains(t, buf.String(), "Segment 0: 3 items in 2 pages, 1.0 read capacity units")
+}
+
+func TestProcessData_DroppedRows(t *testing.T) {
+	client := &mockSegmentClient{pages: map[int64][][]string{0: {{"a"}, {"b"}}}}
+	file := filepath.Join(t.TempDir(), "scan.json")
+	isi := InfoSchemaImpl{DynamoClient: client, ScanSegments: 1, ScanCheckpoint: file}
+	spSchema := ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"a"},
+		ColDefs:  map[string]ddl.ColumnDef{"a": {Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}},
+		Pks:      []ddl.IndexKey{{Col: "a"}},
+	}
+	conv := buildConv(spSchema, schema.Table{
+		Name:        "t",
+		ColNames:    []string{"a"},
+		ColDefs:     map[string]schema.Column{"a": {Name: "a", Type: schema.Type{Name: typeString}}},
+		PrimaryKeys: []schema.Key{{Column: "a"}},
+	})
+	// The writer drops the row of the second page.
+	var dropped int64
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		if vals[0] == "b" {
+			dropped++
+		}
+	})
+	conv.DataFlush = func() {}
+	conv.DataDropped = func() int64 { return dropped }
+
+	assert.NotNil(t, isi.ProcessData(conv, "t", conv.SrcSchema["t"], "t", []string{"a"}, spSchema))
+	saved, err := loadScanCheckpoint(file)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), saved["t"].Segments[0].Pages)
+	assert.Equal(t, "1", *saved["t"].Segments[0].LastEvaluatedKey["page"].N)
+	assert.False(t, saved["t"].Segments[0].Done)
+}
@@ -19,7 +19,7 @@ import (
 	"fmt"
 	"log"
 	"math/big"
-	"sort"
+	"strings"
 	"sync"
 
 	sp "cloud.google.com/go/spanner"
@@ -46,25 +46,42 @@ const (
 	typeNumberSet       = "NumberSet"
 	typeNumberStringSet = "NumberStringSet"
 	typeBinarySet       = "BinarySet"
-
-	errThreshold      = float64(0.001)
-	conflictThreshold = float64(0.05)
 )
 
 type InfoSchemaImpl struct {
 	DynamoClient        dynamodbiface.DynamoDBAPI
 	DynamoStreamsClient dynamodbstreamsiface.DynamoDBStreamsAPI
 	SampleSize          int64
+	ScanSegments        int64     // Number of parallel scan segments used to read each table.
+	ScanCheckpoint      string    // File where the progress of each scan segment is saved.
+	Splitter            *Splitter // Routes the items of single-table-design tables to one table per entity type.
+	StreamCheckpoint    string    // File where the progress of each stream shard is saved.
+	StreamResume        bool      // If true, the streaming migration resumes from StreamCheckpoint.
+	StopFile            string    // The streaming migration stops once a file exists at this path.
+	StatusFile          string    // File where the cutover status of the streaming migration is written.
 }
 
 func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
 	return ToDdlImpl{}
 }
 
+// GetTableName returns the source table name. Tables split by entity type
+// have the DynamoDB table as schema and the entity as name.
 func (isi InfoSchemaImpl) GetTableName(schema string, tableName string) string {
+	if schema != "" {
+		return splitTableName(schema, tableName)
+	}
 	return *aws.String(tableName)
 }
 
+// dynamoTable returns the DynamoDB table that table is read from.
+func dynamoTable(table common.SchemaAndName) string {
+	if table.Schema != "" {
+		return table.Schema
+	}
+	return table.Name
+}
+
 func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 	var tables []common.SchemaAndName
 	input := &dynamodb.ListTablesInput{}
@@ -74,7 +91,22 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 			return nil, err
 		}
 		for _, t := range result.TableNames {
-			tables = append(tables, common.SchemaAndName{Name: *t})
+			r, ok := isi.Splitter.rule(*t)
+			if !ok {
+				tables = append(tables, common.SchemaAndName{Name: *t})
+				continue
+			}
+			// Entities without items in the sample can't have their
+			// schema inferred, so they are left out.
+			es, err := isi.Splitter.sample(isi.DynamoClient, isi.SampleSize, *t)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range r.Entities {
+				if es.counts[e.Name] > 0 {
+					tables = append(tables, common.SchemaAndName{Schema: *t, Name: e.Name})
+				}
+			}
 		}
 
 		if result.LastEvaluatedTableName == nil {
@@ -85,6 +117,13 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 }
 
 func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	if table.Schema != "" {
+		es, err := isi.Splitter.sample(isi.DynamoClient, isi.SampleSize, table.Schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		return inferDataTypes(es.stats[table.Name], es.counts[table.Name], primaryKeys)
+	}
 	stats, count, err := scanSampleData(isi.DynamoClient, isi.SampleSize, table.Name)
 	if err != nil {
 		return nil, nil, err
@@ -92,45 +131,44 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 	return inferDataTypes(stats, count, primaryKeys)
 }
 
+// GetRowsFromTable returns all the items of srcTable, read using a parallel
+// scan with isi.ScanSegments segments.
 func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string) (interface{}, error) {
-	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
-	for {
-		// Build the query input parameters.
-		params := &dynamodb.ScanInput{
-			TableName: aws.String(srcTable),
-		}
-		if lastEvaluatedKey != nil {
-			params.ExclusiveStartKey = lastEvaluatedKey
-		}
-
-		// Make the DynamoDB Query API call.
-		result, err := isi.DynamoClient.Scan(params)
-		if err != nil {
-			return nil, fmt.Errorf("failed to make Query API call for table %v: %v", srcTable, err)
-		}
-
-		if result.LastEvaluatedKey == nil {
-			return result.Items, nil
-		}
-		// If there are more rows, then continue.
-		lastEvaluatedKey = result.LastEvaluatedKey
+	var items []map[string]*dynamodb.AttributeValue
+	_, err := parallelScan(isi.DynamoClient, srcTable, isi.ScanSegments, "", func(page []map[string]*dynamodb.AttributeValue) error {
+		items = append(items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return items, nil
 }
 
+// GetRowCount returns the item count of the table. For a table split by
+// entity type, the count is estimated from the share of the entity in the
+// schema sample.
 func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
 	input := &dynamodb.DescribeTableInput{
-		TableName: aws.String(table.Name),
+		TableName: aws.String(dynamoTable(table)),
 	}
 	result, err := isi.DynamoClient.DescribeTable(input)
 	if err != nil {
 		return 0, err
 	}
+	if table.Schema != "" {
+		es, err := isi.Splitter.sample(isi.DynamoClient, isi.SampleSize, table.Schema)
+		if err != nil || es.total == 0 {
+			return 0, err
+		}
+		return *result.Table.ItemCount * es.counts[table.Name] / es.total, nil
+	}
 	return *result.Table.ItemCount, err
 }
 
 func (isi InfoSchemaImpl) GetConstraints(conv *internal.Conv, table common.SchemaAndName) (primaryKeys []string, constraints map[string][]string, err error) {
 	input := &dynamodb.DescribeTableInput{
-		TableName: aws.String(table.Name),
+		TableName: aws.String(dynamoTable(table)),
 	}
 	result, err := isi.DynamoClient.DescribeTable(input)
 	if err != nil {
@@ -150,7 +188,7 @@ func (isi InfoSchemaImpl) GetForeignKeys(conv *internal.Conv, table common.Schem
 
 func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAndName) (indexes []schema.Index, err error) {
 	input := &dynamodb.DescribeTableInput{
-		TableName: aws.String(table.Name),
+		TableName: aws.String(dynamoTable(table)),
 	}
 
 	result, err := isi.DynamoClient.DescribeTable(input)
@@ -172,23 +210,151 @@ func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAnd
 	for _, i := range result.Table.LocalSecondaryIndexes {
 		indexes = append(indexes, getSchemaIndexStruct(*i.IndexName, i.KeySchema))
 	}
+	if table.Schema != "" {
+		return isi.entityIndexes(table, indexes)
+	}
 	return indexes, nil
 }
 
+// entityIndexes keeps the indexes whose keys are attributes of the items of
+// the entity. In single-table design, secondary indexes are usually shared
+// by a few of the entity types only.
+func (isi InfoSchemaImpl) entityIndexes(table common.SchemaAndName, indexes []schema.Index) ([]schema.Index, error) {
+	es, err := isi.Splitter.sample(isi.DynamoClient, isi.SampleSize, table.Schema)
+	if err != nil {
+		return nil, err
+	}
+	var l []schema.Index
+	for _, i := range indexes {
+		ok := true
+		for _, k := range i.Keys {
+			if _, found := es.stats[table.Name][k.Column]; !found {
+				ok = false
+			}
+		}
+		if ok {
+			l = append(l, i)
+		}
+	}
+	return l, nil
+}
+
 // ProcessData performs data conversion for DynamoDB database. For each table,
-// we extract data using Scan requests, convert the data to Spanner data (based
-// on the source and Spanner schemas), and write it to Spanner. If we can't
+// we extract data using a parallel scan with isi.ScanSegments segments,
+// convert the data to Spanner data (based on the source and Spanner schemas),
+// and write it to Spanner. If isi.ScanCheckpoint is set, each page is flushed
+// to Spanner before the progress of its segment is saved, so an interrupted
+// load resumes from the last saved page of each segment. If we can't
 // get/process data for a table, we skip that table and process the remaining
 // tables.
 func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
-	rows, err := isi.GetRowsFromTable(conv, srcTable)
+	if table := isi.Splitter.DynamoTable(srcTable); table != srcTable {
+		return isi.processSplitData(conv, table)
+	}
+	stats, err := parallelScan(isi.DynamoClient, srcTable, isi.ScanSegments, isi.ScanCheckpoint, func(page []map[string]*dynamodb.AttributeValue) error {
+		dropped := droppedRows(conv)
+		for _, attrsMap := range page {
+			ProcessDataRow(attrsMap, conv, srcTable, srcSchema, spTable, spCols, spSchema)
+		}
+		return isi.flushPage(conv, dropped)
+	})
+	if conv.Audit.ScanStats == nil {
+		conv.Audit.ScanStats = make(map[string][]internal.SegmentStats)
+	}
+	conv.Audit.ScanStats[srcTable] = stats
 	if err != nil {
 		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
 		return err
 	}
-	// Iterate the items returned.
-	for _, attrsMap := range rows.([]map[string]*dynamodb.AttributeValue) {
-		ProcessDataRow(attrsMap, conv, srcTable, srcSchema, spTable, spCols, spSchema)
+	return nil
+}
+
+// flushPage flushes the rows of a page to Spanner when the scan is
+// checkpointed, so the page's segment is only advanced once they are
+// written. It fails if the writer dropped rows since it had dropped the
+// given number, so the segment isn't advanced past rows that are missing
+// from Spanner and resuming the load reads the page again.
+func (isi InfoSchemaImpl) flushPage(conv *internal.Conv, dropped int64) error {
+	if isi.ScanCheckpoint == "" || conv.DataFlush == nil {
+		return nil
+	}
+	conv.DataFlush()
+	if n := droppedRows(conv) - dropped; n > 0 {
+		return fmt.Errorf("%d rows weren't written to Spanner", n)
+	}
+	return nil
+}
+
+// droppedRows returns the number of rows the writer has dropped so far.
+func droppedRows(conv *internal.Conv) int64 {
+	if conv.DataDropped == nil {
+		return 0
+	}
+	return conv.DataDropped()
+}
+
+// processSplitData reads a table split by entity type with a single scan,
+// which routes each item to the table of its entity. The scan is done when
+// the first of these tables is processed, and the others are skipped.
+func (isi InfoSchemaImpl) processSplitData(conv *internal.Conv, table string) error {
+	if _, done := conv.Audit.ScanStats[table]; done {
+		return nil
+	}
+	type target struct {
+		srcSchema schema.Table
+		spTable   string
+		spCols    []string
+		spSchema  ddl.CreateTable
+	}
+	targets := make(map[string]target)
+	for srcTable := range conv.SrcSchema {
+		if isi.Splitter.DynamoTable(srcTable) != table {
+			continue
+		}
+		srcSchema, spTable, spCols, spSchema, err := common.GetColsAndSchemas(conv, srcTable)
+		if err != nil {
+			continue
+		}
+		targets[srcTable] = target{srcSchema, spTable, spCols, spSchema}
+	}
+	// Items are skipped if they match no entity, or if their entity had no
+	// items in the schema sample and so has no table.
+	skipped := make(map[string]int64)
+	stats, err := parallelScan(isi.DynamoClient, table, isi.ScanSegments, isi.ScanCheckpoint, func(page []map[string]*dynamodb.AttributeValue) error {
+		dropped := droppedRows(conv)
+		for _, attrsMap := range page {
+			srcTable, matched := isi.Splitter.Route(table, attrsMap)
+			t, ok := targets[srcTable]
+			if !ok {
+				entity := ""
+				if matched {
+					entity = strings.TrimPrefix(srcTable, splitTableName(table, ""))
+				}
+				skipped[entity]++
+				continue
+			}
+			ProcessDataRow(attrsMap, conv, srcTable, t.srcSchema, t.spTable, t.spCols, t.spSchema)
+		}
+		return isi.flushPage(conv, dropped)
+	})
+	if conv.Audit.ScanStats == nil {
+		conv.Audit.ScanStats = make(map[string][]internal.SegmentStats)
+	}
+	conv.Audit.ScanStats[table] = stats
+	if len(skipped) > 0 {
+		if conv.Audit.SkippedItems == nil {
+			conv.Audit.SkippedItems = make(map[string]map[string]int64)
+		}
+		conv.Audit.SkippedItems[table] = skipped
+		var n int64
+		for _, c := range skipped {
+			n += c
+		}
+		conv.Unexpected(fmt.Sprintf("%d items of table %s don't belong to any entity table and were skipped", n, table))
+	}
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", table, err))
+		return err
 	}
 	return nil
 }
@@ -199,16 +365,34 @@ func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *inte
 	fmt.Println("Starting DynamoDB Streams initialization...")
 
 	latestStreamArn := make(map[string]interface{})
+	if isi.StreamResume {
+		// Continue with the streams that were being processed, even if
+		// newer ones were enabled since.
+		cp, err := loadStreamCheckpoint(isi.StreamCheckpoint)
+		if err != nil {
+			return nil, err
+		}
+		for table, streamArn := range cp.Streams {
+			latestStreamArn[table] = streamArn
+		}
+		fmt.Println("DynamoDB Streams resumed from checkpoint.")
+		return latestStreamArn, nil
+	}
 	orderTableNames := ddl.OrderTables(conv.SpSchema)
 
 	for _, spannerTable := range orderTableNames {
 		srcTable, _ := internal.GetSourceTable(conv, spannerTable)
-		streamArn, err := NewDynamoDBStream(isi.DynamoClient, srcTable)
+		// The entity tables of a split table share its stream.
+		table := isi.Splitter.DynamoTable(srcTable)
+		if _, ok := latestStreamArn[table]; ok {
+			continue
+		}
+		streamArn, err := NewDynamoDBStream(isi.DynamoClient, table)
 		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't initialize DynamoDB Stream for table %s: %s", srcTable, err))
+			conv.Unexpected(fmt.Sprintf("Couldn't initialize DynamoDB Stream for table %s: %s", table, err))
 			continue
 		}
-		latestStreamArn[srcTable] = streamArn
+		latestStreamArn[table] = streamArn
 	}
 
 	fmt.Println("DynamoDB Streams initialized successfully.")
@@ -216,14 +400,53 @@ func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *inte
 }
 
 // StartStreamingMigration starts the streaming migration process by creating a seperate
-// worker thread/goroutine for each table's DynamoDB Stream. It catches Ctrl+C signal if
-// customer wants to stop the process.
+// worker thread/goroutine for each table's DynamoDB Stream. It catches Ctrl+C and SIGTERM
+// signals if customer wants to stop the process, and also stops once isi.StopFile exists.
+// If isi.StreamCheckpoint is set, the progress of each shard is saved to it regularly, and
+// when resuming, shards continue from there. If isi.StatusFile is set, the cutover status
+// is written to it regularly, and a last time once all shards are drained.
 func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, latestStreamArn map[string]interface{}) error {
 	fmt.Println("Processing of DynamoDB Streams started...")
 	fmt.Println("Use Ctrl+C to stop the process.")
+	if isi.StopFile != "" {
+		fmt.Printf("Or create the file %s to stop the process.\n", isi.StopFile)
+	}
 
 	streamInfo := MakeStreamingInfo()
+	streamInfo.splitter = isi.Splitter
 	setWriter(streamInfo, client, conv)
+	if isi.StreamResume {
+		cp, err := loadStreamCheckpoint(isi.StreamCheckpoint)
+		if err != nil {
+			return err
+		}
+		streamInfo.checkpoint = cp
+	}
+	for table, streamArn := range latestStreamArn {
+		streamInfo.checkpoint.Streams[table] = streamArn.(string)
+	}
+	done := make(chan struct{})
+	// The goroutines saving the progress must return before the final saves,
+	// which they could otherwise overwrite.
+	background := &sync.WaitGroup{}
+	if isi.StreamCheckpoint != "" {
+		if err := streamInfo.SaveCheckpoint(isi.StreamCheckpoint); err != nil {
+			return fmt.Errorf("can't save stream checkpoint: %v", err)
+		}
+		background.Add(1)
+		go checkpointStreams(background, streamInfo, isi.StreamCheckpoint, streamCheckpointInterval, done)
+	}
+	if isi.StopFile != "" {
+		background.Add(1)
+		go watchStopFile(background, streamInfo, isi.StopFile, stopFileInterval, done)
+	}
+	if isi.StatusFile != "" {
+		if err := streamInfo.SaveCutoverStatus(isi.StatusFile, streamingState); err != nil {
+			return fmt.Errorf("can't save cutover status: %v", err)
+		}
+		background.Add(1)
+		go reportCutoverStatus(background, streamInfo, isi.StatusFile, cutoverStatusInterval, done)
+	}
 
 	wg := &sync.WaitGroup{}
 
@@ -231,13 +454,28 @@ func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *s
 	go catchCtrlC(wg, streamInfo)
 	go cutoverHelper(wg, streamInfo)
 
+	for srcTable := range conv.SrcSchema {
+		if _, ok := latestStreamArn[isi.Splitter.DynamoTable(srcTable)]; ok {
+			streamInfo.makeRecordMaps(srcTable)
+		}
+	}
 	for srcTable, streamArn := range latestStreamArn {
-		streamInfo.makeRecordMaps(srcTable)
-
 		wg.Add(1)
 		go ProcessStream(wg, isi.DynamoStreamsClient, streamInfo, conv, streamArn.(string), srcTable)
 	}
 	wg.Wait()
+	close(done)
+	background.Wait()
+	if isi.StreamCheckpoint != "" {
+		if err := streamInfo.SaveCheckpoint(isi.StreamCheckpoint); err != nil {
+			streamInfo.Unexpected(fmt.Sprintf("Couldn't save stream checkpoint: %s", err))
+		}
+	}
+	if isi.StatusFile != "" {
+		if err := streamInfo.SaveCutoverStatus(isi.StatusFile, stoppedState); err != nil {
+			streamInfo.Unexpected(fmt.Sprintf("Couldn't save cutover status: %s", err))
+		}
+	}
 
 	fillConvWithStreamingStats(streamInfo, conv)
 
@@ -314,9 +552,9 @@ func incTypeCount(attrName string, attr *dynamodb.AttributeValue, s map[string]i
 		s[typeBinary]++
 	case attr.NULL != nil:
 		// Skip, if not present, it means nullable.
-	case len(attr.L) != 0:
+	case attr.L != nil:
 		s[typeList]++
-	case len(attr.M) != 0:
+	case attr.M != nil:
 		s[typeMap]++
 	case len(attr.SS) != 0:
 		s[typeStringSet]++
@@ -340,69 +578,20 @@ func incTypeCount(attrName string, attr *dynamodb.AttributeValue, s map[string]i
 	}
 }
 
-type statItem struct {
-	Type  string
-	Count int64
-}
-
+// inferDataTypes infers column definitions from sampled type counts.
+// Columns with a significant conflict on data types default to a String
+// type, and the types observed for them are recorded for the report. Types
+// seen in too few items to be candidates don't make a conflict.
 func inferDataTypes(stats map[string]map[string]int64, rows int64, primaryKeys []string) (map[string]schema.Column, []string, error) {
-	colDefs := make(map[string]schema.Column)
-	var colNames []string
-
-	for col, countMap := range stats {
-		var statItems, candidates []statItem
-		var presentRows int64
-		for k, v := range countMap {
-			presentRows += v
-			if float64(v)/float64(rows) <= errThreshold {
-				// If the percentage is less than the error threshold, then
-				// this data type has a high chance to be mistakenly inserted
-				// and we should discard it.
-				continue
-			}
-			statItems = append(statItems, statItem{Type: k, Count: v})
-		}
-		if len(statItems) == 0 {
-			log.Printf("Skip column %v with no data records", col)
-			continue
-		}
-
-		// Check if the column is a part of a primary key.
-		isPKey := false
-		for _, pk := range primaryKeys {
-			if pk == col {
-				isPKey = true
-				break
-			}
-		}
-
-		// If this column is in the primary key, then it cannot be null.
-		nullable := false
-		if !isPKey {
-			nullable = float64(rows-presentRows)/float64(rows) > errThreshold
-		}
-
-		for _, si := range statItems {
-			if float64(si.Count)/float64(presentRows) > conflictThreshold {
-				// If the normalized percentage is greater than the conflicting
-				// threshold, we should consider this data type as a candidate.
-				candidates = append(candidates, si)
-			}
-		}
-
-		colNames = append(colNames, col)
-		if len(candidates) == 1 {
-			colDefs[col] = schema.Column{Name: col, Type: schema.Type{Name: candidates[0].Type}, NotNull: !nullable}
-		} else {
-			// If there is no any candidate or more than a single candidate,
-			// this column has a significant conflict on data types and then
-			// defaults to a String type.
-			colDefs[col] = schema.Column{Name: col, Type: schema.Type{Name: typeString}, NotNull: !nullable}
+	// Conflicting columns get an empty type, which no DynamoDB type has.
+	colDefs, colNames := common.InferColumns(stats, rows, primaryKeys, "")
+	for col, colDef := range colDefs {
+		if colDef.Type.Name == "" {
+			colDef.Type.Name = typeString
+			colDef.ObservedTypes = stats[col]
+			colDefs[col] = colDef
 		}
 	}
-	// Sort column names in increasing order, because the server may return them
-	// in a random order.
-	sort.Strings(colNames)
 	return colDefs, colNames, nil
 }
 
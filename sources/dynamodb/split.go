@@ -0,0 +1,218 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// SplitRule routes the items of a DynamoDB table that uses single-table
+// design to one Spanner table per entity type.
+type SplitRule struct {
+	Table     string       // DynamoDB table the rule applies to.
+	Attribute string       // Attribute that identifies the entity type of an item, e.g. an entity-type attribute or the sort key.
+	Entities  []EntityRule // Entity types, tried in order.
+}
+
+// EntityRule matches the items whose attribute is equal to Value or, if
+// Prefix is set, starts with Prefix. An EntityRule with neither matches
+// every item, so it can be used last as a catch-all.
+type EntityRule struct {
+	Name   string // Name of the entity type. Its source table is named <Table>.<Name>.
+	Value  string
+	Prefix string
+}
+
+func (e EntityRule) matches(v string) bool {
+	switch {
+	case e.Prefix != "":
+		return strings.HasPrefix(v, e.Prefix)
+	case e.Value != "":
+		return v == e.Value
+	}
+	return true
+}
+
+// Splitter routes the items of the tables that have a SplitRule to the
+// source tables of their entity types. It also keeps the data sample of
+// each split table, so that the schema of all its entity types is inferred
+// from a single scan. A nil *Splitter routes every item to its own table.
+type Splitter struct {
+	rules   map[string]SplitRule
+	mu      sync.Mutex
+	samples map[string]*entitySample
+}
+
+// entitySample is a data sample of a split table, broken down by entity.
+type entitySample struct {
+	stats  map[string]map[string]map[string]int64 // Maps entity to a map from column name to a count map of data types.
+	counts map[string]int64                       // Maps entity to its number of items in the sample.
+	total  int64                                  // Number of items in the sample, including unmatched ones.
+}
+
+// LoadSplitRules reads a JSON list of SplitRules from path.
+func LoadSplitRules(path string) (*Splitter, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read split rules %s: %v", path, err)
+	}
+	var rules []SplitRule
+	if err := json.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("can't parse split rules %s: %v", path, err)
+	}
+	return NewSplitter(rules)
+}
+
+// NewSplitter checks rules and returns a Splitter that applies them.
+func NewSplitter(rules []SplitRule) (*Splitter, error) {
+	s := &Splitter{rules: make(map[string]SplitRule), samples: make(map[string]*entitySample)}
+	for _, r := range rules {
+		if r.Table == "" || r.Attribute == "" {
+			return nil, fmt.Errorf("split rule %+v must have a table and an attribute", r)
+		}
+		if _, ok := s.rules[r.Table]; ok {
+			return nil, fmt.Errorf("table %s has more than one split rule", r.Table)
+		}
+		if len(r.Entities) == 0 {
+			return nil, fmt.Errorf("split rule for table %s has no entities", r.Table)
+		}
+		names := make(map[string]bool)
+		for _, e := range r.Entities {
+			if e.Name == "" || names[e.Name] {
+				return nil, fmt.Errorf("split rule for table %s has a missing or duplicate entity name %q", r.Table, e.Name)
+			}
+			names[e.Name] = true
+		}
+		s.rules[r.Table] = r
+	}
+	return s, nil
+}
+
+func (s *Splitter) rule(table string) (SplitRule, bool) {
+	if s == nil {
+		return SplitRule{}, false
+	}
+	r, ok := s.rules[table]
+	return r, ok
+}
+
+// splitTableName is the source table name of entity in table.
+func splitTableName(table, entity string) string {
+	return table + "." + entity
+}
+
+// entity returns the entity of item, using the rule of its table. It
+// returns false if no entity matches.
+func (r SplitRule) entity(item map[string]*dynamodb.AttributeValue) (string, bool) {
+	v := ""
+	if a := item[r.Attribute]; a != nil {
+		switch {
+		case a.S != nil:
+			v = *a.S
+		case a.N != nil:
+			v = *a.N
+		}
+	}
+	for _, e := range r.Entities {
+		if e.matches(v) {
+			return e.Name, true
+		}
+	}
+	return "", false
+}
+
+// Route returns the source table of an item of DynamoDB table. It returns
+// false if table is split and none of its entities matches the item.
+func (s *Splitter) Route(table string, item map[string]*dynamodb.AttributeValue) (string, bool) {
+	r, ok := s.rule(table)
+	if !ok {
+		return table, true
+	}
+	e, ok := r.entity(item)
+	if !ok {
+		return "", false
+	}
+	return splitTableName(table, e), true
+}
+
+// DynamoTable returns the DynamoDB table that the source table srcTable is
+// read from.
+func (s *Splitter) DynamoTable(srcTable string) string {
+	if s != nil {
+		for t, r := range s.rules {
+			for _, e := range r.Entities {
+				if splitTableName(t, e.Name) == srcTable {
+					return t
+				}
+			}
+		}
+	}
+	return srcTable
+}
+
+// sample scans up to sampleSize items of the split table, and counts the
+// data types of each attribute per entity. The sample is computed once and
+// shared by all the entities of the table.
+func (s *Splitter) sample(client dynamodbiface.DynamoDBAPI, sampleSize int64, table string) (*entitySample, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if es, ok := s.samples[table]; ok {
+		return es, nil
+	}
+	r := s.rules[table]
+	es := &entitySample{stats: make(map[string]map[string]map[string]int64), counts: make(map[string]int64)}
+	params := &dynamodb.ScanInput{
+		TableName: aws.String(table),
+	}
+	for es.total < sampleSize {
+		result, err := client.Scan(params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to make Query API call for table %v: %v", table, err)
+		}
+		for _, attrsMap := range result.Items {
+			es.total++
+			e, ok := r.entity(attrsMap)
+			if ok {
+				if es.stats[e] == nil {
+					es.stats[e] = make(map[string]map[string]int64)
+				}
+				for attrName, attr := range attrsMap {
+					if _, ok := es.stats[e][attrName]; !ok {
+						es.stats[e][attrName] = make(map[string]int64)
+					}
+					incTypeCount(attrName, attr, es.stats[e][attrName])
+				}
+				es.counts[e]++
+			}
+			if es.total >= sampleSize {
+				break
+			}
+		}
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		params.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+	s.samples[table] = es
+	return es, nil
+}
@@ -0,0 +1,264 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+var appRule = SplitRule{
+	Table:     "App",
+	Attribute: "SK",
+	Entities: []EntityRule{
+		{Name: "Customer", Value: "PROFILE"},
+		{Name: "Order", Prefix: "ORDER#"},
+	},
+}
+
+func appItem(pk, sk string, attrs map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	item := map[string]*dynamodb.AttributeValue{
+		"PK": {S: aws.String(pk)},
+		"SK": {S: aws.String(sk)},
+	}
+	for k, v := range attrs {
+		item[k] = v
+	}
+	return item
+}
+
+func TestNewSplitter(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []SplitRule
+		ok    bool
+	}{
+		{"valid", []SplitRule{appRule}, true},
+		{"no attribute", []SplitRule{{Table: "App", Entities: appRule.Entities}}, false},
+		{"no entities", []SplitRule{{Table: "App", Attribute: "SK"}}, false},
+		{"duplicate table", []SplitRule{appRule, appRule}, false},
+		{"duplicate entity", []SplitRule{{Table: "App", Attribute: "SK", Entities: []EntityRule{{Name: "A"}, {Name: "A"}}}}, false},
+	}
+	for _, tc := range tests {
+		_, err := NewSplitter(tc.rules)
+		assert.Equal(t, tc.ok, err == nil, tc.name)
+	}
+}
+
+func TestLoadSplitRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `[{"Table": "App", "Attribute": "type", "Entities": [{"Name": "User", "Value": "user"}, {"Name": "Other"}]}]`
+	assert.Nil(t, os.WriteFile(path, []byte(rules), 0644))
+	s, err := LoadSplitRules(path)
+	assert.Nil(t, err)
+	srcTable, ok := s.Route("App", map[string]*dynamodb.AttributeValue{"type": {S: aws.String("user")}})
+	assert.True(t, ok)
+	assert.Equal(t, "App.User", srcTable)
+	// The last entity has no value or prefix: it is a catch-all.
+	srcTable, ok = s.Route("App", map[string]*dynamodb.AttributeValue{"type": {S: aws.String("admin")}})
+	assert.True(t, ok)
+	assert.Equal(t, "App.Other", srcTable)
+
+	_, err = LoadSplitRules(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+}
+
+func TestSplitter_Route(t *testing.T) {
+	s, err := NewSplitter([]SplitRule{appRule})
+	assert.Nil(t, err)
+	tests := []struct {
+		table    string
+		item     map[string]*dynamodb.AttributeValue
+		srcTable string
+		ok       bool
+	}{
+		{"App", appItem("CUST#1", "PROFILE", nil), "App.Customer", true},
+		{"App", appItem("CUST#1", "ORDER#2022-01-01", nil), "App.Order", true},
+		{"App", appItem("CUST#1", "ADDRESS#1", nil), "", false},
+		{"App", map[string]*dynamodb.AttributeValue{"PK": {S: aws.String("CUST#1")}}, "", false},
+		{"Other", appItem("CUST#1", "ADDRESS#1", nil), "Other", true},
+	}
+	for _, tc := range tests {
+		srcTable, ok := s.Route(tc.table, tc.item)
+		assert.Equal(t, tc.ok, ok)
+		assert.Equal(t, tc.srcTable, srcTable)
+	}
+	assert.Equal(t, "App", s.DynamoTable("App.Order"))
+	assert.Equal(t, "App.Unknown", s.DynamoTable("App.Unknown"))
+
+	// A nil Splitter doesn't split any table.
+	var none *Splitter
+	srcTable, ok := none.Route("App", appItem("CUST#1", "ADDRESS#1", nil))
+	assert.True(t, ok)
+	assert.Equal(t, "App", srcTable)
+	assert.Equal(t, "App.Order", none.DynamoTable("App.Order"))
+}
+
+func appClient() *mockDynamoClient {
+	itemCount := int64(400)
+	desc := dynamodb.DescribeTableOutput{
+		Table: &dynamodb.TableDescription{
+			TableName: aws.String("App"),
+			ItemCount: &itemCount,
+			KeySchema: []*dynamodb.KeySchemaElement{
+				{AttributeName: aws.String("PK"), KeyType: aws.String("HASH")},
+				{AttributeName: aws.String("SK"), KeyType: aws.String("RANGE")},
+			},
+			GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{
+				{
+					IndexName: aws.String("ByEmail"),
+					KeySchema: []*dynamodb.KeySchemaElement{{AttributeName: aws.String("email"), KeyType: aws.String("HASH")}},
+				},
+			},
+		},
+	}
+	return &mockDynamoClient{
+		listTableOutputs:     []dynamodb.ListTablesOutput{{TableNames: []*string{aws.String("App")}}, {TableNames: []*string{aws.String("App")}}},
+		describeTableOutputs: []dynamodb.DescribeTableOutput{desc, desc, desc, desc, desc, desc},
+		scanOutputs: []dynamodb.ScanOutput{
+			{
+				Items: []map[string]*dynamodb.AttributeValue{
+					appItem("CUST#1", "PROFILE", map[string]*dynamodb.AttributeValue{"email": {S: aws.String("a@example.com")}}),
+					appItem("CUST#1", "ORDER#1", map[string]*dynamodb.AttributeValue{"total": {N: aws.String("10")}}),
+					appItem("CUST#1", "ORDER#2", map[string]*dynamodb.AttributeValue{"total": {N: aws.String("12")}}),
+					appItem("CUST#1", "ADDRESS#1", map[string]*dynamodb.AttributeValue{"city": {S: aws.String("Paris")}}),
+				},
+			},
+		},
+	}
+}
+
+func TestProcessSchema_Split(t *testing.T) {
+	s, err := NewSplitter([]SplitRule{appRule})
+	assert.Nil(t, err)
+	isi := InfoSchemaImpl{DynamoClient: appClient(), SampleSize: 100, Splitter: s}
+	conv := internal.MakeConv()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+
+	// Each entity has its own table, whose schema is inferred from the items
+	// of the entity only.
+	assert.Equal(t, []string{"PK", "SK", "email"}, conv.SrcSchema["App.Customer"].ColNames)
+	assert.Equal(t, []string{"PK", "SK", "total"}, conv.SrcSchema["App.Order"].ColNames)
+	assert.Len(t, conv.SrcSchema, 2)
+	// The index on email is only kept for the entity that has it.
+	assert.Len(t, conv.SrcSchema["App.Customer"].Indexes, 1)
+	assert.Empty(t, conv.SrcSchema["App.Order"].Indexes)
+
+	spTable, err := internal.GetSpannerTable(conv, "App.Order")
+	assert.Nil(t, err)
+	assert.Equal(t, ddl.Numeric, conv.SpSchema[spTable].ColDefs["total"].T.Name)
+
+	// Row counts are estimated from the share of each entity in the sample.
+	common.SetRowStats(conv, isi)
+	assert.Equal(t, int64(100), conv.Stats.Rows["App.Customer"])
+	assert.Equal(t, int64(200), conv.Stats.Rows["App.Order"])
+}
+
+func TestProcessData_Split(t *testing.T) {
+	s, err := NewSplitter([]SplitRule{appRule})
+	assert.Nil(t, err)
+	client := appClient()
+	isi := InfoSchemaImpl{DynamoClient: client, SampleSize: 100, Splitter: s}
+	conv := internal.MakeConv()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+
+	// Serve the same page to the data scan.
+	client.scanCallCount = 0
+	rows := make(map[string]int)
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		rows[table]++
+	})
+	common.ProcessData(conv, isi)
+	customer, _ := internal.GetSpannerTable(conv, "App.Customer")
+	order, _ := internal.GetSpannerTable(conv, "App.Order")
+	assert.Equal(t, map[string]int{customer: 1, order: 2}, rows)
+	// The table is scanned once for all its entities.
+	assert.Equal(t, 1, client.scanCallCount)
+	// The address matches no entity.
+	assert.Equal(t, int64(1), conv.Unexpecteds())
+	assert.Equal(t, map[string]map[string]int64{"App": {"": 1}}, conv.Audit.SkippedItems)
+}
+
+func TestProcessData_SplitEntityNotSampled(t *testing.T) {
+	rule := appRule
+	rule.Entities = append(rule.Entities, EntityRule{Name: "Address", Prefix: "ADDRESS#"})
+	s, err := NewSplitter([]SplitRule{rule})
+	assert.Nil(t, err)
+	client := appClient()
+	// The address is left out of the sample, so its entity has no table.
+	isi := InfoSchemaImpl{DynamoClient: client, SampleSize: 3, Splitter: s}
+	conv := internal.MakeConv()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+	assert.Len(t, conv.SrcSchema, 2)
+
+	client.scanCallCount = 0
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {})
+	common.ProcessData(conv, isi)
+	assert.Equal(t, map[string]map[string]int64{"App": {"Address": 1}}, conv.Audit.SkippedItems)
+
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.DYNAMODB, conv, w, nil, false, false)
+	w.Flush()
+	assert.Contains(t, buf.String(), "1 items of entity Address were skipped")
+}
+
+func TestProcessRecord_Split(t *testing.T) {
+	s, err := NewSplitter([]SplitRule{appRule})
+	assert.Nil(t, err)
+	conv := internal.MakeConv()
+	assert.Nil(t, common.ProcessSchema(conv, InfoSchemaImpl{DynamoClient: appClient(), SampleSize: 100, Splitter: s}, 1))
+
+	streamInfo := MakeStreamingInfo()
+	streamInfo.splitter = s
+	streamInfo.makeRecordMaps("App.Customer")
+	streamInfo.makeRecordMaps("App.Order")
+	var mutations []*sp.Mutation
+	streamInfo.write = func(m []*sp.Mutation) error {
+		mutations = append(mutations, m...)
+		return nil
+	}
+	record := &dynamodbstreams.Record{
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			NewImage: appItem("CUST#1", "ORDER#3", map[string]*dynamodb.AttributeValue{"total": {N: aws.String("7")}}),
+		},
+		EventName: aws.String("INSERT"),
+	}
+	ProcessRecord(conv, streamInfo, record, "App")
+	assert.Len(t, mutations, 1)
+	assert.Equal(t, int64(1), streamInfo.Records["App.Order"]["INSERT"])
+
+	// Records that match no entity are not written.
+	record.Dynamodb.NewImage = appItem("CUST#1", "ADDRESS#2", nil)
+	ProcessRecord(conv, streamInfo, record, "App")
+	assert.Len(t, mutations, 1)
+	assert.Equal(t, int64(1), streamInfo.Unexpecteds["Record of table App doesn't belong to any entity table"])
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// streamBatchSize is the maximum number of records of a shard committed to
+// Cloud Spanner in one transaction. It is kept well below the mutation limit
+// of a Spanner commit for tables with many columns.
+const streamBatchSize = 100
+
+// streamChange is a converted stream record, waiting to be written to Cloud
+// Spanner.
+type streamChange struct {
+	srcTable  string
+	spTable   string
+	eventName string
+	spCols    []string
+	spVals    []interface{}
+	m         *sp.Mutation
+	key       string     // Identifies the item changed. Changes with an empty key are never collapsed.
+	events    []string   // Event names of the records merged into this change, in order.
+	created   *time.Time // Approximate creation time of the record.
+}
+
+// newStreamChange builds the change, and its mutation, for a converted record.
+func newStreamChange(srcTable, spTable, eventName string, spCols []string, spVals []interface{}, srcSchema schema.Table) *streamChange {
+	return &streamChange{
+		srcTable:  srcTable,
+		spTable:   spTable,
+		eventName: eventName,
+		spCols:    spCols,
+		spVals:    spVals,
+		m:         getMutation(eventName, srcTable, spTable, spCols, spVals, srcSchema),
+		events:    []string{eventName},
+	}
+}
+
+// changeBatch groups consecutive changes of a shard. Changes to the same
+// item are collapsed into the last one, so that a batch has at most one
+// mutation per key: the order of the mutations within the batch then
+// doesn't matter, and batches are committed in order.
+type changeBatch struct {
+	changes   []*streamChange
+	byKey     map[string]int // Maps key to the index of its change.
+	collapsed int64          // Number of records merged with a later change.
+}
+
+func newChangeBatch() *changeBatch {
+	return &changeBatch{byKey: make(map[string]int)}
+}
+
+// add appends c to the batch, replacing the earlier change to the same key.
+// A new image replaces the whole item, so once earlier changes have been
+// dropped it is written with InsertOrUpdate: an INSERT that follows a
+// REMOVE must overwrite the item that the REMOVE would have deleted.
+func (b *changeBatch) add(c *streamChange) {
+	if c.key != "" {
+		if i, ok := b.byKey[c.key]; ok {
+			c.events = append(b.changes[i].events, c.events...)
+			if c.eventName != "REMOVE" {
+				c.m = sp.InsertOrUpdate(c.spTable, c.spCols, c.spVals)
+			}
+			b.changes[i] = c
+			b.collapsed++
+			return
+		}
+		b.byKey[c.key] = len(b.changes)
+	}
+	b.changes = append(b.changes, c)
+}
+
+// write commits the batch to Cloud Spanner in one transaction. If that
+// fails, the changes are written one by one, so that a bad change doesn't
+// drop the others.
+func (b *changeBatch) write(streamInfo *StreamingInfo) {
+	if len(b.changes) == 0 {
+		return
+	}
+	if streamInfo.write == nil {
+		msg := "Internal error: stream changes written but writer not configured"
+		for _, c := range b.changes {
+			for _, e := range c.events {
+				streamInfo.StatsAddBadRecord(c.srcTable, e)
+			}
+			streamInfo.Unexpected(msg)
+		}
+		return
+	}
+	var ms []*sp.Mutation
+	for _, c := range b.changes {
+		ms = append(ms, c.m)
+	}
+	err := writeMutations(ms, streamInfo)
+	if err != nil && len(b.changes) > 1 {
+		for _, c := range b.changes {
+			if err := writeMutations([]*sp.Mutation{c.m}, streamInfo); err != nil {
+				dropChange(streamInfo, c, err)
+			}
+		}
+	} else if err != nil {
+		dropChange(streamInfo, b.changes[0], err)
+	}
+	streamInfo.StatsAddBatch(b.collapsed, b.changes[len(b.changes)-1].created)
+}
+
+// dropChange records the records of a change that couldn't be written.
+func dropChange(streamInfo *StreamingInfo, c *streamChange, err error) {
+	for _, e := range c.events {
+		streamInfo.StatsAddDroppedRecord(c.srcTable, e)
+	}
+	streamInfo.CollectDroppedRecord(c.eventName, c.spTable, c.spCols, c.spVals, err)
+}
+
+// itemKey identifies the item of srcTable with the given key attributes.
+func itemKey(srcTable string, keys map[string]*dynamodb.AttributeValue) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	var names []string
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	l := []string{srcTable}
+	for _, k := range names {
+		l = append(l, k+"="+keys[k].String())
+	}
+	return strings.Join(l, "\x00")
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func batchConv(tableName string) *internal.Conv {
+	return buildConv(
+		ddl.CreateTable{
+			Name:     tableName,
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": {Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+				"b": {Name: "b", T: ddl.Type{Name: ddl.Numeric}},
+			},
+			Pks: []ddl.IndexKey{{Col: "a"}},
+		},
+		schema.Table{
+			Name:     tableName,
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]schema.Column{
+				"a": {Name: "a", Type: schema.Type{Name: typeString}},
+				"b": {Name: "b", Type: schema.Type{Name: typeNumber}},
+			},
+			PrimaryKeys: []schema.Key{{Column: "a"}},
+		},
+	)
+}
+
+func streamRecord(eventName, a string, b int, seq int) *dynamodbstreams.Record {
+	created := time.Now()
+	keys := map[string]*dynamodb.AttributeValue{"a": {S: aws.String(a)}}
+	r := &dynamodbstreams.Record{
+		EventName: aws.String(eventName),
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			Keys:                        keys,
+			SequenceNumber:              aws.String(strconv.Itoa(seq)),
+			ApproximateCreationDateTime: &created,
+		},
+	}
+	if eventName != "REMOVE" {
+		r.Dynamodb.NewImage = map[string]*dynamodb.AttributeValue{
+			"a": {S: aws.String(a)},
+			"b": {N: aws.String(strconv.Itoa(b))},
+		}
+	}
+	return r
+}
+
+func TestProcessRecords_Collapse(t *testing.T) {
+	tableName := "testtable"
+	conv := batchConv(tableName)
+	streamInfo := MakeStreamingInfo()
+	streamInfo.makeRecordMaps(tableName)
+	var batches [][]*sp.Mutation
+	streamInfo.write = func(m []*sp.Mutation) error {
+		batches = append(batches, m)
+		return nil
+	}
+	records := []*dynamodbstreams.Record{
+		streamRecord("INSERT", "x", 1, 1),
+		streamRecord("INSERT", "y", 2, 2),
+		streamRecord("MODIFY", "x", 3, 3),
+		streamRecord("MODIFY", "y", 4, 4),
+		streamRecord("REMOVE", "y", 0, 5),
+		streamRecord("INSERT", "z", 5, 6),
+	}
+	processRecords(conv, streamInfo, records, tableName)
+
+	// All the records are committed in one transaction, with one mutation
+	// per key: the last change to each key wins.
+	assert.Len(t, batches, 1)
+	// n is the Spanner value of DynamoDB number s.
+	n := func(s string) interface{} {
+		r, _, _ := cvtRow(map[string]*dynamodb.AttributeValue{"a": {S: aws.String("k")}, "b": {N: aws.String(s)}}, conv.SrcSchema[tableName], conv.SpSchema[tableName], []string{"a", "b"})
+		return r[1]
+	}
+	assert.Equal(t, []*sp.Mutation{
+		sp.InsertOrUpdate(tableName, []string{"a", "b"}, []interface{}{"x", n("3")}),
+		sp.Delete(tableName, sp.Key{"y"}),
+		sp.Insert(tableName, []string{"a", "b"}, []interface{}{"z", n("5")}),
+	}, batches[0])
+	assert.Equal(t, int64(1), streamInfo.batches)
+	assert.Equal(t, int64(3), streamInfo.collapsedRecords)
+	assert.Equal(t, map[string]int64{"INSERT": 3, "MODIFY": 2, "REMOVE": 1}, streamInfo.Records[tableName])
+	assert.Equal(t, int64(6), streamInfo.recordsProcessed)
+	assert.True(t, streamInfo.maxLag > 0)
+}
+
+func TestProcessRecords_Fallback(t *testing.T) {
+	tableName := "testtable"
+	conv := batchConv(tableName)
+	streamInfo := MakeStreamingInfo()
+	streamInfo.makeRecordMaps(tableName)
+	calls := 0
+	var written []*sp.Mutation
+	streamInfo.write = func(m []*sp.Mutation) error {
+		calls++
+		if len(m) > 1 {
+			return errors.New("batch failed")
+		}
+		if calls == 3 {
+			return errors.New("record not processed")
+		}
+		written = append(written, m...)
+		return nil
+	}
+	records := []*dynamodbstreams.Record{
+		streamRecord("INSERT", "x", 1, 1),
+		streamRecord("INSERT", "y", 2, 2),
+		streamRecord("MODIFY", "y", 3, 3),
+		streamRecord("INSERT", "z", 4, 4),
+	}
+	processRecords(conv, streamInfo, records, tableName)
+
+	// The failed batch is retried change by change: only the change to y,
+	// which merges two records, is dropped.
+	assert.Equal(t, 4, calls)
+	assert.Len(t, written, 2)
+	assert.Equal(t, map[string]int64{"INSERT": 1, "MODIFY": 1}, streamInfo.DroppedRecords[tableName])
+	assert.Len(t, streamInfo.SampleBadWrites, 1)
+}
+
+func TestProcessShard_Batches(t *testing.T) {
+	tableName := "testtable"
+	conv := batchConv(tableName)
+	streamInfo := MakeStreamingInfo()
+	streamInfo.makeRecordMaps(tableName)
+	var batchSizes []int
+	streamInfo.write = func(m []*sp.Mutation) error {
+		batchSizes = append(batchSizes, len(m))
+		return nil
+	}
+	var records []*dynamodbstreams.Record
+	for i := 0; i < streamBatchSize+10; i++ {
+		records = append(records, streamRecord("INSERT", strconv.Itoa(i), i, i+1))
+	}
+	shardId := "shard1"
+	client := &mockDynamoStreamsClient{
+		getShardIteratorOutputsTrimHorizon: []dynamodbstreams.GetShardIteratorOutput{{ShardIterator: aws.String("it")}},
+		getRecordsOutputs:                  []dynamodbstreams.GetRecordsOutput{{Records: records}},
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	ProcessShard(wg, streamInfo, conv, client, &dynamodbstreams.Shard{ShardId: &shardId}, "arn", tableName)
+
+	assert.Equal(t, []int{streamBatchSize, 10}, batchSizes)
+	assert.Equal(t, shardCheckpoint{SequenceNumber: strconv.Itoa(streamBatchSize + 10), Done: true}, streamInfo.shardCheckpoint(shardId))
+
+	fillConvWithStreamingStats(streamInfo, conv)
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.DYNAMODB, conv, w, nil, false, false)
+	w.Flush()
+	assert.Contains(t, buf.String(), "Count of batches committed to Cloud Spanner: 2 (55.0 records per batch on average, 0 records collapsed)")
+	assert.Contains(t, buf.String(), "Throughput: ")
+	assert.Contains(t, buf.String(), "Replication lag: ")
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// streamCheckpointInterval is how often the stream checkpoint is saved.
+const streamCheckpointInterval = 10 * time.Second
+
+// streamCheckpoint records the progress of a streaming migration, so that
+// it can be resumed after a restart.
+type streamCheckpoint struct {
+	Streams map[string]string          // Maps DynamoDB table to the ARN of the stream being processed.
+	Shards  map[string]shardCheckpoint // Maps shard id to its progress.
+}
+
+// shardCheckpoint is the progress of one shard. SequenceNumber is the last
+// record of the shard that was written to Spanner, and Done is set once the
+// shard is closed and all its records have been processed.
+type shardCheckpoint struct {
+	SequenceNumber string `json:",omitempty"`
+	Done           bool
+}
+
+func newStreamCheckpoint() *streamCheckpoint {
+	return &streamCheckpoint{
+		Streams: make(map[string]string),
+		Shards:  make(map[string]shardCheckpoint),
+	}
+}
+
+// loadStreamCheckpoint reads the stream checkpoint file at path.
+func loadStreamCheckpoint(path string) (*streamCheckpoint, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read stream checkpoint %s: %v", path, err)
+	}
+	cp := newStreamCheckpoint()
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("can't parse stream checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+// checkpointStreams saves the progress of the streams to path every
+// interval, until done is closed.
+func checkpointStreams(wg *sync.WaitGroup, streamInfo *StreamingInfo, path string, interval time.Duration, done <-chan struct{}) {
+	defer wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := streamInfo.SaveCheckpoint(path); err != nil {
+				streamInfo.Unexpected(fmt.Sprintf("Couldn't save stream checkpoint: %s", err))
+			}
+		}
+	}
+}
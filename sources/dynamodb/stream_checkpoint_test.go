@@ -0,0 +1,138 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func TestProcessShard_ResumeFromCheckpoint(t *testing.T) {
+	tableName := "testtable"
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     tableName,
+			ColNames: []string{"a"},
+			ColDefs:  map[string]ddl.ColumnDef{"a": {Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}},
+			Pks:      []ddl.IndexKey{{Col: "a"}},
+		},
+		schema.Table{
+			Name:        tableName,
+			ColNames:    []string{"a"},
+			ColDefs:     map[string]schema.Column{"a": {Name: "a", Type: schema.Type{Name: typeString}}},
+			PrimaryKeys: []schema.Key{{Column: "a"}},
+		},
+	)
+	streamInfo := MakeStreamingInfo()
+	streamInfo.makeRecordMaps(tableName)
+	streamInfo.write = func(m []*sp.Mutation) error { return nil }
+	shardId := "shard1"
+	streamInfo.checkpoint.Shards[shardId] = shardCheckpoint{SequenceNumber: "5"}
+
+	client := &mockDynamoStreamsClient{
+		getShardIteratorOutputsSeqNum: []dynamodbstreams.GetShardIteratorOutput{{ShardIterator: aws.String("it")}},
+		getRecordsOutputs: []dynamodbstreams.GetRecordsOutput{
+			{
+				Records: []*dynamodbstreams.Record{
+					{
+						EventName: aws.String("INSERT"),
+						Dynamodb: &dynamodbstreams.StreamRecord{
+							NewImage:       map[string]*dynamodb.AttributeValue{"a": {S: aws.String("x")}},
+							SequenceNumber: aws.String("7"),
+						},
+					},
+				},
+			},
+		},
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	ProcessShard(wg, streamInfo, conv, client, &dynamodbstreams.Shard{ShardId: &shardId}, "arn", tableName)
+
+	// The shard is read after the saved sequence number, not from the trim horizon.
+	assert.Equal(t, 1, client.getShardIteratorCallCountSeqNum)
+	assert.Equal(t, 0, client.getShardIteratorCallCountTrimHorizon)
+	assert.Equal(t, shardCheckpoint{SequenceNumber: "7", Done: true}, streamInfo.shardCheckpoint(shardId))
+	assert.Equal(t, int64(1), streamInfo.Records[tableName]["INSERT"])
+}
+
+func TestProcessShard_DoneInCheckpoint(t *testing.T) {
+	streamInfo := MakeStreamingInfo()
+	shardId := "shard1"
+	streamInfo.checkpoint.Shards[shardId] = shardCheckpoint{SequenceNumber: "5", Done: true}
+	client := &mockDynamoStreamsClient{}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	// The parent shard isn't done, but the shard is skipped without waiting.
+	ProcessShard(wg, streamInfo, nil, client, &dynamodbstreams.Shard{ShardId: &shardId, ParentShardId: aws.String("parent")}, "arn", "t")
+	assert.Equal(t, 0, client.getShardIteratorCallCountSeqNum+client.getShardIteratorCallCountTrimHorizon)
+	assert.True(t, streamInfo.ShardProcessed[shardId])
+}
+
+func TestStreamCheckpoint_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.json")
+	streamInfo := MakeStreamingInfo()
+	streamInfo.checkpoint.Streams["t"] = "arn"
+	streamInfo.SetShardSequenceNumber("shard1", "12")
+	streamInfo.SetShardSequenceNumber("shard2", "3")
+	streamInfo.SetShardDone("shard2")
+
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go checkpointStreams(wg, streamInfo, path, time.Millisecond, done)
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	cp, err := loadStreamCheckpoint(path)
+	assert.Nil(t, err)
+	assert.Equal(t, &streamCheckpoint{
+		Streams: map[string]string{"t": "arn"},
+		Shards: map[string]shardCheckpoint{
+			"shard1": {SequenceNumber: "12"},
+			"shard2": {SequenceNumber: "3", Done: true},
+		},
+	}, cp)
+
+	_, err = loadStreamCheckpoint(filepath.Join(t.TempDir(), "missing.json"))
+	assert.NotNil(t, err)
+}
+
+func TestStartChangeDataCapture_Resume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.json")
+	streamInfo := MakeStreamingInfo()
+	streamInfo.checkpoint.Streams["t"] = "arn-before-restart"
+	assert.Nil(t, streamInfo.SaveCheckpoint(path))
+
+	// No stream is created or looked up: the client isn't called.
+	isi := InfoSchemaImpl{DynamoClient: &mockDynamoClient{}, StreamCheckpoint: path, StreamResume: true}
+	got, err := isi.StartChangeDataCapture(context.Background(), internal.MakeConv())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"t": "arn-before-restart"}, got)
+}
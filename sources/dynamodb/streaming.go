@@ -91,7 +91,7 @@ func catchCtrlC(wg *sync.WaitGroup, streamInfo *StreamingInfo) {
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
-		streamInfo.UserExit = true
+		streamInfo.SetUserExit()
 	}()
 }
 
@@ -115,34 +115,15 @@ func cutoverHelper(wg *sync.WaitGroup, streamInfo *StreamingInfo) {
 
 	updateProgress(false, true, streamInfo.recordsProcessed)
 
-	timer := int64(0)
-	firstFiveMin := int64(0)
-	lastFiveMin := int64(0)
-	tillLastMin := int64(0)
-	arr := [5]int64{0, 0, 0, 0, 0}
-
+	var tracker common.ReadinessTracker
 	for {
 		time.Sleep(60 * time.Second)
-		if streamInfo.UserExit {
+		if streamInfo.UserExited() {
 			break
 		}
-		counter := timer % 5
-
-		lastFiveMin -= arr[counter]
-
-		arr[counter] = streamInfo.recordsProcessed - tillLastMin
-		tillLastMin += arr[counter]
-
-		lastFiveMin += arr[counter]
-
-		if timer < 5 {
-			firstFiveMin += arr[counter]
-		}
-
-		lastMin := arr[counter]
-		optimumCondition := ((lastFiveMin*100 <= 5*firstFiveMin) || (lastMin == 0))
-		updateProgress(optimumCondition, false, tillLastMin)
-		timer++
+		optimumCondition, lastMin := tracker.Update(streamInfo.recordsProcessed)
+		streamInfo.SetCutoverReadiness(optimumCondition, lastMin)
+		updateProgress(optimumCondition, false, tracker.Total())
 	}
 }
 
@@ -166,7 +147,9 @@ func ProcessStream(wgStream *sync.WaitGroup, streamClient dynamodbstreamsiface.D
 			shardId := *shard.ShardId
 			if _, ok := processingStarted[shardId]; !ok {
 				processingStarted[shardId] = false
-				streamInfo.SetShardStatus(shardId, false)
+				// Shards finished before a restart count as processed, so
+				// that their children don't wait for them.
+				streamInfo.SetShardStatus(shardId, streamInfo.shardCheckpoint(shardId).Done)
 			}
 		}
 		for _, shard := range shards {
@@ -181,7 +164,7 @@ func ProcessStream(wgStream *sync.WaitGroup, streamClient dynamodbstreamsiface.D
 
 		if passAfterUserExit {
 			break
-		} else if streamInfo.UserExit {
+		} else if streamInfo.UserExited() {
 			passAfterUserExit = true
 		} else {
 			time.Sleep(20 * time.Second)
@@ -218,24 +201,36 @@ func checkTrimmedDataError(err error) bool {
 	return strings.Contains(err.Error(), "TrimmedDataAccessException")
 }
 
-// ProcessShard processes records within a shard starting from the first unexpired record. It
+// ProcessShard processes records within a shard starting from the first unexpired record, or
+// after the last record saved in the stream checkpoint if the migration is resumed. It
 // doesn't start processing unless parent shard is processed. For closed shards this process is
 // completed after processing all records but for open shards it keeps searching for new records
 // until shards gets closed or customer calls for a exit.
 func ProcessShard(wgShard *sync.WaitGroup, streamInfo *StreamingInfo, conv *internal.Conv, streamClient dynamodbstreamsiface.DynamoDBStreamsAPI, shard *dynamodbstreams.Shard, streamArn, srcTable string) {
 	defer wgShard.Done()
 
-	waitForParentShard(streamInfo, shard.ParentShardId)
-
 	shardId := *shard.ShardId
+	checkpoint := streamInfo.shardCheckpoint(shardId)
+	if checkpoint.Done {
+		streamInfo.SetShardStatus(shardId, true)
+		return
+	}
+
+	waitForParentShard(streamInfo, shard.ParentShardId)
 
 	var lastEvaluatedSequenceNumber *string = nil
+	if checkpoint.SequenceNumber != "" {
+		lastEvaluatedSequenceNumber = aws.String(checkpoint.SequenceNumber)
+	}
 	passAfterUserExit := false
 	retryCount := 0
 	for {
 		shardIterator, err := getShardIterator(streamClient, lastEvaluatedSequenceNumber, shardId, streamArn)
 		if err != nil {
 			if checkTrimmedDataError(err) {
+				if lastEvaluatedSequenceNumber != nil {
+					streamInfo.Unexpected(fmt.Sprintf("Records after sequence number %s of shard %s have expired: some changes to table %s may be missing", *lastEvaluatedSequenceNumber, shardId, srcTable))
+				}
 				lastEvaluatedSequenceNumber = nil
 				continue
 			} else {
@@ -262,16 +257,29 @@ func ProcessShard(wgShard *sync.WaitGroup, streamInfo *StreamingInfo, conv *inte
 			retryCount = 0
 		}
 
+		// Records are written in batches, in order: a batch is committed
+		// before the next one is converted, so the changes to a key are
+		// applied in the order of the shard.
 		records := getRecordsOutput.Records
-		for _, record := range records {
-			ProcessRecord(conv, streamInfo, record, srcTable)
-			lastEvaluatedSequenceNumber = record.Dynamodb.SequenceNumber
+		for i := 0; i < len(records); i += streamBatchSize {
+			end := i + streamBatchSize
+			if end > len(records) {
+				end = len(records)
+			}
+			batch := records[i:end]
+			processRecords(conv, streamInfo, batch, srcTable)
+			lastEvaluatedSequenceNumber = batch[len(batch)-1].Dynamodb.SequenceNumber
+			streamInfo.SetShardSequenceNumber(shardId, *lastEvaluatedSequenceNumber)
 		}
 
-		if getRecordsOutput.NextShardIterator == nil || passAfterUserExit {
+		if getRecordsOutput.NextShardIterator == nil {
+			streamInfo.SetShardDone(shardId)
+			break
+		}
+		if passAfterUserExit {
 			break
 		}
-		if streamInfo.UserExit {
+		if streamInfo.UserExited() {
 			passAfterUserExit = true
 		} else if len(records) == 0 {
 			time.Sleep(5 * time.Second)
@@ -340,50 +348,69 @@ func getRecords(streamClient dynamodbstreamsiface.DynamoDBStreamsAPI, shardItera
 
 // ProcessRecord processes records retrieved from shards. It first converts the data
 // to Spanner data (based on the source and Spanner schemas), and then writes that data
-// to Cloud Spanner.
-func ProcessRecord(conv *internal.Conv, streamInfo *StreamingInfo, record *dynamodbstreams.Record, srcTable string) {
-	eventName := *record.EventName
-	streamInfo.StatsAddRecord(srcTable, eventName)
+// to Cloud Spanner. Records of tables split by entity type are routed to the
+// table of their entity.
+func ProcessRecord(conv *internal.Conv, streamInfo *StreamingInfo, record *dynamodbstreams.Record, table string) {
+	processRecords(conv, streamInfo, []*dynamodbstreams.Record{record}, table)
+}
 
-	srcSchema, spTable, spCols, spSchema, err := common.GetColsAndSchemas(conv, srcTable)
-	if err != nil {
-		streamInfo.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: %v", srcTable, err))
-		return
+// processRecords converts a batch of consecutive records of a shard, and
+// writes them to Cloud Spanner in one transaction.
+func processRecords(conv *internal.Conv, streamInfo *StreamingInfo, records []*dynamodbstreams.Record, table string) {
+	b := newChangeBatch()
+	for _, record := range records {
+		if c := convertRecord(conv, streamInfo, record, table); c != nil {
+			b.add(c)
+		}
 	}
+	b.write(streamInfo)
+}
+
+// convertRecord converts a record to Spanner data and builds its mutation.
+// It returns nil if the record can't be written.
+func convertRecord(conv *internal.Conv, streamInfo *StreamingInfo, record *dynamodbstreams.Record, table string) *streamChange {
+	eventName := *record.EventName
 
 	var srcImage map[string]*dynamodb.AttributeValue
+	routeImage := record.Dynamodb.NewImage
 	if eventName == "REMOVE" {
 		srcImage = record.Dynamodb.Keys
+		// The entity attribute may not be a key: use the old image if the
+		// stream has one. NEW_IMAGE streams have none, so their REMOVE
+		// records are routed by the keys alone.
+		routeImage = record.Dynamodb.OldImage
+		if routeImage == nil {
+			routeImage = record.Dynamodb.Keys
+		}
 	} else {
 		srcImage = record.Dynamodb.NewImage
 	}
 
-	spVals, badCols, srcStrVals := cvtRow(srcImage, srcSchema, spSchema, spCols)
-	if len(badCols) == 0 {
-		writeRecord(streamInfo, srcTable, spTable, eventName, spCols, spVals, srcSchema)
-	} else {
-		streamInfo.StatsAddBadRecord(srcTable, eventName)
-		streamInfo.CollectBadRecord(eventName, srcTable, srcSchema.ColNames, srcStrVals)
+	srcTable, ok := streamInfo.splitter.Route(table, routeImage)
+	if !ok {
+		streamInfo.Unexpected(fmt.Sprintf("Record of table %s doesn't belong to any entity table", table))
+		streamInfo.StatsAddRecordProcessed()
+		return nil
+	}
+	streamInfo.StatsAddRecord(srcTable, eventName)
+
+	srcSchema, spTable, spCols, spSchema, err := common.GetColsAndSchemas(conv, srcTable)
+	if err != nil {
+		streamInfo.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: %v", srcTable, err))
+		return nil
 	}
-	streamInfo.StatsAddRecordProcessed()
-}
 
-// writeRecord handles creation and processing of mutation from the converted data to Cloud Spanner.
-// If the writer which writes mutations to Cloud Spanner is not configured then it treats the record
-// as a bad record.
-func writeRecord(streamInfo *StreamingInfo, srcTable, spTable, eventName string, spCols []string, spVals []interface{}, srcSchema schema.Table) {
-	if streamInfo.write == nil {
-		msg := "Internal error: writeRecord called but writer not configured"
+	spVals, badCols, srcStrVals := cvtRow(srcImage, srcSchema, spSchema, spCols)
+	streamInfo.StatsAddRecordProcessed()
+	if len(badCols) != 0 {
 		streamInfo.StatsAddBadRecord(srcTable, eventName)
-		streamInfo.Unexpected(msg)
-	} else {
-		m := getMutation(eventName, srcTable, spTable, spCols, spVals, srcSchema)
-		err := writeMutation(m, streamInfo)
-		if err != nil {
-			streamInfo.StatsAddDroppedRecord(srcTable, eventName)
-			streamInfo.CollectDroppedRecord(eventName, spTable, spCols, spVals, err)
-		}
+		streamInfo.CollectBadRecord(eventName, srcTable, srcSchema.ColNames, srcStrVals)
+		return nil
 	}
+	c := newStreamChange(srcTable, spTable, eventName, spCols, spVals, srcSchema)
+	c.key = itemKey(srcTable, record.Dynamodb.Keys)
+	c.created = record.Dynamodb.ApproximateCreationDateTime
+	return c
 }
 
 // getMutation creates a mutation for writing to Cloud Spanner from the converted data.
@@ -431,9 +458,10 @@ func parentDataMissingError(err error) bool {
 	return strings.Contains(err.Error(), "NotFound") && strings.Contains(err.Error(), "Parent row") && strings.Contains(err.Error(), "is missing")
 }
 
-// writeMutation handles writing of a mutation to Cloud Spanner. To handle insertions failing
-// because of missing parent data, a retryLimit is set.
-func writeMutation(m *sp.Mutation, streamInfo *StreamingInfo) error {
+// writeMutations handles writing of the mutations of a batch to Cloud Spanner, in one
+// transaction. To handle insertions failing because of missing parent data, a retryLimit
+// is set.
+func writeMutations(m []*sp.Mutation, streamInfo *StreamingInfo) error {
 	var err error
 	tryNum := 0
 	for tryNum < retryLimit {
@@ -449,11 +477,11 @@ func writeMutation(m *sp.Mutation, streamInfo *StreamingInfo) error {
 
 // setWriter initializes the write function used to write mutations to Cloud Spanner.
 func setWriter(streamInfo *StreamingInfo, client *sp.Client, conv *internal.Conv) {
-	streamInfo.write = func(m *sp.Mutation) error {
+	streamInfo.write = func(m []*sp.Mutation) error {
 		migrationData := metrics.GetMigrationData(conv, "", "", constants.DataConv)
 		serializedMigrationData, _ := proto.Marshal(migrationData)
 		migrationMetadataValue := base64.StdEncoding.EncodeToString(serializedMigrationData)
-		_, err := client.Apply(metadata.AppendToOutgoingContext(context.Background(), constants.MigrationMetadataKey, migrationMetadataValue), []*sp.Mutation{m})
+		_, err := client.Apply(metadata.AppendToOutgoingContext(context.Background(), constants.MigrationMetadataKey, migrationMetadataValue), m)
 		return err
 	}
 }
@@ -474,6 +502,11 @@ func fillConvWithStreamingStats(streamInfo *StreamingInfo, conv *internal.Conv)
 	conv.Audit.StreamingStats.TotalRecords = streamInfo.Records
 	conv.Audit.StreamingStats.BadRecords = streamInfo.BadRecords
 	conv.Audit.StreamingStats.DroppedRecords = streamInfo.DroppedRecords
+	conv.Audit.StreamingStats.Batches = streamInfo.batches
+	conv.Audit.StreamingStats.CollapsedRecords = streamInfo.collapsedRecords
+	conv.Audit.StreamingStats.LastLag = streamInfo.lastLag
+	conv.Audit.StreamingStats.MaxLag = streamInfo.maxLag
+	conv.Audit.StreamingStats.Duration = time.Since(streamInfo.started)
 
 	// Pass badRecords and droppedRecords
 	conv.Audit.StreamingStats.SampleBadRecords = streamInfo.SampleBadRecords
@@ -14,8 +14,10 @@
 package dynamodb
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
 	sp "cloud.google.com/go/spanner"
 
@@ -24,16 +26,25 @@ import (
 
 // StreamingInfo contains information related to processing of DynamoDB Streams.
 type StreamingInfo struct {
-	Records          map[string]map[string]int64 // Tablewise count of records received from DynamoDB Streams, broken down by record type i.e. INSERT, MODIFY & REMOVE.
-	BadRecords       map[string]map[string]int64 // Tablewise count of records not converted successfully, broken down by record type.
-	DroppedRecords   map[string]map[string]int64 // Tablewise count of records successfully converted but failed to written on Spanner, broken down by record type.
-	recordsProcessed int64                       // Count of total records processed to Cloud Spanner(includes records which generated error as well).
-	ShardProcessed   map[string]bool             // Processing status of a shard, (default false i.e. unprocessed).
-	UserExit         bool                        // Flag confirming if customer wants to exit or not, (false until user presses Ctrl+C).
-	Unexpecteds      map[string]int64            // Count of unexpected conditions, broken down by condition description.
-	write            func(m *sp.Mutation) error  // Writes a given mutation to Cloud Spanner.
-	SampleBadRecords []string                    // Records that generated errors during conversion.
-	SampleBadWrites  []string                    // Records that faced errors while writing to Cloud Spanner.
+	Records          map[string]map[string]int64  // Tablewise count of records received from DynamoDB Streams, broken down by record type i.e. INSERT, MODIFY & REMOVE.
+	BadRecords       map[string]map[string]int64  // Tablewise count of records not converted successfully, broken down by record type.
+	DroppedRecords   map[string]map[string]int64  // Tablewise count of records successfully converted but failed to written on Spanner, broken down by record type.
+	recordsProcessed int64                        // Count of total records processed to Cloud Spanner(includes records which generated error as well).
+	ShardProcessed   map[string]bool              // Processing status of a shard, (default false i.e. unprocessed).
+	UserExit         bool                         // Flag confirming if customer wants to exit or not, (false until user presses Ctrl+C).
+	Unexpecteds      map[string]int64             // Count of unexpected conditions, broken down by condition description.
+	write            func(m []*sp.Mutation) error // Writes the mutations of a batch to Cloud Spanner, in one transaction.
+	SampleBadRecords []string                     // Records that generated errors during conversion.
+	SampleBadWrites  []string                     // Records that faced errors while writing to Cloud Spanner.
+	splitter         *Splitter                    // Routes the records of tables split by entity type.
+	checkpoint       *streamCheckpoint            // Progress of each shard, saved to resume after a restart.
+	batches          int64                        // Count of batches committed to Cloud Spanner.
+	collapsedRecords int64                        // Count of records merged with a later change to the same key in their batch.
+	lastLag          time.Duration                // Time between the creation of the last committed record and its commit.
+	maxLag           time.Duration                // Maximum lag observed.
+	started          time.Time                    // Start of the processing of the streams.
+	readyForCutover  bool                         // Latest decision of the cutover helper.
+	recordsLastMin   int64                        // Count of records processed in the last complete minute.
 	lock             sync.Mutex
 }
 
@@ -46,6 +57,8 @@ func MakeStreamingInfo() *StreamingInfo {
 		ShardProcessed:   make(map[string]bool),
 		Unexpecteds:      make(map[string]int64),
 		UserExit:         false,
+		checkpoint:       newStreamCheckpoint(),
+		started:          time.Now(),
 		lock:             sync.Mutex{},
 	}
 }
@@ -67,6 +80,100 @@ func (info *StreamingInfo) SetShardStatus(shardId string, status bool) {
 	info.lock.Unlock()
 }
 
+// SetUserExit records that the customer wants to stop the processing of the
+// streams.
+func (info *StreamingInfo) SetUserExit() {
+	info.lock.Lock()
+	info.UserExit = true
+	info.lock.Unlock()
+}
+
+// UserExited returns whether the customer wants to stop the processing of the
+// streams.
+func (info *StreamingInfo) UserExited() bool {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	return info.UserExit
+}
+
+// shardCheckpoint returns the saved progress of a shard.
+func (info *StreamingInfo) shardCheckpoint(shardId string) shardCheckpoint {
+	info.lock.Lock()
+	defer info.lock.Unlock()
+	return info.checkpoint.Shards[shardId]
+}
+
+// SetShardSequenceNumber records the sequence number of the last record of a
+// shard written to Cloud Spanner.
+func (info *StreamingInfo) SetShardSequenceNumber(shardId, sequenceNumber string) {
+	info.lock.Lock()
+	info.checkpoint.Shards[shardId] = shardCheckpoint{SequenceNumber: sequenceNumber}
+	info.lock.Unlock()
+}
+
+// SetShardDone records that all the records of a closed shard have been
+// processed.
+func (info *StreamingInfo) SetShardDone(shardId string) {
+	info.lock.Lock()
+	cp := info.checkpoint.Shards[shardId]
+	cp.Done = true
+	info.checkpoint.Shards[shardId] = cp
+	info.lock.Unlock()
+}
+
+// StatsAddBatch records the commit of a batch, in which collapsed records
+// were merged with a later change to the same key. created is the creation
+// time of the last record of the batch, if known.
+func (info *StreamingInfo) StatsAddBatch(collapsed int64, created *time.Time) {
+	info.lock.Lock()
+	info.batches++
+	info.collapsedRecords += collapsed
+	if created != nil {
+		info.lastLag = time.Since(*created)
+		if info.lastLag > info.maxLag {
+			info.maxLag = info.lastLag
+		}
+	}
+	info.lock.Unlock()
+}
+
+// SaveCheckpoint writes the progress of the streams to path.
+func (info *StreamingInfo) SaveCheckpoint(path string) error {
+	info.lock.Lock()
+	b, err := json.Marshal(info.checkpoint)
+	info.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	return writeJSONFile(path, json.RawMessage(b))
+}
+
+// SetCutoverReadiness records the latest decision of the cutover helper,
+// and the count of records processed in the last minute.
+func (info *StreamingInfo) SetCutoverReadiness(ready bool, recordsLastMin int64) {
+	info.lock.Lock()
+	info.readyForCutover = ready
+	info.recordsLastMin = recordsLastMin
+	info.lock.Unlock()
+}
+
+// SaveCutoverStatus writes the cutover status of the migration to path, in
+// the given state.
+func (info *StreamingInfo) SaveCutoverStatus(path, state string) error {
+	info.lock.Lock()
+	status := cutoverStatus{
+		State:                    state,
+		ReadyForCutover:          info.readyForCutover,
+		RecordsProcessed:         info.recordsProcessed,
+		RecordsLastMinute:        info.recordsLastMin,
+		ReplicationLagSeconds:    info.lastLag.Seconds(),
+		MaxReplicationLagSeconds: info.maxLag.Seconds(),
+		UpdatedAt:                time.Now(),
+	}
+	info.lock.Unlock()
+	return writeJSONFile(path, status)
+}
+
 // StatsAddRecord increases the count of records read from DynamoDB Streams
 // based on the table name and record type.
 func (info *StreamingInfo) StatsAddRecord(srcTable, recordType string) {
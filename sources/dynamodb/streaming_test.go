@@ -14,13 +14,11 @@
 package dynamodb
 
 import (
-	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
 	"sync"
 	"testing"
-	"time"
 
 	sp "cloud.google.com/go/spanner"
 	"github.com/aws/aws-sdk-go/aws"
@@ -326,7 +324,10 @@ func TestProcessShard(t *testing.T) {
 	wgShard.Add(1)
 	ProcessShard(wgShard, streamInfo, nil, mockStreamClient, shard, streamArn, srcTable)
 	assert.Equal(t, true, streamInfo.ShardProcessed[*shard.ShardId])
+	assert.Equal(t, shardCheckpoint{Done: true}, streamInfo.shardCheckpoint(shardId))
 
+	// Forget that the shard is done, so that it is processed again.
+	streamInfo.checkpoint = newStreamCheckpoint()
 	wgShard.Add(1)
 	ProcessShard(wgShard, streamInfo, nil, mockStreamClient, shard, streamArn, srcTable)
 	assert.Equal(t, int64(1), streamInfo.TotalUnexpecteds())
@@ -375,9 +376,9 @@ func TestProcessRecord(t *testing.T) {
 	streamInfo := MakeStreamingInfo()
 	streamInfo.Records[tableName] = make(map[string]int64)
 	writes := 0
-	streamInfo.write = func(m *sp.Mutation) error {
+	streamInfo.write = func(m []*sp.Mutation) error {
 		writes++
-		assert.Equal(t, m, sp.Insert(tableName, []string{"a", "b"}, []interface{}{valA, *numVal}))
+		assert.Equal(t, []*sp.Mutation{sp.Insert(tableName, []string{"a", "b"}, []interface{}{valA, *numVal})}, m)
 		return nil
 	}
 	ProcessRecord(conv, streamInfo, record, tableName)
@@ -386,6 +387,55 @@ func TestProcessRecord(t *testing.T) {
 	assert.Equal(t, 1, writes)
 }
 
+func TestProcessRecord_Map(t *testing.T) {
+	valA := "strA"
+	numStr := "10.10"
+	tableName := "testtable"
+	cols := []string{"a", "b"}
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     tableName,
+			ColNames: cols,
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": {Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+				"b": {Name: "b", T: ddl.Type{Name: ddl.JSON}},
+			},
+			Pks: []ddl.IndexKey{{Col: "a"}},
+		},
+		schema.Table{
+			Name:     tableName,
+			ColNames: cols,
+			ColDefs: map[string]schema.Column{
+				"a": {Name: "a", Type: schema.Type{Name: typeString}},
+				"b": {Name: "b", Type: schema.Type{Name: typeMap}},
+			},
+			PrimaryKeys: []schema.Key{{Column: "a"}},
+		},
+	)
+	record := &dynamodbstreams.Record{
+		Dynamodb: &dynamodbstreams.StreamRecord{
+			NewImage: map[string]*dynamodb.AttributeValue{
+				"a": {S: &valA},
+				"b": {M: map[string]*dynamodb.AttributeValue{
+					"n": {N: &numStr},
+					"l": {L: []*dynamodb.AttributeValue{{B: []byte("ABC")}}},
+				}},
+			},
+		},
+		EventName: aws.String("MODIFY"),
+	}
+	streamInfo := MakeStreamingInfo()
+	streamInfo.Records[tableName] = make(map[string]int64)
+	writes := 0
+	streamInfo.write = func(m []*sp.Mutation) error {
+		writes++
+		assert.Equal(t, []*sp.Mutation{sp.InsertOrUpdate(tableName, cols, []interface{}{valA, `{"l":["QUJD"],"n":10.10}`})}, m)
+		return nil
+	}
+	ProcessRecord(conv, streamInfo, record, tableName)
+	assert.Equal(t, 1, writes)
+}
+
 func Test_getMutation(t *testing.T) {
 	srcTable := "testtable_src"
 	spTable := "testtable_sp"
@@ -448,130 +498,3 @@ func Test_getMutation(t *testing.T) {
 		})
 	}
 }
-
-func Test_writeRecord(t *testing.T) {
-	streamInfo := MakeStreamingInfo()
-
-	table := "testTable"
-	streamInfo.Records[table] = make(map[string]int64)
-	streamInfo.BadRecords[table] = make(map[string]int64)
-	streamInfo.DroppedRecords[table] = make(map[string]int64)
-
-	srcSchema := schema.Table{
-		Name:     table,
-		ColNames: []string{"e", "f"},
-		ColDefs: map[string]schema.Column{
-			"e": {Name: "e", Type: schema.Type{Name: typeString}},
-			"f": {Name: "f", Type: schema.Type{Name: typeNumber}},
-		},
-		PrimaryKeys: []schema.Key{schema.Key{Column: "f"}, schema.Key{Column: "e"}},
-	}
-	tests := []struct {
-		srcTable  string
-		spTable   string
-		eventName string
-		spCols    []string
-		spVals    []interface{}
-		srcSchema schema.Table
-	}{
-		{
-			srcTable:  table,
-			spTable:   table,
-			eventName: "INSERT",
-			spCols:    []string{"a", "b"},
-			spVals:    []interface{}{23, true},
-		},
-		{
-			srcTable:  table,
-			spTable:   table,
-			eventName: "MODIFY",
-			spCols:    []string{"c", "d"},
-			spVals:    []interface{}{"goodTesting", 45},
-		},
-		{
-			srcTable:  table,
-			spTable:   table,
-			eventName: "INSERT",
-			spCols:    []string{"a", "b"},
-			spVals:    []interface{}{27, false},
-		},
-		{
-			srcTable:  table,
-			spTable:   table,
-			eventName: "MODIFY",
-			spCols:    []string{"c", "d"},
-			spVals:    []interface{}{"badTesting", 49},
-		},
-		{
-			srcTable:  table,
-			spTable:   table,
-			eventName: "REMOVE",
-			spCols:    []string{"e", "f"},
-			spVals:    []interface{}{"goodTesting", 45},
-			srcSchema: srcSchema,
-		},
-		{
-			srcTable:  table,
-			spTable:   table,
-			eventName: "REMOVE",
-			spCols:    []string{"e", "f"},
-			spVals:    []interface{}{"badTesting", 55},
-			srcSchema: srcSchema,
-		},
-	}
-	goodMutations := []*sp.Mutation{
-		sp.Insert(table, []string{"a", "b"}, []interface{}{23, true}),
-		sp.InsertOrUpdate(table, []string{"c", "d"}, []interface{}{"goodTesting", 45}),
-		sp.Delete(table, sp.Key{45, "goodTesting"}),
-	}
-	badMutations := []*sp.Mutation{
-		sp.Insert(table, []string{"a", "b"}, []interface{}{27, false}),
-		sp.InsertOrUpdate(table, []string{"c", "d"}, []interface{}{"badTesting", 49}),
-		sp.Delete(table, sp.Key{55, "badTesting"}),
-	}
-
-	writeCount := int64(0)
-	var mutationsWritten []*sp.Mutation
-	var mutationsFailed []*sp.Mutation
-
-	streamInfo.write = func(m *sp.Mutation) error {
-		var err error
-		writeCount++
-		if intersect(m, badMutations) {
-			err = errors.New("record not processed")
-			mutationsFailed = append(mutationsFailed, m)
-		} else {
-			mutationsWritten = append(mutationsWritten, m)
-		}
-		time.Sleep(20 * time.Millisecond)
-		return err
-	}
-
-	for _, data := range tests {
-		writeRecord(streamInfo, data.srcTable, data.spTable, data.eventName, data.spCols, data.spVals, data.srcSchema)
-	}
-
-	// Check data written.
-	assert.Equal(t, true, reflect.DeepEqual(mutationsWritten, goodMutations))
-
-	// Check data rejected.
-	assert.Equal(t, true, reflect.DeepEqual(mutationsFailed, badMutations))
-
-	// Check total write calls.
-	assert.Equal(t, int64(6), writeCount)
-
-	// Check total dropped records.
-	assert.Equal(t, int64(3), sumNestedMapValues(streamInfo.DroppedRecords))
-
-	// Check dropped insert record.
-	assert.Equal(t, "type=INSERT table=testTable cols=[a b] data=[27 false] error=record not processed", streamInfo.SampleBadWrites[0])
-}
-
-func intersect(m *sp.Mutation, mutationSet []*sp.Mutation) bool {
-	for _, mutation := range mutationSet {
-		if reflect.DeepEqual(m, mutation) {
-			return true
-		}
-	}
-	return false
-}
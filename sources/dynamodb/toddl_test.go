@@ -15,6 +15,9 @@
 package dynamodb
 
 import (
+	"bufio"
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
@@ -64,8 +67,8 @@ func TestToSpannerType(t *testing.T) {
 			"c": {Name: "c", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
 			"d": {Name: "d", T: ddl.Type{Name: ddl.Bool}},
 			"e": {Name: "e", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
-			"f": {Name: "f", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
-			"g": {Name: "g", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"f": {Name: "f", T: ddl.Type{Name: ddl.JSON}},
+			"g": {Name: "g", T: ddl.Type{Name: ddl.JSON}},
 			"h": {Name: "h", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
 			"i": {Name: "i", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength, IsArray: true}},
 			"j": {Name: "j", T: ddl.Type{Name: ddl.Numeric, IsArray: true}},
@@ -120,8 +123,8 @@ func TestToExperimentalSpannerType(t *testing.T) {
 			"c": {Name: "c", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
 			"d": {Name: "d", T: ddl.Type{Name: ddl.Bool}},
 			"e": {Name: "e", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
-			"f": {Name: "f", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
-			"g": {Name: "g", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"f": {Name: "f", T: ddl.Type{Name: ddl.JSON}},
+			"g": {Name: "g", T: ddl.Type{Name: ddl.JSON}},
 			"h": {Name: "h", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
 			"i": {Name: "i", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
 			"j": {Name: "j", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
@@ -136,6 +139,46 @@ func TestToExperimentalSpannerType(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestToSpannerType_MixedTypes(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	conv.SrcSchema["test"] = schema.Table{
+		Name:     "test",
+		ColNames: []string{"a", "b"},
+		ColDefs: map[string]schema.Column{
+			"a": {Name: "a", Type: schema.Type{Name: typeString}, NotNull: true},
+			"b": {Name: "b", Type: schema.Type{Name: typeString}, ObservedTypes: map[string]int64{typeMap: 60, typeString: 40}},
+		},
+		PrimaryKeys: []schema.Key{{Column: "a"}},
+	}
+	assert.Nil(t, common.SchemaToSpannerDDL(conv, ToDdlImpl{}))
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, conv.SpSchema["test"].ColDefs["b"].T)
+	assert.Equal(t, []internal.SchemaIssue{internal.MixedType}, conv.Issues["test"]["b"])
+	assert.Empty(t, conv.Issues["test"]["a"])
+
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.DYNAMODB, conv, w, nil, true, true)
+	w.Flush()
+	assert.Contains(t, strings.Join(strings.Fields(buf.String()), " "), "(observed: Map: 60, String: 40)")
+}
+
+func TestToSpannerType_StrayType(t *testing.T) {
+	// A single String among a thousand Numbers is discarded as noise, and
+	// the column isn't reported as mixed.
+	colDefs, colNames, err := inferDataTypes(map[string]map[string]int64{
+		"a": {typeString: 1000},
+		"b": {typeNumber: 999, typeString: 1},
+	}, 1000, []string{"a"})
+	assert.Nil(t, err)
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	conv.SrcSchema["test"] = schema.Table{Name: "test", ColNames: colNames, ColDefs: colDefs, PrimaryKeys: []schema.Key{{Column: "a"}}}
+	assert.Nil(t, common.SchemaToSpannerDDL(conv, ToDdlImpl{}))
+	assert.Equal(t, ddl.Type{Name: ddl.Numeric}, conv.SpSchema["test"].ColDefs["b"].T)
+	assert.Empty(t, conv.Issues["test"]["b"])
+}
+
 func dropComments(t *ddl.CreateTable) {
 	t.Comment = ""
 	for _, c := range t.ColNames {
@@ -0,0 +1,295 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ProcessDataRow converts a MongoDB document to Spanner values and writes
+// them to Spanner.
+func ProcessDataRow(doc map[string]interface{}, conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) {
+	spVals, badCols, srcStrVals := cvtRow(doc, srcSchema, spSchema, spCols)
+	if len(badCols) == 0 {
+		conv.WriteRow(srcTable, spTable, spCols, spVals)
+	} else {
+		conv.Unexpected(fmt.Sprintf("Data conversion error for table %s in column(s) %s\n", srcTable, badCols))
+		conv.StatsAddBadRow(srcTable, conv.DataMode())
+		conv.CollectBadRow(srcTable, srcSchema.ColNames, srcStrVals)
+	}
+}
+
+func cvtRow(doc map[string]interface{}, srcSchema schema.Table, spSchema ddl.CreateTable, spCols []string) ([]interface{}, []string, []string) {
+	var srcStrVals []string
+	var spVals []interface{}
+	var badCols []string
+	for i, srcCol := range srcSchema.ColNames {
+		var spVal interface{}
+		srcStrVal := "null"
+		if v := doc[srcCol]; v != nil {
+			var err error
+			spColDef := spSchema.ColDefs[spCols[i]]
+			spVal, err = convScalar(v, spColDef.T.Name)
+			if err != nil {
+				badCols = append(badCols, srcCol)
+			}
+			if b, err := json.Marshal(v); err == nil {
+				srcStrVal = string(b)
+			}
+		}
+		srcStrVals = append(srcStrVals, srcStrVal)
+		spVals = append(spVals, spVal)
+	}
+	return spVals, badCols, srcStrVals
+}
+
+// convScalar converts a document value, as decoded from MongoDB Extended
+// JSON, to a Go value for the Spanner type spType.
+func convScalar(v interface{}, spType string) (interface{}, error) {
+	switch spType {
+	case ddl.Bool:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case ddl.Bytes:
+		if t, w := wrapped(v); t == typeBinData {
+			return convBinary(w)
+		}
+	case ddl.Int64:
+		if s, ok := numberString(v); ok {
+			return strconv.ParseInt(s, 10, 64)
+		}
+	case ddl.Float64:
+		if s, ok := numberString(v); ok {
+			return strconv.ParseFloat(s, 64)
+		}
+	case ddl.Numeric:
+		if s, ok := numberString(v); ok {
+			val, ok := (&big.Rat{}).SetString(s)
+			if !ok {
+				return nil, fmt.Errorf("can't convert %s to NUMERIC", s)
+			}
+			return *val, nil
+		}
+	case ddl.Timestamp:
+		switch t, w := wrapped(v); t {
+		case typeDate:
+			return convDate(w)
+		case typeTimestamp:
+			return convTimestamp(w)
+		}
+	case ddl.String:
+		switch x := v.(type) {
+		case string:
+			return x, nil
+		case json.Number:
+			return x.String(), nil
+		}
+		if t, w := wrapped(v); t == typeObjectId {
+			if s, ok := w.(string); ok {
+				return s, nil
+			}
+		}
+		return marshalPlain(v)
+	case ddl.JSON:
+		return marshalPlain(v)
+	}
+	return nil, fmt.Errorf("can't convert value %v to Spanner type %s", v, spType)
+}
+
+// typeOf returns the BSON type of a value decoded from MongoDB Extended
+// JSON, or "" for null.
+func typeOf(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case bool:
+		return typeBool
+	case string:
+		return typeString
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			return typeLong
+		}
+		return typeDouble
+	case []interface{}:
+		return typeArray
+	}
+	t, _ := wrapped(v)
+	return t
+}
+
+// wrapped checks whether v is a MongoDB Extended JSON wrapper such as
+// {"$oid": "..."} and returns the corresponding BSON type and the wrapped
+// value. Documents that aren't wrappers are reported as typeObject. See
+// https://www.mongodb.com/docs/manual/reference/mongodb-extended-json/.
+func wrapped(v interface{}) (string, interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", nil
+	}
+	switch len(m) {
+	case 1:
+		for k, w := range m {
+			switch k {
+			case "$oid":
+				return typeObjectId, w
+			case "$date":
+				return typeDate, w
+			case "$numberInt", "$numberLong":
+				return typeLong, w
+			case "$numberDouble":
+				return typeDouble, w
+			case "$numberDecimal":
+				return typeDecimal, w
+			case "$binary":
+				return typeBinData, w
+			case "$timestamp":
+				return typeTimestamp, w
+			}
+		}
+	case 2:
+		// Legacy (v1) encoding of binary data: {"$binary": "...", "$type": "..."}.
+		if b, ok := m["$binary"]; ok {
+			if _, ok := m["$type"]; ok {
+				return typeBinData, b
+			}
+		}
+	}
+	return typeObject, m
+}
+
+// numberString returns the textual form of a numeric value, which can be a
+// plain JSON number or a $numberInt, $numberLong, $numberDouble or
+// $numberDecimal wrapper.
+func numberString(v interface{}) (string, bool) {
+	if n, ok := v.(json.Number); ok {
+		return n.String(), true
+	}
+	switch t, w := wrapped(v); t {
+	case typeLong, typeDouble, typeDecimal:
+		s, ok := w.(string)
+		return s, ok
+	}
+	return "", false
+}
+
+// convBinary decodes the payload of a $binary wrapper, which is either
+// {"base64": "...", "subType": "..."} or, in the legacy encoding, the
+// base64 string itself.
+func convBinary(w interface{}) ([]byte, error) {
+	s, ok := w.(string)
+	if m, isMap := w.(map[string]interface{}); isMap {
+		s, ok = m["base64"].(string)
+	}
+	if !ok {
+		return nil, fmt.Errorf("can't decode binary value %v", w)
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// convDate converts the payload of a $date wrapper, which is either an
+// ISO-8601 string (relaxed mode) or {"$numberLong": "<millis>"}
+// (canonical mode).
+func convDate(w interface{}) (time.Time, error) {
+	if s, ok := w.(string); ok {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	if s, ok := numberString(w); ok {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("can't convert date %v", w)
+}
+
+// convTimestamp converts the payload of a $timestamp wrapper,
+// {"t": <seconds>, "i": <increment>}. The increment only orders events
+// within a second and is dropped.
+func convTimestamp(w interface{}) (time.Time, error) {
+	if m, ok := w.(map[string]interface{}); ok {
+		if s, ok := numberString(m["t"]); ok {
+			secs, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(secs, 0).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't convert timestamp %v", w)
+}
+
+// marshalPlain returns v as a JSON string, with Extended JSON wrappers
+// replaced by their plain JSON equivalents.
+func marshalPlain(v interface{}) (string, error) {
+	b, err := json.Marshal(toPlain(v))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// toPlain strips MongoDB Extended JSON wrappers from v: object ids and
+// binary data become strings, dates become RFC 3339 strings and numbers
+// become JSON numbers. Values that can't be represented in plain JSON
+// (e.g. NaN) and timestamps are kept as they are.
+func toPlain(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, e := range x {
+			l[i] = toPlain(e)
+		}
+		return l
+	case map[string]interface{}:
+		switch t, w := wrapped(x); t {
+		case typeObjectId:
+			return w
+		case typeDate:
+			if d, err := convDate(w); err == nil {
+				return d.Format(time.RFC3339Nano)
+			}
+		case typeLong, typeDouble, typeDecimal:
+			if s, ok := numberString(x); ok {
+				if json.Valid([]byte(s)) {
+					return json.Number(s)
+				}
+			}
+		case typeBinData:
+			if m, ok := w.(map[string]interface{}); ok {
+				return m["base64"]
+			}
+			return w
+		case typeObject:
+			m := make(map[string]interface{}, len(x))
+			for k, e := range x {
+				m[k] = toPlain(e)
+			}
+			return m
+		}
+	}
+	return v
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodb
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+type spannerData struct {
+	table string
+	cols  []string
+	vals  []interface{}
+}
+
+func decode(t *testing.T, s string) interface{} {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	assert.Nil(t, dec.Decode(&v))
+	return v
+}
+
+func TestConvScalar(t *testing.T) {
+	tc := []struct {
+		in     string
+		spType string
+		out    interface{}
+	}{
+		{`true`, ddl.Bool, true},
+		{`"abc"`, ddl.String, "abc"},
+		{`{"$oid": "5f1d7f0b8c1b2a0001a1b2c3"}`, ddl.String, "5f1d7f0b8c1b2a0001a1b2c3"},
+		{`42`, ddl.String, "42"},
+		{`42`, ddl.Int64, int64(42)},
+		{`{"$numberLong": "9007199254740993"}`, ddl.Int64, int64(9007199254740993)},
+		{`{"$numberInt": "7"}`, ddl.Int64, int64(7)},
+		{`1.5`, ddl.Float64, float64(1.5)},
+		{`3`, ddl.Float64, float64(3)},
+		{`{"$numberDouble": "2.25"}`, ddl.Float64, float64(2.25)},
+		{`{"$numberDecimal": "10.1"}`, ddl.Numeric, *big.NewRat(101, 10)},
+		{`{"$date": "2020-05-06T07:08:09.123Z"}`, ddl.Timestamp, time.Date(2020, 5, 6, 7, 8, 9, 123000000, time.UTC)},
+		{`{"$date": {"$numberLong": "1000"}}`, ddl.Timestamp, time.Unix(1, 0).UTC()},
+		{`{"$timestamp": {"t": 1600000000, "i": 3}}`, ddl.Timestamp, time.Unix(1600000000, 0).UTC()},
+		{`{"$binary": {"base64": "AQID", "subType": "00"}}`, ddl.Bytes, []byte{1, 2, 3}},
+		{`{"$binary": "AQID", "$type": "00"}`, ddl.Bytes, []byte{1, 2, 3}},
+		{`{"a": {"$oid": "5f1d7f0b8c1b2a0001a1b2c3"}, "b": [{"$numberLong": "5"}, {"$date": {"$numberLong": "0"}}]}`, ddl.JSON,
+			`{"a":"5f1d7f0b8c1b2a0001a1b2c3","b":[5,"1970-01-01T00:00:00Z"]}`},
+		{`[1, "x", {"$numberDouble": "NaN"}]`, ddl.JSON, `[1,"x",{"$numberDouble":"NaN"}]`},
+		{`{"k": true}`, ddl.String, `{"k":true}`},
+	}
+	for _, c := range tc {
+		out, err := convScalar(decode(t, c.in), c.spType)
+		assert.Nil(t, err, c.in)
+		assert.Equal(t, c.out, out, c.in)
+	}
+
+	errCases := []struct {
+		in     string
+		spType string
+	}{
+		{`"abc"`, ddl.Int64},
+		{`1.5`, ddl.Int64},
+		{`"2020-01-01"`, ddl.Timestamp},
+		{`{"$date": "not a date"}`, ddl.Timestamp},
+		{`{"$binary": {"base64": "!!", "subType": "00"}}`, ddl.Bytes},
+		{`1`, ddl.Bool},
+	}
+	for _, c := range errCases {
+		_, err := convScalar(decode(t, c.in), c.spType)
+		assert.NotNil(t, err, c.in)
+	}
+}
+
+func TestProcessDataRow(t *testing.T) {
+	tableName := "users"
+	srcCols := []string{"_id", "age", "tags"}
+	spCols := []string{"Aid", "age", "tags"}
+	spSchema := ddl.CreateTable{
+		Name:     tableName,
+		ColNames: spCols,
+		ColDefs: map[string]ddl.ColumnDef{
+			"Aid":  {Name: "Aid", T: ddl.Type{Name: ddl.String, Len: 24}},
+			"age":  {Name: "age", T: ddl.Type{Name: ddl.Int64}},
+			"tags": {Name: "tags", T: ddl.Type{Name: ddl.JSON}},
+		},
+		Pks: []ddl.IndexKey{{Col: "Aid"}},
+	}
+	conv := internal.MakeConv()
+	conv.SpSchema[tableName] = spSchema
+	conv.SrcSchema[tableName] = schema.Table{
+		Name:     tableName,
+		ColNames: srcCols,
+		ColDefs: map[string]schema.Column{
+			"_id":  {Name: "_id", Type: schema.Type{Name: typeObjectId}},
+			"age":  {Name: "age", Type: schema.Type{Name: typeLong}},
+			"tags": {Name: "tags", Type: schema.Type{Name: typeArray}},
+		},
+		PrimaryKeys: []schema.Key{{Column: "_id"}},
+	}
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	docs := []string{
+		`{"_id": {"$oid": "5f1d7f0b8c1b2a0001a1b2c3"}, "age": 30, "tags": ["a", "b"], "extra": 1}`,
+		`{"_id": {"$oid": "5f1d7f0b8c1b2a0001a1b2c4"}, "age": null}`,
+		`{"_id": {"$oid": "5f1d7f0b8c1b2a0001a1b2c5"}, "age": "thirty"}`,
+	}
+	for _, d := range docs {
+		ProcessDataRow(decode(t, d).(map[string]interface{}), conv, tableName, conv.SrcSchema[tableName], tableName, spCols, spSchema)
+	}
+	assert.Equal(t,
+		[]spannerData{
+			{table: tableName, cols: spCols, vals: []interface{}{"5f1d7f0b8c1b2a0001a1b2c3", int64(30), `["a","b"]`}},
+			{table: tableName, cols: spCols, vals: []interface{}{"5f1d7f0b8c1b2a0001a1b2c4", nil, nil}},
+		},
+		rows,
+	)
+	assert.Equal(t, int64(1), conv.BadRows())
+}
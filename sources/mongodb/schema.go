@@ -0,0 +1,299 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Type names follow the BSON type aliases used by MongoDB
+// (see https://www.mongodb.com/docs/manual/reference/bson-types/).
+const (
+	typeObjectId  = "objectId"
+	typeString    = "string"
+	typeLong      = "long"
+	typeDouble    = "double"
+	typeDecimal   = "decimal"
+	typeBool      = "bool"
+	typeDate      = "date"
+	typeTimestamp = "timestamp"
+	typeBinData   = "binData"
+	typeObject    = "object"
+	typeArray     = "array"
+	// typeMixed is used for fields whose values have several significant
+	// types across the sampled documents.
+	typeMixed = "mixed"
+)
+
+// idField is the field MongoDB uses as the primary key of every document.
+const idField = "_id"
+
+// fileExtensions lists the file extensions recognized as JSON Lines
+// exports when the source path is a directory.
+var fileExtensions = []string{".json", ".jsonl", ".ndjson"}
+
+// InfoSchemaImpl reads MongoDB collections exported as JSON Lines (e.g.
+// by mongoexport). Each file holds one collection and the collection name
+// is the base name of the file.
+type InfoSchemaImpl struct {
+	Files      map[string]string // Maps collection name to file path.
+	SampleSize int64
+}
+
+// NewInfoSchemaImpl builds an InfoSchemaImpl for path, which is either a
+// single export file or a directory of export files.
+func NewInfoSchemaImpl(path string, sampleSize int64) (InfoSchemaImpl, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return InfoSchemaImpl{}, fmt.Errorf("can't read source path %s: %v", path, err)
+	}
+	files := make(map[string]string)
+	if !fi.IsDir() {
+		files[collectionName(path)] = path
+		return InfoSchemaImpl{Files: files, SampleSize: sampleSize}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return InfoSchemaImpl{}, fmt.Errorf("can't list directory %s: %v", path, err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !hasExportExtension(e.Name()) {
+			continue
+		}
+		name := collectionName(e.Name())
+		if f, found := files[name]; found {
+			return InfoSchemaImpl{}, fmt.Errorf("files %s and %s both map to collection %s", f, e.Name(), name)
+		}
+		files[name] = filepath.Join(path, e.Name())
+	}
+	if len(files) == 0 {
+		return InfoSchemaImpl{}, fmt.Errorf("no files with extension %s found in directory %s", strings.Join(fileExtensions, ", "), path)
+	}
+	return InfoSchemaImpl{Files: files, SampleSize: sampleSize}, nil
+}
+
+func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
+	return ToDdlImpl{}
+}
+
+func (isi InfoSchemaImpl) GetTableName(schema string, tableName string) string {
+	return tableName
+}
+
+func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
+	var tables []common.SchemaAndName
+	for name := range isi.Files {
+		tables = append(tables, common.SchemaAndName{Name: name})
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables, nil
+}
+
+func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	stats, count, err := scanSampleData(isi.Files[table.Name], isi.SampleSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inferDataTypes(stats, count, primaryKeys)
+}
+
+func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string) (interface{}, error) {
+	var docs []map[string]interface{}
+	_, err := readDocuments(isi.Files[srcTable], 0, func(doc map[string]interface{}) {
+		docs = append(docs, doc)
+	})
+	return docs, err
+}
+
+func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
+	return readDocuments(isi.Files[table.Name], 0, func(map[string]interface{}) {})
+}
+
+// GetConstraints returns _id as the primary key if the first document of
+// the collection has it. Every document stored in MongoDB has an _id, but
+// it can be excluded from an export.
+func (isi InfoSchemaImpl) GetConstraints(conv *internal.Conv, table common.SchemaAndName) (primaryKeys []string, constraints map[string][]string, err error) {
+	hasId := false
+	_, err = readDocuments(isi.Files[table.Name], 1, func(doc map[string]interface{}) {
+		_, hasId = doc[idField]
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if hasId {
+		primaryKeys = append(primaryKeys, idField)
+	}
+	return primaryKeys, constraints, nil
+}
+
+func (isi InfoSchemaImpl) GetForeignKeys(conv *internal.Conv, table common.SchemaAndName) (foreignKeys []schema.ForeignKey, err error) {
+	return foreignKeys, err
+}
+
+// GetIndexes returns no indexes: mongoexport doesn't export index
+// definitions.
+func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAndName) (indexes []schema.Index, err error) {
+	return indexes, err
+}
+
+// ProcessData performs data conversion for a MongoDB collection. Documents
+// are read one at a time from the export file, converted to Spanner data
+// (based on the source and Spanner schemas), and written to Spanner.
+func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	_, err := readDocuments(isi.Files[srcTable], 0, func(doc map[string]interface{}) {
+		ProcessDataRow(doc, conv, srcTable, srcSchema, spTable, spCols, spSchema)
+	})
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		return err
+	}
+	return nil
+}
+
+func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("streaming migration is not supported for MongoDB exports")
+}
+
+func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamInfo map[string]interface{}) error {
+	return fmt.Errorf("streaming migration is not supported for MongoDB exports")
+}
+
+// scanSampleData reads up to sampleSize documents and counts the types
+// observed for each top-level field.
+func scanSampleData(path string, sampleSize int64) (map[string]map[string]int64, int64, error) {
+	stats := make(map[string]map[string]int64)
+	count, err := readDocuments(path, sampleSize, func(doc map[string]interface{}) {
+		for field, v := range doc {
+			t := typeOf(v)
+			if t == "" {
+				// Null values don't contribute to the type of a field.
+				continue
+			}
+			if _, ok := stats[field]; !ok {
+				stats[field] = make(map[string]int64)
+			}
+			stats[field][t]++
+		}
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read sample data from %s: %v", path, err)
+	}
+	return stats, count, nil
+}
+
+// inferDataTypes infers column definitions from sampled type counts. Since
+// JSON doesn't distinguish integers from floating point numbers, fields
+// where both were seen are treated as doubles. Fields with conflicting
+// types are mapped to typeMixed and the observed types are recorded for
+// the report.
+func inferDataTypes(stats map[string]map[string]int64, rows int64, primaryKeys []string) (map[string]schema.Column, []string, error) {
+	for _, countMap := range stats {
+		if countMap[typeLong] > 0 && countMap[typeDouble] > 0 {
+			countMap[typeDouble] += countMap[typeLong]
+			delete(countMap, typeLong)
+		}
+	}
+	colDefs, colNames := common.InferColumns(stats, rows, primaryKeys, typeMixed)
+	for col, colDef := range colDefs {
+		if colDef.Type.Name == typeMixed {
+			colDef.ObservedTypes = stats[col]
+			colDefs[col] = colDef
+		}
+	}
+	return colDefs, colNames, nil
+}
+
+// readDocuments calls f on each document in the file at path, and returns
+// the number of documents read. The file can hold either one document per
+// line (the mongoexport default) or a JSON array of documents (mongoexport
+// --jsonArray). If limit is positive, at most limit documents are read.
+func readDocuments(path string, limit int64, f func(doc map[string]interface{})) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	isArray, err := startsWithArray(r)
+	if err != nil {
+		return 0, err
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if isArray {
+		if _, err := dec.Token(); err != nil {
+			return 0, err
+		}
+	}
+	var count int64
+	for dec.More() && (limit <= 0 || count < limit) {
+		var doc map[string]interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return count, fmt.Errorf("can't parse document %d: %v", count+1, err)
+		}
+		f(doc)
+		count++
+	}
+	return count, nil
+}
+
+// startsWithArray reports whether the first non-whitespace character read
+// from r is '[', without consuming it.
+func startsWithArray(r *bufio.Reader) (bool, error) {
+	for {
+		b, err := r.Peek(1)
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			r.ReadByte()
+		default:
+			return b[0] == '[', nil
+		}
+	}
+}
+
+func hasExportExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range fileExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+func collectionName(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
@@ -0,0 +1,143 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mongodb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+func writeFile(t *testing.T, dir, name string, lines ...string) string {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644))
+	return path
+}
+
+func TestNewInfoSchemaImpl(t *testing.T) {
+	dir := t.TempDir()
+	users := writeFile(t, dir, "users.json", `{"_id": {"$oid": "5f1d7f0b8c1b2a0001a1b2c3"}}`)
+	orders := writeFile(t, dir, "orders.jsonl", `{"_id": 1}`)
+	writeFile(t, dir, "README.md", "not a collection")
+
+	isi, err := NewInfoSchemaImpl(dir, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"users": users, "orders": orders}, isi.Files)
+	tables, err := isi.GetTables()
+	assert.Nil(t, err)
+	assert.Equal(t, []common.SchemaAndName{{Name: "orders"}, {Name: "users"}}, tables)
+
+	isi, err = NewInfoSchemaImpl(users, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"users": users}, isi.Files)
+
+	_, err = NewInfoSchemaImpl(filepath.Join(dir, "missing"), 10)
+	assert.NotNil(t, err)
+}
+
+func TestReadDocuments(t *testing.T) {
+	dir := t.TempDir()
+	jsonl := writeFile(t, dir, "a.json", `{"x": 1}`, ``, `{"x": 2}`, `{"x": 3}`)
+	array := writeFile(t, dir, "b.json", ` [{"x": 1},`, `{"x": 2}]`)
+	bad := writeFile(t, dir, "c.json", `{"x": 1}`, `{"x": `)
+
+	var docs []map[string]interface{}
+	collect := func(doc map[string]interface{}) { docs = append(docs, doc) }
+	n, err := readDocuments(jsonl, 0, collect)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, 3, len(docs))
+
+	n, err = readDocuments(jsonl, 2, func(map[string]interface{}) {})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	n, err = readDocuments(array, 0, func(map[string]interface{}) {})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	_, err = readDocuments(bad, 0, func(map[string]interface{}) {})
+	assert.NotNil(t, err)
+}
+
+func TestInferDataTypes(t *testing.T) {
+	stats := map[string]map[string]int64{
+		"_id":     {typeObjectId: 100},
+		"name":    {typeString: 100},
+		"age":     {typeLong: 90},
+		"score":   {typeLong: 50, typeDouble: 50},
+		"address": {typeObject: 60},
+		"tags":    {typeArray: 100},
+		"misc":    {typeString: 50, typeLong: 40, typeBool: 10},
+	}
+	colDefs, colNames, err := inferDataTypes(stats, 100, []string{"_id"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"_id", "address", "age", "misc", "name", "score", "tags"}, colNames)
+	assert.Equal(t, map[string]schema.Column{
+		"_id":     {Name: "_id", Type: schema.Type{Name: typeObjectId}, NotNull: true},
+		"name":    {Name: "name", Type: schema.Type{Name: typeString}, NotNull: true},
+		"age":     {Name: "age", Type: schema.Type{Name: typeLong}},
+		"score":   {Name: "score", Type: schema.Type{Name: typeDouble}, NotNull: true},
+		"address": {Name: "address", Type: schema.Type{Name: typeObject}},
+		"tags":    {Name: "tags", Type: schema.Type{Name: typeArray}, NotNull: true},
+		"misc": {Name: "misc", Type: schema.Type{Name: typeMixed}, NotNull: true,
+			ObservedTypes: map[string]int64{typeString: 50, typeLong: 40, typeBool: 10}},
+	}, colDefs)
+}
+
+func TestProcessSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "users.json",
+		`{"_id": {"$oid": "5f1d7f0b8c1b2a0001a1b2c3"}, "name": "alice", "born": {"$date": "1990-01-02T03:04:05Z"}, "score": 1, "address": {"city": "Paris"}, "misc": "a"}`,
+		`{"_id": {"$oid": "5f1d7f0b8c1b2a0001a1b2c4"}, "name": "bob", "born": {"$date": {"$numberLong": "631152000000"}}, "score": 2.5, "address": null, "misc": 3}`,
+	)
+	isi, err := NewInfoSchemaImpl(dir, 100)
+	assert.Nil(t, err)
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+
+	assert.Equal(t, []schema.Key{{Column: "_id"}}, conv.SrcSchema["users"].PrimaryKeys)
+	assert.Equal(t, map[string]int64{typeString: 1, typeLong: 1}, conv.SrcSchema["users"].ColDefs["misc"].ObservedTypes)
+	actual := conv.SpSchema["users"]
+	assert.Equal(t, []ddl.IndexKey{{Col: "Aid"}}, actual.Pks)
+	expected := map[string]ddl.Type{
+		"Aid":     {Name: ddl.String, Len: 24},
+		"name":    {Name: ddl.String, Len: ddl.MaxLength},
+		"born":    {Name: ddl.Timestamp},
+		"score":   {Name: ddl.Float64},
+		"address": {Name: ddl.JSON},
+		"misc":    {Name: ddl.JSON},
+	}
+	assert.Equal(t, len(expected), len(actual.ColNames))
+	for col, ty := range expected {
+		assert.Equal(t, ty, actual.ColDefs[col].T, col)
+	}
+	assert.False(t, actual.ColDefs["address"].NotNull)
+	assert.Contains(t, conv.Issues["users"]["misc"], internal.MixedType)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mongodb handles schema and data migrations from MongoDB
+// collections exported as JSON Lines (e.g. by mongoexport).
+package mongodb
+
+import (
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ToDdl implementation for MongoDB
+type ToDdlImpl struct {
+}
+
+// Functions below implement the common.ToDdl interface
+// toSpannerType maps a scalar source schema type (defined by id and
+// mods) into a Spanner type. This is the core source-to-Spanner type
+// mapping.  toSpannerType returns the Spanner type and a list of type
+// conversion issues encountered.
+func (tdi ToDdlImpl) ToSpannerType(conv *internal.Conv, spType string, srcType schema.Type) (ddl.Type, []internal.SchemaIssue) {
+	switch srcType.Name {
+	case typeObjectId:
+		// ObjectIds are exported as 24 character hex strings.
+		return ddl.Type{Name: ddl.String, Len: 24}, nil
+	case typeString:
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+	case typeLong:
+		return ddl.Type{Name: ddl.Int64}, nil
+	case typeDouble:
+		return ddl.Type{Name: ddl.Float64}, nil
+	case typeDecimal:
+		// Decimal128 has 34 significant digits and a wide exponent range.
+		return ddl.Type{Name: ddl.Numeric}, []internal.SchemaIssue{internal.Decimal}
+	case typeBool:
+		return ddl.Type{Name: ddl.Bool}, nil
+	case typeDate, typeTimestamp:
+		return ddl.Type{Name: ddl.Timestamp}, nil
+	case typeBinData:
+		return ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, nil
+	case typeObject, typeArray:
+		return ddl.Type{Name: ddl.JSON}, nil
+	case typeMixed:
+		return ddl.Type{Name: ddl.JSON}, []internal.SchemaIssue{internal.MixedType}
+	default:
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
+	}
+}
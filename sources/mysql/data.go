@@ -69,6 +69,18 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, srcSche
 		if !ok1 || !ok2 {
 			return "", []string{}, []interface{}{}, fmt.Errorf("can't find Spanner and source-db schema for col %s", spCol)
 		}
+		if (spColDef.T.Name == ddl.Date || spColDef.T.Name == ddl.Timestamp) && isZeroDate(vals[i]) {
+			switch conv.ZeroDates {
+			case constants.ZeroDatesNull:
+				continue
+			case "", constants.ZeroDatesReject:
+				return "", []string{}, []interface{}{}, fmt.Errorf("can't convert zero date %q of column %s", vals[i], srcCol)
+			default:
+				// Replace the date part with the sentinel date, keeping the
+				// time part of datetimes and timestamps.
+				vals[i] = conv.ZeroDates + vals[i][len("0000-00-00"):]
+			}
+		}
 		var x interface{}
 		var err error
 		if spColDef.T.IsArray {
@@ -91,6 +103,16 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, srcSche
 	return spTable, c, v, nil
 }
 
+// isZeroDate returns true for the zero dates MySQL accepts depending on its
+// SQL mode, whose year, month or day is 0 e.g. 0000-00-00 or 2020-00-00.
+// Zero datetimes and timestamps have a zero date part.
+func isZeroDate(val string) bool {
+	if len(val) < len("0000-00-00") || val[4] != '-' || val[7] != '-' {
+		return false
+	}
+	return val[0:4] == "0000" || val[5:7] == "00" || val[8:10] == "00"
+}
+
 // convScalar converts a source database string value to an
 // appropriate Spanner value. It is the caller's responsibility to
 // detect and handle NULL values: convScalar will return error if a
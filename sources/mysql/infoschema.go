@@ -39,6 +39,7 @@ type InfoSchemaImpl struct {
 	Db            *sql.DB
 	SourceProfile profiles.SourceProfile
 	TargetProfile profiles.TargetProfile
+	Snapshot      *common.SnapshotReader // Set for the readers of a consistent snapshot.
 }
 
 // GetToDdl implement the common.InfoSchema interface.
@@ -64,10 +65,31 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string)
 	// but MySQL doesn't support this. So we quote it instead.
 	colNameList := buildColNameList(srcSchema, srcCols)
 	q := fmt.Sprintf("SELECT %s FROM `%s`.`%s`;", colNameList, conv.SrcSchema[srcTable].Schema, srcTable)
-	rows, err := isi.Db.Query(q)
+	rows, err := isi.Snapshot.Query(isi.Db, q)
 	return rows, err
 }
 
+// ProfileTable implements the common.ProfilingInfoSchema interface.
+func (isi InfoSchemaImpl) ProfileTable(conv *internal.Conv, srcTable string, cols []common.ProfileColumn) (map[string]*internal.ColumnProfile, error) {
+	tbl := conv.SrcSchema[srcTable]
+	quote := func(col string) string {
+		q := "`" + strings.ReplaceAll(col, "`", "``") + "`"
+		// Spatial values are migrated as text.
+		for _, spatial := range MysqlSpatialDataTypes {
+			if strings.Contains(strings.ToLower(tbl.ColDefs[col].Type.Name), spatial) {
+				return "ST_AsText(" + q + ")"
+			}
+		}
+		return q
+	}
+	q := common.ProfileQuery(fmt.Sprintf("`%s`.`%s`", tbl.Schema, srcTable), cols, quote, "CHAR_LENGTH(%s)")
+	rows, err := isi.Snapshot.Query(isi.Db, q)
+	if err != nil {
+		return nil, err
+	}
+	return common.ScanProfile(rows, cols)
+}
+
 // Building list of column names to support mysql spatial datatypes instead of
 // using 'SELECT *' because spatial columns will be fetched using ST_AsText(colName).
 func buildColNameList(srcSchema schema.Table, srcColName []string) string {
@@ -92,7 +114,9 @@ func buildColNameList(srcSchema schema.Table, srcColName []string) string {
 func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
 	rowsInterface, err := isi.GetRowsFromTable(conv, srcTable)
 	if err != nil {
-		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		isi.Snapshot.Do(func() {
+			conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		})
 		return err
 	}
 	rows := rowsInterface.(*sql.Rows)
@@ -102,14 +126,16 @@ func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcS
 	for rows.Next() {
 		// get RawBytes from data.
 		err := rows.Scan(scanArgs...)
-		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
-			// Scan failed, so we don't have any data to add to bad rows.
-			conv.StatsAddBadRow(srcTable, conv.DataMode())
-			continue
-		}
-		values := valsToStrings(v)
-		ProcessDataRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, values)
+		isi.Snapshot.Do(func() {
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
+				// Scan failed, so we don't have any data to add to bad rows.
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				return
+			}
+			values := valsToStrings(v)
+			ProcessDataRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, values)
+		})
 	}
 	return nil
 }
@@ -120,7 +146,7 @@ func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error)
 	// Ideally we would pass schema/name as a query parameter,
 	// but MySQL doesn't support this. So we quote it instead.
 	q := fmt.Sprintf("SELECT COUNT(*) FROM `%s`.`%s`;", table.Schema, table.Name)
-	rows, err := isi.Db.Query(q)
+	rows, err := isi.Snapshot.Query(isi.Db, q)
 	if err != nil {
 		return 0, err
 	}
@@ -195,6 +221,9 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 			NotNull: common.ToNotNull(conv, isNullable),
 			Ignored: ignored,
 		}
+		if dataType == "enum" {
+			c.EnumValues = parseEnumValues(columnType)
+		}
 		colDefs[colName] = c
 		colNames = append(colNames, colName)
 	}
@@ -337,7 +366,7 @@ func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAnd
 // performing a streaming migration.
 func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
 	mp := make(map[string]interface{})
-	streamingCfg, err := streaming.StartDatastream(ctx, isi.SourceProfile, isi.TargetProfile)
+	streamingCfg, err := streaming.StartDatastream(ctx, isi.SourceProfile, isi.TargetProfile, conv.Audit.MigrationRequestId)
 	if err != nil {
 		err = fmt.Errorf("error starting datastream: %v", err)
 		return nil, err
@@ -347,9 +376,13 @@ func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *inte
 }
 
 // StartStreamingMigration is used for automatic triggering of Dataflow job when
-// performing a streaming migration.
+// performing a streaming migration. If the streaming config has a LocalApplyCfg,
+// the Datastream output files are applied by HarbourBridge instead.
 func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamingInfo map[string]interface{}) error {
 	streamingCfg, _ := streamingInfo["streamingCfg"].(streaming.StreamingCfg)
+	if streamingCfg.LocalApplyCfg.Dir != "" {
+		return streaming.StartLocalApply(ctx, client, conv, streamingCfg.LocalApplyCfg, isi)
+	}
 
 	err := streaming.StartDataflow(ctx, isi.SourceProfile, isi.TargetProfile, streamingCfg, conv)
 	if err != nil {
@@ -359,10 +392,18 @@ func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *s
 	return nil
 }
 
+// ConvertRow converts a row of a Datastream change event, like the rows of
+// the snapshot.
+func (isi InfoSchemaImpl) ConvertRow(conv *internal.Conv, srcTable string, srcCols []string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, vals []string) (string, []string, []interface{}, error) {
+	return ConvertData(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, vals)
+}
+
 func toType(dataType string, columnType string, charLen sql.NullInt64, numericPrecision, numericScale sql.NullInt64) schema.Type {
 	switch {
 	case dataType == "set":
 		return schema.Type{Name: dataType, ArrayBounds: []int64{-1}}
+	case dataType == "bigint" && strings.Contains(strings.ToLower(columnType), "unsigned"):
+		return schema.Type{Name: "bigint unsigned"}
 	case charLen.Valid:
 		return schema.Type{Name: dataType, Mods: []int64{charLen.Int64}}
 	case dataType == "decimal" && numericPrecision.Valid && numericScale.Valid && numericScale.Int64 != 0:
@@ -383,6 +424,36 @@ func toType(dataType string, columnType string, charLen sql.NullInt64, numericPr
 	}
 }
 
+// parseEnumValues returns the values of an enum column type as shown in
+// information_schema e.g. enum('a','b'), where a quote in a value is
+// written as two quotes.
+func parseEnumValues(columnType string) []string {
+	start, end := strings.Index(columnType, "("), strings.LastIndex(columnType, ")")
+	if start == -1 || end < start {
+		return nil
+	}
+	var vals []string
+	var cur strings.Builder
+	inQuote := false
+	s := columnType[start+1 : end]
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\'' && inQuote && i+1 < len(s) && s[i+1] == '\'':
+			cur.WriteByte('\'')
+			i++
+		case s[i] == '\'':
+			if inQuote {
+				vals = append(vals, cur.String())
+				cur.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			cur.WriteByte(s[i])
+		}
+	}
+	return vals
+}
+
 // buildVals constructs []sql.RawBytes value containers to scan row
 // results into.  Returns both the underlying containers (as a slice)
 // as well as an interface{} of pointers to containers to pass to
@@ -15,15 +15,20 @@
 package mysql
 
 import (
+	"bufio"
+	"bytes"
 	"database/sql"
 	"database/sql/driver"
+	"math/big"
+	"regexp"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
-	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+	"github.com/cloudspannerecosystem/harbourbridge/proto/migration"
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
@@ -196,7 +201,7 @@ func TestProcessSchemaMYSQL(t *testing.T) {
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
-	isi := InfoSchemaImpl{"test", db, profiles.SourceProfile{}, profiles.TargetProfile{}}
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
 	err := common.ProcessSchema(conv, isi, 1)
 	assert.Nil(t, err)
 	expectedSchema := map[string]ddl.CreateTable{
@@ -320,7 +325,7 @@ func TestProcessData(t *testing.T) {
 		func(table string, cols []string, vals []interface{}) {
 			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
 		})
-	isi := InfoSchemaImpl{"test", db, profiles.SourceProfile{}, profiles.TargetProfile{}}
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
 	common.ProcessData(conv, isi)
 	assert.Equal(t,
 		[]spannerData{
@@ -377,7 +382,7 @@ func TestProcessData_MultiCol(t *testing.T) {
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
-	isi := InfoSchemaImpl{"test", db, profiles.SourceProfile{}, profiles.TargetProfile{}}
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
 	err := common.ProcessSchema(conv, isi, 1)
 	assert.Nil(t, err)
 	expectedSchema := map[string]ddl.CreateTable{
@@ -410,6 +415,153 @@ func TestProcessData_MultiCol(t *testing.T) {
 	assert.Equal(t, int64(0), conv.Unexpecteds())
 }
 
+func TestProcessSchema_ProfileData(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_name FROM information_schema.tables where table_type = 'BASE TABLE' and (.+)",
+			args:  []driver.Value{"test"},
+			cols:  []string{"table_name"},
+			rows:  [][]driver.Value{{"test"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"column_name", "constraint_type"},
+			rows:  [][]driver.Value{{"id", "PRIMARY KEY"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"REFERENCED_TABLE_NAME", "COLUMN_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			rows: [][]driver.Value{
+				{"id", "bigint", "bigint", "NO", nil, nil, 64, 0, nil},
+				{"qty", "decimal", "decimal", "YES", nil, nil, 65, 0, nil},
+				{"price", "decimal", "decimal(10,2)", "YES", nil, nil, 10, 2, nil},
+				{"name", "text", "text", "YES", nil, nil, nil, nil, nil},
+				{"note", "text", "text", "YES", nil, nil, nil, nil, nil}},
+		}, {
+			// The columns are profiled with aggregate functions. Only the
+			// NULLs of id are counted, since its type can't be narrowed.
+			query: regexp.QuoteMeta("SELECT COUNT(*), SUM(CASE WHEN `id` IS NULL THEN 1 ELSE 0 END), " +
+				"SUM(CASE WHEN `qty` IS NULL THEN 1 ELSE 0 END), MIN(`qty`), MAX(`qty`), SUM(CASE WHEN `qty` <> FLOOR(`qty`) THEN 1 ELSE 0 END), " +
+				"SUM(CASE WHEN `price` IS NULL THEN 1 ELSE 0 END), MIN(`price`), MAX(`price`), SUM(CASE WHEN `price` <> FLOOR(`price`) THEN 1 ELSE 0 END), " +
+				"SUM(CASE WHEN `name` IS NULL THEN 1 ELSE 0 END), MAX(CHAR_LENGTH(`name`)), " +
+				"SUM(CASE WHEN `note` IS NULL THEN 1 ELSE 0 END), MAX(CHAR_LENGTH(`note`)) FROM `test`.`test`"),
+			cols: []string{"count", "id_nulls", "qty_nulls", "qty_min", "qty_max", "qty_fractions", "price_nulls", "price_min", "price_max", "price_fractions", "name_nulls", "name_length", "note_nulls", "note_length"},
+			rows: [][]driver.Value{{3, 0, 0, "-7", "100", 0, 0, "1.50", "3.25", 2, 0, 4, 2, 1}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	conv.ProfileData = true
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+	expectedSchema := map[string]ddl.CreateTable{
+		"test": ddl.CreateTable{
+			Name:     "test",
+			ColNames: []string{"id", "qty", "price", "name", "note"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"qty":   ddl.ColumnDef{Name: "qty", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"price": ddl.ColumnDef{Name: "price", T: ddl.Type{Name: ddl.Numeric}, NotNull: true},
+				"name":  ddl.ColumnDef{Name: "name", T: ddl.Type{Name: ddl.String, Len: 16}, NotNull: true},
+				"note":  ddl.ColumnDef{Name: "note", T: ddl.Type{Name: ddl.String, Len: 16}},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}}},
+	}
+	assert.Equal(t, expectedSchema, stripSchemaComments(conv.SpSchema))
+	expectedIssues := map[string][]internal.SchemaIssue{
+		"qty":   []internal.SchemaIssue{internal.ProfileNarrowed, internal.ProfileNotNull},
+		"price": []internal.SchemaIssue{internal.ProfileNotNull},
+		"name":  []internal.SchemaIssue{internal.ProfileNarrowed, internal.ProfileNotNull},
+		"note":  []internal.SchemaIssue{internal.ProfileNarrowed},
+	}
+	assert.Equal(t, expectedIssues, conv.Issues["test"])
+	assert.Equal(t, "-7", conv.Profiles["test"]["qty"].Min)
+	assert.Equal(t, "100", conv.Profiles["test"]["qty"].Max)
+	assert.False(t, conv.Profiles["test"]["price"].Integer)
+	assert.Equal(t, int64(2), conv.Profiles["test"]["note"].Nulls)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+
+	conv.Audit.MigrationType = migration.MigrationData_SCHEMA_ONLY.Enum()
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.MYSQL, conv, w, nil, true, false)
+	w.Flush()
+	report := buf.String()
+	assert.Contains(t, report, "Column 'qty': type decimal(65) is mapped to int64 based on its data")
+	assert.Contains(t, report, "Column 'price': 3 rows, 0 nulls, min 1.50, max 3.25")
+	assert.Contains(t, report, "Column 'note': 3 rows, 2 nulls, max length 1")
+}
+
+func TestProcessSchema_UnsignedEnumZeroDates(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_name FROM information_schema.tables where table_type = 'BASE TABLE' and (.+)",
+			args:  []driver.Value{"test"},
+			cols:  []string{"table_name"},
+			rows:  [][]driver.Value{{"test"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"column_name", "constraint_type"},
+			rows:  [][]driver.Value{{"id", "PRIMARY KEY"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"REFERENCED_TABLE_NAME", "COLUMN_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"test", "test"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			rows: [][]driver.Value{
+				{"id", "bigint", "bigint(20) unsigned", "NO", nil, nil, 20, 0, nil},
+				{"size", "enum", "enum('S','M','it''s XL')", "YES", nil, 8, nil, nil, nil},
+				{"d", "date", "date", "YES", nil, nil, nil, nil, nil}},
+		}, {
+			query: "SELECT (.+) FROM `test`.`test`",
+			cols:  []string{"id", "size", "d"},
+			rows: [][]driver.Value{
+				{"18446744073709551615", "it's XL", "0000-00-00"},
+				{"1", "S", "2022-10-01"}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+	sp := stripSchemaComments(conv.SpSchema)["test"]
+	assert.Equal(t, ddl.Type{Name: ddl.Numeric}, sp.ColDefs["id"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, sp.ColDefs["size"].T)
+	assert.Equal(t, []ddl.CheckConstraint{{Name: "CK_test_size", Expr: "`size` IN ('S', 'M', 'it\\'s XL')"}}, sp.Checks)
+	assert.Equal(t, []string{"S", "M", "it's XL"}, conv.SrcSchema["test"].ColDefs["size"].EnumValues)
+	assert.Equal(t, []internal.SchemaIssue{internal.UnsignedBigint}, conv.Issues["test"]["id"])
+
+	conv.SetDataMode()
+	conv.ZeroDates = constants.ZeroDatesNull
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	common.ProcessData(conv, isi)
+	assert.Equal(t, []spannerData{
+		{table: "test", cols: []string{"id", "size"}, vals: []interface{}{new(big.Rat).SetInt(new(big.Int).SetUint64(18446744073709551615)), "it's XL"}},
+		{table: "test", cols: []string{"id", "size", "d"}, vals: []interface{}{big.NewRat(1, 1), "S", getDate("2022-10-01")}}},
+		rows)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
 func TestSetRowStats(t *testing.T) {
 	ms := []mockSpec{
 		{
@@ -430,7 +582,7 @@ func TestSetRowStats(t *testing.T) {
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
 	conv.SetDataMode()
-	isi := InfoSchemaImpl{"test", db, profiles.SourceProfile{}, profiles.TargetProfile{}}
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
 	common.SetRowStats(conv, isi)
 	assert.Equal(t, int64(5), conv.Stats.Rows["test1"])
 	assert.Equal(t, int64(142), conv.Stats.Rows["test2"])
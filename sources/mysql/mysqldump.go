@@ -444,11 +444,18 @@ func processColumn(conv *internal.Conv, tableName string, col *ast.ColumnDef) (s
 		return "", schema.Column{}, columnConstraint{}, fmt.Errorf("can't get column type for %s: %w", name, fmt.Errorf("found nil *ast.ColumnDef.Tp"))
 	}
 	tid, mods := getTypeModsAndID(conv, col.Tp.String())
+	if tid == "bigint" && strings.HasSuffix(col.Tp.String(), " UNSIGNED") {
+		// The display width of integer types doesn't restrict their values.
+		tid, mods = "bigint unsigned", nil
+	}
 	ty := schema.Type{
 		Name:        tid,
 		Mods:        mods,
 		ArrayBounds: getArrayBounds(col.Tp.String(), col.Tp.GetElems())}
 	column := schema.Column{Name: name, Type: ty}
+	if tid == "enum" {
+		column.EnumValues = col.Tp.GetElems()
+	}
 	return name, column, updateColsByOption(conv, tableName, col, &column), nil
 }
 
@@ -780,6 +780,60 @@ func TestProcessMySQLDump_TimeZone(t *testing.T) {
 	assert.Equal(t, conv.TimezoneOffset, "+02:30", "Set timezone")
 }
 
+func TestProcessMySQLDump_UnsignedAndEnum(t *testing.T) {
+	conv, rows := runProcessMySQLDump("CREATE TABLE t (id bigint(20) unsigned NOT NULL, e enum('a','b''c') NOT NULL, PRIMARY KEY (id));\n" +
+		"INSERT INTO t VALUES (18446744073709551615,'b''c');")
+	noIssues(conv, t, "Unsigned and enum")
+	expected := ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"id", "e"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id": ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Numeric}, NotNull: true},
+			"e":  ddl.ColumnDef{Name: "e", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+		},
+		Pks:    []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+		Checks: []ddl.CheckConstraint{{Name: "CK_t_e", Expr: "`e` IN ('a', 'b\\'c')"}},
+	}
+	assert.Equal(t, expected, stripSchemaComments(conv.SpSchema)["t"])
+	assert.Equal(t, []internal.SchemaIssue{internal.UnsignedBigint}, conv.Issues["t"]["id"])
+	assert.Equal(t, []spannerData{{table: "t", cols: []string{"id", "e"}, vals: []interface{}{big.NewRat(0, 1).SetInt(new(big.Int).SetUint64(18446744073709551615)), "b'c"}}}, rows)
+	assert.Contains(t, conv.SpSchema["t"].PrintCreateTable(ddl.Config{}), "\tCONSTRAINT CK_t_e CHECK (`e` IN ('a', 'b\\'c')),\n")
+}
+
+func TestProcessMySQLDump_ZeroDates(t *testing.T) {
+	s := "CREATE TABLE t (id int NOT NULL, d date, dt datetime, PRIMARY KEY (id));\n" +
+		"INSERT INTO t VALUES (1,'0000-00-00','0000-00-00 10:20:30');\n" +
+		"INSERT INTO t VALUES (2,'2020-00-15','2020-01-02 03:04:05');\n"
+	for _, tc := range []struct {
+		zeroDates string
+		badRows   int64
+		rows      []spannerData
+	}{
+		{"", 2, nil},
+		{"reject", 2, nil},
+		{"null", 0, []spannerData{
+			{table: "t", cols: []string{"id"}, vals: []interface{}{int64(1)}},
+			{table: "t", cols: []string{"id", "dt"}, vals: []interface{}{int64(2), getTimeWithoutTimezone(t, "2020-01-02 03:04:05")}}}},
+		{"1970-01-01", 0, []spannerData{
+			{table: "t", cols: []string{"id", "d", "dt"}, vals: []interface{}{int64(1), getDate("1970-01-01"), getTimeWithoutTimezone(t, "1970-01-01 10:20:30")}},
+			{table: "t", cols: []string{"id", "d", "dt"}, vals: []interface{}{int64(2), getDate("1970-01-01"), getTimeWithoutTimezone(t, "2020-01-02 03:04:05")}}}},
+	} {
+		conv := internal.MakeConv()
+		conv.SetLocation(time.UTC)
+		conv.ZeroDates = tc.zeroDates
+		conv.SetSchemaMode()
+		common.ProcessDbDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil), DbDumpImpl{})
+		conv.SetDataMode()
+		var rows []spannerData
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+		common.ProcessDbDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil), DbDumpImpl{})
+		assert.Equal(t, tc.badRows, conv.BadRows(), tc.zeroDates)
+		assert.Equal(t, tc.rows, rows, tc.zeroDates)
+	}
+}
+
 func TestProcessMySQLDump_DataError(t *testing.T) {
 	// Finally test data conversion errors.
 	dataErrorTests := []struct {
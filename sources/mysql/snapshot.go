@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+// BeginSnapshot implements the common.SnapshotInfoSchema interface. The
+// tables are locked with FLUSH TABLES WITH READ LOCK while the readers start
+// their transactions and the binlog position is read, so that the snapshot
+// is exactly at that position. Without the RELOAD privilege, e.g. on Cloud
+// SQL, the tables can't be locked: there is then a single reader, and the
+// position is read before its snapshot, so that changes made in between are
+// applied again after the snapshot.
+func (isi InfoSchemaImpl) BeginSnapshot(ctx context.Context, n int) (*common.Snapshot, error) {
+	lockConn, err := isi.Db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer lockConn.Close()
+	var pos common.SnapshotPosition
+	if _, err := lockConn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+		fmt.Printf("Can't lock the tables to read the binlog position of the snapshot (%v): using a single reader, and changes made while the snapshot starts may be applied twice.\n", err)
+		if pos, err = binlogPosition(ctx, lockConn); err != nil {
+			return nil, err
+		}
+		n = 1
+	} else {
+		defer lockConn.ExecContext(ctx, "UNLOCK TABLES")
+	}
+	var conns []*sql.Conn
+	closeAll := func() error {
+		var firstErr error
+		for _, c := range conns {
+			if _, err := c.ExecContext(context.Background(), "COMMIT"); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			c.Close()
+		}
+		return firstErr
+	}
+	lock := &sync.Mutex{}
+	var readers []common.InfoSchema
+	for i := 0; i < n; i++ {
+		c, err := isi.Db.Conn(ctx)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		conns = append(conns, c)
+		if _, err := c.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("can't start snapshot transaction: %v", err)
+		}
+		reader := isi
+		reader.Snapshot = &common.SnapshotReader{Tx: c, Lock: lock}
+		readers = append(readers, reader)
+	}
+	if pos.LogFile == "" {
+		if pos, err = binlogPosition(ctx, lockConn); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+	return common.NewSnapshot(pos, readers, lock, closeAll), nil
+}
+
+// binlogPosition reads the current binlog position with SHOW MASTER STATUS.
+func binlogPosition(ctx context.Context, c *sql.Conn) (common.SnapshotPosition, error) {
+	rows, err := c.QueryContext(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		return common.SnapshotPosition{}, fmt.Errorf("can't read binlog position: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return common.SnapshotPosition{}, fmt.Errorf("can't read binlog position: %v", err)
+		}
+		return common.SnapshotPosition{}, fmt.Errorf("can't read binlog position: binary logging is disabled")
+	}
+	// The number of columns depends on the version of MySQL: File and
+	// Position come first.
+	cols, err := rows.Columns()
+	if err != nil {
+		return common.SnapshotPosition{}, err
+	}
+	v, iv := buildVals(len(cols))
+	if err := rows.Scan(iv...); err != nil {
+		return common.SnapshotPosition{}, fmt.Errorf("can't read binlog position: %v", err)
+	}
+	logPos, err := strconv.ParseInt(string(v[1]), 10, 64)
+	if err != nil {
+		return common.SnapshotPosition{}, fmt.Errorf("can't parse binlog position %s: %v", v[1], err)
+	}
+	return common.SnapshotPosition{Driver: constants.MYSQL, LogFile: string(v[0]), LogPos: logPos, TakenAt: time.Now().UTC()}, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+func masterStatus() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}).
+		AddRow("mysql-bin.000003", "154", "", "", "")
+}
+
+func TestBeginSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mock.ExpectExec("FLUSH TABLES WITH READ LOCK").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(masterStatus())
+	mock.ExpectExec("UNLOCK TABLES").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT `id` FROM `test`.`t`;").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
+	snap, err := isi.BeginSnapshot(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql-bin.000003", snap.Position.LogFile)
+	assert.Equal(t, int64(154), snap.Position.LogPos)
+	assert.Equal(t, 2, len(snap.Readers))
+
+	conv := internal.MakeConv()
+	conv.SrcSchema["t"] = schema.Table{Name: "t", Schema: "test", ColNames: []string{"id"}, ColDefs: map[string]schema.Column{"id": {Name: "id", Type: schema.Type{Name: "int"}}}}
+	reader := snap.Readers[1].(InfoSchemaImpl)
+	assert.NotNil(t, reader.Snapshot)
+	rows, err := reader.GetRowsFromTable(conv, "t")
+	assert.Nil(t, err)
+	rows.(*sql.Rows).Close()
+	assert.Nil(t, snap.Close())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestBeginSnapshot_NoLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mock.ExpectExec("FLUSH TABLES WITH READ LOCK").WillReturnError(fmt.Errorf("Access denied; you need the RELOAD privilege"))
+	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(masterStatus())
+	mock.ExpectExec("START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
+	snap, err := isi.BeginSnapshot(context.Background(), 4)
+	assert.Nil(t, err)
+	assert.Equal(t, common.SnapshotPosition{Driver: "mysql", LogFile: "mysql-bin.000003", LogPos: 154, TakenAt: snap.Position.TakenAt}, snap.Position)
+	assert.Equal(t, 1, len(snap.Readers))
+	assert.Nil(t, snap.Close())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestBeginSnapshot_NoBinlog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mock.ExpectExec("FLUSH TABLES WITH READ LOCK").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SHOW MASTER STATUS").WillReturnRows(sqlmock.NewRows([]string{"File", "Position"}))
+	mock.ExpectExec("COMMIT").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UNLOCK TABLES").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
+	_, err = isi.BeginSnapshot(context.Background(), 1)
+	assert.NotNil(t, err)
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
@@ -104,6 +104,17 @@ func toSpannerTypeInternal(srcType schema.Type, spType string) (ddl.Type, []inte
 		default:
 			return ddl.Type{Name: ddl.Int64}, nil
 		}
+	case "bigint unsigned":
+		switch spType {
+		case ddl.String:
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
+		case ddl.Int64:
+			// Values above 2^63-1 won't fit, but this may be what the user
+			// wants if the column never holds them.
+			return ddl.Type{Name: ddl.Int64}, nil
+		default:
+			return ddl.Type{Name: ddl.Numeric}, []internal.SchemaIssue{internal.UnsignedBigint}
+		}
 	case "smallint", "mediumint", "integer", "int":
 		switch spType {
 		case ddl.String:
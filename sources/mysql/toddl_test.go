@@ -188,3 +188,47 @@ func dropComments(t *ddl.CreateTable) {
 		t.ColDefs[c] = cd
 	}
 }
+
+func TestTypeMappingOverrides(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	tm, err := internal.ParseTypeMapping([]byte(`
+overrides:
+  - "decimal(p<=18,s=0) -> INT64"
+  - "test.d -> BYTES"
+  - "test.e -> DATE"
+`))
+	assert.Nil(t, err)
+	conv.TypeMapping = tm
+	name := "test"
+	conv.SrcSchema[name] = schema.Table{
+		Name:     name,
+		ColNames: []string{"a", "b", "c", "d", "e"},
+		ColDefs: map[string]schema.Column{
+			"a": schema.Column{Name: "a", Type: schema.Type{Name: "decimal", Mods: []int64{10, 0}}},
+			"b": schema.Column{Name: "b", Type: schema.Type{Name: "decimal", Mods: []int64{20, 0}}},
+			"c": schema.Column{Name: "c", Type: schema.Type{Name: "decimal", Mods: []int64{10, 2}}},
+			"d": schema.Column{Name: "d", Type: schema.Type{Name: "text"}},
+			"e": schema.Column{Name: "e", Type: schema.Type{Name: "bigint"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "e"}},
+	}
+	assert.Nil(t, common.SchemaToSpannerDDL(conv, ToDdlImpl{}))
+	actual := conv.SpSchema[name]
+	assert.Equal(t, ddl.Type{Name: ddl.Int64}, actual.ColDefs["a"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.Numeric}, actual.ColDefs["b"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.Numeric}, actual.ColDefs["c"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, actual.ColDefs["d"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.Int64}, actual.ColDefs["e"].T)
+	expectedIssues := map[string][]internal.SchemaIssue{
+		"a": []internal.SchemaIssue{internal.TypeOverridden},
+		"d": []internal.SchemaIssue{internal.TypeOverridden},
+		"e": []internal.SchemaIssue{internal.TypeOverrideRejected},
+	}
+	assert.Equal(t, expectedIssues, conv.Issues[name])
+	assert.Equal(t, map[string]internal.TypeOverride{
+		"a": {Rule: "decimal(p<=18,s=0) -> INT64", Type: "INT64"},
+		"d": {Rule: "test.d -> BYTES", Type: "BYTES(MAX)"},
+		"e": {Rule: "test.e -> DATE", Type: "DATE"},
+	}, conv.TypeOverrides[name])
+}
@@ -143,12 +143,18 @@ func convBytes(val string) ([]byte, error) {
 	return b, nil
 }
 
+// convDate converts a DATE value, e.g. 2019-10-29T00:00:00Z, to a Spanner
+// DATE. Values with a time of day are rejected rather than truncated, since
+// DATEs are only mapped to Spanner DATEs when the sampled values have none.
 func convDate(val string) (civil.Date, error) {
-	date := strings.Split(val, "T")[0]
+	date, timeOfDay, _ := strings.Cut(val, "T")
 	d, err := civil.ParseDate(date)
 	if err != nil {
 		return d, fmt.Errorf("can't convert to date: %w", err)
 	}
+	if strings.Trim(timeOfDay, "0:.Z") != "" {
+		return d, fmt.Errorf("can't convert to date: %s has a time of day", val)
+	}
 	return d, err
 }
 
@@ -253,23 +253,31 @@ func TestConvertError(t *testing.T) {
 			cols: []string{"a", "b", "c"},
 			vals: []string{"6", "6.6", "2022-01-199:34:06.47Z"},
 		},
+		{
+			// DATEs mapped to DATE can't be truncated.
+			name: "Time of day in date",
+			cols: []string{"a", "b", "d"},
+			vals: []string{"6", "6.6", "2022-01-19T09:34:06Z"},
+		},
 	}
 	tableName := "testtable"
 	spTable := ddl.CreateTable{
 		Name:     tableName,
-		ColNames: []string{"a", "b", "c"},
+		ColNames: []string{"a", "b", "c", "d"},
 		ColDefs: map[string]ddl.ColumnDef{
 			"a": {Name: "a", T: ddl.Type{Name: ddl.Int64}},
 			"b": {Name: "b", T: ddl.Type{Name: ddl.Float64}},
 			"c": {Name: "c", T: ddl.Type{Name: ddl.Timestamp}},
+			"d": {Name: "d", T: ddl.Type{Name: ddl.Date}},
 		}}
 	srcTable := schema.Table{
 		Name:     tableName,
-		ColNames: []string{"a", "b", "c"},
+		ColNames: []string{"a", "b", "c", "d"},
 		ColDefs: map[string]schema.Column{
 			"a": {Type: schema.Type{Name: "NUMBER"}},
 			"b": {Type: schema.Type{Name: "FLOAT"}},
 			"c": {Type: schema.Type{Name: "TIMESTAMP(6)"}},
+			"d": {Type: schema.Type{Name: "DATE"}},
 		}}
 	for _, tc := range errorTests {
 		t.Run(tc.name, func(t *testing.T) {
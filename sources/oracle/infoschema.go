@@ -18,7 +18,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"sort"
+	"strconv"
 	"strings"
 
 	sp "cloud.google.com/go/spanner"
@@ -61,6 +63,33 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string)
 	return rows, err
 }
 
+// ProfileTable implements the common.ProfilingInfoSchema interface.
+func (isi InfoSchemaImpl) ProfileTable(conv *internal.Conv, srcTable string, cols []common.ProfileColumn) (map[string]*internal.ColumnProfile, error) {
+	tbl := conv.SrcSchema[srcTable]
+	var profiled []common.ProfileColumn
+	for _, c := range cols {
+		// Only character values have a length, and collections and objects
+		// can't be compared.
+		col := tbl.ColDefs[c.Name]
+		if len(col.Type.ArrayBounds) > 0 || col.Type.Name == "OBJECT" {
+			continue
+		}
+		switch col.Type.Name {
+		case "CHAR", "NCHAR", "VARCHAR", "VARCHAR2", "NVARCHAR2", "CLOB", "NCLOB":
+		default:
+			c.Text = false
+		}
+		profiled = append(profiled, c)
+	}
+	quote := func(col string) string { return `"` + strings.ReplaceAll(col, `"`, `""`) + `"` }
+	q := common.ProfileQuery(fmt.Sprintf(`"%s"."%s"`, tbl.Schema, tbl.Name), profiled, quote, "LENGTH(%s)")
+	rows, err := isi.Db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return common.ScanProfile(rows, profiled)
+}
+
 func getSelectQuery(srcDb string, schemaName string, tableName string, colNames []string, colDefs map[string]schema.Column) string {
 	var selects = make([]string, len(colNames))
 
@@ -75,6 +104,10 @@ func getSelectQuery(srcDb string, schemaName string, tableName string, colNames
 			switch colDefs[cn].Type.Name {
 			case "NUMBER":
 				s = fmt.Sprintf(`TO_CHAR("%s") AS "%s"`, cn, cn)
+			case "DATE":
+				// DATEs have a time of day, which is kept when they are
+				// mapped to TIMESTAMP.
+				s = fmt.Sprintf(`TO_CHAR("%s", 'YYYY-MM-DD"T"HH24:MI:SS"Z"') AS "%s"`, cn, cn)
 			case "XMLTYPE":
 				s = fmt.Sprintf(`CAST(XMLTYPE.getStringVal("%s") AS VARCHAR2(4000)) AS "%s"`, cn, cn)
 			case "SDO_GEOMETRY":
@@ -225,9 +258,134 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 		colDefs[colName] = c
 		colNames = append(colNames, colName)
 	}
+	isi.sampleColumns(conv, table, colDefs, colNames)
 	return colDefs, colNames, nil
 }
 
+// sampleColumns sets the Sample of the columns whose type doesn't tell
+// enough to choose a Spanner type: unconstrained NUMBERs, which can hold
+// integers as well as values that don't fit in a NUMERIC, and DATEs, which
+// have a time of day that is often unused. The sample is a random sample of
+// about the schema sample size rows of the table, using SAMPLE so that it
+// isn't biased towards the rows stored first. Errors are reported, and leave
+// the columns with their default types.
+func (isi InfoSchemaImpl) sampleColumns(conv *internal.Conv, table common.SchemaAndName, colDefs map[string]schema.Column, colNames []string) {
+	var selects []string
+	for _, cn := range colNames {
+		t := colDefs[cn].Type
+		switch {
+		case len(t.ArrayBounds) > 0:
+		case t.Name == "NUMBER" && len(t.Mods) == 0:
+			selects = append(selects, fmt.Sprintf(`TO_CHAR("%s") AS "%s"`, cn, cn))
+		case t.Name == "DATE":
+			selects = append(selects, fmt.Sprintf(`CASE WHEN "%s" <> TRUNC("%s") THEN 1 WHEN "%s" IS NOT NULL THEN 0 END AS "%s"`, cn, cn, cn, cn))
+		}
+	}
+	if len(selects) == 0 {
+		return
+	}
+	var count int64
+	err := isi.Db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"."%s"`, table.Schema, table.Name)).Scan(&count)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't sample table %s.%s: %s", table.Schema, table.Name, err))
+		return
+	}
+	q := fmt.Sprintf(`SELECT %s FROM "%s"."%s"%s`, strings.Join(selects, ", "), table.Schema, table.Name, sampleClause(count, profiles.GetSchemaSampleSize(isi.SourceProfile)))
+	rows, err := isi.Db.Query(q)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't sample table %s.%s: %s", table.Schema, table.Name, err))
+		return
+	}
+	profs, err := common.ProfileSQLRows(rows)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't sample table %s.%s: %s", table.Schema, table.Name, err))
+		return
+	}
+	for cn, p := range profs {
+		c, ok := colDefs[cn]
+		if !ok || p.Rows == p.Nulls {
+			continue
+		}
+		c.Sample = &schema.Sample{Rows: p.Rows - p.Nulls}
+		if c.Type.Name == "DATE" {
+			c.Sample.Time = p.Max == "1"
+		} else {
+			c.Sample.Integer = p.Integer
+			c.Sample.Numeric = p.Numeric && p.MaxScale <= 9 && intDigits(p.Min) <= 29 && intDigits(p.Max) <= 29
+		}
+		colDefs[cn] = c
+	}
+}
+
+// sampleClause returns the SAMPLE clause selecting about sampleSize rows of
+// a table of count rows, or "" if all rows are read.
+func sampleClause(count, sampleSize int64) string {
+	if sampleSize <= 0 || count <= sampleSize {
+		return ""
+	}
+	// The percentage must be at least 0.000001 and less than 100.
+	percent := math.Max(100*float64(sampleSize)/float64(count), 0.000001)
+	return fmt.Sprintf(" SAMPLE(%s)", strconv.FormatFloat(math.Min(percent, 99.999999), 'f', -1, 64))
+}
+
+// intDigits returns the number of significant digits before the decimal
+// point of decimal number s.
+func intDigits(s string) int {
+	s = strings.TrimLeft(s, "+-")
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	return len(strings.TrimLeft(s, "0"))
+}
+
+// GetSequences implements the common.SequenceInfoSchema interface. The next
+// value of a sequence is LAST_NUMBER, the first value not yet cached,
+// which is an upper bound of the values already generated.
+func (isi InfoSchemaImpl) GetSequences(conv *internal.Conv) ([]schema.Sequence, error) {
+	q := fmt.Sprintf(`SELECT sequence_name, TO_CHAR(min_value), TO_CHAR(max_value), increment_by, cycle_flag, TO_CHAR(last_number) FROM all_sequences WHERE sequence_owner = '%s'`, isi.DbName)
+	rows, err := isi.Db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var seqs []schema.Sequence
+	for rows.Next() {
+		s := schema.Sequence{Schema: isi.DbName}
+		var cycle string
+		if err := rows.Scan(&s.Name, &s.MinValue, &s.MaxValue, &s.Increment, &cycle, &s.Next); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		s.Cycle = cycle == "Y"
+		seqs = append(seqs, s)
+	}
+	return seqs, rows.Err()
+}
+
+// GetSynonyms implements the common.SynonymInfoSchema interface. It
+// returns the synonyms owned by the schema, and those of other schemas
+// that refer to its objects.
+func (isi InfoSchemaImpl) GetSynonyms(conv *internal.Conv) ([]schema.Synonym, error) {
+	q := fmt.Sprintf(`SELECT owner, synonym_name, table_owner, table_name FROM all_synonyms WHERE owner = '%s' OR table_owner = '%s'`, isi.DbName, isi.DbName)
+	rows, err := isi.Db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var syns []schema.Synonym
+	for rows.Next() {
+		var s schema.Synonym
+		var targetOwner, target string
+		if err := rows.Scan(&s.Owner, &s.Name, &targetOwner, &target); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		s.Target = targetOwner + "." + target
+		syns = append(syns, s)
+	}
+	return syns, rows.Err()
+}
+
 // GetConstraints returns a list of primary keys and by-column map of
 // other constraints.  Note: we need to preserve ordinal order of
 // columns in primary key constraints.
@@ -407,7 +565,7 @@ func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAnd
 // performing a streaming migration.
 func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
 	mp := make(map[string]interface{})
-	streamingCfg, err := streaming.StartDatastream(ctx, isi.SourceProfile, isi.TargetProfile)
+	streamingCfg, err := streaming.StartDatastream(ctx, isi.SourceProfile, isi.TargetProfile, conv.Audit.MigrationRequestId)
 	if err != nil {
 		err = fmt.Errorf("error starting datastream: %v", err)
 		return nil, err
@@ -417,9 +575,13 @@ func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *inte
 }
 
 // StartStreamingMigration is used for automatic triggering of Dataflow job when
-// performing a streaming migration.
+// performing a streaming migration. If the streaming config has a LocalApplyCfg,
+// the Datastream output files are applied by HarbourBridge instead.
 func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamingInfo map[string]interface{}) error {
 	streamingCfg, _ := streamingInfo["streamingCfg"].(streaming.StreamingCfg)
+	if streamingCfg.LocalApplyCfg.Dir != "" {
+		return streaming.StartLocalApply(ctx, client, conv, streamingCfg.LocalApplyCfg, isi)
+	}
 	err := streaming.StartDataflow(ctx, isi.SourceProfile, isi.TargetProfile, streamingCfg, conv)
 	if err != nil {
 		return err
@@ -427,6 +589,12 @@ func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *s
 	return nil
 }
 
+// ConvertRow converts a row of a Datastream change event, like the rows of
+// the snapshot.
+func (isi InfoSchemaImpl) ConvertRow(conv *internal.Conv, srcTable string, srcCols []string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, vals []string) (string, []string, []interface{}, error) {
+	return convertData(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, vals)
+}
+
 func toType(dataType string, typecode, elementDataType sql.NullString, charLen sql.NullInt64, numericPrecision, numericScale, elementCharMaxLen, elementNumericPrecision, elementNumericScale sql.NullInt64) schema.Type {
 	switch {
 	case typecode.Valid && typecode.String == "COLLECTION":
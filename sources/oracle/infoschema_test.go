@@ -15,15 +15,20 @@
 package oracle
 
 import (
+	"bufio"
+	"bytes"
 	"database/sql"
 	"database/sql/driver"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 )
@@ -86,6 +91,18 @@ func TestProcessSchemaOracle(t *testing.T) {
 				{"NAME", "VARCHAR2", "N", nil, nil, nil, nil, nil, nil, nil, nil, nil},
 				{"REF", "NUMBER", "Y", nil, nil, nil, nil, nil, nil, nil, nil, nil}},
 		},
+		{
+			query: `SELECT COUNT\(\*\) FROM (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{2}},
+		},
+		{
+			query: `SELECT TO_CHAR\("REF"\) AS "REF" FROM (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"REF"},
+			rows:  [][]driver.Value{},
+		},
 
 		// test table
 		{
@@ -114,6 +131,18 @@ func TestProcessSchemaOracle(t *testing.T) {
 			rows: [][]driver.Value{
 				{"ID", "NUMBER", "N", nil, nil, nil, nil, nil, nil, nil, nil, nil}},
 		},
+		{
+			query: `SELECT COUNT\(\*\) FROM (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{2}},
+		},
+		{
+			query: `SELECT TO_CHAR\("ID"\) AS "ID" FROM (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"ID"},
+			rows:  [][]driver.Value{},
+		},
 
 		// test2 table [json column test]
 		{
@@ -150,6 +179,30 @@ func TestProcessSchemaOracle(t *testing.T) {
 				{"ARRAY_DATE", "STUDENT", "N", nil, nil, nil, nil, "COLLECTION", "DATE", nil, nil, nil},
 				{"ARRAY_INT", "STUDENT", "N", nil, nil, nil, nil, "COLLECTION", "NUMBER", nil, 10, 0},
 				{"OBJECT", "CONTACTS", "N", nil, nil, nil, nil, "OBJECT", nil, nil, nil, nil}}},
+		{
+			query: `SELECT COUNT\(\*\) FROM (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{2}},
+		},
+		{
+			query: `SELECT TO_CHAR\("ID"\) AS "ID" FROM (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"ID"},
+			rows:  [][]driver.Value{},
+		},
+		{
+			query: "SELECT (.+) FROM all_sequences (.+)",
+			args:  []driver.Value{},
+			cols:  []string{"sequence_name", "min_value", "max_value", "increment_by", "cycle_flag", "last_number"},
+			rows:  [][]driver.Value{},
+		},
+		{
+			query: "SELECT (.+) FROM all_synonyms (.+)",
+			args:  []driver.Value{},
+			cols:  []string{"owner", "synonym_name", "table_owner", "table_name"},
+			rows:  [][]driver.Value{},
+		},
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
@@ -236,3 +289,128 @@ func mkMockDB(t *testing.T, ms []mockSpec) *sql.DB {
 	}
 	return db
 }
+
+func TestSampleClause(t *testing.T) {
+	assert.Equal(t, "", sampleClause(100, 100000))
+	assert.Equal(t, "", sampleClause(100000, 100000))
+	assert.Equal(t, " SAMPLE(25)", sampleClause(400000, 100000))
+	assert.Equal(t, " SAMPLE(99.999999)", sampleClause(100000001, 100000000))
+	assert.Equal(t, " SAMPLE(0.000001)", sampleClause(1e15, 10))
+	assert.Equal(t, "", sampleClause(400000, 0))
+}
+
+func TestProcessSchemaOracle_SampledTypesAndSequences(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_name FROM all_tables (.+)",
+			args:  []driver.Value{},
+			cols:  []string{"table_name"},
+			rows:  [][]driver.Value{{"T"}},
+		},
+		{
+			query: `SELECT (.+) FROM all_constraints (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"column_name", "contraint_type", "condition"},
+			rows:  [][]driver.Value{{"ID", "P", "ID IS NOT NULL"}},
+		},
+		{
+			query: `SELECT (.+) all_cons_columns A JOIN all_constraints C ON (.+) JOIN all_cons_columns B (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"ref_table", "column_name", "ref_column_name", "name"},
+			rows:  [][]driver.Value{},
+		},
+		{
+			query: `SELECT (.+) LEFT JOIN all_ind_expressions IE (.+) LEFT JOIN all_indexes I (.+)`,
+			args:  []driver.Value{},
+			cols:  []string{"name", "column_name", "column_position", "descend", "uniqueness", "column_expression", "index_type"},
+			rows:  [][]driver.Value{},
+		},
+		{
+			query: "SELECT (.+) FROM all_tab_columns (.+)",
+			args:  []driver.Value{},
+			cols:  []string{"column_name", "data_type", "nullable", "data_default", "data_length", "data_precision", "data_scale", "typecode", "element_type", "element_length", "element_precision", "element_scale"},
+			rows: [][]driver.Value{
+				{"ID", "NUMBER", "N", nil, nil, nil, nil, nil, nil, nil, nil, nil},
+				{"AMOUNT", "NUMBER", "Y", nil, nil, nil, nil, nil, nil, nil, nil, nil},
+				{"RATIO", "NUMBER", "Y", nil, nil, nil, nil, nil, nil, nil, nil, nil},
+				{"EMPTY", "NUMBER", "Y", nil, nil, nil, nil, nil, nil, nil, nil, nil},
+				{"QTY", "NUMBER", "Y", nil, nil, 10, nil, nil, nil, nil, nil, nil},
+				{"CREATED", "DATE", "Y", nil, nil, nil, nil, nil, nil, nil, nil, nil},
+				{"BIRTHDAY", "DATE", "Y", nil, nil, nil, nil, nil, nil, nil, nil, nil}},
+		},
+		{
+			// The table has more rows than the schema sample size, so
+			// it is sampled.
+			query: `SELECT COUNT\(\*\) FROM "test"."T"`,
+			args:  []driver.Value{},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{1000000}},
+		},
+		{
+			query: `SELECT TO_CHAR\("ID"\) AS "ID", TO_CHAR\("AMOUNT"\) AS "AMOUNT", TO_CHAR\("RATIO"\) AS "RATIO", TO_CHAR\("EMPTY"\) AS "EMPTY", CASE WHEN "CREATED" <> TRUNC\("CREATED"\) THEN 1 (.+) AS "CREATED", (.+) AS "BIRTHDAY" FROM "test"."T" SAMPLE\(10\)$`,
+			args:  []driver.Value{},
+			cols:  []string{"ID", "AMOUNT", "RATIO", "EMPTY", "CREATED", "BIRTHDAY"},
+			rows: [][]driver.Value{
+				{"1", "12.5", "1.0E+40", nil, "0", "0"},
+				{"2", "-3.25", ".5", nil, "1", nil},
+			},
+		},
+		{
+			query: "SELECT (.+) FROM all_sequences (.+)",
+			args:  []driver.Value{},
+			cols:  []string{"sequence_name", "min_value", "max_value", "increment_by", "cycle_flag", "last_number"},
+			rows: [][]driver.Value{
+				{"T_SEQ", "1", "9999999999999999999999999999", 1, "N", "1021"},
+				{"CYCLE_SEQ", "1", "100", 5, "Y", "41"},
+			},
+		},
+		{
+			query: "SELECT (.+) FROM all_synonyms (.+)",
+			args:  []driver.Value{},
+			cols:  []string{"owner", "synonym_name", "table_owner", "table_name"},
+			rows:  [][]driver.Value{{"PUBLIC", "T_ALIAS", "test", "T"}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	err := common.ProcessSchema(conv, InfoSchemaImpl{"test", db, profiles.SourceProfile{}, profiles.TargetProfile{}}, 1)
+	assert.Nil(t, err)
+	expected := ddl.CreateTable{
+		Name:     "T",
+		ColNames: []string{"ID", "AMOUNT", "RATIO", "EMPTY", "QTY", "CREATED", "BIRTHDAY"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"ID":       {Name: "ID", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"AMOUNT":   {Name: "AMOUNT", T: ddl.Type{Name: ddl.Numeric}},
+			"RATIO":    {Name: "RATIO", T: ddl.Type{Name: ddl.Float64}},
+			"EMPTY":    {Name: "EMPTY", T: ddl.Type{Name: ddl.Numeric}},
+			"QTY":      {Name: "QTY", T: ddl.Type{Name: ddl.Int64}},
+			"CREATED":  {Name: "CREATED", T: ddl.Type{Name: ddl.Timestamp}},
+			"BIRTHDAY": {Name: "BIRTHDAY", T: ddl.Type{Name: ddl.Date}},
+		},
+		Pks: []ddl.IndexKey{{Col: "ID"}},
+	}
+	assert.Equal(t, expected, stripSchemaComments(conv.SpSchema)["T"])
+	issues := conv.Issues["T"]
+	assert.Equal(t, []internal.SchemaIssue{internal.SampledType}, issues["ID"])
+	assert.Equal(t, []internal.SchemaIssue{internal.SampledType}, issues["AMOUNT"])
+	assert.Equal(t, []internal.SchemaIssue{internal.SampledFloat}, issues["RATIO"])
+	assert.Empty(t, issues["EMPTY"])
+	assert.Equal(t, []internal.SchemaIssue{internal.SampledType}, issues["CREATED"])
+	assert.Empty(t, issues["BIRTHDAY"])
+	assert.Equal(t, map[string]ddl.Sequence{
+		"T_SEQ":     {Name: "T_SEQ", StartWithCounter: 1021, Comment: "From: T_SEQ"},
+		"CYCLE_SEQ": {Name: "CYCLE_SEQ", StartWithCounter: 41, Comment: "From: CYCLE_SEQ"},
+	}, conv.SpSequences)
+	assert.Equal(t, []schema.Synonym{{Name: "T_ALIAS", Owner: "PUBLIC", Target: "test.T"}}, conv.Synonyms)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.ORACLE, conv, w, nil, true, true)
+	w.Flush()
+	report := strings.Join(strings.Fields(buf.String()), " ")
+	assert.Contains(t, report, "Column 'RATIO': type NUMBER is mapped to float64 based on a sample of 2 values.")
+	assert.Contains(t, report, "Sequence 'CYCLE_SEQ' is mapped to Spanner sequence 'CYCLE_SEQ', whose counter starts at 41.")
+	assert.Contains(t, report, "Its increment of 5 isn't supported. Spanner sequences don't cycle.")
+	assert.Contains(t, report, "Synonym 'PUBLIC.T_ALIAS' refers to 'test.T'.")
+}
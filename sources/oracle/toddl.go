@@ -78,6 +78,13 @@ func toSpannerTypeInternal(conv *internal.Conv, spType string, srcType schema.Ty
 		switch spType {
 		case ddl.String:
 			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+		case ddl.Int64, ddl.Float64:
+			// Unconstrained numbers can hold anything, so the best type
+			// depends on the data (see the column's sample).
+			if len(srcType.Mods) == 0 {
+				return ddl.Type{Name: spType}, nil
+			}
+			fallthrough
 		default:
 			modsLen := len(srcType.Mods)
 			if modsLen == 0 {
@@ -116,6 +123,9 @@ func toSpannerTypeInternal(conv *internal.Conv, spType string, srcType schema.Ty
 		switch spType {
 		case ddl.String:
 			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+		case ddl.Timestamp:
+			// Oracle DATEs have a time of day.
+			return ddl.Type{Name: ddl.Timestamp}, nil
 		default:
 			return ddl.Type{Name: ddl.Date}, nil
 		}
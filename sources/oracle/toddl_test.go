@@ -107,6 +107,26 @@ func TestToSpannerType(t *testing.T) {
 }
 
 // This is just a very basic smoke-test for toExperimentalSpannerType.
+func TestToSpannerTypeAlternatives(t *testing.T) {
+	conv := internal.MakeConv()
+	toddl := ToDdlImpl{}
+	for _, tc := range []struct {
+		spType  string
+		srcType schema.Type
+		want    ddl.Type
+	}{
+		{ddl.Int64, schema.Type{Name: "NUMBER"}, ddl.Type{Name: ddl.Int64}},
+		{ddl.Float64, schema.Type{Name: "NUMBER"}, ddl.Type{Name: ddl.Float64}},
+		// Constrained numbers keep their default type.
+		{ddl.Float64, schema.Type{Name: "NUMBER", Mods: []int64{10, 2}}, ddl.Type{Name: ddl.Numeric}},
+		{ddl.Timestamp, schema.Type{Name: "DATE"}, ddl.Type{Name: ddl.Timestamp}},
+	} {
+		ty, issues := toddl.ToSpannerType(conv, tc.spType, tc.srcType)
+		assert.Equal(t, tc.want, ty, tc.srcType.Print())
+		assert.Empty(t, issues)
+	}
+}
+
 func TestToExperimentalSpannerType(t *testing.T) {
 	conv := internal.MakeConv()
 	conv.SetSchemaMode()
@@ -28,6 +28,7 @@ import (
 	"cloud.google.com/go/spanner"
 	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 )
 
@@ -89,9 +90,12 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, vals []
 		}
 		var x interface{}
 		var err error
-		if spColDef.T.IsArray {
+		switch {
+		case spColDef.T.IsArray:
 			x, err = convArray(spColDef.T, srcColDef.Type.Name, conv.Location, vals[i])
-		} else {
+		case spColDef.T.Name == ddl.JSON:
+			x, err = convJSON(srcColDef, vals[i])
+		default:
 			x, err = convScalar(conv, spColDef.T, srcColDef.Type.Name, conv.Location, vals[i])
 		}
 		if err != nil {
@@ -200,6 +204,19 @@ func convNumeric(conv *internal.Conv, val string) (interface{}, error) {
 	}
 }
 
+// convJSON maps a source database string value to a Spanner JSON value.
+// Composites and ranges are converted to JSON objects, while other values
+// (e.g. of json and jsonb types) are expected to be JSON already.
+func convJSON(srcCol schema.Column, val string) (string, error) {
+	switch {
+	case srcCol.Type.Name == compositeType:
+		return convComposite(srcCol.Fields, val)
+	case isRangeType(srcCol.Type.Name):
+		return convRange(val)
+	}
+	return val, nil
+}
+
 // convTimestamp maps a source DB timestamp into a go Time (which
 // is translated to a Spanner timestamp by the go Spanner client library).
 // It handles both timestamptz and timestamp conversions.
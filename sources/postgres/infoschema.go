@@ -17,16 +17,18 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"math/bits"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/civil"
 	sp "cloud.google.com/go/spanner"
-	_ "github.com/lib/pq" // we will use database/sql package instead of using this package directly
+	"github.com/lib/pq"
 
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/profiles"
@@ -41,13 +43,14 @@ type InfoSchemaImpl struct {
 	Db            *sql.DB
 	SourceProfile profiles.SourceProfile
 	TargetProfile profiles.TargetProfile
+	Snapshot      *common.SnapshotReader // Set for the readers of a consistent snapshot.
 }
 
 // StartChangeDataCapture is used for automatic triggering of Datastream job when
 // performing a streaming migration.
 func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
 	mp := make(map[string]interface{})
-	streamingCfg, err := streaming.StartDatastream(ctx, isi.SourceProfile, isi.TargetProfile)
+	streamingCfg, err := streaming.StartDatastream(ctx, isi.SourceProfile, isi.TargetProfile, conv.Audit.MigrationRequestId)
 	if err != nil {
 		err = fmt.Errorf("error starting datastream: %v", err)
 		return nil, err
@@ -57,9 +60,13 @@ func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *inte
 }
 
 // StartStreamingMigration is used for automatic triggering of Dataflow job when
-// performing a streaming migration.
+// performing a streaming migration. If the streaming config has a LocalApplyCfg,
+// the Datastream output files are applied by HarbourBridge instead.
 func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *sp.Client, conv *internal.Conv, streamingInfo map[string]interface{}) error {
 	streamingCfg, _ := streamingInfo["streamingCfg"].(streaming.StreamingCfg)
+	if streamingCfg.LocalApplyCfg.Dir != "" {
+		return streaming.StartLocalApply(ctx, client, conv, streamingCfg.LocalApplyCfg, isi)
+	}
 
 	err := streaming.StartDataflow(ctx, isi.SourceProfile, isi.TargetProfile, streamingCfg, conv)
 	if err != nil {
@@ -69,6 +76,49 @@ func (isi InfoSchemaImpl) StartStreamingMigration(ctx context.Context, client *s
 	return nil
 }
 
+// StreamedTables implements the streaming.StreamedTables interface: the
+// stream reads the tables of the publication, through the replication slot,
+// of the Datastream properties.
+func (isi InfoSchemaImpl) StreamedTables(ctx context.Context, datastreamCfg streaming.DatastreamCfg) ([]string, error) {
+	params, err := profiles.ParseMap(datastreamCfg.Properties)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse properties: %v", err)
+	}
+	slot, publication := params["replicationSlot"], params["publication"]
+	for _, check := range []struct{ kind, name, q string }{
+		{"replication slot", slot, "SELECT COUNT(*) FROM pg_replication_slots WHERE slot_name = $1"},
+		{"publication", publication, "SELECT COUNT(*) FROM pg_publication WHERE pubname = $1"},
+	} {
+		var n int64
+		if err := isi.Db.QueryRowContext(ctx, check.q, check.name).Scan(&n); err != nil {
+			return nil, fmt.Errorf("can't check %s %s: %v", check.kind, check.name, err)
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("%s %s doesn't exist", check.kind, check.name)
+		}
+	}
+	rows, err := isi.Db.QueryContext(ctx, "SELECT schemaname, tablename FROM pg_publication_tables WHERE pubname = $1", publication)
+	if err != nil {
+		return nil, fmt.Errorf("can't get the tables of publication %s: %v", publication, err)
+	}
+	defer rows.Close()
+	var tables []string
+	var schema, table string
+	for rows.Next() {
+		if err := rows.Scan(&schema, &table); err != nil {
+			return nil, fmt.Errorf("can't get the tables of publication %s: %v", publication, err)
+		}
+		tables = append(tables, isi.GetTableName(schema, table))
+	}
+	return tables, rows.Err()
+}
+
+// ConvertRow converts a row of a Datastream change event, like the rows of
+// the snapshot.
+func (isi InfoSchemaImpl) ConvertRow(conv *internal.Conv, srcTable string, srcCols []string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, vals []string) (string, []string, []interface{}, error) {
+	return ConvertData(conv, srcTable, srcCols, vals)
+}
+
 // GetToDdl function below implement the common.InfoSchema interface.
 func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
 	return ToDdlImpl{}
@@ -87,14 +137,25 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string)
 	// PostgreSQL schema and name can be arbitrary strings.
 	// Ideally we would pass schema/name as a query parameter,
 	// but PostgreSQL doesn't support this. So we quote it instead.
-	q := fmt.Sprintf(`SELECT * FROM "%s"."%s";`, conv.SrcSchema[srcTable].Schema, srcTable)
-	rows, err := isi.Db.Query(q)
+	q := fmt.Sprintf(`SELECT * FROM %s.%s;`, pq.QuoteIdentifier(conv.SrcSchema[srcTable].Schema), pq.QuoteIdentifier(srcTable))
+	rows, err := isi.Snapshot.Query(isi.Db, q)
 	if err != nil {
 		return nil, err
 	}
 	return rows, err
 }
 
+// ProfileTable implements the common.ProfilingInfoSchema interface.
+func (isi InfoSchemaImpl) ProfileTable(conv *internal.Conv, srcTable string, cols []common.ProfileColumn) (map[string]*internal.ColumnProfile, error) {
+	table := pq.QuoteIdentifier(conv.SrcSchema[srcTable].Schema) + "." + pq.QuoteIdentifier(srcTable)
+	q := common.ProfileQuery(table, cols, pq.QuoteIdentifier, "CHAR_LENGTH(CAST(%s AS TEXT))")
+	rows, err := isi.Snapshot.Query(isi.Db, q)
+	if err != nil {
+		return nil, err
+	}
+	return common.ScanProfile(rows, cols)
+}
+
 // ProcessDataRows performs data conversion for source database
 // 'db'. For each table, we extract data using a "SELECT *" query,
 // convert the data to Spanner data (based on the source and Spanner
@@ -115,35 +176,81 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string)
 // we can generate more targeted error messages: hence we pass
 // *interface{} parameters to row.Scan.
 func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	if len(srcSchema.Partitions) > 0 {
+		return isi.processPartitionedData(conv, srcTable, srcSchema, spTable, spSchema)
+	}
 	rowsInterface, err := isi.GetRowsFromTable(conv, srcTable)
 	if err != nil {
-		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		isi.Snapshot.Do(func() {
+			conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		})
 		return err
 	}
 	rows := rowsInterface.(*sql.Rows)
 	defer rows.Close()
 	srcCols, _ := rows.Columns()
-	v, iv := buildVals(len(srcCols))
-	for rows.Next() {
-		err := rows.Scan(iv...)
+	processRows(conv, rows, isi.Snapshot, srcTable, srcCols, srcSchema, spTable, spCols, spSchema)
+	return nil
+}
+
+// processPartitionedData writes the data of a table and all its partitions
+// (or child tables) to spTable. Each of them is read with SELECT * FROM
+// ONLY, so that rows are read once and columns of child tables aren't lost,
+// and their columns are mapped by name since partitions can order them
+// differently.
+func (isi InfoSchemaImpl) processPartitionedData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spSchema ddl.CreateTable) error {
+	tables := []common.SchemaAndName{{Schema: srcSchema.Schema, Name: srcTable}}
+	for _, p := range srcSchema.Partitions {
+		tables = append(tables, common.SchemaAndName{Schema: p.Schema, Name: strings.TrimPrefix(p.Name, p.Schema+".")})
+	}
+	for _, t := range tables {
+		rows, err := isi.Snapshot.Query(isi.Db, fmt.Sprintf(`SELECT * FROM ONLY %s.%s;`, pq.QuoteIdentifier(t.Schema), pq.QuoteIdentifier(t.Name)))
 		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
-			// Scan failed, so we don't have any data to add to bad rows.
-			conv.StatsAddBadRow(srcTable, conv.DataMode())
-			continue
+			isi.Snapshot.Do(func() {
+				conv.Unexpected(fmt.Sprintf("Couldn't get data for partition %s.%s of table %s : err = %s", t.Schema, t.Name, srcTable, err))
+			})
+			return err
 		}
-		cvtCols, cvtVals, err := convertSQLRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, v)
+		srcCols, _ := rows.Columns()
+		spCols, err := internal.GetSpannerCols(conv, srcTable, srcCols)
 		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
-			conv.StatsAddBadRow(srcTable, conv.DataMode())
-			conv.CollectBadRow(srcTable, srcCols, valsToStrings(v))
-			continue
+			rows.Close()
+			isi.Snapshot.Do(func() {
+				conv.Unexpected(fmt.Sprintf("Can't get cols for partition %s.%s of table %s: %s", t.Schema, t.Name, srcTable, err))
+			})
+			return err
 		}
-		conv.WriteRow(srcTable, spTable, cvtCols, cvtVals)
+		processRows(conv, rows, isi.Snapshot, srcTable, srcCols, srcSchema, spTable, spCols, spSchema)
+		rows.Close()
 	}
 	return nil
 }
 
+// processRows converts the rows of a 'SELECT *' query and writes them to
+// Spanner. The rows read by a snapshot reader are processed under its lock.
+func processRows(conv *internal.Conv, rows *sql.Rows, snapshot *common.SnapshotReader, srcTable string, srcCols []string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) {
+	v, iv := buildVals(len(srcCols))
+	for rows.Next() {
+		err := rows.Scan(iv...)
+		snapshot.Do(func() {
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
+				// Scan failed, so we don't have any data to add to bad rows.
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				return
+			}
+			cvtCols, cvtVals, err := convertSQLRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, v)
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, srcCols, valsToStrings(v))
+				return
+			}
+			conv.WriteRow(srcTable, spTable, cvtCols, cvtVals)
+		})
+	}
+}
+
 // ConvertSQLRow performs data conversion for a single row of data
 // returned from a 'SELECT *' query. ConvertSQLRow assumes that
 // srcCols, spCols and srcVals all have the same length. Note that
@@ -189,8 +296,8 @@ func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error)
 	// PostgreSQL schema and name can be arbitrary strings.
 	// Ideally we would pass schema/name as a query parameter,
 	// but PostgreSQL doesn't support this. So we quote it instead.
-	q := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"."%s";`, table.Schema, table.Name)
-	rows, err := isi.Db.Query(q)
+	q := fmt.Sprintf(`SELECT COUNT(*) FROM %s.%s;`, pq.QuoteIdentifier(table.Schema), pq.QuoteIdentifier(table.Name))
+	rows, err := isi.Snapshot.Query(isi.Db, q)
 	if err != nil {
 		return 0, err
 	}
@@ -214,7 +321,13 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 	for _, s := range []string{"information_schema", "postgres", "pg_catalog", "pg_temp_1", "pg_toast", "pg_toast_temp_1"} {
 		ignored[s] = true
 	}
-	q := "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'"
+	// Partitions and child tables are merged into their root table (see
+	// GetPartitions), so they are excluded.
+	q := `SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'
+              AND NOT EXISTS (SELECT 1 FROM pg_inherits i
+                                JOIN pg_class c ON c.oid = i.inhrelid
+                                JOIN pg_namespace n ON n.oid = c.relnamespace
+                              WHERE n.nspname = table_schema AND c.relname = table_name)`
 	rows, err := isi.Db.Query(q)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get tables: %w", err)
@@ -231,9 +344,52 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 	return tables, nil
 }
 
+// GetPartitions implements the common.PartitionedInfoSchema interface. It
+// returns the partitions of a declaratively partitioned table, or the child
+// tables of a table with inheritance children, at all levels. The scheme is
+// the partition key of the table e.g. "RANGE (logdate)", or "INHERITS".
+func (isi InfoSchemaImpl) GetPartitions(conv *internal.Conv, table common.SchemaAndName) (string, []common.PartitionInfo, error) {
+	q := `WITH RECURSIVE parts(parent, oid) AS (
+                SELECT i.inhparent, i.inhrelid FROM pg_inherits i
+                  JOIN pg_class c ON c.oid = i.inhparent
+                  JOIN pg_namespace n ON n.oid = c.relnamespace
+                WHERE n.nspname = $1 AND c.relname = $2
+              UNION ALL
+                SELECT i.inhparent, i.inhrelid FROM pg_inherits i JOIN parts p ON i.inhparent = p.oid)
+              SELECT n.nspname, c.relname, COALESCE(pg_get_expr(c.relpartbound, c.oid), ''),
+                     COALESCE((SELECT pg_get_partkeydef(r.oid) FROM pg_class r JOIN pg_namespace rn ON rn.oid = r.relnamespace
+                               WHERE rn.nspname = $1 AND r.relname = $2), '')
+              FROM parts JOIN pg_class c ON c.oid = parts.oid
+                JOIN pg_namespace n ON n.oid = c.relnamespace
+              ORDER BY n.nspname, c.relname`
+	rows, err := isi.Db.Query(q, table.Schema, table.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+	var scheme string
+	var partitions []common.PartitionInfo
+	for rows.Next() {
+		var p common.PartitionInfo
+		if err := rows.Scan(&p.Table.Schema, &p.Table.Name, &p.Bound, &scheme); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		partitions = append(partitions, p)
+	}
+	if scheme == "" {
+		scheme = "INHERITS"
+	}
+	return scheme, partitions, rows.Err()
+}
+
 // GetColumns returns a list of Column objects and names
 func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
-	q := `SELECT c.column_name, c.data_type, e.data_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale
+	// The last two columns name the type of the column (or of its
+	// elements), which may be user-defined. Note that the type of columns of
+	// a domain is reported as its base type.
+	q := `SELECT c.column_name, c.data_type, e.data_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale,
+                     COALESCE(c.domain_schema, e.udt_schema, c.udt_schema), COALESCE(c.domain_name, e.udt_name, c.udt_name)
               FROM information_schema.COLUMNS c LEFT JOIN information_schema.element_types e
                  ON ((c.table_catalog, c.table_schema, c.table_name, 'TABLE', c.dtd_identifier)
                      = (e.object_catalog, e.object_schema, e.object_name, e.object_type, e.collection_type_identifier))
@@ -245,10 +401,10 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 	colDefs := make(map[string]schema.Column)
 	var colNames []string
 	var colName, dataType, isNullable string
-	var colDefault, elementDataType sql.NullString
+	var colDefault, elementDataType, typeSchema, typeName sql.NullString
 	var charMaxLen, numericPrecision, numericScale sql.NullInt64
 	for cols.Next() {
-		err := cols.Scan(&colName, &dataType, &elementDataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale)
+		err := cols.Scan(&colName, &dataType, &elementDataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale, &typeSchema, &typeName)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
@@ -272,12 +428,97 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 			NotNull: common.ToNotNull(conv, isNullable),
 			Ignored: ignored,
 		}
+		if typeSchema.Valid && typeSchema.String != "pg_catalog" && typeName.Valid {
+			c, err = isi.resolveUserType(c, typeSchema.String, typeName.String)
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Can't resolve type %s.%s of column %s: %v", typeSchema.String, typeName.String, colName, err))
+			}
+		}
 		colDefs[colName] = c
 		colNames = append(colNames, colName)
 	}
 	return colDefs, colNames, nil
 }
 
+// resolveUserType resolves the type of column c if it is a user-defined
+// enum, domain, composite or range type. Columns of other types are
+// returned unchanged.
+func (isi InfoSchemaImpl) resolveUserType(c schema.Column, typeSchema, typeName string) (schema.Column, error) {
+	ut, ok, err := isi.getUserType(typeSchema, typeName)
+	if err != nil || !ok {
+		return c, err
+	}
+	var lookupErr error
+	c, err = applyUserType(c, ut, func(name string) (schema.UserType, bool) {
+		typeSchema, typeName, ok := strings.Cut(name, ".")
+		if !ok || lookupErr != nil {
+			return schema.UserType{}, false
+		}
+		ut, ok, lookupErr = isi.getUserType(typeSchema, typeName)
+		return ut, ok
+	})
+	if err != nil {
+		return c, err
+	}
+	return c, lookupErr
+}
+
+// getUserType reads the definition of user-defined type typeSchema.typeName.
+// It returns false if the type isn't an enum, domain, composite or range.
+// The base type of domains is only set if it is user-defined itself, and is
+// then qualified with its schema.
+func (isi InfoSchemaImpl) getUserType(typeSchema, typeName string) (schema.UserType, bool, error) {
+	q := `SELECT t.typtype, t.typnotnull, bn.nspname, b.typname,
+                     array_to_json(ARRAY(SELECT e.enumlabel FROM pg_enum e WHERE e.enumtypid = t.oid ORDER BY e.enumsortorder))::text,
+                     array_to_json(ARRAY(SELECT a.attname FROM pg_attribute a WHERE a.attrelid = t.typrelid AND a.attnum > 0 AND NOT a.attisdropped ORDER BY a.attnum))::text,
+                     array_to_json(ARRAY(SELECT pg_get_constraintdef(c.oid) FROM pg_constraint c WHERE c.contypid = t.oid AND c.contype = 'c' ORDER BY c.conname))::text
+              FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace
+                 LEFT JOIN pg_type b ON b.oid = t.typbasetype
+                 LEFT JOIN pg_namespace bn ON bn.oid = b.typnamespace
+              WHERE n.nspname = $1 AND t.typname = $2;`
+	rows, err := isi.Db.Query(q, typeSchema, typeName)
+	if err != nil {
+		return schema.UserType{}, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return schema.UserType{}, false, rows.Err()
+	}
+	var kind, enumValues, fields, checks string
+	var notNull bool
+	var baseSchema, baseName sql.NullString
+	if err := rows.Scan(&kind, &notNull, &baseSchema, &baseName, &enumValues, &fields, &checks); err != nil {
+		return schema.UserType{}, false, err
+	}
+	ut := schema.UserType{Name: userTypeName(typeSchema + "." + typeName)}
+	switch kind {
+	case "e":
+		ut.Kind = enumType
+		err = json.Unmarshal([]byte(enumValues), &ut.EnumValues)
+	case "c":
+		ut.Kind = compositeType
+		err = json.Unmarshal([]byte(fields), &ut.Fields)
+	case "r":
+		ut.Kind = rangeType
+	case "d":
+		ut.Kind = domainType
+		ut.NotNull = notNull
+		if baseSchema.Valid && baseSchema.String != "pg_catalog" {
+			ut.Base = schema.Type{Name: baseSchema.String + "." + baseName.String}
+		}
+		var defs []string
+		if err = json.Unmarshal([]byte(checks), &defs); err == nil {
+			for _, d := range defs {
+				// pg_get_constraintdef returns e.g. "CHECK ((VALUE > 0))".
+				ut.Checks = append(ut.Checks, strings.TrimPrefix(d, "CHECK "))
+			}
+		}
+	default:
+		return schema.UserType{}, false, nil
+	}
+	return ut, err == nil, err
+}
+
 // GetConstraints returns a list of primary keys and by-column map of
 // other constraints.  Note: we need to preserve ordinal order of
 // columns in primary key constraints.
@@ -562,9 +803,9 @@ func cvtSQLScalar(conv *internal.Conv, srcCd schema.Column, spCd ddl.ColumnDef,
 	case ddl.JSON:
 		switch v := val.(type) {
 		case string:
-			return string(v), nil
+			return convJSON(srcCd, v)
 		case []uint8:
-			return string(v), nil
+			return convJSON(srcCd, string(v))
 		}
 	}
 	return nil, fmt.Errorf("can't convert value of type %s to Spanner type %s", reflect.TypeOf(val), reflect.TypeOf(spCd.T))
@@ -15,6 +15,7 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 	"math/big"
@@ -25,10 +26,10 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/logger"
-	"github.com/cloudspannerecosystem/harbourbridge/profiles"
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/cloudspannerecosystem/harbourbridge/streaming"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -76,12 +77,12 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "user"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
 			rows: [][]driver.Value{
-				{"user_id", "text", nil, "NO", nil, nil, nil, nil},
-				{"name", "text", nil, "NO", nil, nil, nil, nil},
-				{"ref", "bigint", nil, "YES", nil, nil, nil, nil}},
-		}, {
+				{"user_id", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"name", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"ref", "bigint", nil, "YES", nil, nil, nil, nil, nil, nil}},
+		}, noPartitions("public", "user"), {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "cart"},
 			cols:  []string{"column_name", "constraint_type"},
@@ -108,12 +109,12 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "cart"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
 			rows: [][]driver.Value{
-				{"productid", "text", nil, "NO", nil, nil, nil, nil},
-				{"userid", "text", nil, "NO", nil, nil, nil, nil},
-				{"quantity", "bigint", nil, "YES", nil, nil, 64, 0}},
-		}, {
+				{"productid", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"userid", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"quantity", "bigint", nil, "YES", nil, nil, 64, 0, nil, nil}},
+		}, noPartitions("public", "cart"), {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "product"},
 			cols:  []string{"column_name", "constraint_type"},
@@ -130,11 +131,11 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "product"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
 			rows: [][]driver.Value{
-				{"product_id", "text", nil, "NO", nil, nil, nil, nil},
-				{"product_name", "text", nil, "NO", nil, nil, nil, nil}},
-		}, {
+				{"product_id", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"product_name", "text", nil, "NO", nil, nil, nil, nil, nil, nil}},
+		}, noPartitions("public", "product"), {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "test"},
 			cols:  []string{"column_name", "constraint_type"},
@@ -152,30 +153,30 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "test"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
 			rows: [][]driver.Value{
-				{"id", "bigint", nil, "NO", nil, nil, 64, 0},
-				{"aint", "ARRAY", "integer", "YES", nil, nil, nil, nil},
-				{"atext", "ARRAY", "text", "YES", nil, nil, nil, nil},
-				{"b", "boolean", nil, "YES", nil, nil, nil, nil},
-				{"bs", "bigint", nil, "NO", "nextval('test11_bs_seq'::regclass)", nil, 64, 0},
-				{"by", "bytea", nil, "YES", nil, nil, nil, nil},
-				{"c", "character", nil, "YES", nil, 1, nil, nil},
-				{"c8", "character", nil, "YES", nil, 8, nil, nil},
-				{"d", "date", nil, "YES", nil, nil, nil, nil},
-				{"f8", "double precision", nil, "YES", nil, nil, 53, nil},
-				{"f4", "real", nil, "YES", nil, nil, 24, nil},
-				{"i8", "bigint", nil, "YES", nil, nil, 64, 0},
-				{"i4", "integer", nil, "YES", nil, nil, 32, 0},
-				{"i2", "smallint", nil, "YES", nil, nil, 16, 0},
-				{"num", "numeric", nil, "YES", nil, nil, nil, nil},
-				{"s", "integer", nil, "NO", "nextval('test11_s_seq'::regclass)", nil, 32, 0},
-				{"ts", "timestamp without time zone", nil, "YES", nil, nil, nil, nil},
-				{"tz", "timestamp with time zone", nil, "YES", nil, nil, nil, nil},
-				{"txt", "text", nil, "NO", nil, nil, nil, nil},
-				{"vc", "character varying", nil, "YES", nil, nil, nil, nil},
-				{"vc6", "character varying", nil, "YES", nil, 6, nil, nil}},
-		}, {
+				{"id", "bigint", nil, "NO", nil, nil, 64, 0, nil, nil},
+				{"aint", "ARRAY", "integer", "YES", nil, nil, nil, nil, nil, nil},
+				{"atext", "ARRAY", "text", "YES", nil, nil, nil, nil, nil, nil},
+				{"b", "boolean", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"bs", "bigint", nil, "NO", "nextval('test11_bs_seq'::regclass)", nil, 64, 0, nil, nil},
+				{"by", "bytea", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"c", "character", nil, "YES", nil, 1, nil, nil, nil, nil},
+				{"c8", "character", nil, "YES", nil, 8, nil, nil, nil, nil},
+				{"d", "date", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"f8", "double precision", nil, "YES", nil, nil, 53, nil, nil, nil},
+				{"f4", "real", nil, "YES", nil, nil, 24, nil, nil, nil},
+				{"i8", "bigint", nil, "YES", nil, nil, 64, 0, nil, nil},
+				{"i4", "integer", nil, "YES", nil, nil, 32, 0, nil, nil},
+				{"i2", "smallint", nil, "YES", nil, nil, 16, 0, nil, nil},
+				{"num", "numeric", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"s", "integer", nil, "NO", "nextval('test11_s_seq'::regclass)", nil, 32, 0, nil, nil},
+				{"ts", "timestamp without time zone", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"tz", "timestamp with time zone", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"txt", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"vc", "character varying", nil, "YES", nil, nil, nil, nil, nil, nil},
+				{"vc6", "character varying", nil, "YES", nil, 6, nil, nil, nil, nil}},
+		}, noPartitions("public", "test"), {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "test_ref"},
 			cols:  []string{"column_name", "constraint_type"},
@@ -193,16 +194,16 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "test_ref"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
 			rows: [][]driver.Value{
-				{"ref_id", "bigint", nil, "NO", nil, nil, 64, 0},
-				{"ref_txt", "text", nil, "NO", nil, nil, nil, nil},
-				{"abc", "text", nil, "NO", nil, nil, nil, nil}},
-		},
+				{"ref_id", "bigint", nil, "NO", nil, nil, 64, 0, nil, nil},
+				{"ref_txt", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"abc", "text", nil, "NO", nil, nil, nil, nil, nil, nil}},
+		}, noPartitions("public", "test_ref"),
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
-	err := common.ProcessSchema(conv, InfoSchemaImpl{db, profiles.SourceProfile{}, profiles.TargetProfile{}}, 1)
+	err := common.ProcessSchema(conv, InfoSchemaImpl{Db: db}, 1)
 	assert.Nil(t, err)
 	expectedSchema := map[string]ddl.CreateTable{
 		"user": ddl.CreateTable{
@@ -330,7 +331,7 @@ func TestProcessData(t *testing.T) {
 		func(table string, cols []string, vals []interface{}) {
 			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
 		})
-	common.ProcessData(conv, InfoSchemaImpl{db, profiles.SourceProfile{}, profiles.TargetProfile{}})
+	common.ProcessData(conv, InfoSchemaImpl{Db: db})
 
 	assert.Equal(t,
 		[]spannerData{
@@ -443,12 +444,12 @@ func TestConvertSqlRow_MultiCol(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "test"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
 			rows: [][]driver.Value{
-				{"a", "text", nil, "NO", nil, nil, nil, nil},
-				{"b", "double precision", nil, "YES", nil, nil, 53, nil},
-				{"c", "bigint", nil, "YES", nil, nil, 64, 0}},
-		}, {
+				{"a", "text", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"b", "double precision", nil, "YES", nil, nil, 53, nil, nil, nil},
+				{"c", "bigint", nil, "YES", nil, nil, 64, 0, nil, nil}},
+		}, noPartitions("public", "test"), {
 			query: `SELECT [*] FROM "public"."test"`, // query is a regexp!
 			cols:  []string{"a", "b", "c"},
 			rows: [][]driver.Value{
@@ -458,7 +459,7 @@ func TestConvertSqlRow_MultiCol(t *testing.T) {
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
-	err := common.ProcessSchema(conv, InfoSchemaImpl{db, profiles.SourceProfile{}, profiles.TargetProfile{}}, 1)
+	err := common.ProcessSchema(conv, InfoSchemaImpl{Db: db}, 1)
 	assert.Nil(t, err)
 	conv.SetDataMode()
 	var rows []spannerData
@@ -466,7 +467,7 @@ func TestConvertSqlRow_MultiCol(t *testing.T) {
 		func(table string, cols []string, vals []interface{}) {
 			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
 		})
-	common.ProcessData(conv, InfoSchemaImpl{db, profiles.SourceProfile{}, profiles.TargetProfile{}})
+	common.ProcessData(conv, InfoSchemaImpl{Db: db})
 	assert.Equal(t, []spannerData{
 		{table: "test", cols: []string{"a", "b", "synth_id"}, vals: []interface{}{"cat", float64(42.3), "0"}},
 		{table: "test", cols: []string{"a", "c", "synth_id"}, vals: []interface{}{"dog", int64(22), "-9223372036854775808"}}},
@@ -474,6 +475,199 @@ func TestConvertSqlRow_MultiCol(t *testing.T) {
 	assert.Equal(t, int64(0), conv.Unexpecteds())
 }
 
+func TestProcessSchema_UserTypes(t *testing.T) {
+	userType := "SELECT (.+) FROM pg_type (.+)"
+	userTypeCols := []string{"typtype", "typnotnull", "nspname", "typname", "enum_values", "fields", "checks"}
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "t"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"public", "t"},
+			cols:  []string{"column_name", "constraint_type"},
+			rows:  [][]driver.Value{{"id", "PRIMARY KEY"}},
+		}, {
+			query: "SELECT (.+) FROM PG_CLASS (.+) JOIN PG_NAMESPACE (.+) JOIN PG_CONSTRAINT (.+)",
+			args:  []driver.Value{"public", "t"},
+			cols:  []string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "REF_COLUMN_NAME", "CONSTRAINT_NAME"},
+		}, {
+			query: "SELECT (.+) FROM pg_index (.+)",
+			args:  []driver.Value{"public", "t"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"public", "t"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"},
+			rows: [][]driver.Value{
+				{"id", "integer", nil, "YES", nil, nil, 32, 0, "public", "posint"},
+				{"m", "USER-DEFINED", nil, "YES", nil, nil, nil, nil, "public", "mood"},
+				{"ms", "ARRAY", "USER-DEFINED", "YES", nil, nil, nil, nil, "public", "mood"},
+				{"a", "USER-DEFINED", nil, "YES", nil, nil, nil, nil, "public", "addr"},
+				{"r", "USER-DEFINED", nil, "YES", nil, nil, nil, nil, "public", "floatrange"},
+				{"i", "int4range", nil, "YES", nil, nil, nil, nil, "pg_catalog", "int4range"}},
+		}, {
+			query: userType,
+			args:  []driver.Value{"public", "posint"},
+			cols:  userTypeCols,
+			rows:  [][]driver.Value{{"d", true, "pg_catalog", "int4", "[]", "[]", `["CHECK ((VALUE > 0))"]`}},
+		}, {
+			query: userType,
+			args:  []driver.Value{"public", "mood"},
+			cols:  userTypeCols,
+			rows:  [][]driver.Value{{"e", false, nil, nil, `["sad","ok"]`, "[]", "[]"}},
+		}, {
+			query: userType,
+			args:  []driver.Value{"public", "mood"},
+			cols:  userTypeCols,
+			rows:  [][]driver.Value{{"e", false, nil, nil, `["sad","ok"]`, "[]", "[]"}},
+		}, {
+			query: userType,
+			args:  []driver.Value{"public", "addr"},
+			cols:  userTypeCols,
+			rows:  [][]driver.Value{{"c", false, nil, nil, "[]", `["street","zip"]`, "[]"}},
+		}, {
+			query: userType,
+			args:  []driver.Value{"public", "floatrange"},
+			cols:  userTypeCols,
+			rows:  [][]driver.Value{{"r", false, nil, nil, "[]", "[]", "[]"}},
+		}, noPartitions("public", "t"), {
+			query: `SELECT [*] FROM "public"."t"`,
+			cols:  []string{"id", "m", "ms", "a", "r", "i"},
+			rows: [][]driver.Value{
+				{int64(1), []byte("ok"), []byte("{sad,ok}"), []byte(`("1 Main St",)`), []byte("[1.5,2)"), []byte("[1,5)")}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	isi := InfoSchemaImpl{Db: db}
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+	sp := stripSchemaComments(conv.SpSchema)["t"]
+	assert.Equal(t, map[string]ddl.ColumnDef{
+		"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+		"m":  {Name: "m", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		"ms": {Name: "ms", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
+		"a":  {Name: "a", T: ddl.Type{Name: ddl.JSON}},
+		"r":  {Name: "r", T: ddl.Type{Name: ddl.JSON}},
+		"i":  {Name: "i", T: ddl.Type{Name: ddl.JSON}},
+	}, sp.ColDefs)
+	// The CHECK of the domain is a PostgreSQL expression, which is dropped
+	// for GoogleSQL.
+	assert.Equal(t, []ddl.CheckConstraint{
+		{Name: "CK_t_m", Expr: "`m` IN ('sad', 'ok')"},
+	}, sp.Checks)
+	assert.Equal(t, []internal.SchemaIssue{internal.Widened, internal.SourceCheckDropped}, conv.Issues["t"]["id"])
+
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	common.ProcessData(conv, isi)
+	assert.Equal(t, []spannerData{{
+		table: "t",
+		cols:  []string{"id", "m", "ms", "a", "r", "i"},
+		vals: []interface{}{int64(1), "ok", []spanner.NullString{{StringVal: "sad", Valid: true}, {StringVal: "ok", Valid: true}},
+			`{"street":"1 Main St","zip":null}`, `{"lower":"1.5","upper":"2","lower_inc":true,"upper_inc":false}`,
+			`{"lower":"1","upper":"5","lower_inc":true,"upper_inc":false}`},
+	}}, rows)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
+func TestProcessSchema_Partitions(t *testing.T) {
+	colsCols := []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "type_schema", "type_name"}
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE' AND NOT EXISTS (.+) pg_inherits (.+)",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "logs"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"public", "logs"},
+			cols:  []string{"column_name", "constraint_type"},
+			rows:  [][]driver.Value{{"id", "PRIMARY KEY"}, {"logdate", "PRIMARY KEY"}},
+		}, {
+			query: "SELECT (.+) FROM PG_CLASS (.+) JOIN PG_NAMESPACE (.+) JOIN PG_CONSTRAINT (.+)",
+			args:  []driver.Value{"public", "logs"},
+			cols:  []string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "REF_COLUMN_NAME", "CONSTRAINT_NAME"},
+		}, {
+			query: "SELECT (.+) FROM pg_index (.+)",
+			args:  []driver.Value{"public", "logs"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"public", "logs"},
+			cols:  colsCols,
+			rows: [][]driver.Value{
+				{"id", "bigint", nil, "NO", nil, nil, 64, 0, nil, nil},
+				{"logdate", "date", nil, "NO", nil, nil, nil, nil, nil, nil}},
+		}, {
+			query: partitionsQuery,
+			args:  []driver.Value{"public", "logs"},
+			cols:  []string{"nspname", "relname", "bound", "scheme"},
+			rows: [][]driver.Value{
+				{"archive", "logs_2020", "FOR VALUES FROM ('2020-01-01') TO ('2021-01-01')", "RANGE (logdate)"},
+				{"public", "logs_2021", "FOR VALUES FROM ('2021-01-01') TO ('2022-01-01')", "RANGE (logdate)"}},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"archive", "logs_2020"},
+			cols:  colsCols,
+			rows: [][]driver.Value{
+				{"logdate", "date", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"id", "bigint", nil, "NO", nil, nil, 64, 0, nil, nil}},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"public", "logs_2021"},
+			cols:  colsCols,
+			rows: [][]driver.Value{
+				{"id", "bigint", nil, "NO", nil, nil, 64, 0, nil, nil},
+				{"logdate", "date", nil, "NO", nil, nil, nil, nil, nil, nil},
+				{"msg", "text", nil, "NO", nil, nil, nil, nil, nil, nil}},
+		}, {
+			query: `SELECT [*] FROM ONLY "public"."logs"`,
+			cols:  []string{"id", "logdate"},
+		}, {
+			query: `SELECT [*] FROM ONLY "archive"."logs_2020"`,
+			cols:  []string{"logdate", "id"},
+			rows:  [][]driver.Value{{"2020-05-01", int64(1)}},
+		}, {
+			query: `SELECT [*] FROM ONLY "public"."logs_2021"`,
+			cols:  []string{"id", "logdate", "msg"},
+			rows:  [][]driver.Value{{int64(2), "2021-05-01", "hi"}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	isi := InfoSchemaImpl{Db: db}
+	assert.Nil(t, common.ProcessSchema(conv, isi, 1))
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+	src := conv.SrcSchema["logs"]
+	assert.Equal(t, "RANGE (logdate)", src.PartitionScheme)
+	assert.Equal(t, []schema.Partition{
+		{Name: "archive.logs_2020", Schema: "archive", ColNames: []string{"logdate", "id"}, Bound: "FOR VALUES FROM ('2020-01-01') TO ('2021-01-01')"},
+		{Name: "logs_2021", Schema: "public", ColNames: []string{"id", "logdate", "msg"}, Bound: "FOR VALUES FROM ('2021-01-01') TO ('2022-01-01')"},
+	}, src.Partitions)
+	sp := stripSchemaComments(conv.SpSchema)["logs"]
+	assert.Equal(t, []string{"id", "logdate", "msg"}, sp.ColNames)
+	assert.Equal(t, ddl.ColumnDef{Name: "msg", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}, sp.ColDefs["msg"])
+
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	common.ProcessData(conv, isi)
+	assert.Equal(t, []spannerData{
+		{table: "logs", cols: []string{"logdate", "id"}, vals: []interface{}{getDate("2020-05-01"), int64(1)}},
+		{table: "logs", cols: []string{"id", "logdate", "msg"}, vals: []interface{}{int64(2), getDate("2021-05-01"), "hi"}},
+	}, rows)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
 func TestSetRowStats(t *testing.T) {
 	ms := []mockSpec{
 		{
@@ -493,12 +687,24 @@ func TestSetRowStats(t *testing.T) {
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
 	conv.SetDataMode()
-	common.SetRowStats(conv, InfoSchemaImpl{db, profiles.SourceProfile{}, profiles.TargetProfile{}})
+	common.SetRowStats(conv, InfoSchemaImpl{Db: db})
 	assert.Equal(t, int64(5), conv.Stats.Rows["test1"])
 	assert.Equal(t, int64(142), conv.Stats.Rows["test2"])
 	assert.Equal(t, int64(0), conv.Unexpecteds())
 }
 
+const partitionsQuery = "WITH RECURSIVE parts(.+) pg_inherits (.+)"
+
+// noPartitions returns the mock of GetPartitions for a table without
+// partitions.
+func noPartitions(schema, table string) mockSpec {
+	return mockSpec{
+		query: partitionsQuery,
+		args:  []driver.Value{schema, table},
+		cols:  []string{"nspname", "relname", "bound", "scheme"},
+	}
+}
+
 func mkMockDB(t *testing.T, ms []mockSpec) *sql.DB {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -516,3 +722,40 @@ func mkMockDB(t *testing.T, ms []mockSpec) *sql.DB {
 	}
 	return db
 }
+
+func TestStreamedTables(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT COUNT[(][*][)] FROM pg_replication_slots WHERE slot_name = [$]1",
+			args:  []driver.Value{"hb_slot"},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{1}},
+		}, {
+			query: "SELECT COUNT[(][*][)] FROM pg_publication WHERE pubname = [$]1",
+			args:  []driver.Value{"hb_pub"},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{1}},
+		}, {
+			query: "SELECT schemaname, tablename FROM pg_publication_tables WHERE pubname = [$]1",
+			args:  []driver.Value{"hb_pub"},
+			cols:  []string{"schemaname", "tablename"},
+			rows:  [][]driver.Value{{"public", "orders"}, {"sales", "items"}},
+		},
+	}
+	isi := InfoSchemaImpl{Db: mkMockDB(t, ms)}
+	tables, err := isi.StreamedTables(context.Background(), streaming.DatastreamCfg{Properties: "replicationSlot=hb_slot,publication=hb_pub"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"orders", "sales.items"}, tables)
+
+	ms = []mockSpec{
+		{
+			query: "SELECT COUNT[(][*][)] FROM pg_replication_slots WHERE slot_name = [$]1",
+			args:  []driver.Value{"missing"},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{0}},
+		},
+	}
+	isi = InfoSchemaImpl{Db: mkMockDB(t, ms)}
+	_, err = isi.StreamedTables(context.Background(), streaming.DatastreamCfg{Properties: "replicationSlot=missing,publication=hb_pub"})
+	assert.EqualError(t, err, "replication slot missing doesn't exist")
+}
@@ -199,6 +199,22 @@ func processStatements(conv *internal.Conv, rawStmts []*pg_query.RawStmt) *copyO
 			if conv.SchemaMode() {
 				processIndexStmt(conv, n.IndexStmt)
 			}
+		case *pg_query.Node_CreateEnumStmt:
+			if conv.SchemaMode() {
+				processCreateEnumStmt(conv, n.CreateEnumStmt)
+			}
+		case *pg_query.Node_CreateDomainStmt:
+			if conv.SchemaMode() {
+				processCreateDomainStmt(conv, n.CreateDomainStmt)
+			}
+		case *pg_query.Node_CompositeTypeStmt:
+			if conv.SchemaMode() {
+				processCompositeTypeStmt(conv, n.CompositeTypeStmt)
+			}
+		case *pg_query.Node_CreateRangeStmt:
+			if conv.SchemaMode() {
+				processCreateRangeStmt(conv, n.CreateRangeStmt)
+			}
 		default:
 			conv.SkipStatement(printNodeType(n))
 		}
@@ -223,12 +239,120 @@ func processIndexStmt(conv *internal.Conv, n *pg_query.IndexStmt) {
 			Keys:   toIndexKeys(conv, n.Idxname, n.IndexParams),
 		})
 		conv.SrcSchema[tableName] = ctable
+	} else if _, _, ok := findPartition(conv, tableName); ok {
+		// Indexes of partitions are covered by the indexes of their root
+		// table.
+		conv.SkipStatement(printNodeType(n))
 	} else {
 		conv.Unexpected(fmt.Sprintf("Table %s not found while processing index statement", tableName))
 		conv.SkipStatement(printNodeType(n))
 	}
 }
 
+func processCreateEnumStmt(conv *internal.Conv, n *pg_query.CreateEnumStmt) {
+	name, err := getTypeID(n.TypeName)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get type name: %w", err))
+		return
+	}
+	var vals []string
+	for _, v := range n.Vals {
+		s, err := getString(v)
+		if err != nil {
+			logStmtError(conv, n, fmt.Errorf("can't get enum value: %w", err))
+			return
+		}
+		vals = append(vals, s)
+	}
+	addUserType(conv, n, schema.UserType{Name: userTypeName(name), Kind: enumType, EnumValues: vals})
+}
+
+func processCreateDomainStmt(conv *internal.Conv, n *pg_query.CreateDomainStmt) {
+	name, err := getTypeID(n.Domainname)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get domain name: %w", err))
+		return
+	}
+	if n.TypeName == nil {
+		logStmtError(conv, n, fmt.Errorf("domain %s has no base type", name))
+		return
+	}
+	base, err := getTypeID(n.TypeName.Names)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get base type of domain %s: %w", name, err))
+		return
+	}
+	ut := schema.UserType{
+		Name: userTypeName(name),
+		Kind: domainType,
+		Base: schema.Type{
+			Name:        base,
+			Mods:        getTypeMods(conv, n.TypeName.Typmods),
+			ArrayBounds: getArrayBounds(conv, n.TypeName.ArrayBounds)},
+	}
+	for _, c := range n.Constraints {
+		switch c.GetConstraint().GetContype() {
+		case pg_query.ConstrType_CONSTR_NOTNULL:
+			ut.NotNull = true
+		case pg_query.ConstrType_CONSTR_CHECK:
+			expr, err := deparseExpr(c.GetConstraint().RawExpr)
+			if err != nil {
+				logStmtError(conv, n, fmt.Errorf("can't get check of domain %s: %w", name, err))
+				return
+			}
+			ut.Checks = append(ut.Checks, expr)
+		}
+	}
+	addUserType(conv, n, ut)
+}
+
+func processCompositeTypeStmt(conv *internal.Conv, n *pg_query.CompositeTypeStmt) {
+	if n.Typevar == nil {
+		logStmtError(conv, n, fmt.Errorf("typevar is nil"))
+		return
+	}
+	name, err := getTableName(conv, n.Typevar)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get type name: %w", err))
+		return
+	}
+	var fields []string
+	for _, c := range n.Coldeflist {
+		if cd := c.GetColumnDef(); cd != nil {
+			fields = append(fields, cd.Colname)
+		}
+	}
+	addUserType(conv, n, schema.UserType{Name: name, Kind: compositeType, Fields: fields})
+}
+
+func processCreateRangeStmt(conv *internal.Conv, n *pg_query.CreateRangeStmt) {
+	name, err := getTypeID(n.TypeName)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get type name: %w", err))
+		return
+	}
+	addUserType(conv, n, schema.UserType{Name: userTypeName(name), Kind: rangeType})
+}
+
+func addUserType(conv *internal.Conv, n interface{}, ut schema.UserType) {
+	if conv.UserTypes == nil {
+		conv.UserTypes = make(map[string]schema.UserType)
+	}
+	conv.UserTypes[ut.Name] = ut
+	conv.SchemaStatement(printNodeType(n))
+}
+
+// deparseExpr returns the SQL text of expression node expr.
+func deparseExpr(expr *pg_query.Node) (string, error) {
+	s, err := pg_query.Deparse(&pg_query.ParseResult{Stmts: []*pg_query.RawStmt{{
+		Stmt: &pg_query.Node{Node: &pg_query.Node_SelectStmt{SelectStmt: &pg_query.SelectStmt{
+			TargetList: []*pg_query.Node{pg_query.MakeResTargetNodeWithVal(expr, 0)}}}}}}})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(s, "SELECT "), nil
+}
+
 func processAlterTableStmt(conv *internal.Conv, n *pg_query.AlterTableStmt) {
 	if n.Relation == nil {
 		logStmtError(conv, n, fmt.Errorf("relation is nil"))
@@ -239,13 +363,22 @@ func processAlterTableStmt(conv *internal.Conv, n *pg_query.AlterTableStmt) {
 		logStmtError(conv, n, fmt.Errorf("can't get table name: %w", err))
 		return
 	}
-	if _, ok := conv.SrcSchema[table]; ok {
+	_, ok := conv.SrcSchema[table]
+	if !ok && isAttachPartition(n) {
+		// Partitions can be attached to partitions that are already
+		// merged into their root table.
+		_, _, ok = findPartition(conv, table)
+	}
+	if ok {
 		for _, i := range n.Cmds {
 			cmd := i.GetNode()
 			switch t := cmd.(type) {
 			case *pg_query.Node_AlterTableCmd:
 				a := t.AlterTableCmd
 				switch {
+				case a.Subtype == pg_query.AlterTableType_AT_AttachPartition && a.Def != nil:
+					processAttachPartition(conv, n, a.Def.GetPartitionCmd(), table)
+					conv.SchemaStatement(strings.Join([]string{printNodeType(n), printNodeType(t)}, "."))
 				case a.Subtype == pg_query.AlterTableType_AT_SetNotNull && a.Name != "":
 					c := constraint{ct: pg_query.ConstrType_CONSTR_NOTNULL, cols: []string{a.Name}}
 					updateSchema(conv, table, []constraint{c}, "ALTER TABLE")
@@ -289,15 +422,21 @@ func processCreateStmt(conv *internal.Conv, n *pg_query.CreateStmt) {
 		logStmtError(conv, n, fmt.Errorf("can't get table name: %w", err))
 		return
 	}
-	if len(n.InhRelations) > 0 {
-		// Skip inherited tables.
+	if len(n.InhRelations) > 1 {
+		// Skip tables with multiple inheritance: we can only merge a
+		// table into one parent.
 		conv.SkipStatement(printNodeType(n))
-		conv.Unexpected(fmt.Sprintf("Found inherited table %s -- we do not currently handle inherited tables", table))
+		conv.Unexpected(fmt.Sprintf("Found inherited table %s with multiple parents -- we do not currently handle multiple inheritance", table))
 		internal.VerbosePrintf("Processing %v statement: table %s is inherited table", printNodeType(n), table)
 		logger.Log.Debug(fmt.Sprintf("Processing %v statement: table %s is inherited table", printNodeType(n), table))
 
 		return
 	}
+	if len(n.InhRelations) == 1 && n.Partbound != nil {
+		// Partitions have the columns of their parent.
+		processChildTable(conv, n, table, nil, nil)
+		return
+	}
 	var constraints []constraint
 	for _, te := range n.TableElts {
 		switch te.GetNode().(type) {
@@ -319,16 +458,185 @@ func processCreateStmt(conv *internal.Conv, n *pg_query.CreateStmt) {
 			conv.Unexpected(fmt.Sprintf("Found %s node while processing CreateStmt TableElts", printNodeType(te)))
 		}
 	}
+	if len(n.InhRelations) == 1 {
+		processChildTable(conv, n, table, colNames, colDef)
+		return
+	}
+	var scheme string
+	if n.Partspec != nil {
+		scheme, err = deparsePartitionSpec(n.Partspec)
+		if err != nil {
+			logStmtError(conv, n, fmt.Errorf("can't get partition scheme of table %s: %w", table, err))
+			return
+		}
+	}
 	conv.SchemaStatement(printNodeType(n))
 	conv.SrcSchema[table] = schema.Table{
-		Name:     table,
-		ColNames: colNames,
-		ColDefs:  colDef}
+		Name:            table,
+		ColNames:        colNames,
+		ColDefs:         colDef,
+		PartitionScheme: scheme}
 	// Note: constraints contains all info about primary keys, not-null keys
 	// and foreign keys.
 	updateSchema(conv, table, constraints, "CREATE TABLE")
 }
 
+// processChildTable merges table, created with PARTITION OF or INHERITS,
+// into the root table of its parent. Partitions have the columns of their
+// parent, while child tables add their own columns (colNames) to them.
+func processChildTable(conv *internal.Conv, n *pg_query.CreateStmt, table string, colNames []string, colDef map[string]schema.Column) {
+	parent, err := getTableName(conv, n.InhRelations[0].GetRangeVar())
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get parent of table %s: %w", table, err))
+		return
+	}
+	root, parentCols, ok := findParent(conv, parent)
+	if !ok {
+		conv.SkipStatement(printNodeType(n))
+		conv.Unexpected(fmt.Sprintf("Parent %s of table %s not found", parent, table))
+		return
+	}
+	t := conv.SrcSchema[root]
+	cols := parentCols
+	colDefs := t.ColDefs
+	var bound string
+	if n.Partbound != nil {
+		if bound, err = deparsePartitionBound(n.Partbound); err != nil {
+			logStmtError(conv, n, fmt.Errorf("can't get bound of partition %s: %w", table, err))
+			return
+		}
+	} else {
+		if t.PartitionScheme == "" {
+			t.PartitionScheme = "INHERITS"
+		}
+		cols = append([]string{}, parentCols...)
+		colDefs = colDef
+		for _, c := range colNames {
+			if _, ok := t.ColDefs[c]; !ok {
+				cols = append(cols, c)
+			}
+		}
+	}
+	common.MergePartition(&t, schema.Partition{Name: table, Schema: relSchema(n.Relation), ColNames: cols, Bound: bound}, colDefs)
+	conv.SrcSchema[root] = t
+	conv.SchemaStatement(printNodeType(n))
+}
+
+// processAttachPartition merges the partition attached by cmd to table
+// into the root table of table. Partitions already attached to the
+// partition are moved to the root table too.
+func processAttachPartition(conv *internal.Conv, n *pg_query.AlterTableStmt, cmd *pg_query.PartitionCmd, table string) {
+	if cmd == nil || cmd.Name == nil {
+		logStmtError(conv, n, fmt.Errorf("can't get partition attached to table %s", table))
+		return
+	}
+	partition, err := getTableName(conv, cmd.Name)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get partition name: %w", err))
+		return
+	}
+	pt, ok := conv.SrcSchema[partition]
+	if !ok {
+		conv.Unexpected(fmt.Sprintf("Partition %s of table %s not found", partition, table))
+		return
+	}
+	bound, err := deparsePartitionBound(cmd.Bound)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get bound of partition %s: %w", partition, err))
+		return
+	}
+	root, _, _ := findParent(conv, table)
+	t := conv.SrcSchema[root]
+	common.MergePartition(&t, schema.Partition{Name: partition, Schema: relSchema(cmd.Name), ColNames: pt.ColNames, Bound: bound}, pt.ColDefs)
+	for _, p := range pt.Partitions {
+		common.MergePartition(&t, p, pt.ColDefs)
+	}
+	conv.SrcSchema[root] = t
+	delete(conv.SrcSchema, partition)
+}
+
+// findPartition returns the root table that partition (or child table)
+// table was merged into, and its partition.
+func findPartition(conv *internal.Conv, table string) (string, schema.Partition, bool) {
+	for root, t := range conv.SrcSchema {
+		for _, p := range t.Partitions {
+			if p.Name == table {
+				return root, p, true
+			}
+		}
+	}
+	return "", schema.Partition{}, false
+}
+
+// findParent returns the root table of table and the columns of table,
+// where table is either a table of conv.SrcSchema or a partition merged
+// into one.
+func findParent(conv *internal.Conv, table string) (string, []string, bool) {
+	if t, ok := conv.SrcSchema[table]; ok {
+		return table, t.ColNames, true
+	}
+	root, p, ok := findPartition(conv, table)
+	return root, p.ColNames, ok
+}
+
+// isAttachPartition returns true if n only attaches partitions.
+func isAttachPartition(n *pg_query.AlterTableStmt) bool {
+	for _, i := range n.Cmds {
+		if i.GetAlterTableCmd().GetSubtype() != pg_query.AlterTableType_AT_AttachPartition {
+			return false
+		}
+	}
+	return len(n.Cmds) > 0
+}
+
+// relSchema returns the schema of relation n.
+func relSchema(n *pg_query.RangeVar) string {
+	if n.Schemaname == "" {
+		return "public"
+	}
+	return n.Schemaname
+}
+
+// deparsePartitionSpec returns the SQL text of a PARTITION BY clause
+// without its keywords e.g. "RANGE (logdate)".
+func deparsePartitionSpec(spec *pg_query.PartitionSpec) (string, error) {
+	s, err := pg_query.Deparse(&pg_query.ParseResult{Stmts: []*pg_query.RawStmt{{
+		Stmt: &pg_query.Node{Node: &pg_query.Node_CreateStmt{CreateStmt: &pg_query.CreateStmt{
+			Relation: &pg_query.RangeVar{Relname: "t", Inh: true, Relpersistence: "p"},
+			Partspec: spec}}}}}})
+	if err != nil {
+		return "", err
+	}
+	prefix := "PARTITION BY " + spec.Strategy
+	i := strings.Index(s, prefix)
+	if i < 0 {
+		return "", fmt.Errorf("unexpected partition spec %q", s)
+	}
+	return strings.ToUpper(spec.Strategy) + " " + strings.TrimSpace(s[i+len(prefix):]), nil
+}
+
+// deparsePartitionBound returns the SQL text of a partition bound e.g.
+// "FOR VALUES IN (1, 2)" or "DEFAULT".
+func deparsePartitionBound(bound *pg_query.PartitionBoundSpec) (string, error) {
+	if bound == nil {
+		return "", fmt.Errorf("partition bound is nil")
+	}
+	s, err := pg_query.Deparse(&pg_query.ParseResult{Stmts: []*pg_query.RawStmt{{
+		Stmt: &pg_query.Node{Node: &pg_query.Node_CreateStmt{CreateStmt: &pg_query.CreateStmt{
+			Relation:     &pg_query.RangeVar{Relname: "c", Inh: true, Relpersistence: "p"},
+			InhRelations: []*pg_query.Node{pg_query.MakeSimpleRangeVarNode("p", 0)},
+			Partbound:    bound}}}}}})
+	if err != nil {
+		return "", err
+	}
+	prefix := "PARTITION OF p "
+	i := strings.Index(s, prefix)
+	if i < 0 {
+		return "", fmt.Errorf("unexpected partition bound %q", s)
+	}
+	return s[i+len(prefix):], nil
+}
+
 func processColumn(conv *internal.Conv, n *pg_query.ColumnDef, table string) (string, schema.Column, []constraint, error) {
 	mods := getTypeMods(conv, n.TypeName.Typmods)
 	if n.Colname == "" {
@@ -343,7 +651,17 @@ func processColumn(conv *internal.Conv, n *pg_query.ColumnDef, table string) (st
 		Name:        tid,
 		Mods:        mods,
 		ArrayBounds: getArrayBounds(conv, n.TypeName.ArrayBounds)}
-	return name, schema.Column{Name: name, Type: ty}, analyzeColDefConstraints(conv, printNodeType(n), table, n.Constraints, name), nil
+	col := schema.Column{Name: name, Type: ty}
+	if ut, ok := conv.UserTypes[userTypeName(tid)]; ok {
+		col, err = applyUserType(col, ut, func(name string) (schema.UserType, bool) {
+			ut, ok := conv.UserTypes[userTypeName(name)]
+			return ut, ok
+		})
+		if err != nil {
+			return "", schema.Column{}, nil, fmt.Errorf("can't resolve type of %s: %w", name, err)
+		}
+	}
+	return name, col, analyzeColDefConstraints(conv, printNodeType(n), table, n.Constraints, name), nil
 }
 
 func processInsertStmt(conv *internal.Conv, n *pg_query.InsertStmt) *copyOrInsert {
@@ -356,13 +674,18 @@ func processInsertStmt(conv *internal.Conv, n *pg_query.InsertStmt) *copyOrInser
 		logStmtError(conv, n, fmt.Errorf("can't get table name: %w", err))
 		return nil
 	}
+	var partitionCols []string
+	if root, p, ok := findPartition(conv, table); ok {
+		// Rows of partitions and child tables go to their root table.
+		table, partitionCols = root, p.ColNames
+	}
 	if _, ok := conv.SrcSchema[table]; !ok {
 		// If we don't have schema information for a table, we drop all insert
 		// statements for it. The most likely reason we don't have schema information
-		// for a table is that it is an inherited table - we skip all inherited tables.
+		// for a table is that it has multiple parents - we skip such tables.
 		conv.SkipStatement(printNodeType(n))
 		internal.VerbosePrintf("Processing %v statement: table %s not found", printNodeType(n), table)
-		logger.Log.Debug(fmt.Sprintf("Processing %v statement: table %s is inherited table", printNodeType(n), table))
+		logger.Log.Debug(fmt.Sprintf("Processing %v statement: table %s not found", printNodeType(n), table))
 
 		return nil
 	}
@@ -373,6 +696,9 @@ func processInsertStmt(conv *internal.Conv, n *pg_query.InsertStmt) *copyOrInser
 		conv.StatsAddBadRow(table, conv.SchemaMode())
 		return nil
 	}
+	if len(colNames) == 0 {
+		colNames = partitionCols
+	}
 
 	switch sel := n.SelectStmt.GetNode().(type) {
 	case *pg_query.Node_SelectStmt:
@@ -402,13 +728,18 @@ func processCopyStmt(conv *internal.Conv, n *pg_query.CopyStmt) *copyOrInsert {
 	} else {
 		logStmtError(conv, n, fmt.Errorf("relation is nil"))
 	}
+	var partitionCols []string
+	if root, p, ok := findPartition(conv, table); ok {
+		// Rows of partitions and child tables go to their root table.
+		table, partitionCols = root, p.ColNames
+	}
 	if _, ok := conv.SrcSchema[table]; !ok {
 		// If we don't have schema information for a table, we drop all copy
 		// statements for it. The most likely reason we don't have schema information
-		// for a table is that it is an inherited table - we skip all inherited tables.
+		// for a table is that it has multiple parents - we skip such tables.
 		conv.SkipStatement(printNodeType(n))
 		internal.VerbosePrintf("Processing %v statement: table %s not found", printNodeType(n), table)
-		logger.Log.Debug(fmt.Sprintf("Processing %v statement: table %s is inherited table", printNodeType(n), table))
+		logger.Log.Debug(fmt.Sprintf("Processing %v statement: table %s not found", printNodeType(n), table))
 		return &copyOrInsert{stmt: copyFrom, table: table, cols: []string{}}
 	}
 	var cols []string
@@ -420,6 +751,9 @@ func processCopyStmt(conv *internal.Conv, n *pg_query.CopyStmt) *copyOrInsert {
 		}
 		cols = append(cols, s)
 	}
+	if len(cols) == 0 {
+		cols = partitionCols
+	}
 	conv.DataStatement(printNodeType(n))
 	return &copyOrInsert{stmt: copyFrom, table: table, cols: cols}
 }
@@ -27,6 +27,7 @@ import (
 
 	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 	pg_query "github.com/pganalyze/pg_query_go/v2"
@@ -1377,6 +1378,95 @@ COPY test (id, a, b, c, d, e) FROM stdin;
 	}
 }
 
+func TestProcessPgDump_UserTypes(t *testing.T) {
+	s := "CREATE TYPE public.mood AS ENUM ('sad', 'ok', 'it''s');\n" +
+		"ALTER TYPE public.mood OWNER TO postgres;\n" +
+		"CREATE DOMAIN public.posint AS integer CONSTRAINT posint_check CHECK ((VALUE > 0)) NOT NULL;\n" +
+		"CREATE DOMAIN public.short_mood AS public.mood CHECK (VALUE <> 'it''s');\n" +
+		"CREATE TYPE public.addr AS (street text, zip integer);\n" +
+		"CREATE TYPE public.floatrange AS RANGE (subtype = float8);\n" +
+		"CREATE TABLE t (id public.posint PRIMARY KEY, m public.mood, ms public.mood[], sm public.short_mood, a public.addr, r public.floatrange, i int4range);\n" +
+		"COPY public.t (id, m, ms, sm, a, r, i) FROM stdin;\n" +
+		"1\tok\t{sad,ok}\tsad\t(\"1 Main St\",)\t[1.5,2)\tempty\n" +
+		"\\.\n"
+	conv, rows := runProcessPgDump(s)
+	noIssues(conv, t, "User types")
+	sp := stripSchemaComments(conv.SpSchema)["t"]
+	assert.Equal(t, map[string]ddl.ColumnDef{
+		"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+		"m":  {Name: "m", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		"ms": {Name: "ms", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
+		"sm": {Name: "sm", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		"a":  {Name: "a", T: ddl.Type{Name: ddl.JSON}},
+		"r":  {Name: "r", T: ddl.Type{Name: ddl.JSON}},
+		"i":  {Name: "i", T: ddl.Type{Name: ddl.JSON}},
+	}, sp.ColDefs)
+	// The CHECKs of the domains are PostgreSQL expressions, which are
+	// dropped for GoogleSQL.
+	assert.Equal(t, []ddl.CheckConstraint{
+		{Name: "CK_t_m", Expr: "`m` IN ('sad', 'ok', 'it\\'s')"},
+		{Name: "CK_t_sm", Expr: "`sm` IN ('sad', 'ok', 'it\\'s')"},
+	}, sp.Checks)
+	assert.Equal(t, []internal.SchemaIssue{internal.Widened, internal.SourceCheckDropped}, conv.Issues["t"]["id"])
+	assert.Equal(t, []internal.SchemaIssue{internal.SourceCheckDropped}, conv.Issues["t"]["sm"])
+	// They are kept for the PostgreSQL dialect.
+	pgConv, _ := runProcessPgDumpPGTarget(s)
+	assert.Equal(t, []ddl.CheckConstraint{
+		{Name: "CK_t_id", Expr: "id > 0"},
+		{Name: "CK_t_m", Expr: "\"m\" IN ('sad', 'ok', 'it''s')"},
+		{Name: "CK_t_sm", Expr: "\"sm\" IN ('sad', 'ok', 'it''s')"},
+		{Name: "CK_t_sm_4", Expr: "sm <> 'it''s'"},
+	}, stripSchemaComments(pgConv.SpSchema)["t"].Checks)
+	assert.Equal(t, []internal.SchemaIssue{internal.Widened, internal.SourceCheck}, pgConv.Issues["t"]["id"])
+	assert.Equal(t, []spannerData{{
+		table: "t",
+		cols:  []string{"id", "m", "ms", "sm", "a", "r", "i"},
+		vals: []interface{}{int64(1), "ok", []spanner.NullString{{StringVal: "sad", Valid: true}, {StringVal: "ok", Valid: true}}, "sad",
+			`{"street":"1 Main St","zip":null}`, `{"lower":"1.5","upper":"2","lower_inc":true,"upper_inc":false}`, `{"empty":true}`},
+	}}, rows)
+}
+
+func TestProcessPgDump_Partitions(t *testing.T) {
+	s := "CREATE TABLE public.logs (id bigint NOT NULL, logdate date NOT NULL, msg text) PARTITION BY RANGE (logdate);\n" +
+		"CREATE TABLE public.logs_2021 (msg text, logdate date NOT NULL, id bigint NOT NULL);\n" +
+		"CREATE TABLE public.logs_old (id bigint NOT NULL, logdate date NOT NULL, msg text) PARTITION BY LIST (id);\n" +
+		"CREATE TABLE public.logs_old_1 (id bigint NOT NULL, logdate date NOT NULL, msg text);\n" +
+		"ALTER TABLE ONLY public.logs_old ATTACH PARTITION public.logs_old_1 FOR VALUES IN (1, 2);\n" +
+		"ALTER TABLE ONLY public.logs ATTACH PARTITION public.logs_2021 FOR VALUES FROM ('2021-01-01') TO ('2022-01-01');\n" +
+		"ALTER TABLE ONLY public.logs ATTACH PARTITION public.logs_old DEFAULT;\n" +
+		"ALTER TABLE ONLY public.logs ADD CONSTRAINT logs_pkey PRIMARY KEY (id, logdate);\n" +
+		"ALTER TABLE ONLY public.logs_2021 ADD CONSTRAINT logs_2021_pkey PRIMARY KEY (id, logdate);\n" +
+		"CREATE INDEX logs_2021_msg_idx ON public.logs_2021 USING btree (msg);\n" +
+		"CREATE TABLE public.cities (name text NOT NULL, population integer);\n" +
+		"CREATE TABLE public.capitals (state character(2) NOT NULL) INHERITS (public.cities);\n" +
+		"COPY public.logs_2021 (msg, logdate, id) FROM stdin;\n" +
+		"hi\t2021-03-01\t3\n" +
+		"\\.\n" +
+		"COPY public.logs_old_1 (id, logdate, msg) FROM stdin;\n" +
+		"1\t2019-01-01\tbye\n" +
+		"\\.\n" +
+		"INSERT INTO public.capitals VALUES ('Paris', 2100000, 'FR');\n"
+	conv, rows := runProcessPgDump(s)
+	noIssues(conv, t, "Partitions")
+	assert.Equal(t, 2, len(conv.SpSchema))
+	logs := conv.SrcSchema["logs"]
+	assert.Equal(t, "RANGE (logdate)", logs.PartitionScheme)
+	assert.Equal(t, []schema.Partition{
+		{Name: "logs_2021", Schema: "public", ColNames: []string{"msg", "logdate", "id"}, Bound: "FOR VALUES FROM ('2021-01-01') TO ('2022-01-01')"},
+		{Name: "logs_old", Schema: "public", ColNames: []string{"id", "logdate", "msg"}, Bound: "DEFAULT"},
+		{Name: "logs_old_1", Schema: "public", ColNames: []string{"id", "logdate", "msg"}, Bound: "FOR VALUES IN (1, 2)"},
+	}, logs.Partitions)
+	cities := conv.SrcSchema["cities"]
+	assert.Equal(t, "INHERITS", cities.PartitionScheme)
+	assert.Equal(t, []string{"name", "population", "state"}, cities.ColNames)
+	assert.False(t, cities.ColDefs["state"].NotNull)
+	assert.Equal(t, []spannerData{
+		{table: "logs", cols: []string{"msg", "logdate", "id"}, vals: []interface{}{"hi", getDate("2021-03-01"), int64(3)}},
+		{table: "logs", cols: []string{"id", "logdate", "msg"}, vals: []interface{}{int64(1), getDate("2019-01-01"), "bye"}},
+		{table: "cities", cols: []string{"name", "population", "state", "synth_id"}, vals: []interface{}{"Paris", int64(2100000), "FR", fmt.Sprintf("%d", bitReverse(0))}},
+	}, rows)
+}
+
 // The following test Conv API calls based on data generated by ProcessPgDump.
 
 func TestProcessPgDump_GetDDL(t *testing.T) {
@@ -172,3 +172,27 @@ conditions.
 `
 	assert.Equal(t, expected, buf.String())
 }
+
+func TestReport_Partitions(t *testing.T) {
+	s := `
+        CREATE TABLE logs (id bigint PRIMARY KEY, logdate date) PARTITION BY RANGE (logdate);
+        CREATE TABLE logs_2021 (id bigint NOT NULL, logdate date);
+        ALTER TABLE ONLY logs ATTACH PARTITION logs_2021 FOR VALUES FROM ('2021-01-01') TO ('2022-01-01');
+        CREATE TABLE cities (name text PRIMARY KEY);
+        CREATE TABLE capitals (state text) INHERITS (cities);`
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	common.ProcessDbDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil), DbDumpImpl{})
+	conv.Audit = internal.Audit{
+		MigrationType: migration.MigrationData_SCHEMA_ONLY.Enum(),
+	}
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.PGDUMP, conv, w, nil, true, true)
+	w.Flush()
+	report := strings.Join(strings.Fields(buf.String()), " ")
+	assert.Contains(t, report, "Partitioning 1) Table is partitioned by RANGE (logdate). Its 1 partitions were merged into a single Spanner table. "+
+		"2) Partition 'logs_2021': FOR VALUES FROM ('2021-01-01') TO ('2022-01-01').")
+	assert.Contains(t, report, "Partitioning 1) Table has 1 child tables, which were merged into a single Spanner table.")
+	assert.Contains(t, report, "2) Child table 'capitals'.")
+}
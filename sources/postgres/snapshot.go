@@ -0,0 +1,103 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+// BeginSnapshot implements the common.SnapshotInfoSchema interface. The
+// first reader exports its snapshot with pg_export_snapshot, and the others
+// import it with SET TRANSACTION SNAPSHOT. The position records the
+// transactions visible in the snapshot (txid_current_snapshot), which tells
+// exactly which change events it includes.
+func (isi InfoSchemaImpl) BeginSnapshot(ctx context.Context, n int) (*common.Snapshot, error) {
+	var txs []*sql.Tx
+	closeAll := func() error {
+		var firstErr error
+		for _, tx := range txs {
+			if err := tx.Commit(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+	opts := &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	lock := &sync.Mutex{}
+	var readers []common.InfoSchema
+	var pos common.SnapshotPosition
+	var snapshotId string
+	for i := 0; i < n; i++ {
+		tx, err := isi.Db.BeginTx(ctx, opts)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("can't start snapshot transaction: %v", err)
+		}
+		txs = append(txs, tx)
+		if i == 0 {
+			var lsn, txidSnapshot string
+			err = tx.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text, pg_export_snapshot(), txid_current_snapshot()::text").Scan(&lsn, &snapshotId, &txidSnapshot)
+			if err == nil {
+				pos, err = parseTxidSnapshot(txidSnapshot)
+				pos.Lsn = lsn
+			}
+		} else {
+			// The snapshot id is quoted: it can't be a query parameter.
+			_, err = tx.ExecContext(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", snapshotId))
+		}
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("can't share snapshot: %v", err)
+		}
+		reader := isi
+		reader.Snapshot = &common.SnapshotReader{Tx: tx, Lock: lock}
+		readers = append(readers, reader)
+	}
+	return common.NewSnapshot(pos, readers, lock, closeAll), nil
+}
+
+// parseTxidSnapshot parses the text of a txid_snapshot, xmin:xmax:xip,...
+func parseTxidSnapshot(s string) (common.SnapshotPosition, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return common.SnapshotPosition{}, fmt.Errorf("can't parse txid snapshot %s", s)
+	}
+	var ids []uint64
+	for i, p := range parts {
+		if i < 2 && strings.Contains(p, ",") {
+			return common.SnapshotPosition{}, fmt.Errorf("can't parse txid snapshot %s", s)
+		}
+		for _, x := range strings.Split(p, ",") {
+			if x == "" && i == 2 {
+				continue
+			}
+			id, err := strconv.ParseUint(x, 10, 64)
+			if err != nil {
+				return common.SnapshotPosition{}, fmt.Errorf("can't parse txid snapshot %s", s)
+			}
+			ids = append(ids, id)
+		}
+	}
+	return common.SnapshotPosition{Driver: constants.POSTGRES, Xmin: ids[0], Xmax: ids[1], Xip: ids[2:], TakenAt: time.Now().UTC()}, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeginSnapshot(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT pg_current_wal_lsn\(\)::text, pg_export_snapshot\(\), txid_current_snapshot\(\)::text`).
+		WillReturnRows(sqlmock.NewRows([]string{"lsn", "snapshot", "txids"}).AddRow("0/16B3748", "00000003-0000001B-1", "740:746:740,743"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET TRANSACTION SNAPSHOT '00000003-0000001B-1'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectCommit()
+
+	isi := InfoSchemaImpl{Db: db}
+	snap, err := isi.BeginSnapshot(context.Background(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, "0/16B3748", snap.Position.Lsn)
+	assert.Equal(t, uint64(740), snap.Position.Xmin)
+	assert.Equal(t, uint64(746), snap.Position.Xmax)
+	assert.Equal(t, []uint64{740, 743}, snap.Position.Xip)
+	assert.Equal(t, 2, len(snap.Readers))
+	assert.NotNil(t, snap.Readers[1].(InfoSchemaImpl).Snapshot)
+	assert.Nil(t, snap.Close())
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestParseTxidSnapshot(t *testing.T) {
+	pos, err := parseTxidSnapshot("10:20:")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(10), pos.Xmin)
+	assert.Equal(t, uint64(20), pos.Xmax)
+	assert.Empty(t, pos.Xip)
+
+	for _, s := range []string{"", "10:20", "a:20:", "10,11:20:", "10:20:x"} {
+		_, err := parseTxidSnapshot(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -38,6 +38,10 @@ func (tdi ToDdlImpl) ToSpannerType(conv *internal.Conv, spType string, srcType s
 		if len(srcType.ArrayBounds) > 1 {
 			ty = ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
 			issues = append(issues, internal.MultiDimensionalArray)
+		} else if len(srcType.ArrayBounds) == 1 && ty.Name == ddl.JSON && (srcType.Name == compositeType || isRangeType(srcType.Name)) {
+			// We don't convert arrays of composites or ranges, so they
+			// are kept in their text representation.
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
 		}
 		ty.IsArray = len(srcType.ArrayBounds) == 1
 	}
@@ -178,6 +182,21 @@ func toSpannerTypeInternal(srcType schema.Type, spType string) (ddl.Type, []inte
 		default:
 			return ddl.Type{Name: ddl.JSON}, nil
 		}
+	case enumType:
+		switch spType {
+		case ddl.Bytes:
+			return ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, nil
+		default:
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+		}
+	case compositeType, rangeType, "int4range", "int8range", "numrange", "tsrange", "tstzrange", "daterange":
+		// Composites and ranges are converted to JSON objects.
+		switch spType {
+		case ddl.String:
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+		default:
+			return ddl.Type{Name: ddl.JSON}, nil
+		}
 	case "varchar", "character varying":
 		switch spType {
 		case ddl.Bytes:
@@ -0,0 +1,240 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	pg_query "github.com/pganalyze/pg_query_go/v2"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// Kinds of user-defined types. Columns of enum, composite and range
+// types get the kind as their source type name, since the name of the
+// user-defined type doesn't tell how to convert them.
+const (
+	enumType      = "enum"
+	domainType    = "domain"
+	compositeType = "composite"
+	rangeType     = "range"
+)
+
+// builtinRangeTypes lists the range types predefined by PostgreSQL.
+var builtinRangeTypes = map[string]bool{
+	"int4range": true,
+	"int8range": true,
+	"numrange":  true,
+	"tsrange":   true,
+	"tstzrange": true,
+	"daterange": true,
+}
+
+// isRangeType returns true if values of srcTypeName are ranges.
+func isRangeType(srcTypeName string) bool {
+	return srcTypeName == rangeType || builtinRangeTypes[srcTypeName]
+}
+
+// userTypeName returns the name we use for a user-defined type. As for
+// table names, the "public" schema is dropped.
+func userTypeName(name string) string {
+	return strings.TrimPrefix(name, "public.")
+}
+
+// maxDomainDepth bounds the resolution of domains defined over other
+// domains.
+const maxDomainDepth = 16
+
+// applyUserType resolves the type of column col, which is of user-defined
+// type ut. Domains are replaced by their base type (if set), and their NOT
+// NULL and CHECK constraints are carried over to the column. If the base
+// type of a domain is itself user-defined, lookup returns its definition.
+func applyUserType(col schema.Column, ut schema.UserType, lookup func(name string) (schema.UserType, bool)) (schema.Column, error) {
+	for depth := 0; ; depth++ {
+		switch ut.Kind {
+		case enumType:
+			col.Type = schema.Type{Name: enumType, ArrayBounds: col.Type.ArrayBounds}
+			col.EnumValues = ut.EnumValues
+			return col, nil
+		case compositeType:
+			col.Type = schema.Type{Name: compositeType, ArrayBounds: col.Type.ArrayBounds}
+			col.Fields = ut.Fields
+			return col, nil
+		case rangeType:
+			col.Type = schema.Type{Name: rangeType, ArrayBounds: col.Type.ArrayBounds}
+			return col, nil
+		case domainType:
+			if ut.Base.Name != "" {
+				col.Type = schema.Type{
+					Name:        ut.Base.Name,
+					Mods:        ut.Base.Mods,
+					ArrayBounds: append(ut.Base.ArrayBounds, col.Type.ArrayBounds...)}
+			}
+			col.NotNull = col.NotNull || ut.NotNull
+			for _, c := range ut.Checks {
+				expr, err := columnCheck(c, col.Name)
+				if err != nil {
+					return col, fmt.Errorf("can't convert check %q of domain %s: %w", c, ut.Name, err)
+				}
+				col.Checks = append(col.Checks, expr)
+			}
+			base, ok := lookup(col.Type.Name)
+			if !ok {
+				return col, nil
+			}
+			if depth == maxDomainDepth {
+				return col, fmt.Errorf("too many nested domains for type %s", ut.Name)
+			}
+			ut = base
+		default:
+			return col, fmt.Errorf("unknown kind %q of user-defined type %s", ut.Kind, ut.Name)
+		}
+	}
+}
+
+// columnCheck turns the CHECK expression of a domain, which refers to the
+// value being checked as VALUE, into an expression on column col.
+func columnCheck(check, col string) (string, error) {
+	tree, err := pg_query.Parse("SELECT " + check)
+	if err != nil {
+		return "", err
+	}
+	if len(tree.Stmts) != 1 || len(tree.Stmts[0].Stmt.GetSelectStmt().GetTargetList()) != 1 {
+		return "", fmt.Errorf("not an expression")
+	}
+	renameValue(tree.Stmts[0].Stmt.ProtoReflect(), col)
+	s, err := pg_query.Deparse(tree)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(s, "SELECT "), nil
+}
+
+// renameValue replaces all references to VALUE in the parse tree m with
+// references to column col.
+func renameValue(m protoreflect.Message, col string) {
+	if cr, ok := m.Interface().(*pg_query.ColumnRef); ok {
+		if len(cr.Fields) == 1 && cr.Fields[0].GetString_().GetStr() == "value" {
+			cr.Fields[0] = pg_query.MakeStrNode(col)
+		}
+		return
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsList() && fd.Message() != nil:
+			l := v.List()
+			for i := 0; i < l.Len(); i++ {
+				renameValue(l.Get(i).Message(), col)
+			}
+		case !fd.IsList() && !fd.IsMap() && fd.Message() != nil:
+			renameValue(v.Message(), col)
+		}
+		return true
+	})
+}
+
+// convComposite converts the text representation of a composite value,
+// e.g. (1,"a b",), into a JSON object mapping each of fields to its value
+// (as a string), or null.
+func convComposite(fields []string, val string) (string, error) {
+	if len(val) < 2 || val[0] != '(' || val[len(val)-1] != ')' {
+		return "", fmt.Errorf("unrecognized data format for composite: expected (v1,v2,...)")
+	}
+	vals, err := splitRecord(val[1 : len(val)-1])
+	if err != nil {
+		return "", err
+	}
+	if len(vals) != len(fields) {
+		return "", fmt.Errorf("composite has %d values, expected %d", len(vals), len(fields))
+	}
+	m := make(map[string]*string)
+	for i, f := range fields {
+		m[f] = vals[i]
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+// convRange converts the text representation of a range value, e.g.
+// [1,10), into a JSON object with its bounds (as strings, or null if
+// unbounded) and whether they are inclusive.
+func convRange(val string) (string, error) {
+	if val == "empty" {
+		return `{"empty":true}`, nil
+	}
+	if len(val) < 2 || !strings.ContainsRune("[(", rune(val[0])) || !strings.ContainsRune("])", rune(val[len(val)-1])) {
+		return "", fmt.Errorf("unrecognized data format for range: expected [lower,upper)")
+	}
+	bounds, err := splitRecord(val[1 : len(val)-1])
+	if err != nil {
+		return "", err
+	}
+	if len(bounds) != 2 {
+		return "", fmt.Errorf("range has %d bounds, expected 2", len(bounds))
+	}
+	b, err := json.Marshal(struct {
+		Lower    *string `json:"lower"`
+		Upper    *string `json:"upper"`
+		LowerInc bool    `json:"lower_inc"`
+		UpperInc bool    `json:"upper_inc"`
+	}{bounds[0], bounds[1], val[0] == '[', val[len(val)-1] == ']'})
+	return string(b), err
+}
+
+// splitRecord splits the comma-separated items of a composite or range
+// value. Items may be double-quoted, with embedded double quotes and
+// backslashes escaped by doubling or by a backslash. Unquoted empty items
+// are NULL, and are returned as nil.
+func splitRecord(s string) ([]*string, error) {
+	var items []*string
+	var b strings.Builder
+	quoted, inQuotes := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inQuotes && c == '"' && i+1 < len(s) && s[i+1] == '"':
+			b.WriteByte('"')
+			i++
+		case c == '"':
+			inQuotes, quoted = !inQuotes, true
+		case c == '\\':
+			if i+1 == len(s) {
+				return nil, fmt.Errorf("unterminated escape in %q", s)
+			}
+			b.WriteByte(s[i+1])
+			i++
+		case c == ',' && !inQuotes:
+			items = append(items, recordItem(b.String(), quoted))
+			b.Reset()
+			quoted = false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	return append(items, recordItem(b.String(), quoted)), nil
+}
+
+func recordItem(s string, quoted bool) *string {
+	if s == "" && !quoted {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvComposite(t *testing.T) {
+	tests := []struct {
+		val      string
+		expected string
+		errMsg   string
+	}{
+		{`(1,abc)`, `{"a":"1","b":"abc"}`, ""},
+		{`(,"")`, `{"a":null,"b":""}`, ""},
+		{`("x,y","say ""hi"" \\o/")`, `{"a":"x,y","b":"say \"hi\" \\o/"}`, ""},
+		{`(1)`, "", "composite has 1 values, expected 2"},
+		{`1,2`, "", "unrecognized data format for composite"},
+		{`("1,2)`, "", "unterminated quote"},
+	}
+	for _, tc := range tests {
+		got, err := convComposite([]string{"a", "b"}, tc.val)
+		if tc.errMsg != "" {
+			assert.ErrorContains(t, err, tc.errMsg, tc.val)
+			continue
+		}
+		assert.Nil(t, err, tc.val)
+		assert.Equal(t, tc.expected, got, tc.val)
+	}
+}
+
+func TestConvRange(t *testing.T) {
+	tests := []struct {
+		val      string
+		expected string
+	}{
+		{`[1,10)`, `{"lower":"1","upper":"10","lower_inc":true,"upper_inc":false}`},
+		{`(,5]`, `{"lower":null,"upper":"5","lower_inc":false,"upper_inc":true}`},
+		{`["2022-01-01 00:00:00","2022-02-01 00:00:00")`, `{"lower":"2022-01-01 00:00:00","upper":"2022-02-01 00:00:00","lower_inc":true,"upper_inc":false}`},
+		{`empty`, `{"empty":true}`},
+	}
+	for _, tc := range tests {
+		got, err := convRange(tc.val)
+		assert.Nil(t, err, tc.val)
+		assert.Equal(t, tc.expected, got, tc.val)
+	}
+	_, err := convRange("1,10")
+	assert.NotNil(t, err)
+}
+
+func TestColumnCheck(t *testing.T) {
+	tests := []struct {
+		check    string
+		col      string
+		expected string
+	}{
+		{"((VALUE > 0))", "id", "id > 0"},
+		{"VALUE IN ('a', 'b') AND length(VALUE) < 5", "c", "c IN ('a', 'b') AND length(c) < 5"},
+		{"(VALUE <> 'value')", "MixedCase", `"MixedCase" <> 'value'`},
+	}
+	for _, tc := range tests {
+		got, err := columnCheck(tc.check, tc.col)
+		assert.Nil(t, err, tc.check)
+		assert.Equal(t, tc.expected, got, tc.check)
+	}
+}
@@ -0,0 +1,318 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ProcessDataRow converts a row read from a Spanner database and writes it
+// to Spanner.
+func ProcessDataRow(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, row *spanner.Row) {
+	vals := make([]spanner.GenericColumnValue, row.Size())
+	for i := range vals {
+		if err := row.Column(i, &vals[i]); err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't process spanner data row: %s", err))
+			// Decoding failed, so we don't have any data to add to bad rows.
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			return
+		}
+	}
+	cvtCols, cvtVals, err := ConvertData(conv, srcSchema, spTable, spCols, spSchema, vals)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Error while converting data: %s\n", err))
+		conv.StatsAddBadRow(srcTable, conv.DataMode())
+		conv.CollectBadRow(srcTable, srcSchema.ColNames, valsToStrings(vals))
+		return
+	}
+	conv.WriteRow(srcTable, spTable, cvtCols, cvtVals)
+}
+
+// ConvertData maps the values of a row read from a Spanner database into
+// Spanner data for the columns spCols of the target table. Since entries in
+// vals may be NULL, we also return the list of columns (NULL cols are
+// dropped).
+func ConvertData(conv *internal.Conv, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, vals []spanner.GenericColumnValue) ([]string, []interface{}, error) {
+	var c []string
+	var v []interface{}
+	if len(spCols) != len(vals) {
+		return nil, nil, fmt.Errorf("ConvertData: spCols and vals don't have the same lengths: len(spCols)=%d, len(vals)=%d", len(spCols), len(vals))
+	}
+	for i, spCol := range spCols {
+		if _, isNull := vals[i].Value.GetKind().(*structpb.Value_NullValue); isNull {
+			continue
+		}
+		spColDef, ok := spSchema.ColDefs[spCol]
+		if !ok {
+			return nil, nil, fmt.Errorf("can't find Spanner schema for col %s", spCol)
+		}
+		x, err := convValue(conv, spColDef.T, vals[i])
+		if err != nil {
+			return nil, nil, fmt.Errorf("column %s: %w", srcSchema.ColNames[i], err)
+		}
+		c = append(c, spCol)
+		v = append(v, x)
+	}
+	if aux, ok := conv.SyntheticPKeys[spTable]; ok {
+		c = append(c, aux.Col)
+		v = append(v, fmt.Sprintf("%d", int64(bits.Reverse64(uint64(aux.Sequence)))))
+		aux.Sequence++
+		conv.SyntheticPKeys[spTable] = aux
+	}
+	return c, v, nil
+}
+
+// convValue converts a non-NULL value read from Spanner to a value of type
+// spType. Values are read in their wire format, which is the same for
+// GoogleSQL and PostgreSQL dialect databases.
+func convValue(conv *internal.Conv, spType ddl.Type, val spanner.GenericColumnValue) (interface{}, error) {
+	code := val.Type.GetCode()
+	if code != sppb.TypeCode_ARRAY {
+		s, err := wireString(val.Value)
+		if err != nil {
+			return nil, err
+		}
+		return convScalar(conv, spType, code, s)
+	}
+	elemCode := val.Type.GetArrayElementType().GetCode()
+	var elems []*string
+	for _, e := range val.Value.GetListValue().GetValues() {
+		if _, isNull := e.GetKind().(*structpb.Value_NullValue); isNull {
+			elems = append(elems, nil)
+			continue
+		}
+		s, err := wireString(e)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, &s)
+	}
+	if !spType.IsArray {
+		// PostgreSQL dialect databases don't support arrays, so they are
+		// stored as a JSON array of strings.
+		if spType.Name != ddl.String {
+			return nil, fmt.Errorf("can't convert array to %s", spType.Name)
+		}
+		b, err := json.Marshal(elems)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+	return convArray(conv, spType, elemCode, elems)
+}
+
+// wireString returns a scalar value in Spanner's wire format: numbers and
+// booleans are JSON values, and all other types (including INT64, NUMERIC
+// and non-finite FLOAT64 values) are strings.
+func wireString(v *structpb.Value) (string, error) {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return k.StringValue, nil
+	case *structpb.Value_NumberValue:
+		return strconv.FormatFloat(k.NumberValue, 'g', -1, 64), nil
+	case *structpb.Value_BoolValue:
+		return strconv.FormatBool(k.BoolValue), nil
+	default:
+		return "", fmt.Errorf("unexpected value %v", v)
+	}
+}
+
+// convScalar converts a value in Spanner's wire format, read from a column
+// of type code srcCode, to a value of type spType.
+func convScalar(conv *internal.Conv, spType ddl.Type, srcCode sppb.TypeCode, val string) (interface{}, error) {
+	switch spType.Name {
+	case ddl.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert to bool: %w", err)
+		}
+		return b, nil
+	case ddl.Bytes:
+		if srcCode != sppb.TypeCode_BYTES {
+			return []byte(val), nil
+		}
+		b, err := base64.StdEncoding.DecodeString(val)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert to bytes: %w", err)
+		}
+		return b, nil
+	case ddl.Date:
+		d, err := civil.ParseDate(val)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert to date: %w", err)
+		}
+		return d, nil
+	case ddl.Float64:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert to float64: %w", err)
+		}
+		return f, nil
+	case ddl.Int64:
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert to int64: %w", err)
+		}
+		return i, nil
+	case ddl.Numeric:
+		if conv.TargetDb == constants.TargetExperimentalPostgres {
+			return spanner.PGNumeric{Numeric: val, Valid: true}, nil
+		}
+		r := new(big.Rat)
+		if _, ok := r.SetString(val); !ok {
+			return nil, fmt.Errorf("can't convert %q to big.Rat", val)
+		}
+		return r, nil
+	case ddl.String, ddl.JSON:
+		return val, nil
+	case ddl.Timestamp:
+		t, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil {
+			return nil, fmt.Errorf("can't convert to timestamp: %w", err)
+		}
+		return t, nil
+	default:
+		return nil, fmt.Errorf("data conversion not implemented for type %v", spType.Name)
+	}
+}
+
+// convArray converts the elements of an array, where nil elements are NULL.
+// The Spanner client for go does not accept []interface{} for arrays, so
+// a slice of the specific type is built.
+func convArray(conv *internal.Conv, spType ddl.Type, srcCode sppb.TypeCode, elems []*string) (interface{}, error) {
+	var vals []interface{}
+	for _, e := range elems {
+		if e == nil {
+			vals = append(vals, nil)
+			continue
+		}
+		x, err := convScalar(conv, spType, srcCode, *e)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, x)
+	}
+	switch spType.Name {
+	case ddl.Bool:
+		r := []spanner.NullBool{}
+		for _, x := range vals {
+			b, ok := x.(bool)
+			r = append(r, spanner.NullBool{Bool: b, Valid: ok})
+		}
+		return r, nil
+	case ddl.Bytes:
+		r := [][]byte{}
+		for _, x := range vals {
+			b, _ := x.([]byte)
+			r = append(r, b)
+		}
+		return r, nil
+	case ddl.Date:
+		r := []spanner.NullDate{}
+		for _, x := range vals {
+			d, ok := x.(civil.Date)
+			r = append(r, spanner.NullDate{Date: d, Valid: ok})
+		}
+		return r, nil
+	case ddl.Float64:
+		r := []spanner.NullFloat64{}
+		for _, x := range vals {
+			f, ok := x.(float64)
+			r = append(r, spanner.NullFloat64{Float64: f, Valid: ok})
+		}
+		return r, nil
+	case ddl.Int64:
+		r := []spanner.NullInt64{}
+		for _, x := range vals {
+			i, ok := x.(int64)
+			r = append(r, spanner.NullInt64{Int64: i, Valid: ok})
+		}
+		return r, nil
+	case ddl.Numeric:
+		r := []spanner.NullNumeric{}
+		for _, x := range vals {
+			n, ok := x.(*big.Rat)
+			if ok {
+				r = append(r, spanner.NullNumeric{Numeric: *n, Valid: true})
+			} else {
+				r = append(r, spanner.NullNumeric{})
+			}
+		}
+		return r, nil
+	case ddl.String:
+		r := []spanner.NullString{}
+		for _, x := range vals {
+			s, ok := x.(string)
+			r = append(r, spanner.NullString{StringVal: s, Valid: ok})
+		}
+		return r, nil
+	case ddl.JSON:
+		r := []spanner.NullJSON{}
+		for _, x := range vals {
+			s, ok := x.(string)
+			if !ok {
+				r = append(r, spanner.NullJSON{})
+				continue
+			}
+			var j interface{}
+			if err := json.Unmarshal([]byte(s), &j); err != nil {
+				return nil, fmt.Errorf("can't convert %q to JSON: %w", s, err)
+			}
+			r = append(r, spanner.NullJSON{Value: j, Valid: true})
+		}
+		return r, nil
+	case ddl.Timestamp:
+		r := []spanner.NullTime{}
+		for _, x := range vals {
+			t, ok := x.(time.Time)
+			r = append(r, spanner.NullTime{Time: t, Valid: ok})
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("data conversion not implemented for type ARRAY<%v>", spType.Name)
+	}
+}
+
+// valsToStrings converts the values of a row to strings for the bad rows
+// report.
+func valsToStrings(vals []spanner.GenericColumnValue) []string {
+	var s []string
+	for _, v := range vals {
+		b, err := v.Value.MarshalJSON()
+		if err != nil {
+			s = append(s, v.Value.String())
+			continue
+		}
+		s = append(s, string(b))
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func scalar(code sppb.TypeCode, v *structpb.Value) spanner.GenericColumnValue {
+	return spanner.GenericColumnValue{Type: &sppb.Type{Code: code}, Value: v}
+}
+
+func array(code sppb.TypeCode, vals ...*structpb.Value) spanner.GenericColumnValue {
+	return spanner.GenericColumnValue{
+		Type:  &sppb.Type{Code: sppb.TypeCode_ARRAY, ArrayElementType: &sppb.Type{Code: code}},
+		Value: structpb.NewListValue(&structpb.ListValue{Values: vals}),
+	}
+}
+
+func TestConvValue(t *testing.T) {
+	conv := internal.MakeConv()
+	str := structpb.NewStringValue
+	tc := []struct {
+		name   string
+		spType ddl.Type
+		val    spanner.GenericColumnValue
+		out    interface{}
+	}{
+		{"bool", ddl.Type{Name: ddl.Bool}, scalar(sppb.TypeCode_BOOL, structpb.NewBoolValue(true)), true},
+		{"bytes", ddl.Type{Name: ddl.Bytes}, scalar(sppb.TypeCode_BYTES, str("AQL/")), []byte{0x01, 0x02, 0xff}},
+		{"date", ddl.Type{Name: ddl.Date}, scalar(sppb.TypeCode_DATE, str("2020-01-02")), civil.Date{Year: 2020, Month: 1, Day: 2}},
+		{"float", ddl.Type{Name: ddl.Float64}, scalar(sppb.TypeCode_FLOAT64, structpb.NewNumberValue(1.5)), 1.5},
+		{"float_inf", ddl.Type{Name: ddl.Float64}, scalar(sppb.TypeCode_FLOAT64, str("Infinity")), math.Inf(1)},
+		{"int", ddl.Type{Name: ddl.Int64}, scalar(sppb.TypeCode_INT64, str("9007199254740993")), int64(9007199254740993)},
+		{"numeric", ddl.Type{Name: ddl.Numeric}, scalar(sppb.TypeCode_NUMERIC, str("12.5")), big.NewRat(25, 2)},
+		{"string", ddl.Type{Name: ddl.String}, scalar(sppb.TypeCode_STRING, str("abc")), "abc"},
+		{"json", ddl.Type{Name: ddl.JSON}, scalar(sppb.TypeCode_JSON, str(`{"a":1}`)), `{"a":1}`},
+		{"timestamp", ddl.Type{Name: ddl.Timestamp}, scalar(sppb.TypeCode_TIMESTAMP, str("2020-01-02T03:04:05.123456789Z")), time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)},
+		{"int_array", ddl.Type{Name: ddl.Int64, IsArray: true}, array(sppb.TypeCode_INT64, str("1"), structpb.NewNullValue()),
+			[]spanner.NullInt64{{Int64: 1, Valid: true}, {}}},
+		{"string_array", ddl.Type{Name: ddl.String, IsArray: true}, array(sppb.TypeCode_STRING),
+			[]spanner.NullString{}},
+		// PostgreSQL dialect databases store arrays as strings.
+		{"array_to_string", ddl.Type{Name: ddl.String}, array(sppb.TypeCode_INT64, str("1"), structpb.NewNullValue()), `["1",null]`},
+	}
+	for _, c := range tc {
+		out, err := convValue(conv, c.spType, c.val)
+		assert.Nil(t, err, c.name)
+		assert.Equal(t, c.out, out, c.name)
+	}
+
+	conv.TargetDb = constants.TargetExperimentalPostgres
+	out, err := convValue(conv, ddl.Type{Name: ddl.Numeric}, scalar(sppb.TypeCode_NUMERIC, str("12.5")))
+	assert.Nil(t, err)
+	assert.Equal(t, spanner.PGNumeric{Numeric: "12.5", Valid: true}, out)
+
+	_, err = convValue(conv, ddl.Type{Name: ddl.Int64}, scalar(sppb.TypeCode_STRING, str("abc")))
+	assert.NotNil(t, err)
+}
+
+func TestConvertData(t *testing.T) {
+	conv := internal.MakeConv()
+	srcSchema := schema.Table{Name: "t", ColNames: []string{"a", "b", "c"}}
+	spSchema := ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"a", "b", "c"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"a": {Name: "a", T: ddl.Type{Name: ddl.Int64}},
+			"b": {Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"c": {Name: "c", T: ddl.Type{Name: ddl.Bool}},
+		},
+	}
+	vals := []spanner.GenericColumnValue{
+		scalar(sppb.TypeCode_INT64, structpb.NewStringValue("7")),
+		scalar(sppb.TypeCode_STRING, structpb.NewNullValue()),
+		scalar(sppb.TypeCode_BOOL, structpb.NewBoolValue(false)),
+	}
+	cols, v, err := ConvertData(conv, srcSchema, "t", []string{"a", "b", "c"}, spSchema, vals)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "c"}, cols)
+	assert.Equal(t, []interface{}{int64(7), false}, v)
+}
@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner"
 	_ "github.com/lib/pq" // we will use database/sql package instead of using this package directly
@@ -33,11 +34,90 @@ import (
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 )
 
-// InfoSchemaImpl postgres specific implementation for InfoSchema.
+// InfoSchemaImpl is the InfoSchema implementation for Spanner. It is used to
+// read the schema of the target database, and to read the schema and data of
+// a Spanner database used as the source of a migration.
 type InfoSchemaImpl struct {
-	Client   *spanner.Client
-	Ctx      context.Context
+	Client *spanner.Client
+	Ctx    context.Context
+	// TargetDb is the dialect of the database being read, using the same
+	// values as conv.TargetDb.
 	TargetDb string
+	// ReadTimestamp is the timestamp at which all reads are done, so that
+	// a copy of the database is consistent. If it is zero, strong reads are
+	// used.
+	ReadTimestamp time.Time
+}
+
+// NewInfoSchemaImpl returns an InfoSchemaImpl for reading a Spanner database
+// used as the source of a migration. The dialect of the database is detected,
+// and if readTimestamp is zero the time of this first read is used for all
+// subsequent reads. Otherwise, readTimestamp must be within the version
+// retention period of the database.
+func NewInfoSchemaImpl(ctx context.Context, client *spanner.Client, readTimestamp time.Time) (InfoSchemaImpl, error) {
+	isi := InfoSchemaImpl{Client: client, Ctx: ctx, TargetDb: constants.TargetSpanner, ReadTimestamp: readTimestamp}
+	// The options are read with a strong read, which works for any
+	// readTimestamp, and whose timestamp is the default read timestamp.
+	ro := client.Single()
+	defer ro.Close()
+	stmt := spanner.Statement{
+		SQL: `SELECT option_name, option_value FROM information_schema.database_options WHERE option_name IN ('database_dialect', 'version_retention_period')`,
+	}
+	// The default version retention period is one hour.
+	retention := time.Hour
+	iter := ro.Query(ctx, stmt)
+	defer iter.Stop()
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return isi, fmt.Errorf("couldn't get database options: %w", err)
+		}
+		var name, value string
+		if err := row.Columns(&name, &value); err != nil {
+			return isi, fmt.Errorf("couldn't get database options: %w", err)
+		}
+		switch name {
+		case "database_dialect":
+			if strings.ToLower(value) == constants.DIALECT_POSTGRESQL {
+				isi.TargetDb = constants.TargetExperimentalPostgres
+			}
+		case "version_retention_period":
+			if retention, err = parseRetentionPeriod(value); err != nil {
+				return isi, fmt.Errorf("couldn't get version retention period: %w", err)
+			}
+		}
+	}
+	if isi.ReadTimestamp.IsZero() {
+		var err error
+		if isi.ReadTimestamp, err = ro.Timestamp(); err != nil {
+			return isi, fmt.Errorf("couldn't get read timestamp: %w", err)
+		}
+		return isi, nil
+	}
+	if time.Since(isi.ReadTimestamp) > retention {
+		return isi, fmt.Errorf("read timestamp %s is older than the version retention period (%s) of the source database, use a more recent timestamp or increase the version retention period", isi.ReadTimestamp.Format(time.RFC3339Nano), retention)
+	}
+	return isi, nil
+}
+
+// parseRetentionPeriod parses a version_retention_period option, e.g. "1h",
+// "90m", "7d" or "3600s".
+func parseRetentionPeriod(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid version retention period %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version retention period %q", s)
+	}
+	return d, nil
 }
 
 // GetToDdl function below implement the common.InfoSchema interface.
@@ -45,18 +125,37 @@ func (isi InfoSchemaImpl) GetToDdl() common.ToDdl {
 	return ToDdlImpl{}
 }
 
-// We leave the 5 functions below empty to be able to pass this as an infoSchema interface. We don't need these for now.
+// ProcessData performs data conversion for a Spanner table. All tables are
+// read at the same timestamp.
 func (isi InfoSchemaImpl) ProcessData(conv *internal.Conv, srcTable string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable) error {
+	rowsInterface, err := isi.GetRowsFromTable(conv, srcTable)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+		return err
+	}
+	iter := rowsInterface.(*spanner.RowIterator)
+	defer iter.Stop()
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", srcTable, err))
+			return err
+		}
+		ProcessDataRow(conv, srcTable, srcSchema, spTable, spCols, spSchema, row)
+	}
 	return nil
 }
 
 // GetRowCount returns the row count of the table.
 func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
-	q := "SELECT count(*) FROM " + table.Name + ";"
+	q := "SELECT count(*) FROM " + isi.quote(table.Name) + ";"
 	stmt := spanner.Statement{
 		SQL: q,
 	}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 	var count int64
 	row, err := iter.Next()
@@ -71,8 +170,21 @@ func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error)
 
 }
 
+// GetRowsFromTable returns an iterator over the rows of a table, with
+// columns in the order of the source schema.
 func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string) (interface{}, error) {
-	return nil, nil
+	srcCols := conv.SrcSchema[srcTable].ColNames
+	if len(srcCols) == 0 {
+		return nil, fmt.Errorf("couldn't get source columns for table %s", srcTable)
+	}
+	var cols []string
+	for _, c := range srcCols {
+		cols = append(cols, isi.quote(c))
+	}
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), isi.quote(srcTable)),
+	}
+	return isi.single().Query(isi.Ctx, stmt), nil
 }
 
 func (isi InfoSchemaImpl) StartChangeDataCapture(ctx context.Context, conv *internal.Conv) (map[string]interface{}, error) {
@@ -106,7 +218,7 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 	WHERE table_type = 'BASE TABLE' AND table_schema = 'public'`
 	}
 	stmt := spanner.Statement{SQL: q}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 
 	var tableSchema, tableName string
@@ -146,7 +258,7 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 			"p1": table.Name,
 		},
 	}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 
 	colDefs := make(map[string]schema.Column)
@@ -173,9 +285,13 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 				// Nothing to do here -- these are handled elsewhere.
 			}
 		}
+		ty := toType(spannerType)
+		if isi.TargetDb == constants.TargetExperimentalPostgres {
+			ty = toPGType(spannerType)
+		}
 		c := schema.Column{
 			Name:    colName,
-			Type:    toType(spannerType),
+			Type:    ty,
 			NotNull: common.ToNotNull(conv, isNullable),
 		}
 		colDefs[colName] = c
@@ -207,7 +323,7 @@ func (isi InfoSchemaImpl) GetConstraints(conv *internal.Conv, table common.Schem
 			"p1": table.Name,
 		},
 	}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 
 	var primaryKeys []string
@@ -261,7 +377,7 @@ func (isi InfoSchemaImpl) GetForeignKeys(conv *internal.Conv, table common.Schem
 			"p1": table.Name,
 		},
 	}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 
 	var col, refCol, fKeyName, refTable string
@@ -330,7 +446,7 @@ func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAnd
 			"p1": table.Name,
 		},
 	}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 	var name, column, ordering string
 	var isUnique bool
@@ -374,7 +490,7 @@ func (isi InfoSchemaImpl) GetInterleaveTables() (map[string]string, error) {
 		WHERE interleave_type = 'IN PARENT' AND table_type = 'BASE TABLE' AND table_schema = 'public'`
 	}
 	stmt := spanner.Statement{SQL: q}
-	iter := isi.Client.Single().Query(isi.Ctx, stmt)
+	iter := isi.single().Query(isi.Ctx, stmt)
 	defer iter.Stop()
 
 	var tableName, parentTable string
@@ -396,6 +512,23 @@ func (isi InfoSchemaImpl) GetInterleaveTables() (map[string]string, error) {
 	return parentTables, nil
 }
 
+// single returns a read-only transaction for a single read at the read
+// timestamp.
+func (isi InfoSchemaImpl) single() *spanner.ReadOnlyTransaction {
+	if isi.ReadTimestamp.IsZero() {
+		return isi.Client.Single()
+	}
+	return isi.Client.Single().WithTimestampBound(spanner.ReadTimestamp(isi.ReadTimestamp))
+}
+
+// quote quotes an identifier in the dialect of the database being read.
+func (isi InfoSchemaImpl) quote(s string) string {
+	if isi.TargetDb == constants.TargetExperimentalPostgres {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return "`" + s + "`"
+}
+
 func toType(dataType string) schema.Type {
 	switch {
 	case strings.Contains(dataType, "ARRAY"):
@@ -417,3 +550,47 @@ func toType(dataType string) schema.Type {
 		return schema.Type{Name: dataType}
 	}
 }
+
+// pgTypeNames maps the type names reported in information_schema.columns of
+// PostgreSQL dialect databases to the names used in
+// ddl.PGSQL_TO_GOOGLE_SQL_TYPEMAP.
+var pgTypeNames = map[string]string{
+	"BIGINT":                   ddl.PGInt8,
+	"BOOLEAN":                  ddl.Bool,
+	"CHARACTER VARYING":        ddl.PGVarchar,
+	"DOUBLE PRECISION":         ddl.PGFloat8,
+	"TIMESTAMP WITH TIME ZONE": ddl.PGTimestamptz,
+}
+
+// toPGType converts a PostgreSQL dialect type e.g. character varying(100)
+// or bigint[] to the equivalent GoogleSQL type, so that the schema of
+// databases of both dialects is handled the same way.
+func toPGType(dataType string) schema.Type {
+	var ty schema.Type
+	if strings.HasSuffix(dataType, "[]") {
+		dataType = strings.TrimSuffix(dataType, "[]")
+		ty.ArrayBounds = []int64{-1}
+	}
+	if idx := strings.Index(dataType, "("); idx != -1 && strings.HasSuffix(dataType, ")") {
+		typeLen, err := strconv.ParseInt(dataType[idx+1:len(dataType)-1], 10, 64)
+		if err == nil {
+			if typeLen == ddl.PGMaxLength {
+				typeLen = ddl.MaxLength
+			}
+			ty.Mods = []int64{typeLen}
+		}
+		dataType = dataType[:idx]
+	}
+	name := strings.ToUpper(strings.TrimSpace(dataType))
+	if n, ok := pgTypeNames[name]; ok {
+		name = n
+	}
+	if n, ok := ddl.PGSQL_TO_GOOGLE_SQL_TYPEMAP[name]; ok {
+		name = n
+	}
+	if (name == ddl.String || name == ddl.Bytes) && len(ty.Mods) == 0 {
+		ty.Mods = []int64{ddl.MaxLength}
+	}
+	ty.Name = name
+	return ty
+}
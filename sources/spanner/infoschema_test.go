@@ -16,6 +16,7 @@ package spanner
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
@@ -51,3 +52,44 @@ func TestToType(t *testing.T) {
 		assert.Equal(t, tc.expColumnType, ty, tc.name)
 	}
 }
+
+func TestToPGType(t *testing.T) {
+	testCases := []struct {
+		dataType      string
+		expColumnType schema.Type
+	}{
+		{"boolean", schema.Type{Name: "BOOL"}},
+		{"bigint", schema.Type{Name: "INT64"}},
+		{"double precision", schema.Type{Name: "FLOAT64"}},
+		{"date", schema.Type{Name: "DATE"}},
+		{"numeric", schema.Type{Name: "NUMERIC"}},
+		{"jsonb", schema.Type{Name: "JSON"}},
+		{"timestamp with time zone", schema.Type{Name: "TIMESTAMP"}},
+		{"bytea", schema.Type{Name: "BYTES", Mods: []int64{ddl.MaxLength}}},
+		{"character varying", schema.Type{Name: "STRING", Mods: []int64{ddl.MaxLength}}},
+		{"character varying(100)", schema.Type{Name: "STRING", Mods: []int64{100}}},
+		{"character varying(2621440)", schema.Type{Name: "STRING", Mods: []int64{ddl.MaxLength}}},
+		{"bigint[]", schema.Type{Name: "INT64", ArrayBounds: []int64{-1}}},
+		{"character varying(10)[]", schema.Type{Name: "STRING", Mods: []int64{10}, ArrayBounds: []int64{-1}}},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expColumnType, toPGType(tc.dataType), tc.dataType)
+	}
+}
+
+func TestParseRetentionPeriod(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"1h":    time.Hour,
+		"90m":   90 * time.Minute,
+		"3600s": time.Hour,
+		"7d":    7 * 24 * time.Hour,
+	} {
+		got, err := parseRetentionPeriod(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"", "1w", "d"} {
+		_, err := parseRetentionPeriod(s)
+		assert.NotNil(t, err, s)
+	}
+}
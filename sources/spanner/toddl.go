@@ -67,8 +67,12 @@ func toSpannerTypeInternal(conv *internal.Conv, srcType schema.Type) (ddl.Type,
 	return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
 }
 
-// Override the types to map to experimental postgres types.
+// Override the types to map to experimental postgres types. PostgreSQL
+// dialect databases don't support arrays, so arrays are stored as strings.
 func overrideExperimentalType(srcType schema.Type, originalType ddl.Type, issues []internal.SchemaIssue) (ddl.Type, []internal.SchemaIssue) {
+	if len(srcType.ArrayBounds) > 0 {
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, append(issues, internal.NoGoodType)
+	}
 	switch srcType.Name {
 	case "PG.NUMERIC":
 		return ddl.Type{Name: ddl.Numeric}, nil
@@ -46,6 +46,9 @@ func TestToSpannerType(t *testing.T) {
 		// PG target.
 		{"pg_numeric", true, schema.Type{Name: "PG.NUMERIC"}, ddl.Type{Name: ddl.Numeric}},
 		{"pg_json", true, schema.Type{Name: "PG.JSONB"}, ddl.Type{Name: ddl.JSON}},
+		{"pg_string", true, schema.Type{Name: "STRING", Mods: []int64{100}}, ddl.Type{Name: ddl.String, Len: 100}},
+		// Arrays.
+		{"int_array", false, schema.Type{Name: "INT64", ArrayBounds: []int64{-1}}, ddl.Type{Name: ddl.Int64, IsArray: true}},
 	}
 	for _, tc := range toDDLTests {
 		conv.TargetDb = constants.TargetSpanner
@@ -56,4 +59,10 @@ func TestToSpannerType(t *testing.T) {
 		assert.Nil(t, err, tc.name)
 		assert.Equal(t, tc.expDDLType, ty, tc.name)
 	}
+
+	// PostgreSQL dialect databases don't support arrays.
+	conv.TargetDb = constants.TargetExperimentalPostgres
+	ty, issues := toDDLImpl.ToSpannerType(conv, "", schema.Type{Name: "INT64", ArrayBounds: []int64{-1}})
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, ty)
+	assert.Equal(t, []internal.SchemaIssue{internal.NoGoodType}, issues)
 }
@@ -66,6 +66,10 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, srcSche
 		if !ok1 || !ok2 {
 			return "", []string{}, []interface{}{}, fmt.Errorf("can't find Spanner and source-db schema for col %s", spCol)
 		}
+		// Spanner computes the values of generated columns.
+		if spColDef.Generated != "" {
+			continue
+		}
 		var x interface{}
 		var err error
 		x, err = convScalar(conv, spColDef.T, srcColDef.Type.Name, conv.TimezoneOffset, vals[i])
@@ -178,6 +182,7 @@ func convTimestamp(srcTypeName string, val string) (t time.Time, err error) {
 
 	if srcTypeName == dateTimeOffsetType {
 		t, err = time.Parse(time.RFC3339, val)
+		t = t.UTC()
 	} else {
 		t, err = time.Parse("2006-01-02T15:04:05", val)
 	}
@@ -86,7 +86,8 @@ func TestConvertData(t *testing.T) {
 		{"int64", ddl.Type{Name: ddl.Int64}, "", "42", int64(42)},
 		{"string", ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, "", "eh", "eh"},
 		{"datetime", ddl.Type{Name: ddl.Timestamp}, "datetime", "2019-10-29T05:30:00", getTimeWithoutTimezone(t, "2019-10-29T05:30:00")},
-		{"datetimeoffset", ddl.Type{Name: ddl.Timestamp}, "datetimeoffset", "2021-12-15T07:39:52.9433333+01:20", getTimeWithTimezone(t, "2021-12-15T07:39:52.9433333+01:20")},
+		{"datetimeoffset", ddl.Type{Name: ddl.Timestamp}, "datetimeoffset", "2021-12-15T07:39:52.9433333+01:20", getTimeWithTimezone(t, "2021-12-15T07:39:52.9433333+01:20").UTC()},
+		{"rowversion", ddl.Type{Name: ddl.Bytes, Len: 8}, "timestamp", string([]byte{0, 0, 0, 0, 0, 0, 7, 209}), []byte{0, 0, 0, 0, 0, 0, 7, 209}},
 		{"decimal", ddl.Type{Name: ddl.Numeric}, "decimal", "234.90909090909", big.NewRat(23490909090909, 100000000000)},
 		{"numeric", ddl.Type{Name: ddl.Numeric}, "numeric", numStr, numVal},
 	}
@@ -171,6 +172,41 @@ func TestConvertMultiColData(t *testing.T) {
 	}
 }
 
+func TestConvertData_Generated(t *testing.T) {
+	tableName := "testtable"
+	spTable := ddl.CreateTable{
+		Name:     tableName,
+		ColNames: []string{"a", "b", "c"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"a": {Name: "a", T: ddl.Type{Name: ddl.Int64}},
+			"b": {Name: "b", T: ddl.Type{Name: ddl.Int64}},
+			"c": {Name: "c", T: ddl.Type{Name: ddl.Int64}, Generated: "a + b"},
+		}}
+	srcTable := schema.Table{
+		Name:     tableName,
+		ColNames: []string{"a", "b", "c"},
+		ColDefs: map[string]schema.Column{
+			"a": {Type: schema.Type{Name: "int"}},
+			"b": {Type: schema.Type{Name: "int"}},
+			"c": {Type: schema.Type{Name: "int"}, Generated: "([a]+[b])"},
+		}}
+	conv := buildConv(spTable, srcTable)
+	cols := []string{"a", "b", "c"}
+	atable, acols, avals, err := ConvertData(conv, tableName, cols, conv.SrcSchema[tableName], tableName, cols, conv.SpSchema[tableName], []string{"1", "2", "3"})
+	checkResults(t, atable, acols, avals, err, tableName, []string{"a", "b"}, []interface{}{int64(1), int64(2)}, "generated column")
+}
+
+func TestGetSelectQuery(t *testing.T) {
+	colDefs := map[string]schema.Column{
+		"id":             {Name: "id", Type: schema.Type{Name: "int"}},
+		"created":        {Name: "created", Type: schema.Type{Name: dateTimeOffsetType}},
+		"version":        {Name: "version", Type: schema.Type{Name: "timestamp"}},
+		"created_offset": {Name: "created_offset", Type: schema.Type{Name: tzOffsetType}},
+	}
+	q := getSelectQuery("db", "sales", "orders", []string{"id", "created", "version", "created_offset"}, colDefs)
+	assert.Equal(t, "SELECT [id], CONVERT(VARCHAR(33), [created], 126) AS created, [version], DATENAME(TZOFFSET, [created]) AS [created_offset] FROM [db].[sales].[orders]", q)
+}
+
 func TestConvertError(t *testing.T) {
 	errorTests := []struct {
 		name string
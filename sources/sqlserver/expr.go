@@ -0,0 +1,420 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlserver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// exprFunctions maps the SQL Server functions we can translate to their
+// Spanner equivalent. LEN ignores trailing spaces while CHAR_LENGTH counts
+// them, so the lengths of values ending with spaces differ.
+var exprFunctions = map[string]string{
+	"ABS":       "ABS",
+	"CEILING":   "CEIL",
+	"COALESCE":  "COALESCE",
+	"CONCAT":    "CONCAT",
+	"FLOOR":     "FLOOR",
+	"IIF":       "IF",
+	"ISNULL":    "IFNULL",
+	"LEN":       "CHAR_LENGTH",
+	"LOWER":     "LOWER",
+	"LTRIM":     "LTRIM",
+	"NULLIF":    "NULLIF",
+	"POWER":     "POW",
+	"REPLACE":   "REPLACE",
+	"ROUND":     "ROUND",
+	"RTRIM":     "RTRIM",
+	"SIGN":      "SIGN",
+	"SQRT":      "SQRT",
+	"SUBSTRING": "SUBSTR",
+	"UPPER":     "UPPER",
+}
+
+// exprKeywords lists the SQL Server keywords that have the same meaning
+// in Spanner expressions.
+var exprKeywords = map[string]bool{
+	"AND": true, "BETWEEN": true, "CASE": true, "ELSE": true, "END": true, "IN": true,
+	"IS": true, "LIKE": true, "NOT": true, "NULL": true, "OR": true, "THEN": true, "WHEN": true,
+}
+
+// exprKind is the kind of value of an operand, which tells whether + adds
+// or concatenates.
+type exprKind int
+
+const (
+	unknownKind exprKind = iota
+	numberKind
+	stringKind
+)
+
+// stringTypes and numberTypes list the SQL Server types of columns whose
+// values are strings and numbers.
+var (
+	stringTypes = map[string]bool{"char": true, "nchar": true, "varchar": true, "nvarchar": true, "text": true, "ntext": true}
+	numberTypes = map[string]bool{"bigint": true, "int": true, "smallint": true, "tinyint": true, "bit": true,
+		"decimal": true, "numeric": true, "money": true, "smallmoney": true, "float": true, "real": true}
+)
+
+// ToSpannerExpr implements the common.ExprToDdl interface. It translates
+// the definition of a computed column of srcTable, whose Spanner type is ty,
+// into a Spanner expression. Only column references, literals, arithmetic
+// and comparison operators, CASE expressions and the functions in
+// exprFunctions are supported. + is translated to || if both its operands
+// are strings, and kept if both are numbers; other expressions using + are
+// rejected.
+func (tdi ToDdlImpl) ToSpannerExpr(conv *internal.Conv, srcTable string, expr string, ty ddl.Type) (string, error) {
+	// kinds holds the kind of the values among the tokens of out.
+	var out []string
+	var kinds []exprKind
+	add := func(token string, kind exprKind) {
+		out, kinds = append(out, token), append(kinds, kind)
+	}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '[':
+			end := i + 1
+			var name strings.Builder
+			for ; end < len(expr); end++ {
+				if expr[end] == ']' {
+					if end+1 < len(expr) && expr[end+1] == ']' {
+						name.WriteByte(']')
+						end++
+						continue
+					}
+					break
+				}
+				name.WriteByte(expr[end])
+			}
+			if end == len(expr) {
+				return "", fmt.Errorf("unterminated identifier in %q", expr)
+			}
+			col, kind, err := exprColumn(conv, srcTable, name.String())
+			if err != nil {
+				return "", err
+			}
+			add(col, kind)
+			i = end + 1
+		case c == '\'' || ((c == 'N' || c == 'n') && i+1 < len(expr) && expr[i+1] == '\''):
+			if c != '\'' {
+				i++
+			}
+			s, n, err := exprString(expr[i:])
+			if err != nil {
+				return "", err
+			}
+			add(s, stringKind)
+			i += n
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(expr) && isIdentPart(expr[end]) {
+				end++
+			}
+			word := expr[i:end]
+			i = end
+			upper := strings.ToUpper(word)
+			switch {
+			case nextNonSpace(expr, i) == '(' && !exprKeywords[upper]:
+				f, ok := exprFunctions[upper]
+				if !ok {
+					return "", fmt.Errorf("function %s is not supported", word)
+				}
+				add(f, unknownKind)
+			case exprKeywords[upper]:
+				add(upper, unknownKind)
+			default:
+				col, kind, err := exprColumn(conv, srcTable, word)
+				if err != nil {
+					return "", err
+				}
+				add(col, kind)
+			}
+		case c >= '0' && c <= '9' || c == '.':
+			end := i + 1
+			for end < len(expr) && (expr[end] >= '0' && expr[end] <= '9' || expr[end] == '.') {
+				end++
+			}
+			add(expr[i:end], numberKind)
+			i = end
+		default:
+			op := string(c)
+			if i+1 < len(expr) {
+				switch two := expr[i : i+2]; two {
+				case "<=", ">=", "<>", "!=":
+					op = two
+				}
+			}
+			i += len(op)
+			switch op {
+			case "/":
+				// Integer division truncates in SQL Server, but
+				// returns a FLOAT64 in Spanner.
+				if ty.Name == ddl.Int64 {
+					return "", fmt.Errorf("integer division is not supported")
+				}
+			case "+", "-", "*", "(", ")", ",", "=", "<", ">", "<=", ">=", "<>", "!=":
+			default:
+				return "", fmt.Errorf("operator %s is not supported", op)
+			}
+			add(op, unknownKind)
+		}
+	}
+	for i, t := range out {
+		if t != "+" {
+			continue
+		}
+		left, right := leftOperandKind(out, kinds, i), rightOperandKind(out, kinds, i)
+		switch {
+		case left == stringKind && right == stringKind:
+			out[i] = "||"
+		case left == numberKind && right == numberKind:
+		default:
+			return "", fmt.Errorf("can't tell whether + adds or concatenates in %q", expr)
+		}
+	}
+	return joinExprTokens(out), nil
+}
+
+// exprColumn returns the quoted Spanner name of column col of srcTable, and
+// the kind of its values.
+func exprColumn(conv *internal.Conv, srcTable, col string) (string, exprKind, error) {
+	srcCol, ok := conv.SrcSchema[srcTable].ColDefs[col]
+	if !ok {
+		return "", unknownKind, fmt.Errorf("unknown column %s", col)
+	}
+	spCol, err := internal.GetSpannerCol(conv, srcTable, col, false)
+	if err != nil {
+		return "", unknownKind, err
+	}
+	kind := unknownKind
+	switch {
+	case len(srcCol.Type.ArrayBounds) > 0:
+	case stringTypes[srcCol.Type.Name]:
+		kind = stringKind
+	case numberTypes[srcCol.Type.Name]:
+		kind = numberKind
+	}
+	return "`" + spCol + "`", kind, nil
+}
+
+// exprFunctionKinds is the kind of the values returned by the Spanner
+// functions of exprFunctions, or unknownKind if it depends on their first
+// argument.
+var exprFunctionKinds = map[string]exprKind{
+	"ABS": numberKind, "CEIL": numberKind, "CHAR_LENGTH": numberKind, "FLOOR": numberKind, "POW": numberKind,
+	"ROUND": numberKind, "SIGN": numberKind, "SQRT": numberKind,
+	"CONCAT": stringKind, "LOWER": stringKind, "LTRIM": stringKind, "REPLACE": stringKind, "RTRIM": stringKind,
+	"SUBSTR": stringKind, "UPPER": stringKind,
+}
+
+// leftOperandKind returns the kind of the operand before the operator at
+// tokens[i]: a value, or a parenthesized expression or function call.
+func leftOperandKind(tokens []string, kinds []exprKind, i int) exprKind {
+	if i == 0 {
+		return unknownKind
+	}
+	if tokens[i-1] != ")" {
+		return kinds[i-1]
+	}
+	depth := 0
+	for j := i - 1; j >= 0; j-- {
+		switch tokens[j] {
+		case ")":
+			depth++
+		case "(":
+			depth--
+		}
+		if depth == 0 {
+			if j > 0 && exprFunctionNames[tokens[j-1]] {
+				return callKind(tokens, kinds, j-1, i-1)
+			}
+			return spanKind(tokens, kinds, j+1, i-1)
+		}
+	}
+	return unknownKind
+}
+
+// rightOperandKind returns the kind of the operand after the operator at
+// tokens[i].
+func rightOperandKind(tokens []string, kinds []exprKind, i int) exprKind {
+	start := i + 1
+	if start < len(tokens) && exprFunctionNames[tokens[start]] {
+		start++
+	}
+	if start >= len(tokens) {
+		return unknownKind
+	}
+	if tokens[start] != "(" {
+		return kinds[start]
+	}
+	end := matchingParen(tokens, start)
+	if end == -1 {
+		return unknownKind
+	}
+	if start > i+1 {
+		return callKind(tokens, kinds, i+1, end)
+	}
+	return spanKind(tokens, kinds, start+1, end)
+}
+
+// callKind returns the kind of the call of the function at tokens[f],
+// whose closing parenthesis is at tokens[end].
+func callKind(tokens []string, kinds []exprKind, f, end int) exprKind {
+	if kind, ok := exprFunctionKinds[tokens[f]]; ok {
+		return kind
+	}
+	switch tokens[f] {
+	case "COALESCE", "IFNULL", "NULLIF":
+		// These return their first argument, or another argument of the
+		// same type.
+		argEnd := f + 2
+		for depth := 0; argEnd < end; argEnd++ {
+			if tokens[argEnd] == "(" {
+				depth++
+			} else if tokens[argEnd] == ")" {
+				depth--
+			} else if tokens[argEnd] == "," && depth == 0 {
+				break
+			}
+		}
+		return spanKind(tokens, kinds, f+2, argEnd)
+	}
+	return unknownKind
+}
+
+// spanKind returns the kind of the expression tokens[start:end] if all its
+// operands have the same kind and are combined with arithmetic operators,
+// and unknownKind otherwise.
+func spanKind(tokens []string, kinds []exprKind, start, end int) exprKind {
+	kind := unknownKind
+	for j := start; j < end; j++ {
+		var k exprKind
+		switch t := tokens[j]; {
+		case t == "+" || t == "||" || t == "-" || t == "*" || t == "/":
+			continue
+		case t == "(" || exprFunctionNames[t]:
+			open := j
+			if t != "(" {
+				open++
+			}
+			close := matchingParen(tokens, open)
+			if close == -1 || close >= end {
+				return unknownKind
+			}
+			if t == "(" {
+				k = spanKind(tokens, kinds, open+1, close)
+			} else {
+				k = callKind(tokens, kinds, j, close)
+			}
+			j = close
+		default:
+			k = kinds[j]
+		}
+		if k == unknownKind || (kind != unknownKind && k != kind) {
+			return unknownKind
+		}
+		kind = k
+	}
+	return kind
+}
+
+// matchingParen returns the index of the parenthesis closing the one at
+// tokens[open], or -1 if it isn't closed.
+func matchingParen(tokens []string, open int) int {
+	if open >= len(tokens) || tokens[open] != "(" {
+		return -1
+	}
+	depth := 0
+	for j := open; j < len(tokens); j++ {
+		switch tokens[j] {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// exprString converts the string literal at the start of s into a Spanner
+// string literal. It returns the literal and the length of the source
+// literal.
+func exprString(s string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\'' {
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(b.String()) + "'", i + 1, nil
+		}
+		b.WriteByte(s[i])
+	}
+	return "", 0, fmt.Errorf("unterminated string in %q", s)
+}
+
+// joinExprTokens joins the tokens of an expression with spaces, except
+// around parentheses and before commas.
+func joinExprTokens(tokens []string) string {
+	var b strings.Builder
+	for i, t := range tokens {
+		if i > 0 {
+			prev := tokens[i-1]
+			if !(prev == "(" || t == ")" || t == "," || (t == "(" && exprFunctionNames[prev])) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(t)
+	}
+	return b.String()
+}
+
+// exprFunctionNames is the set of Spanner function names in exprFunctions.
+var exprFunctionNames = func() map[string]bool {
+	m := make(map[string]bool)
+	for _, f := range exprFunctions {
+		m[f] = true
+	}
+	return m
+}()
+
+func nextNonSpace(s string, i int) byte {
+	for ; i < len(s); i++ {
+		if s[i] != ' ' && s[i] != '\t' && s[i] != '\n' && s[i] != '\r' {
+			return s[i]
+		}
+	}
+	return 0
+}
+
+func isIdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '@' || c == '#'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '$'
+}
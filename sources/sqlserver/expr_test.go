@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlserver
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToSpannerExpr(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["t"] = schema.Table{
+		Name:     "t",
+		ColNames: []string{"a", "b", "first name", "code", "created"},
+		ColDefs: map[string]schema.Column{
+			"a":          {Name: "a", Type: schema.Type{Name: "int"}},
+			"b":          {Name: "b", Type: schema.Type{Name: "float"}},
+			"first name": {Name: "first name", Type: schema.Type{Name: "nvarchar"}},
+			"code":       {Name: "code", Type: schema.Type{Name: "char", Mods: []int64{3}}},
+			"created":    {Name: "created", Type: schema.Type{Name: "datetime2"}},
+		},
+	}
+	_, err := internal.GetSpannerTable(conv, "t")
+	assert.Nil(t, err)
+	tests := []struct {
+		expr     string
+		ty       ddl.Type
+		expected string
+		errMsg   string
+	}{
+		{"([a]+(1))", ddl.Type{Name: ddl.Int64}, "(`a` + (1))", ""},
+		{"([b]/[a])", ddl.Type{Name: ddl.Float64}, "(`b` / `a`)", ""},
+		{"(upper([first name])+N'it''s \\')", ddl.Type{Name: ddl.String}, "(UPPER(`first_name`) || 'it\\'s \\\\')", ""},
+		{"(case when [a]>=(0) then len([first name]) else NULL end)", ddl.Type{Name: ddl.Int64}, "(CASE WHEN `a` >= (0) THEN CHAR_LENGTH(`first_name`) ELSE NULL END)", ""},
+		{"(isnull(a, 0) * power(b, 2))", ddl.Type{Name: ddl.Float64}, "(IFNULL(`a`, 0) * POW(`b`, 2))", ""},
+		{"(iif([a] not in (1, 2), 'x', 'y'))", ddl.Type{Name: ddl.String}, "(IF(`a` NOT IN (1, 2), 'x', 'y'))", ""},
+		// The operator of + depends on its operands, not on the type of
+		// the column.
+		{"(([first name]+' ')+[code])", ddl.Type{Name: ddl.String}, "((`first_name` || ' ') || `code`)", ""},
+		{"(len([first name])+[a])", ddl.Type{Name: ddl.String}, "(CHAR_LENGTH(`first_name`) + `a`)", ""},
+		{"(isnull([code],'')+lower([first name]))", ddl.Type{Name: ddl.String}, "(IFNULL(`code`, '') || LOWER(`first_name`))", ""},
+		{"([code]+[a])", ddl.Type{Name: ddl.String}, "", "can't tell whether + adds or concatenates"},
+		{"([created]+(1))", ddl.Type{Name: ddl.Timestamp}, "", "can't tell whether + adds or concatenates"},
+		{"(iif([a]>(0),'x','y')+[code])", ddl.Type{Name: ddl.String}, "", "can't tell whether + adds or concatenates"},
+		{"([a]/(2))", ddl.Type{Name: ddl.Int64}, "", "integer division"},
+		{"([a]%(2))", ddl.Type{Name: ddl.Int64}, "", "operator %"},
+		{"(datepart(year,[a]))", ddl.Type{Name: ddl.Int64}, "", "function datepart"},
+		{"([c]+(1))", ddl.Type{Name: ddl.Int64}, "", "unknown column c"},
+		{"('abc)", ddl.Type{Name: ddl.String}, "", "unterminated string"},
+	}
+	for _, tc := range tests {
+		got, err := ToDdlImpl{}.ToSpannerExpr(conv, "t", tc.expr, tc.ty)
+		if tc.errMsg != "" {
+			assert.ErrorContains(t, err, tc.errMsg, tc.expr)
+			continue
+		}
+		assert.Nil(t, err, tc.expr)
+		assert.Equal(t, tc.expected, got, tc.expr)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlserver
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+// maxQueryParams is the maximum number of parameters of a query sent to SQL
+// Server, which supports 2100.
+const maxQueryParams = 2000
+
+// WithPredicates implements the common.IncrementalInfoSchema interface.
+func (isi InfoSchemaImpl) WithPredicates(predicates map[string]common.WatermarkPredicate) common.InfoSchema {
+	isi.Predicates = predicates
+	return isi
+}
+
+// watermarkFilter returns the WHERE clause, and its args, that restricts the
+// rows of srcTable to its predicate. It is empty for the first sync.
+func (isi InfoSchemaImpl) watermarkFilter(srcTable string) (string, []interface{}) {
+	p, ok := isi.Predicates[srcTable]
+	if !ok || p.Low == "" {
+		return "", nil
+	}
+	return fmt.Sprintf(" WHERE [%s] > @p1 AND [%s] <= @p2", p.Col, p.Col), []interface{}{p.Low, p.High}
+}
+
+// GetMaxWatermark implements the common.IncrementalInfoSchema interface.
+// Watermarks are returned in the ISO 8601 format for dates and times, so that
+// SQL Server converts them back when they are compared to the column.
+func (isi InfoSchemaImpl) GetMaxWatermark(conv *internal.Conv, srcTable, col string) (string, error) {
+	tbl := conv.SrcSchema[srcTable]
+	tblName := strings.Replace(srcTable, tbl.Schema+".", "", 1)
+	var s string
+	switch ty := tbl.ColDefs[col].Type.Name; ty {
+	case smallDateTimeType, dateTimeType, dateTime2Type:
+		s = fmt.Sprintf("CONVERT(VARCHAR(33), MAX([%s]), 126)", col)
+	case dateTimeOffsetType:
+		s = fmt.Sprintf("CONVERT(VARCHAR(33), MAX([%s]), 127)", col)
+	case dateType:
+		s = fmt.Sprintf("CONVERT(VARCHAR(10), MAX([%s]), 23)", col)
+	case "tinyint", "smallint", "int", "bigint":
+		s = fmt.Sprintf("CAST(MAX([%s]) AS VARCHAR(20))", col)
+	default:
+		return "", fmt.Errorf("watermark column %s has type %s: only date, time and integer columns are supported", col, ty)
+	}
+	q := fmt.Sprintf("SELECT %s FROM [%s].[%s].[%s];", s, isi.DbName, tbl.Schema, tblName)
+	var max sql.NullString
+	if err := isi.Db.QueryRow(q).Scan(&max); err != nil {
+		return "", err
+	}
+	return max.String, nil
+}
+
+// GetExistingKeys implements the common.IncrementalInfoSchema interface. The
+// keys are compared by SQL Server, with the collation of the key columns.
+func (isi InfoSchemaImpl) GetExistingKeys(conv *internal.Conv, srcTable string, keyCols []string, keys [][]interface{}) ([]bool, error) {
+	tbl := conv.SrcSchema[srcTable]
+	tblName := strings.Replace(srcTable, tbl.Schema+".", "", 1)
+	exists := make([]bool, len(keys))
+	chunk := maxQueryParams / (len(keyCols) + 1)
+	for start := 0; start < len(keys); start += chunk {
+		end := start + chunk
+		if end > len(keys) {
+			end = len(keys)
+		}
+		var values, conds []string
+		var args []interface{}
+		for i := start; i < end; i++ {
+			params := []string{fmt.Sprintf("@p%d", len(args)+1)}
+			args = append(args, i)
+			for _, v := range keys[i] {
+				params = append(params, fmt.Sprintf("@p%d", len(args)+1))
+				args = append(args, v)
+			}
+			values = append(values, "("+strings.Join(params, ", ")+")")
+		}
+		kCols := []string{"i"}
+		for j, c := range keyCols {
+			kCols = append(kCols, fmt.Sprintf("c%d", j))
+			conds = append(conds, fmt.Sprintf("t.[%s] = k.c%d", c, j))
+		}
+		q := fmt.Sprintf("SELECT k.i FROM (VALUES %s) AS k(%s) WHERE EXISTS (SELECT 1 FROM [%s].[%s].[%s] AS t WHERE %s);",
+			strings.Join(values, ", "), strings.Join(kCols, ", "), isi.DbName, tbl.Schema, tblName, strings.Join(conds, " AND "))
+		rows, err := isi.Db.Query(q, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var i int
+			if err := rows.Scan(&i); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			exists[i] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return exists, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlserver
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+func incrementalConv() *internal.Conv {
+	conv := internal.MakeConv()
+	conv.SrcSchema["sales.orders"] = schema.Table{
+		Name:     "sales.orders",
+		Schema:   "sales",
+		ColNames: []string{"id", "code", "updated_at", "note"},
+		ColDefs: map[string]schema.Column{
+			"id":         {Name: "id", Type: schema.Type{Name: "int"}},
+			"code":       {Name: "code", Type: schema.Type{Name: "nvarchar"}},
+			"updated_at": {Name: "updated_at", Type: schema.Type{Name: "datetime2"}},
+			"note":       {Name: "note", Type: schema.Type{Name: "nvarchar"}},
+		},
+	}
+	return conv
+}
+
+func TestIncrementalQueries(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: `SELECT CONVERT\(VARCHAR\(33\), MAX\(\[updated_at\]\), 126\) FROM \[test\]\.\[sales\]\.\[orders\];`,
+			cols:  []string{"max"},
+			rows:  [][]driver.Value{{"2022-11-01T10:00:00.1234567"}},
+		}, {
+			query: `SELECT COUNT\(1\) FROM \[test\]\.\[sales\]\.\[orders\] WHERE \[updated_at\] > @p1 AND \[updated_at\] <= @p2;`,
+			args:  []driver.Value{"2022-10-01T00:00:00", "2022-11-01T10:00:00.1234567"},
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{int64(2)}},
+		}, {
+			query: `SELECT (.+) FROM \[test\]\.\[sales\]\.\[orders\] WHERE \[updated_at\] > @p1 AND \[updated_at\] <= @p2`,
+			args:  []driver.Value{"2022-10-01T00:00:00", "2022-11-01T10:00:00.1234567"},
+			cols:  []string{"id", "code", "updated_at", "note"},
+		}, {
+			query: `SELECT k\.i FROM \(VALUES \(@p1, @p2, @p3\), \(@p4, @p5, @p6\)\) AS k\(i, c0, c1\) WHERE EXISTS \(SELECT 1 FROM \[test\]\.\[sales\]\.\[orders\] AS t WHERE t\.\[id\] = k\.c0 AND t\.\[code\] = k\.c1\);`,
+			args:  []driver.Value{int64(0), int64(1), "a", int64(1), int64(2), "b"},
+			cols:  []string{"i"},
+			rows:  [][]driver.Value{{int64(1)}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := incrementalConv()
+	isi := InfoSchemaImpl{DbName: "test", Db: db}
+
+	wm, err := isi.GetMaxWatermark(conv, "sales.orders", "updated_at")
+	assert.Nil(t, err)
+	assert.Equal(t, "2022-11-01T10:00:00.1234567", wm)
+
+	predicates := map[string]common.WatermarkPredicate{"sales.orders": {Col: "updated_at", Low: "2022-10-01T00:00:00", High: wm}}
+	filtered := isi.WithPredicates(predicates)
+	count, err := filtered.GetRowCount(common.SchemaAndName{Schema: "sales", Name: "orders"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	_, err = filtered.GetRowsFromTable(conv, "sales.orders")
+	assert.Nil(t, err)
+
+	exists, err := isi.GetExistingKeys(conv, "sales.orders", []string{"id", "code"}, [][]interface{}{{int64(1), "a"}, {int64(2), "b"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false, true}, exists)
+
+	_, err = isi.GetMaxWatermark(conv, "sales.orders", "note")
+	assert.NotNil(t, err)
+}
+
+func TestWatermarkFilter_FirstSync(t *testing.T) {
+	isi := InfoSchemaImpl{Predicates: map[string]common.WatermarkPredicate{"orders": {Col: "updated_at", High: "2022-11-01"}}}
+	where, args := isi.watermarkFilter("orders")
+	assert.Equal(t, "", where)
+	assert.Nil(t, args)
+}
@@ -35,17 +35,25 @@ const (
 	geometryType       string = "geometry"
 	timeType           string = "time"
 	hierarchyIdType    string = "hierarchyid"
-	timestampType      string = "timestamp"
 	dateTimeType       string = "datetime"
 	dateTime2Type      string = "datetime2"
 	dateTimeOffsetType string = "datetimeoffset"
 	smallDateTimeType  string = "smalldatetime"
 	dateType           string = "date"
+	// tzOffsetType is the type of the columns we add to hold the time
+	// zone offsets of datetimeoffset columns (see addOffsetColumn).
+	tzOffsetType string = "tzoffset"
 )
 
 type InfoSchemaImpl struct {
 	DbName string
 	Db     *sql.DB
+	// KeepOffset keeps the time zone offsets of datetimeoffset values
+	// (which are converted to UTC timestamps) in separate columns.
+	KeepOffset bool
+	// Predicates restrict the rows read from each table to those that
+	// changed since the previous incremental sync (see WithPredicates).
+	Predicates map[string]common.WatermarkPredicate
 }
 
 // GetToDdl function below implement the common.InfoSchema interface.
@@ -116,13 +124,39 @@ func (isi InfoSchemaImpl) GetRowsFromTable(conv *internal.Conv, srcTable string)
 	tblName := strings.Replace(srcTable, tbl.Schema+".", "", 1)
 
 	q := getSelectQuery(isi.DbName, tbl.Schema, tblName, tbl.ColNames, tbl.ColDefs)
-	rows, err := isi.Db.Query(q)
+	where, args := isi.watermarkFilter(srcTable)
+	rows, err := isi.Db.Query(q+where, args...)
 	if err != nil {
 		return nil, err
 	}
 	return rows, err
 }
 
+// ProfileTable implements the common.ProfilingInfoSchema interface.
+func (isi InfoSchemaImpl) ProfileTable(conv *internal.Conv, srcTable string, cols []common.ProfileColumn) (map[string]*internal.ColumnProfile, error) {
+	tbl := conv.SrcSchema[srcTable]
+	tblName := strings.Replace(srcTable, tbl.Schema+".", "", 1)
+	var profiled []common.ProfileColumn
+	for _, c := range cols {
+		switch tbl.ColDefs[c.Name].Type.Name {
+		case tzOffsetType:
+			// Offset columns are computed from their datetimeoffset column.
+			continue
+		case geometryType, geographyType:
+			c.Text = false
+		}
+		profiled = append(profiled, c)
+	}
+	quote := func(col string) string { return "[" + strings.ReplaceAll(col, "]", "]]") + "]" }
+	// LEN ignores trailing spaces.
+	q := common.ProfileQuery(fmt.Sprintf("[%s].[%s].[%s]", isi.DbName, tbl.Schema, tblName), profiled, quote, "(LEN(CAST(%s AS NVARCHAR(MAX)) + N'x') - 1)")
+	rows, err := isi.Db.Query(q)
+	if err != nil {
+		return nil, err
+	}
+	return common.ScanProfile(rows, profiled)
+}
+
 func getSelectQuery(srcDb string, schemaName string, tableName string, colNames []string, colDefs map[string]schema.Column) string {
 	var selects = make([]string, len(colNames))
 
@@ -137,8 +171,8 @@ func getSelectQuery(srcDb string, schemaName string, tableName string, colNames
 			s = fmt.Sprintf("CAST([%s] AS VARCHAR(4000)) AS %s", cn, cn)
 		case timeType:
 			s = fmt.Sprintf("CAST([%s] AS VARCHAR(12)) AS %s", cn, cn)
-		case timestampType:
-			s = fmt.Sprintf("CAST([%s] AS BIGINT) AS %s", cn, cn)
+		case tzOffsetType:
+			s = fmt.Sprintf("DATENAME(TZOFFSET, [%s]) AS [%s]", strings.TrimSuffix(cn, offsetSuffix), cn)
 		case smallDateTimeType, dateTimeType, dateTime2Type, dateTimeOffsetType:
 			s = fmt.Sprintf("CONVERT(VARCHAR(33), [%s], 126) AS %s", cn, cn)
 		case dateType:
@@ -166,8 +200,9 @@ func buildVals(n int) (v []interface{}, iv []interface{}) {
 
 // GetRowCount with number of rows in each table.
 func (isi InfoSchemaImpl) GetRowCount(table common.SchemaAndName) (int64, error) {
-	q := fmt.Sprintf(`SELECT COUNT(1) FROM [%s].[%s].[%s];`, isi.DbName, table.Schema, table.Name)
-	rows, err := isi.Db.Query(q)
+	where, args := isi.watermarkFilter(isi.GetTableName(table.Schema, table.Name))
+	q := fmt.Sprintf(`SELECT COUNT(1) FROM [%s].[%s].[%s]%s;`, isi.DbName, table.Schema, table.Name, where)
+	rows, err := isi.Db.Query(q, args...)
 	if err != nil {
 		return 0, err
 	}
@@ -210,16 +245,23 @@ func (isi InfoSchemaImpl) GetTables() ([]common.SchemaAndName, error) {
 func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAndName, constraints map[string][]string, primaryKeys []string) (map[string]schema.Column, []string, error) {
 	q := `
 		SELECT 
-			column_name, 
-			data_type, 
-			is_nullable, 
-			column_default, 
-			character_maximum_length, 
-			numeric_precision, 
-			numeric_scale
-		FROM information_schema.COLUMNS 
-		WHERE table_schema = @p1 and table_name = @p2 
-		ORDER BY ordinal_position;
+			c.column_name, 
+			c.data_type, 
+			c.is_nullable, 
+			c.column_default, 
+			c.character_maximum_length, 
+			c.numeric_precision, 
+			c.numeric_scale,
+			cc.definition,
+			CAST(ic.seed_value AS BIGINT),
+			CAST(ic.increment_value AS BIGINT)
+		FROM information_schema.COLUMNS AS c
+		LEFT JOIN sys.computed_columns AS cc
+			ON cc.object_id = OBJECT_ID(QUOTENAME(c.table_schema) + '.' + QUOTENAME(c.table_name)) AND cc.name = c.column_name
+		LEFT JOIN sys.identity_columns AS ic
+			ON ic.object_id = OBJECT_ID(QUOTENAME(c.table_schema) + '.' + QUOTENAME(c.table_name)) AND ic.name = c.column_name
+		WHERE c.table_schema = @p1 and c.table_name = @p2 
+		ORDER BY c.ordinal_position;
 	`
 	cols, err := isi.Db.Query(q, table.Schema, table.Name)
 	if err != nil {
@@ -229,11 +271,13 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 	var colNames []string
 	var colName, dataType string
 	var isNullable string
-	var colDefault sql.NullString
+	var colDefault, computed sql.NullString
 	// elementDataType
 	var charMaxLen, numericPrecision, numericScale sql.NullInt64
+	var identitySeed, identityIncrement sql.NullInt64
+	var offsetCols []string
 	for cols.Next() {
-		err := cols.Scan(&colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale)
+		err := cols.Scan(&colName, &dataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale, &computed, &identitySeed, &identityIncrement)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
@@ -252,13 +296,27 @@ func (isi InfoSchemaImpl) GetColumns(conv *internal.Conv, table common.SchemaAnd
 		}
 		ignored.Default = colDefault.Valid
 		c := schema.Column{
-			Name:    colName,
-			Type:    toType(dataType, charMaxLen, numericPrecision, numericScale),
-			NotNull: strings.ToUpper(isNullable) == "NO",
-			Ignored: ignored,
+			Name:      colName,
+			Type:      toType(dataType, charMaxLen, numericPrecision, numericScale),
+			NotNull:   strings.ToUpper(isNullable) == "NO",
+			Ignored:   ignored,
+			Generated: computed.String,
+		}
+		if identitySeed.Valid && identityIncrement.Valid {
+			c.Ignored.Identity = true
+			c.Identity = &schema.Identity{Seed: identitySeed.Int64, Increment: identityIncrement.Int64}
 		}
 		colDefs[colName] = c
 		colNames = append(colNames, colName)
+		if isi.KeepOffset && dataType == dateTimeOffsetType {
+			offsetCols = append(offsetCols, colName)
+		}
+	}
+	for _, col := range offsetCols {
+		if !addOffsetColumn(colDefs, &colNames, col) {
+			conv.Unexpected(fmt.Sprintf("Can't keep the time zone offsets of column %s of table %s.%s, since the table already has a column %s",
+				col, table.Schema, table.Name, col+offsetSuffix))
+		}
 	}
 	return colDefs, colNames, nil
 }
@@ -415,6 +473,23 @@ func (isi InfoSchemaImpl) GetIndexes(conv *internal.Conv, table common.SchemaAnd
 	return indexes, nil
 }
 
+// offsetSuffix is appended to the name of a datetimeoffset column to name
+// the column holding its offsets.
+const offsetSuffix = "_offset"
+
+// addOffsetColumn adds a column holding the time zone offsets (e.g.
+// "+01:00") of datetimeoffset column col. It returns false if the table
+// already has a column with that name, in which case no column is added.
+func addOffsetColumn(colDefs map[string]schema.Column, colNames *[]string, col string) bool {
+	name := col + offsetSuffix
+	if _, ok := colDefs[name]; ok {
+		return false
+	}
+	colDefs[name] = schema.Column{Name: name, Type: schema.Type{Name: tzOffsetType}}
+	*colNames = append(*colNames, name)
+	return true
+}
+
 func toType(dataType string, charLen sql.NullInt64, numericPrecision, numericScale sql.NullInt64) schema.Type {
 	switch {
 	case charLen.Valid:
@@ -15,13 +15,19 @@
 package sqlserver
 
 import (
+	"bufio"
+	"bytes"
 	"database/sql"
 	"database/sql/driver"
+	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/proto/migration"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 	"github.com/stretchr/testify/assert"
@@ -72,11 +78,11 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "user"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "computed_definition", "identity_seed", "identity_increment"},
 			rows: [][]driver.Value{
-				{"user_id", "text", "NO", nil, nil, nil, nil},
-				{"name", "text", "NO", nil, nil, nil, nil},
-				{"ref", "bigint", "YES", nil, nil, nil, nil}},
+				{"user_id", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"name", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"ref", "bigint", "YES", nil, nil, nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"dbo", "test"},
@@ -96,45 +102,45 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "test"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "computed_definition", "identity_seed", "identity_increment"},
 			rows: [][]driver.Value{
-				{"Id", "int", "NO", nil, nil, 10, 0},
-				{"BigInt", "bigint", "YES", nil, nil, 19, 0},
-				{"Binary", "binary", "YES", nil, 50, nil, nil},
-				{"Bit", "bit", "YES", nil, nil, nil, nil},
-				{"Char", "char", "YES", nil, 10, nil, nil},
-				{"Date", "date", "YES", nil, nil, nil, nil},
-				{"DateTime", "datetime", "YES", nil, nil, nil, nil},
-				{"DateTime2", "datetime2", "YES", nil, nil, nil, nil},
-				{"DateTimeOffset", "datetimeoffset", "YES", nil, nil, nil, nil},
-				{"Decimal", "decimal", "YES", nil, nil, 18, 9},
-				{"Float", "float", "YES", nil, nil, 53, nil},
-				{"Geography", "geography", "YES", nil, -1, nil, nil},
-				{"Geometry", "geometry", "YES", nil, -1, nil, nil},
-				{"HierarchyId", "hierarchyid", "YES", nil, 892, nil, nil},
-				{"Image", "image", "YES", nil, 2147483647, nil, nil},
-				{"Int", "int", "YES", nil, nil, 10, 0},
-				{"Money", "money", "YES", nil, nil, 19, 4},
-				{"NChar", "nchar", "YES", nil, 10, nil, nil},
-				{"NText", "ntext", "YES", nil, 1073741823, nil, nil},
-				{"Numeric", "numeric", "YES", nil, nil, 18, 17},
-				{"NVarChar", "nvarchar", "YES", nil, 50, nil, nil},
-				{"NVarCharMax", "nvarchar", "YES", nil, -1, nil, nil},
-				{"Real", "real", "YES", nil, nil, 24, nil},
-				{"SmallDateTime", "smalldatetime", "YES", nil, nil, nil, nil},
-				{"SmallInt", "smallint", "YES", nil, nil, 5, 0},
-				{"SmallMoney", "smallmoney", "YES", nil, nil, 10, 4},
-				{"SQLVariant", "sql_variant", "YES", nil, 0, nil, nil},
-				{"Text", "text", "YES", nil, 2147483647, nil, nil},
-				{"Time", "time", "YES", nil, nil, nil, nil},
-				{"TimeStamp", "timestamp", "YES", nil, nil, nil, nil},
-				{"TinyInt", "tinyint", "YES", nil, nil, 3, 0},
-				{"UniqueIdentifier", "uniqueidentifier", "YES", nil, nil, nil, nil},
-				{"VarBinary", "varbinary", "YES", nil, 50, nil, nil},
-				{"VarBinaryMax", "varbinary", "YES", nil, -1, nil, nil},
-				{"VarChar", "varchar", "YES", nil, 50, nil, nil},
-				{"VarCharMax", "varchar", "YES", nil, -1, nil, nil},
-				{"Xml", "xml", "YES", nil, -1, nil, nil},
+				{"Id", "int", "NO", nil, nil, 10, 0, nil, nil, nil},
+				{"BigInt", "bigint", "YES", nil, nil, 19, 0, nil, nil, nil},
+				{"Binary", "binary", "YES", nil, 50, nil, nil, nil, nil, nil},
+				{"Bit", "bit", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"Char", "char", "YES", nil, 10, nil, nil, nil, nil, nil},
+				{"Date", "date", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"DateTime", "datetime", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"DateTime2", "datetime2", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"DateTimeOffset", "datetimeoffset", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"Decimal", "decimal", "YES", nil, nil, 18, 9, nil, nil, nil},
+				{"Float", "float", "YES", nil, nil, 53, nil, nil, nil, nil},
+				{"Geography", "geography", "YES", nil, -1, nil, nil, nil, nil, nil},
+				{"Geometry", "geometry", "YES", nil, -1, nil, nil, nil, nil, nil},
+				{"HierarchyId", "hierarchyid", "YES", nil, 892, nil, nil, nil, nil, nil},
+				{"Image", "image", "YES", nil, 2147483647, nil, nil, nil, nil, nil},
+				{"Int", "int", "YES", nil, nil, 10, 0, nil, nil, nil},
+				{"Money", "money", "YES", nil, nil, 19, 4, nil, nil, nil},
+				{"NChar", "nchar", "YES", nil, 10, nil, nil, nil, nil, nil},
+				{"NText", "ntext", "YES", nil, 1073741823, nil, nil, nil, nil, nil},
+				{"Numeric", "numeric", "YES", nil, nil, 18, 17, nil, nil, nil},
+				{"NVarChar", "nvarchar", "YES", nil, 50, nil, nil, nil, nil, nil},
+				{"NVarCharMax", "nvarchar", "YES", nil, -1, nil, nil, nil, nil, nil},
+				{"Real", "real", "YES", nil, nil, 24, nil, nil, nil, nil},
+				{"SmallDateTime", "smalldatetime", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"SmallInt", "smallint", "YES", nil, nil, 5, 0, nil, nil, nil},
+				{"SmallMoney", "smallmoney", "YES", nil, nil, 10, 4, nil, nil, nil},
+				{"SQLVariant", "sql_variant", "YES", nil, 0, nil, nil, nil, nil, nil},
+				{"Text", "text", "YES", nil, 2147483647, nil, nil, nil, nil, nil},
+				{"Time", "time", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"TimeStamp", "timestamp", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"TinyInt", "tinyint", "YES", nil, nil, 3, 0, nil, nil, nil},
+				{"UniqueIdentifier", "uniqueidentifier", "YES", nil, nil, nil, nil, nil, nil, nil},
+				{"VarBinary", "varbinary", "YES", nil, 50, nil, nil, nil, nil, nil},
+				{"VarBinaryMax", "varbinary", "YES", nil, -1, nil, nil, nil, nil, nil},
+				{"VarChar", "varchar", "YES", nil, 50, nil, nil, nil, nil, nil},
+				{"VarCharMax", "varchar", "YES", nil, -1, nil, nil, nil, nil, nil},
+				{"Xml", "xml", "YES", nil, -1, nil, nil, nil, nil, nil},
 			},
 		},
 
@@ -166,11 +172,11 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "cart"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "computed_definition", "identity_seed", "identity_increment"},
 			rows: [][]driver.Value{
-				{"productid", "text", "NO", nil, nil, nil, nil},
-				{"userid", "text", "NO", nil, nil, nil, nil},
-				{"quantity", "bigint", "YES", nil, nil, 64, 0}},
+				{"productid", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"userid", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"quantity", "bigint", "YES", nil, nil, 64, 0, nil, nil, nil}},
 		},
 
 		{
@@ -191,10 +197,10 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"production", "product"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "computed_definition", "identity_seed", "identity_increment"},
 			rows: [][]driver.Value{
-				{"product_id", "text", "NO", nil, nil, nil, nil},
-				{"product_name", "text", "NO", nil, nil, nil, nil},
+				{"product_id", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"product_name", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
 			},
 		},
 
@@ -217,17 +223,17 @@ func TestProcessSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"dbo", "test_ref"},
-			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "computed_definition", "identity_seed", "identity_increment"},
 			rows: [][]driver.Value{
-				{"ref_id", "bigint", "NO", nil, nil, 64, 0},
-				{"ref_txt", "text", "NO", nil, nil, nil, nil},
-				{"abc", "text", "NO", nil, nil, nil, nil},
+				{"ref_id", "bigint", "NO", nil, nil, 64, 0, nil, nil, nil},
+				{"ref_txt", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"abc", "text", "NO", nil, nil, nil, nil, nil, nil, nil},
 			},
 		},
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
-	err := common.ProcessSchema(conv, InfoSchemaImpl{"test", db}, 1)
+	err := common.ProcessSchema(conv, InfoSchemaImpl{DbName: "test", Db: db}, 1)
 	assert.Nil(t, err)
 	expectedSchema := map[string]ddl.CreateTable{
 		"user": {
@@ -277,7 +283,7 @@ func TestProcessSchema(t *testing.T) {
 				"SQLVariant":       {Name: "SQLVariant", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
 				"Text":             {Name: "Text", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
 				"Time":             {Name: "Time", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
-				"TimeStamp":        {Name: "TimeStamp", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
+				"TimeStamp":        {Name: "TimeStamp", T: ddl.Type{Name: ddl.Bytes, Len: 8}, NotNull: false},
 				"TinyInt":          {Name: "TinyInt", T: ddl.Type{Name: ddl.Int64}, NotNull: false},
 				"UniqueIdentifier": {Name: "UniqueIdentifier", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: false},
 				"VarBinary":        {Name: "VarBinary", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, NotNull: false},
@@ -328,6 +334,98 @@ func TestProcessSchema(t *testing.T) {
 
 }
 
+func TestProcessSchema_ComputedIdentityOffset(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: `SELECT (.+) WHERE TBL.type = 'U' AND TBL.is_tracked_by_cdc = 0 AND TBL.is_ms_shipped = 0 AND TBL.name <> 'sysdiagrams'`,
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"sales", "orders"}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"sales", "orders"},
+			cols:  []string{"column_name", "constraint_type"},
+			rows:  [][]driver.Value{{"id", "PRIMARY KEY"}},
+		}, {
+			query: "SELECT (.+) FROM sys.foreign_keys AS FK (.+)",
+			args:  []driver.Value{"sales.orders"},
+			cols:  []string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "REF_COLUMN_NAME", "CONSTRAINT_NAME"},
+		}, {
+			query: "SELECT (.+) FROM sys.indexes (.+)",
+			args:  []driver.Value{"orders", "sales"},
+			cols:  []string{"index_name", "column_name", "is_unique", "order", "is_included_column"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"sales", "orders"},
+			cols:  []string{"column_name", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "computed_definition", "identity_seed", "identity_increment"},
+			rows: [][]driver.Value{
+				{"id", "int", "NO", nil, nil, 10, 0, nil, 1000, 10},
+				{"qty", "int", "NO", nil, nil, 10, 0, nil, nil, nil},
+				{"price", "decimal", "NO", nil, nil, 10, 2, nil, nil, nil},
+				{"total", "decimal", "YES", nil, nil, 21, 2, "([qty]*[price])", nil, nil},
+				{"label", "nvarchar", "YES", nil, 60, nil, nil, "(isnull(upper([code]),N'none')+'-x')", nil, nil},
+				{"code", "nvarchar", "YES", nil, 10, nil, nil, nil, nil, nil},
+				{"half", "int", "YES", nil, nil, 10, 0, "([qty]/(2))", nil, nil},
+				{"created", "datetimeoffset", "NO", nil, nil, nil, nil, nil, nil, nil},
+				{"version", "timestamp", "NO", nil, nil, nil, nil, nil, nil, nil},
+			},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	err := common.ProcessSchema(conv, InfoSchemaImpl{DbName: "test", Db: db, KeepOffset: true}, 1)
+	assert.Nil(t, err)
+	expected := ddl.CreateTable{
+		Name:     "sales_orders",
+		ColNames: []string{"id", "qty", "price", "total", "label", "code", "half", "created", "version", "created_offset"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":             {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"qty":            {Name: "qty", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"price":          {Name: "price", T: ddl.Type{Name: ddl.Numeric}, NotNull: true},
+			"total":          {Name: "total", T: ddl.Type{Name: ddl.Numeric}, Generated: "(`qty` * `price`)"},
+			"label":          {Name: "label", T: ddl.Type{Name: ddl.String, Len: 60}, Generated: "(IFNULL(UPPER(`code`), 'none') || '-x')"},
+			"code":           {Name: "code", T: ddl.Type{Name: ddl.String, Len: 10}},
+			"half":           {Name: "half", T: ddl.Type{Name: ddl.Int64}},
+			"created":        {Name: "created", T: ddl.Type{Name: ddl.Timestamp}, NotNull: true},
+			"version":        {Name: "version", T: ddl.Type{Name: ddl.Bytes, Len: 8}, NotNull: true},
+			"created_offset": {Name: "created_offset", T: ddl.Type{Name: ddl.String, Len: 6}},
+		},
+		Pks: []ddl.IndexKey{{Col: "id"}},
+	}
+	assert.Equal(t, expected, stripSchemaComments(conv.SpSchema)["sales_orders"])
+	assert.Equal(t, &schema.Identity{Seed: 1000, Increment: 10}, conv.SrcSchema["sales.orders"].ColDefs["id"].Identity)
+	issues := conv.Issues["sales.orders"]
+	assert.Contains(t, issues["id"], internal.Identity)
+	assert.Equal(t, []internal.SchemaIssue{internal.ComputedColumn}, issues["total"])
+	assert.Equal(t, []internal.SchemaIssue{internal.ComputedColumn}, issues["label"])
+	assert.Contains(t, issues["half"], internal.ComputedNotTranslated)
+	assert.Equal(t, []internal.SchemaIssue{internal.DatetimeOffset}, issues["created"])
+	assert.Empty(t, issues["version"])
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+
+	conv.Audit = internal.Audit{MigrationType: migration.MigrationData_SCHEMA_ONLY.Enum()}
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.SQLSERVER, conv, w, nil, true, true)
+	w.Flush()
+	report := strings.Join(strings.Fields(buf.String()), " ")
+	assert.Contains(t, report, "Column 'id' is an IDENTITY(1000, 10) column.")
+	assert.Contains(t, report, "Column 'half': Expression of computed column can't be translated")
+}
+
+func TestAddOffsetColumn(t *testing.T) {
+	colDefs := map[string]schema.Column{
+		"created": {Name: "created", Type: schema.Type{Name: dateTimeOffsetType}},
+		"updated": {Name: "updated", Type: schema.Type{Name: dateTimeOffsetType}},
+		// A column that has the name of the offset column of updated.
+		"updated_offset": {Name: "updated_offset", Type: schema.Type{Name: "int"}},
+	}
+	colNames := []string{"created", "updated", "updated_offset"}
+	assert.True(t, addOffsetColumn(colDefs, &colNames, "created"))
+	assert.False(t, addOffsetColumn(colDefs, &colNames, "updated"))
+	assert.Equal(t, []string{"created", "updated", "updated_offset", "created_offset"}, colNames)
+	assert.Equal(t, "int", colDefs["updated_offset"].Type.Name)
+}
+
 func mkMockDB(t *testing.T, ms []mockSpec) *sql.DB {
 	db, mock, err := sqlmock.New()
 	assert.Nil(t, err)
@@ -143,19 +143,33 @@ func toSpannerTypeInternal(srcType schema.Type, spType string) (ddl.Type, []inte
 		default:
 			return ddl.Type{Name: ddl.Date}, nil
 		}
-	case "datetime2", "datetime", "datetimeoffset", "smalldatetime", "rowversion":
+	case "datetime2", "datetime", "smalldatetime":
 		switch spType {
 		case ddl.String:
 			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
 		default:
 			return ddl.Type{Name: ddl.Timestamp}, []internal.SchemaIssue{internal.Timestamp}
 		}
-	case "timestamp":
+	case "datetimeoffset":
 		switch spType {
 		case ddl.String:
 			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
 		default:
-			return ddl.Type{Name: ddl.Int64}, nil
+			// Values are converted to UTC, which is what Spanner
+			// stores, so there is no loss of precision.
+			return ddl.Type{Name: ddl.Timestamp}, []internal.SchemaIssue{internal.DatetimeOffset}
+		}
+	case tzOffsetType:
+		return ddl.Type{Name: ddl.String, Len: 6}, nil
+	case "timestamp", "rowversion":
+		// rowversion (whose information_schema type is timestamp) is an
+		// 8-byte binary value incremented on each update, unrelated to
+		// date and time. It isn't a key, so it doesn't cause hotspots.
+		switch spType {
+		case ddl.String:
+			return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Widened}
+		default:
+			return ddl.Type{Name: ddl.Bytes, Len: 8}, nil
 		}
 	case "time":
 		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.Time}
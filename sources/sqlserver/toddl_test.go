@@ -88,7 +88,7 @@ func TestToSpannerType(t *testing.T) {
 			"c": {Name: "c", T: ddl.Type{Name: ddl.Int64}},
 			"d": {Name: "d", T: ddl.Type{Name: ddl.String, Len: int64(6)}},
 			"e": {Name: "e", T: ddl.Type{Name: ddl.Numeric}},
-			"f": {Name: "f", T: ddl.Type{Name: ddl.Int64}},
+			"f": {Name: "f", T: ddl.Type{Name: ddl.Bytes, Len: 8}},
 			"g": {Name: "g", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
 			"h": {Name: "h", T: ddl.Type{Name: ddl.Date}},
 			"i": {Name: "i", T: ddl.Type{Name: ddl.Numeric}},
@@ -180,7 +180,7 @@ func TestToExperimentalSpannerType(t *testing.T) {
 			"c": {Name: "c", T: ddl.Type{Name: ddl.Int64}},
 			"d": {Name: "d", T: ddl.Type{Name: ddl.String, Len: int64(6)}},
 			"e": {Name: "e", T: ddl.Type{Name: ddl.Numeric}},
-			"f": {Name: "f", T: ddl.Type{Name: ddl.Int64}},
+			"f": {Name: "f", T: ddl.Type{Name: ddl.Bytes, Len: 8}},
 			"g": {Name: "g", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
 			"h": {Name: "h", T: ddl.Type{Name: ddl.Date}},
 			"i": {Name: "i", T: ddl.Type{Name: ddl.Numeric}},
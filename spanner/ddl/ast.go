@@ -157,13 +157,14 @@ func (ty Type) PGPrintColumnDefType() string {
 // ColumnDef encodes the following DDL definition:
 //
 //	column_def:
-//	  column_name type [NOT NULL] [options_def]
+//	  column_name type [NOT NULL] [AS ( expression ) STORED] [options_def]
 type ColumnDef struct {
-	Name    string
-	T       Type
-	NotNull bool
-	Comment string
-	Id      string
+	Name      string
+	T         Type
+	NotNull   bool
+	Comment   string
+	Id        string
+	Generated string // Expression of a stored generated column, if any.
 }
 
 // Config controls how AST nodes are printed (aka unparsed).
@@ -199,6 +200,13 @@ func (cd ColumnDef) PrintColumnDef(c Config) (string, string) {
 	if cd.NotNull {
 		s += " NOT NULL"
 	}
+	if cd.Generated != "" {
+		if c.TargetDb == constants.TargetExperimentalPostgres {
+			s += fmt.Sprintf(" GENERATED ALWAYS AS (%s) STORED", cd.Generated)
+		} else {
+			s += fmt.Sprintf(" AS (%s) STORED", cd.Generated)
+		}
+	}
 	return s, cd.Comment
 }
 
@@ -250,9 +258,25 @@ func (k Foreignkey) PrintForeignKey(c Config) string {
 	return s + fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", strings.Join(cols, ", "), c.quote(k.ReferTable), strings.Join(referCols, ", "))
 }
 
+// CheckConstraint encodes the following DDL definition:
+//
+//	CONSTRAINT constraint_name CHECK ( expression )
+//
+// Expr is printed as is, so it must already use the syntax of the target
+// dialect.
+type CheckConstraint struct {
+	Name string
+	Expr string
+}
+
+// PrintCheckConstraint unparses the check constraint.
+func (cc CheckConstraint) PrintCheckConstraint(c Config) string {
+	return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", c.quote(cc.Name), cc.Expr)
+}
+
 // CreateTable encodes the following DDL definition:
 //
-//	create_table: CREATE TABLE table_name ([column_def, ...] ) primary_key [, cluster]
+//	create_table: CREATE TABLE table_name ([column_def, ...] [, check_constraint, ...]) primary_key [, cluster]
 type CreateTable struct {
 	Name     string
 	ColNames []string             // Provides names and order of columns
@@ -263,6 +287,7 @@ type CreateTable struct {
 	Parent   string //if not empty, this table will be interleaved
 	Comment  string
 	Id       string
+	Checks   []CheckConstraint `json:",omitempty"`
 }
 
 // PrintCreateTable unparses a CREATE TABLE statement.
@@ -286,6 +311,9 @@ func (ct CreateTable) PrintCreateTable(config Config) string {
 		}
 		cols += "\n"
 	}
+	for _, cc := range ct.Checks {
+		cols += "\t" + cc.PrintCheckConstraint(config) + ",\n"
+	}
 
 	orderedPks := []IndexKey{}
 	orderedPks = append(orderedPks, ct.Pks...)
@@ -363,6 +391,52 @@ func (ci CreateIndex) PrintCreateIndex(c Config) string {
 	return fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)%s", unique, c.quote(ci.Name), c.quote(ci.Table), strings.Join(keys, ", "), storingClause)
 }
 
+// Sequence encodes the following DDL definition:
+//
+//	create sequence: CREATE SEQUENCE sequence_name OPTIONS ( sequence_kind = 'bit_reversed_positive' [, start_with_counter = n] )
+type Sequence struct {
+	Name string
+	// StartWithCounter is the first value of the sequence's internal
+	// counter (values are the bit-reversed counter), if set.
+	StartWithCounter int64
+	Comment          string
+}
+
+// PrintCreateSequence unparses a CREATE SEQUENCE statement.
+func (sq Sequence) PrintCreateSequence(c Config) string {
+	var comment string
+	if c.Comments && sq.Comment != "" {
+		comment = "-- " + sq.Comment + "\n"
+	}
+	if c.TargetDb == constants.TargetExperimentalPostgres {
+		var start string
+		if sq.StartWithCounter > 0 {
+			start = fmt.Sprintf(" START COUNTER WITH %d", sq.StartWithCounter)
+		}
+		return fmt.Sprintf("%sCREATE SEQUENCE %s BIT_REVERSED_POSITIVE%s", comment, c.quote(sq.Name), start)
+	}
+	options := []string{"sequence_kind = 'bit_reversed_positive'"}
+	if sq.StartWithCounter > 0 {
+		options = append(options, fmt.Sprintf("start_with_counter = %d", sq.StartWithCounter))
+	}
+	return fmt.Sprintf("%sCREATE SEQUENCE %s OPTIONS (%s)", comment, c.quote(sq.Name), strings.Join(options, ", "))
+}
+
+// GetSequenceDDL returns the CREATE SEQUENCE statements of seqs, ordered by
+// sequence name.
+func GetSequenceDDL(seqs map[string]Sequence, c Config) []string {
+	var names []string
+	for n := range seqs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	var ddl []string
+	for _, n := range names {
+		ddl = append(ddl, seqs[n].PrintCreateSequence(c))
+	}
+	return ddl
+}
+
 // PrintForeignKeyAlterTable unparses the foreign keys using ALTER TABLE.
 func (k Foreignkey) PrintForeignKeyAlterTable(c Config, tableName string) string {
 	var cols, referCols []string
@@ -71,6 +71,7 @@ func TestPrintColumnDef(t *testing.T) {
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, NotNull: true}, expected: "col1 INT64 NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64, IsArray: true}, NotNull: true}, expected: "col1 ARRAY<INT64> NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}}, protectIds: true, expected: "`col1` INT64"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, NotNull: true, Generated: "a + b"}, expected: "col1 INT64 NOT NULL AS (a + b) STORED"},
 	}
 	for _, tc := range tests {
 		s, _ := tc.in.PrintColumnDef(Config{ProtectIds: tc.protectIds})
@@ -89,6 +90,7 @@ func TestPrintColumnDefPG(t *testing.T) {
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, NotNull: true}, expected: "col1 INT8 NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64, IsArray: true}, NotNull: true}, expected: "col1 VARCHAR(2621440) NOT NULL"},
 		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}}, protectIds: true, expected: "col1 INT8"},
+		{in: ColumnDef{Name: "col1", T: Type{Name: Int64}, Generated: "a + b"}, expected: "col1 INT8 GENERATED ALWAYS AS (a + b) STORED"},
 	}
 	for _, tc := range tests {
 		s, _ := tc.in.PrintColumnDef(Config{ProtectIds: tc.protectIds, TargetDb: constants.TargetExperimentalPostgres})
@@ -128,6 +130,7 @@ func TestPrintCreateTable(t *testing.T) {
 		"",
 		"",
 		"1",
+		nil,
 	}
 	t2 := CreateTable{
 		"mytable",
@@ -139,7 +142,10 @@ func TestPrintCreateTable(t *testing.T) {
 		"parent",
 		"",
 		"1",
+		nil,
 	}
+	t3 := t1
+	t3.Checks = []CheckConstraint{{Name: "CK_mytable_col2", Expr: "`col2` IN ('a', 'b')"}}
 	tests := []struct {
 		name       string
 		protectIds bool
@@ -177,6 +183,17 @@ func TestPrintCreateTable(t *testing.T) {
 				") PRIMARY KEY (col1 DESC),\n" +
 				"INTERLEAVE IN PARENT parent",
 		},
+		{
+			"check constraint",
+			false,
+			t3,
+			"CREATE TABLE mytable (\n" +
+				"	col1 INT64 NOT NULL,\n" +
+				"	col2 STRING(MAX),\n" +
+				"	col3 BYTES(42),\n" +
+				"	CONSTRAINT CK_mytable_col2 CHECK (`col2` IN ('a', 'b')),\n" +
+				") PRIMARY KEY (col1 DESC)",
+		},
 	}
 	for _, tc := range tests {
 		assert.Equal(t, tc.expected, tc.ct.PrintCreateTable(Config{ProtectIds: tc.protectIds}))
@@ -198,6 +215,7 @@ func TestPrintCreateTablePG(t *testing.T) {
 		"",
 		"",
 		"1",
+		nil,
 	}
 	t2 := CreateTable{
 		"mytable",
@@ -209,7 +227,10 @@ func TestPrintCreateTablePG(t *testing.T) {
 		"parent",
 		"",
 		"1",
+		nil,
 	}
+	t3 := t1
+	t3.Checks = []CheckConstraint{{Name: "CK_mytable_col2", Expr: "col2 IN ('a', 'b')"}}
 	tests := []struct {
 		name       string
 		protectIds bool
@@ -249,6 +270,18 @@ func TestPrintCreateTablePG(t *testing.T) {
 				"	PRIMARY KEY (col1 DESC)\n" +
 				") INTERLEAVE IN PARENT parent",
 		},
+		{
+			"check constraint",
+			false,
+			t3,
+			"CREATE TABLE mytable (\n" +
+				"	col1 INT8 NOT NULL,\n" +
+				"	col2 VARCHAR(2621440),\n" +
+				"	col3 BYTEA,\n" +
+				"	CONSTRAINT CK_mytable_col2 CHECK (col2 IN ('a', 'b')),\n" +
+				"	PRIMARY KEY (col1 DESC)\n" +
+				")",
+		},
 	}
 	for _, tc := range tests {
 		assert.Equal(t, tc.expected, tc.ct.PrintCreateTable(Config{ProtectIds: tc.protectIds, TargetDb: constants.TargetExperimentalPostgres}))
@@ -291,6 +324,28 @@ func TestPrintCreateIndex(t *testing.T) {
 	}
 }
 
+func TestPrintCreateSequence(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		seq      Sequence
+		expected string
+	}{
+		{"no counter", Config{ProtectIds: true}, Sequence{Name: "seq"}, "CREATE SEQUENCE `seq` OPTIONS (sequence_kind = 'bit_reversed_positive')"},
+		{"counter", Config{}, Sequence{Name: "seq", StartWithCounter: 42}, "CREATE SEQUENCE seq OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = 42)"},
+		{"comment", Config{Comments: true}, Sequence{Name: "seq", Comment: "From: SEQ"}, "-- From: SEQ\nCREATE SEQUENCE seq OPTIONS (sequence_kind = 'bit_reversed_positive')"},
+		{"counter PG", Config{TargetDb: constants.TargetExperimentalPostgres}, Sequence{Name: "seq", StartWithCounter: 42}, "CREATE SEQUENCE seq BIT_REVERSED_POSITIVE START COUNTER WITH 42"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, tc.seq.PrintCreateSequence(tc.config), tc.name)
+	}
+	seqs := map[string]Sequence{"b": {Name: "b"}, "a": {Name: "a", StartWithCounter: 7}}
+	assert.Equal(t, []string{
+		"CREATE SEQUENCE a OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = 7)",
+		"CREATE SEQUENCE b OPTIONS (sequence_kind = 'bit_reversed_positive')",
+	}, GetSequenceDDL(seqs, Config{}))
+}
+
 func TestPrintForeignKey(t *testing.T) {
 	fk := []Foreignkey{
 		{
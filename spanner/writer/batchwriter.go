@@ -60,6 +60,7 @@ type BatchWriter struct {
 	bytesLimit int64                      // Limit on bytes buffered. AddRow blocks if rBytes exceeded this value.
 	retryLimit int64                      // Limit on retries.
 	verbose    bool                       // If true, print out messages about each write batch.
+	upsert     bool                       // If true, rows are written with InsertOrUpdate rather than Insert.
 	async      asyncState
 }
 
@@ -93,6 +94,7 @@ type BatchWriterConfig struct {
 	RetryLimit int64                      // Limit on retries.
 	Write      func([]*sp.Mutation) error // Function to call to write to Spanner (typically a closure that calls client.Apply).
 	Verbose    bool                       // If true, print out messages about each write batch.
+	Upsert     bool                       // If true, rows that already exist are overwritten instead of failing the write.
 }
 
 // NewBatchWriter returns a new BatchWriter with parameters defined by config.
@@ -103,6 +105,7 @@ func NewBatchWriter(config BatchWriterConfig) *BatchWriter {
 		bytesLimit: config.BytesLimit,
 		retryLimit: config.RetryLimit,
 		verbose:    config.Verbose,
+		upsert:     config.Upsert,
 		async: asyncState{
 			errors:      make(map[string]int64),
 			droppedRows: make(map[string]int64),
@@ -252,7 +255,11 @@ func (bw *BatchWriter) errorStats(rows []*row, err error, retry bool) {
 func (bw *BatchWriter) doWriteAndHandleErrors(rows []*row) {
 	var m []*sp.Mutation
 	for _, x := range rows {
-		m = append(m, sp.Insert(x.table, x.cols, x.vals))
+		if bw.upsert {
+			m = append(m, sp.InsertOrUpdate(x.table, x.cols, x.vals))
+		} else {
+			m = append(m, sp.Insert(x.table, x.cols, x.vals))
+		}
 	}
 	if err := bw.write(m); err != nil {
 		hitRetryLimit := atomic.LoadInt64(&bw.async.retries) >= bw.retryLimit
@@ -99,6 +99,24 @@ func TestFlush(t *testing.T) {
 	}
 }
 
+func TestUpsert(t *testing.T) {
+	var written []*sp.Mutation
+	config := BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 1,
+		RetryLimit: 1000,
+		Upsert:     true,
+		Write: func(m []*sp.Mutation) error {
+			written = append(written, m...)
+			return nil
+		},
+	}
+	bw := NewBatchWriter(config)
+	bw.AddRow("test", []string{"a", "b"}, []interface{}{int64(1), "x"})
+	bw.Flush()
+	assert.Equal(t, []*sp.Mutation{sp.InsertOrUpdate("test", []string{"a", "b"}, []interface{}{int64(1), "x"})}, written)
+}
+
 func TestDroppedRowsByTable(t *testing.T) {
 	bw := NewBatchWriter(BatchWriterConfig{})
 	bw.async.lock.Lock()
@@ -0,0 +1,742 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+const (
+	// defaultPollInterval is the default number of seconds between two
+	// listings of the Datastream output directory.
+	defaultPollInterval = 10
+	// applyBatchSize is the maximum number of change events committed to
+	// Cloud Spanner in one transaction.
+	applyBatchSize = 100
+	// maxOpenFiles is the maximum number of change event files read at the
+	// same time. More new files are applied in groups of this many files.
+	maxOpenFiles = 100
+	// maxSampleRecords is the maximum number of bad records kept for the
+	// bad data file.
+	maxSampleRecords = 100
+)
+
+// Formats of the change event files applied by HarbourBridge.
+const (
+	DatastreamFormat = "datastream" // Datastream output files, in JSON format.
+	DebeziumFormat   = "debezium"   // Debezium change events, one envelope per line.
+)
+
+// LocalApplyCfg configures HarbourBridge to apply the Datastream output files
+// itself, instead of launching a Dataflow job. It can also apply the change
+// events written by Debezium.
+type LocalApplyCfg struct {
+	Dir            string // Local directory or GCS prefix (gs://bucket/path) of the change event files, in JSON format.
+	Format         string // Format of the files: datastream (default) or debezium.
+	CheckpointFile string // Local file where the applied files are recorded, so that they are skipped after a restart.
+	PollInterval   int64  // Number of seconds between two listings of Dir (default 10).
+	Once           bool   // If true, stop after applying the files present at the start, instead of tailing Dir. The files are then taken as complete.
+	PositionFile   string // Position of the consistent snapshot read by HarbourBridge: the changes already in the snapshot are skipped. Defaults to SnapshotCfg.PositionFile.
+}
+
+// RowConverter converts the rows of change events to Spanner
+// values, the same way as the rows of the snapshot. It is implemented by the
+// InfoSchema of the sources that stream through Datastream or Debezium.
+type RowConverter interface {
+	GetTableName(schema string, tableName string) string
+	ConvertRow(conv *internal.Conv, srcTable string, srcCols []string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, vals []string) (string, []string, []interface{}, error)
+}
+
+// changeEvent is a change to a row of a source table, read from a change
+// event file.
+type changeEvent struct {
+	timestamp time.Time // Time of the change in the source database.
+	schema    string    // Schema of the table, or database for MySQL.
+	table     string
+	kind      string                 // INSERT, UPDATE or DELETE; other kinds of events can't be applied.
+	row       map[string]interface{} // Values of the new row, or of the deleted row.
+	err       error                  // Set if a value of the row couldn't be decoded.
+	source    common.ChangeSource    // Position of the change in the change log of the source.
+}
+
+// eventParsers parse a line of a change event file, for each format. They
+// return nil for lines that aren't changes, e.g. Debezium tombstones.
+var eventParsers = map[string]func(raw []byte) (*changeEvent, error){
+	"":               parseDatastreamEvent,
+	DatastreamFormat: parseDatastreamEvent,
+	DebeziumFormat:   parseDebeziumEvent,
+}
+
+// datastreamEvent is a change event of a Datastream output file.
+type datastreamEvent struct {
+	SourceTimestamp json.RawMessage `json:"source_timestamp"`
+	SourceMetadata  struct {
+		Database    string `json:"database"` // Set for MySQL.
+		Schema      string `json:"schema"`   // Set for PostgreSQL and Oracle.
+		Table       string `json:"table"`
+		ChangeType  string `json:"change_type"`
+		IsDeleted   bool   `json:"is_deleted"`
+		LogFile     string `json:"log_file"`     // Set for MySQL.
+		LogPosition int64  `json:"log_position"` // Set for MySQL.
+		TxId        uint64 `json:"tx_id"`        // Set for PostgreSQL.
+	} `json:"source_metadata"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// kind returns the type of change of the event, as shown in the report:
+// INSERT, UPDATE or DELETE. The UPDATE-DELETE events of MySQL, for updates
+// that change the primary key, are deletes of the old row.
+func (e *datastreamEvent) kind() string {
+	switch {
+	case e.SourceMetadata.IsDeleted || strings.HasSuffix(e.SourceMetadata.ChangeType, "DELETE"):
+		return "DELETE"
+	case e.SourceMetadata.ChangeType == "INSERT" || e.SourceMetadata.ChangeType == "":
+		return "INSERT"
+	}
+	return "UPDATE"
+}
+
+func parseDatastreamEvent(raw []byte) (*changeEvent, error) {
+	e := &datastreamEvent{}
+	if err := decodeJSON(raw, e); err != nil {
+		return nil, err
+	}
+	ts, err := parseSourceTimestamp(e.SourceTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	schemaName := e.SourceMetadata.Schema
+	if schemaName == "" {
+		schemaName = e.SourceMetadata.Database
+	}
+	source := common.ChangeSource{LogFile: e.SourceMetadata.LogFile, LogPos: e.SourceMetadata.LogPosition, TxId: e.SourceMetadata.TxId}
+	return &changeEvent{timestamp: ts, schema: schemaName, table: e.SourceMetadata.Table, kind: e.kind(), row: e.Payload, source: source}, nil
+}
+
+// decodeJSON decodes raw into v, keeping numbers as their text, which is what
+// the row converters expect.
+func decodeJSON(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// parseSourceTimestamp parses a source timestamp, which is either an
+// RFC 3339 string or a number of milliseconds since the epoch.
+func parseSourceTimestamp(raw json.RawMessage) (time.Time, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return time.Parse(time.RFC3339Nano, s)
+	}
+	var ms int64
+	if err := json.Unmarshal(raw, &ms); err != nil {
+		return time.Time{}, fmt.Errorf("can't parse source timestamp %s", raw)
+	}
+	return time.UnixMilli(ms).UTC(), nil
+}
+
+// applyCheckpoint records the progress of the change event files, so that
+// applied events are skipped after a restart.
+type applyCheckpoint struct {
+	Files map[string]*fileProgress // Maps file name to its progress.
+}
+
+// fileProgress is the progress of a change event file.
+type fileProgress struct {
+	Records int64 // Number of records applied from the start of the file, including the ones that aren't changes.
+	Done    bool  // True once all the records of the file are applied.
+}
+
+func loadApplyCheckpoint(path string) (*applyCheckpoint, error) {
+	cp := &applyCheckpoint{Files: make(map[string]*fileProgress)}
+	if path == "" {
+		return cp, nil
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read checkpoint %s: %v", path, err)
+	}
+	if err := json.Unmarshal(b, cp); err != nil {
+		return nil, fmt.Errorf("can't parse checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+// save writes the checkpoint to path, atomically.
+func (cp *applyCheckpoint) save(path string) error {
+	if path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fileInfo describes a change event file. Its size and modification time
+// tell whether it is still being written.
+type fileInfo struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+// sameAs returns true if f and g have the same size and modification time.
+func (f fileInfo) sameAs(g fileInfo) bool {
+	return f.size == g.size && f.modified.Equal(g.modified)
+}
+
+// fileStore lists and reads the change event files.
+type fileStore interface {
+	list(ctx context.Context) ([]fileInfo, error)
+	open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// localStore reads the files under a local directory.
+type localStore struct {
+	dir string
+}
+
+func (l localStore) list(ctx context.Context) ([]fileInfo, error) {
+	var files []fileInfo
+	err := filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files = append(files, fileInfo{name: path, size: info.Size(), modified: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+func (l localStore) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+// gcsStore reads the objects under a GCS prefix.
+type gcsStore struct {
+	bucket *storage.BucketHandle
+	prefix string
+}
+
+func (g gcsStore) list(ctx context.Context) ([]fileInfo, error) {
+	var files []fileInfo
+	it := g.bucket.Objects(ctx, &storage.Query{Prefix: g.prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fileInfo{name: attrs.Name, size: attrs.Size, modified: attrs.Updated})
+	}
+	return files, nil
+}
+
+func (g gcsStore) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return g.bucket.Object(name).NewReader(ctx)
+}
+
+// applier applies the change events of Datastream or Debezium files to Cloud
+// Spanner, and records their stats in conv.Audit.StreamingStats.
+type applier struct {
+	conv      *internal.Conv
+	converter RowConverter
+	write     func(m []*sp.Mutation) error // Writes the mutations of a batch to Cloud Spanner, in one transaction.
+	position  *common.SnapshotPosition     // If set, the events already in this snapshot are skipped.
+	listed    map[string]fileInfo          // Files of the previous listing, to tell the files that are still being written.
+}
+
+func newApplier(conv *internal.Conv, converter RowConverter, write func(m []*sp.Mutation) error) *applier {
+	stats := &conv.Audit.StreamingStats
+	stats.Streaming = true
+	if stats.TotalRecords == nil {
+		stats.TotalRecords = make(map[string]map[string]int64)
+		stats.BadRecords = make(map[string]map[string]int64)
+		stats.DroppedRecords = make(map[string]map[string]int64)
+	}
+	return &applier{conv: conv, converter: converter, write: write}
+}
+
+// StartLocalApply applies the change event files under cfg.Dir to Cloud
+// Spanner. New files are applied as they appear, until Ctrl+C or SIGTERM, or
+// once the files present are applied if cfg.Once is set.
+func StartLocalApply(ctx context.Context, client *sp.Client, conv *internal.Conv, cfg LocalApplyCfg, converter RowConverter) error {
+	var store fileStore = localStore{dir: cfg.Dir}
+	if strings.HasPrefix(cfg.Dir, "gs://") {
+		u, err := utils.ParseGCSFilePath(cfg.Dir)
+		if err != nil {
+			return err
+		}
+		gcsClient, err := storage.NewClient(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to create GCS client: %v", err)
+		}
+		defer gcsClient.Close()
+		store = gcsStore{bucket: gcsClient.Bucket(u.Host), prefix: strings.TrimPrefix(u.Path, "/")}
+	}
+	write := func(m []*sp.Mutation) error {
+		// Writes are not cancelled on exit, so that the last files are applied entirely.
+		_, err := client.Apply(context.Background(), m)
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	format := "Datastream output"
+	if cfg.Format == DebeziumFormat {
+		format = "Debezium change event"
+	}
+	if cfg.Once {
+		fmt.Printf("Applying the %s files in %s...\n", format, cfg.Dir)
+	} else {
+		fmt.Printf("Applying the %s files in %s as they appear. Use Ctrl+C to stop the process.\n", format, cfg.Dir)
+	}
+	return applyFiles(ctx, store, cfg, newApplier(conv, converter, write))
+}
+
+// applyFiles applies the new files of store every cfg.PollInterval seconds,
+// until ctx is done.
+func applyFiles(ctx context.Context, store fileStore, cfg LocalApplyCfg, a *applier) error {
+	cp, err := loadApplyCheckpoint(cfg.CheckpointFile)
+	if err != nil {
+		return err
+	}
+	if cfg.PositionFile != "" {
+		pos, err := common.LoadSnapshotPosition(cfg.PositionFile)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Skipping the change events before the snapshot at %s.\n", pos)
+		a.position = &pos
+	}
+	parse, ok := eventParsers[cfg.Format]
+	if !ok {
+		return fmt.Errorf("unknown format %s of change event files", cfg.Format)
+	}
+	interval := time.Duration(cfg.PollInterval) * time.Second
+	if cfg.PollInterval <= 0 {
+		interval = defaultPollInterval * time.Second
+	}
+	for {
+		if err := a.applyNewFiles(ctx, store, cp, cfg, parse); err != nil {
+			return err
+		}
+		if cfg.Once {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// applyNewFiles applies the events of the files of store that aren't done
+// in the checkpoint. A file is only applied once it has kept its size and
+// modification time since the previous listing, unless cfg.Once is set,
+// since Datastream and Debezium may still be writing it. Files are read
+// maxOpenFiles at a time and their events are merged in source timestamp
+// order. The checkpoint is saved after each batch of events, with the number
+// of records applied from each file.
+func (a *applier) applyNewFiles(ctx context.Context, store fileStore, cp *applyCheckpoint, cfg LocalApplyCfg, parse func(raw []byte) (*changeEvent, error)) error {
+	files, err := store.list(ctx)
+	if err != nil {
+		return fmt.Errorf("can't list change event files: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	listed := make(map[string]fileInfo)
+	var names []string
+	for _, f := range files {
+		listed[f.name] = f
+		if p, ok := cp.Files[f.name]; ok && p.Done {
+			continue
+		}
+		base := strings.TrimSuffix(f.name, ".gz")
+		switch {
+		case strings.HasSuffix(base, ".avro"):
+			a.conv.Unexpected(fmt.Sprintf("Can't apply %s: only the JSON format is supported", f.name))
+			continue
+		case !strings.HasSuffix(base, ".jsonl") && !strings.HasSuffix(base, ".json"):
+			continue
+		}
+		if prev, ok := a.listed[f.name]; !cfg.Once && (!ok || !prev.sameAs(f)) {
+			continue
+		}
+		names = append(names, f.name)
+	}
+	a.listed = listed
+	for i := 0; i < len(names); i += maxOpenFiles {
+		end := i + maxOpenFiles
+		if end > len(names) {
+			end = len(names)
+		}
+		if err := a.mergeFiles(ctx, store, names[i:end], cp, cfg.CheckpointFile, parse); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeFiles applies the events of files in source timestamp order, in
+// batches of applyBatchSize events. The files are read at the same time and
+// their events merged, so each file keeps its order and only one event per
+// file is held in memory. Files are sorted by name, i.e. by table and time,
+// so that events with the same timestamp keep the order of their files.
+func (a *applier) mergeFiles(ctx context.Context, store fileStore, names []string, cp *applyCheckpoint, checkpointFile string, parse func(raw []byte) (*changeEvent, error)) error {
+	readers := make([]*eventReader, len(names))
+	heads := make([]*changeEvent, len(names)) // Next event of each file, nil once the file is read.
+	defer func() {
+		for _, r := range readers {
+			if r != nil {
+				r.close()
+			}
+		}
+	}()
+	// progress maps the files of the batch to their progress once it is
+	// applied.
+	progress := make(map[string]fileProgress)
+	advance := func(i int) error {
+		r := readers[i]
+		e, err := r.next()
+		if err != nil {
+			return fmt.Errorf("can't read change event file %s: %v", r.name, err)
+		}
+		heads[i] = e
+		if e == nil {
+			progress[r.name] = fileProgress{Records: r.records, Done: true}
+		}
+		return nil
+	}
+	for i, name := range names {
+		var skip int64
+		if p, ok := cp.Files[name]; ok {
+			skip = p.Records
+		}
+		r, err := openEvents(ctx, store, name, skip, parse)
+		if err != nil {
+			return fmt.Errorf("can't read change event file %s: %v", name, err)
+		}
+		readers[i] = r
+		if err := advance(i); err != nil {
+			return err
+		}
+	}
+	var batch []*changeEvent
+	flush := func() error {
+		a.applyEvents(batch)
+		batch = nil
+		for name, p := range progress {
+			p := p
+			cp.Files[name] = &p
+		}
+		progress = make(map[string]fileProgress)
+		if err := cp.save(checkpointFile); err != nil {
+			return fmt.Errorf("can't save checkpoint: %v", err)
+		}
+		return nil
+	}
+	for {
+		next := -1
+		for i, e := range heads {
+			if e != nil && (next < 0 || e.timestamp.Before(heads[next].timestamp)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		batch = append(batch, heads[next])
+		progress[names[next]] = fileProgress{Records: readers[next].records}
+		if err := advance(next); err != nil {
+			return err
+		}
+		if len(batch) == applyBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// eventReader reads the change events of a JSON Lines file, gzipped or not.
+type eventReader struct {
+	name    string
+	parse   func(raw []byte) (*changeEvent, error)
+	dec     *json.Decoder
+	closers []io.Closer
+	records int64 // Number of records read, including the ones that aren't changes.
+}
+
+// openEvents opens the file name of store, and skips its first skip
+// records.
+func openEvents(ctx context.Context, store fileStore, name string, skip int64, parse func(raw []byte) (*changeEvent, error)) (*eventReader, error) {
+	f, err := store.open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	r := &eventReader{name: name, parse: parse, closers: []io.Closer{f}}
+	var in io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		r.closers = append(r.closers, gz)
+		in = gz
+	}
+	r.dec = json.NewDecoder(bufio.NewReader(in))
+	for r.records < skip {
+		var raw json.RawMessage
+		if err := r.dec.Decode(&raw); err != nil {
+			r.close()
+			return nil, fmt.Errorf("can't skip the %d records already applied: %v", skip, err)
+		}
+		r.records++
+	}
+	return r, nil
+}
+
+// next returns the next change event of the file, or nil at the end of the
+// file.
+func (r *eventReader) next() (*changeEvent, error) {
+	for {
+		var raw json.RawMessage
+		err := r.dec.Decode(&raw)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.records++
+		e, err := r.parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if e != nil {
+			return e, nil
+		}
+	}
+}
+
+func (r *eventReader) close() {
+	for i := len(r.closers) - 1; i >= 0; i-- {
+		r.closers[i].Close()
+	}
+}
+
+// change is a converted event, waiting to be written to Cloud Spanner.
+type change struct {
+	srcTable string
+	kind     string
+	spTable  string
+	cols     []string
+	vals     []interface{}
+	m        *sp.Mutation
+}
+
+// applyEvents converts a batch of events in order, and writes them to Cloud
+// Spanner in one transaction. If that fails, the mutations are written one by
+// one, so that a bad event doesn't drop the others.
+func (a *applier) applyEvents(events []*changeEvent) {
+	var changes []*change
+	for _, e := range events {
+		if a.position != nil && a.position.Includes(e.source) {
+			continue
+		}
+		if c := a.convertEvent(e); c != nil {
+			changes = append(changes, c)
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	var ms []*sp.Mutation
+	for _, c := range changes {
+		ms = append(ms, c.m)
+	}
+	if err := a.write(ms); err == nil {
+		return
+	}
+	for _, c := range changes {
+		if err := a.write([]*sp.Mutation{c.m}); err != nil {
+			stats := &a.conv.Audit.StreamingStats
+			stats.DroppedRecords[c.srcTable][c.kind]++
+			if len(stats.SampleBadWrites) < maxSampleRecords {
+				stats.SampleBadWrites = append(stats.SampleBadWrites, fmt.Sprintf("type=%s table=%s cols=%v data=%v error=%v", c.kind, c.spTable, c.cols, c.vals, err))
+			}
+		}
+	}
+}
+
+// convertEvent converts the row of an event through conv.ToSpanner and the
+// row converter of the source, and builds its mutation: a delete for DELETE
+// events, an insert-or-update otherwise. It returns nil if the event can't
+// be applied.
+func (a *applier) convertEvent(e *changeEvent) *change {
+	conv := a.conv
+	stats := &conv.Audit.StreamingStats
+	srcTable := a.converter.GetTableName(e.schema, e.table)
+	srcSchema, ok := conv.SrcSchema[srcTable]
+	if !ok {
+		conv.Unexpected(fmt.Sprintf("Change event for table %s, which isn't in the schema", srcTable))
+		return nil
+	}
+	kind := e.kind
+	if kind != "INSERT" && kind != "UPDATE" && kind != "DELETE" {
+		conv.Unexpected(fmt.Sprintf("Can't apply %s event for table %s", kind, srcTable))
+		return nil
+	}
+	if stats.TotalRecords[srcTable] == nil {
+		stats.TotalRecords[srcTable] = make(map[string]int64)
+		stats.BadRecords[srcTable] = make(map[string]int64)
+		stats.DroppedRecords[srcTable] = make(map[string]int64)
+	}
+	stats.TotalRecords[srcTable][kind]++
+
+	var srcCols, vals []string
+	for _, col := range srcSchema.ColNames {
+		if v, ok := e.row[col]; ok {
+			srcCols = append(srcCols, col)
+			vals = append(vals, eventValue(v))
+		}
+	}
+	bad := func(err error) *change {
+		stats.BadRecords[srcTable][kind]++
+		if len(stats.SampleBadRecords) < maxSampleRecords {
+			stats.SampleBadRecords = append(stats.SampleBadRecords, fmt.Sprintf("type=%s table=%s cols=%v data=%v error=%v", kind, srcTable, srcCols, vals, err))
+		}
+		return nil
+	}
+	if e.err != nil {
+		return bad(e.err)
+	}
+	spTable, err := internal.GetSpannerTable(conv, srcTable)
+	if err != nil {
+		return bad(err)
+	}
+	spCols, err := internal.GetSpannerCols(conv, srcTable, srcCols)
+	if err != nil {
+		return bad(err)
+	}
+	spSchema := conv.SpSchema[spTable]
+	if _, ok := conv.SyntheticPKeys[spTable]; ok && kind != "INSERT" {
+		return bad(fmt.Errorf("can't apply %s to table %s: it has no primary key", kind, spTable))
+	}
+	_, cols, cvtVals, err := a.converter.ConvertRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, vals)
+	if err != nil {
+		return bad(err)
+	}
+	if kind == "DELETE" {
+		key, err := primaryKey(spSchema, cols, cvtVals)
+		if err != nil {
+			return bad(err)
+		}
+		return &change{srcTable, kind, spTable, cols, cvtVals, sp.Delete(spTable, key)}
+	}
+	// The converters drop NULL columns: set them, so that updates clear them.
+	converted := make(map[string]bool)
+	for _, c := range cols {
+		converted[c] = true
+	}
+	for _, c := range spCols {
+		if !converted[c] {
+			cols = append(cols, c)
+			cvtVals = append(cvtVals, nil)
+		}
+	}
+	return &change{srcTable, kind, spTable, cols, cvtVals, sp.InsertOrUpdate(spTable, cols, cvtVals)}
+}
+
+// eventValue converts a value of the row of an event to the string
+// representation used for the rows of the snapshot.
+func eventValue(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return x
+	case json.Number:
+		return x.String()
+	case bool:
+		return strconv.FormatBool(x)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// primaryKey returns the primary key of a converted row of spSchema.
+func primaryKey(spSchema ddl.CreateTable, cols []string, vals []interface{}) (sp.Key, error) {
+	var key sp.Key
+	for _, pk := range spSchema.Pks {
+		found := false
+		for i, c := range cols {
+			if c == pk.Col {
+				key = append(key, vals[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("primary key column %s of table %s is missing", pk.Col, spSchema.Name)
+		}
+	}
+	return key, nil
+}
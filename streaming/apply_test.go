@@ -0,0 +1,309 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/logger"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func init() {
+	logger.Log = zap.NewNop()
+}
+
+// fakeConverter converts INT64 columns to int64, and others to string.
+type fakeConverter struct{}
+
+func (fakeConverter) GetTableName(schema string, tableName string) string {
+	return tableName
+}
+
+func (fakeConverter) ConvertRow(conv *internal.Conv, srcTable string, srcCols []string, srcSchema schema.Table, spTable string, spCols []string, spSchema ddl.CreateTable, vals []string) (string, []string, []interface{}, error) {
+	var c []string
+	var v []interface{}
+	for i, spCol := range spCols {
+		if vals[i] == "NULL" {
+			continue
+		}
+		if spSchema.ColDefs[spCol].T.Name == ddl.Int64 {
+			n, err := strconv.ParseInt(vals[i], 10, 64)
+			if err != nil {
+				return "", nil, nil, err
+			}
+			v = append(v, n)
+		} else {
+			v = append(v, vals[i])
+		}
+		c = append(c, spCol)
+	}
+	return spTable, c, v, nil
+}
+
+// applyConv has a source table t, mapped to the Spanner table T.
+func applyConv() *internal.Conv {
+	conv := internal.MakeConv()
+	conv.SrcSchema["t"] = schema.Table{
+		Name:     "t",
+		ColNames: []string{"id", "name"},
+		ColDefs: map[string]schema.Column{
+			"id":   {Name: "id", Type: schema.Type{Name: "int"}},
+			"name": {Name: "name", Type: schema.Type{Name: "varchar"}},
+		},
+		PrimaryKeys: []schema.Key{{Column: "id"}},
+	}
+	conv.SpSchema["T"] = ddl.CreateTable{
+		Name:     "T",
+		ColNames: []string{"Id", "Name"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"Id":   {Name: "Id", T: ddl.Type{Name: ddl.Int64}},
+			"Name": {Name: "Name", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{{Col: "Id"}},
+	}
+	conv.ToSpanner["t"] = internal.NameAndCols{Name: "T", Cols: map[string]string{"id": "Id", "name": "Name"}}
+	conv.ToSource["T"] = internal.NameAndCols{Name: "t", Cols: map[string]string{"Id": "id", "Name": "name"}}
+	return conv
+}
+
+func writeFile(t *testing.T, path, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestApplyFiles(t *testing.T) {
+	dir := t.TempDir()
+	// The update and delete of the second file happened before the insert of
+	// the first one.
+	writeFile(t, filepath.Join(dir, "t/2022/11/01/10/00/a.jsonl"),
+		`{"source_timestamp":"2022-11-01T10:00:03Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT","is_deleted":false},"payload":{"id":1,"name":"c"}}`+"\n")
+	writeFile(t, filepath.Join(dir, "t/2022/11/01/10/00/b.jsonl"),
+		`{"source_timestamp":"2022-11-01T10:00:01Z","source_metadata":{"database":"db","table":"t","change_type":"UPDATE-INSERT","is_deleted":false},"payload":{"id":2,"name":null}}`+"\n"+
+			`{"source_timestamp":1667296802000,"source_metadata":{"database":"db","table":"t","change_type":"DELETE","is_deleted":true},"payload":{"id":3,"name":"x"}}`+"\n"+
+			`{"source_timestamp":"2022-11-01T10:00:02Z","source_metadata":{"database":"db","table":"unknown","change_type":"INSERT"},"payload":{"id":4}}`+"\n")
+	writeFile(t, filepath.Join(dir, "t/2022/11/01/10/00/c.avro"), "")
+	writeFile(t, filepath.Join(dir, "t/2022/11/01/10/00/README"), "")
+
+	conv := applyConv()
+	var batches [][]*sp.Mutation
+	write := func(m []*sp.Mutation) error {
+		batches = append(batches, m)
+		return nil
+	}
+	checkpoint := filepath.Join(t.TempDir(), "applied.json")
+	cfg := LocalApplyCfg{Dir: dir, CheckpointFile: checkpoint, Once: true}
+	assert.Nil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(conv, fakeConverter{}, write)))
+
+	// The events are applied in the order of their source timestamps, with
+	// the Spanner names of the table and columns.
+	assert.Equal(t, [][]*sp.Mutation{{
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(2), nil}),
+		sp.Delete("T", sp.Key{int64(3)}),
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(1), "c"}),
+	}}, batches)
+	stats := conv.Audit.StreamingStats
+	assert.True(t, stats.Streaming)
+	assert.Equal(t, map[string]int64{"INSERT": 1, "UPDATE": 1, "DELETE": 1}, stats.TotalRecords["t"])
+	assert.Equal(t, int64(1), conv.Stats.Unexpected["Change event for table unknown, which isn't in the schema"])
+	// The Avro file is reported too, and the README is ignored.
+	assert.Equal(t, int64(2), conv.Unexpecteds())
+
+	// Applied files are skipped after a restart: only the new file is applied.
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	gz.Write([]byte(`{"source_timestamp":"2022-11-01T10:01:00Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT"},"payload":{"id":5,"name":"e"}}` + "\n"))
+	gz.Close()
+	writeFile(t, filepath.Join(dir, "t/2022/11/01/10/01/d.jsonl.gz"), b.String())
+	batches = nil
+	assert.Nil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(conv, fakeConverter{}, write)))
+	assert.Equal(t, [][]*sp.Mutation{{sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(5), "e"})}}, batches)
+
+	cp, err := loadApplyCheckpoint(checkpoint)
+	assert.Nil(t, err)
+	assert.Len(t, cp.Files, 3)
+	assert.Equal(t, &fileProgress{Records: 3, Done: true}, cp.Files[filepath.Join(dir, "t/2022/11/01/10/00/b.jsonl")])
+
+	buf := new(bytes.Buffer)
+	w := bufio.NewWriter(buf)
+	internal.GenerateReport(constants.MYSQL, conv, w, nil, false, false)
+	w.Flush()
+	assert.Contains(t, buf.String(), "Count of records read from change event files: 4")
+}
+
+func TestApplyNewFiles_StableFiles(t *testing.T) {
+	dir := t.TempDir()
+	insert := func(id int) string {
+		return `{"source_timestamp":"2022-11-01T10:00:01Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT"},"payload":{"id":` + strconv.Itoa(id) + `,"name":"a"}}` + "\n"
+	}
+	writeFile(t, filepath.Join(dir, "a.jsonl"), insert(1))
+	var written []*sp.Mutation
+	a := newApplier(applyConv(), fakeConverter{}, func(m []*sp.Mutation) error {
+		written = append(written, m...)
+		return nil
+	})
+	cp := &applyCheckpoint{Files: make(map[string]*fileProgress)}
+	cfg := LocalApplyCfg{Dir: dir}
+	apply := func() {
+		assert.Nil(t, a.applyNewFiles(context.Background(), localStore{dir: dir}, cp, cfg, parseDatastreamEvent))
+	}
+
+	// A file is applied once it is unchanged since the previous listing.
+	apply()
+	assert.Empty(t, written)
+	apply()
+	assert.Len(t, written, 1)
+
+	// A file that grew since the previous listing waits for the next one.
+	b := filepath.Join(dir, "b.jsonl")
+	writeFile(t, b, insert(2))
+	apply()
+	writeFile(t, b, insert(2)+insert(3))
+	apply()
+	assert.Len(t, written, 1)
+	apply()
+	assert.Len(t, written, 3)
+	assert.Equal(t, &fileProgress{Records: 2, Done: true}, cp.Files[b])
+}
+
+func TestApplyFiles_Resume(t *testing.T) {
+	dir := t.TempDir()
+	var lines string
+	for i := 0; i < applyBatchSize+2; i++ {
+		lines += `{"source_timestamp":"2022-11-01T10:00:01Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT"},"payload":{"id":` + strconv.Itoa(i) + `,"name":"a"}}` + "\n"
+	}
+	file := filepath.Join(dir, "a.jsonl")
+	writeFile(t, file, lines)
+	checkpoint := filepath.Join(t.TempDir(), "applied.json")
+	cfg := LocalApplyCfg{Dir: dir, CheckpointFile: checkpoint, Once: true}
+
+	// The second batch fails to be saved: the first one is in the checkpoint.
+	var written []*sp.Mutation
+	write := func(m []*sp.Mutation) error {
+		written = append(written, m...)
+		if len(written) > applyBatchSize {
+			// The temporary file of the checkpoint can't be written.
+			os.Mkdir(checkpoint+".tmp", 0755)
+		}
+		return nil
+	}
+	assert.NotNil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(applyConv(), fakeConverter{}, write)))
+	assert.Nil(t, os.Remove(checkpoint+".tmp"))
+	cp, err := loadApplyCheckpoint(checkpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, &fileProgress{Records: applyBatchSize}, cp.Files[file])
+
+	// Only the records after the checkpoint are applied again.
+	written = nil
+	assert.Nil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(applyConv(), fakeConverter{}, write)))
+	assert.Equal(t, []*sp.Mutation{
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(applyBatchSize), "a"}),
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(applyBatchSize + 1), "a"}),
+	}, written)
+	cp, err = loadApplyCheckpoint(checkpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, &fileProgress{Records: applyBatchSize + 2, Done: true}, cp.Files[file])
+}
+
+func TestApplyEvents_BadRowsAndFallback(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.jsonl"),
+		`{"source_timestamp":"2022-11-01T10:00:01Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT"},"payload":{"id":"one","name":"a"}}`+"\n"+
+			`{"source_timestamp":"2022-11-01T10:00:02Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT"},"payload":{"id":2,"name":"b"}}`+"\n"+
+			`{"source_timestamp":"2022-11-01T10:00:03Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT"},"payload":{"id":3,"name":"c"}}`+"\n")
+	conv := applyConv()
+	calls := 0
+	write := func(m []*sp.Mutation) error {
+		calls++
+		if len(m) > 1 || calls == 2 {
+			return errors.New("write failed")
+		}
+		return nil
+	}
+	assert.Nil(t, applyFiles(context.Background(), localStore{dir: dir}, LocalApplyCfg{Dir: dir, Once: true}, newApplier(conv, fakeConverter{}, write)))
+
+	// The batch failed, so its events were written one by one: the first one
+	// failed.
+	assert.Equal(t, 3, calls)
+	stats := conv.Audit.StreamingStats
+	assert.Equal(t, map[string]int64{"INSERT": 1}, stats.BadRecords["t"])
+	assert.Equal(t, map[string]int64{"INSERT": 1}, stats.DroppedRecords["t"])
+	assert.Len(t, stats.SampleBadRecords, 1)
+	assert.Len(t, stats.SampleBadWrites, 1)
+}
+
+func TestApplyEvents_SyntheticPrimaryKey(t *testing.T) {
+	conv := applyConv()
+	conv.SyntheticPKeys["T"] = internal.SyntheticPKey{Col: "synth_id"}
+	a := newApplier(conv, fakeConverter{}, func(m []*sp.Mutation) error { return nil })
+	e := &changeEvent{table: "t", kind: "UPDATE", row: map[string]interface{}{"id": "1", "name": "a"}}
+	// Rows without a primary key can't be updated.
+	assert.Nil(t, a.convertEvent(e))
+	assert.Equal(t, int64(1), conv.Audit.StreamingStats.BadRecords["t"]["UPDATE"])
+}
+
+func TestApplyFiles_SnapshotPosition(t *testing.T) {
+	dir := t.TempDir()
+	// The first event is in the snapshot, which ends at mysql-bin.000003:154.
+	writeFile(t, filepath.Join(dir, "a.jsonl"),
+		`{"source_timestamp":"2022-11-01T10:00:01Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT","log_file":"mysql-bin.000003","log_position":120},"payload":{"id":1,"name":"a"}}`+"\n"+
+			`{"source_timestamp":"2022-11-01T10:00:02Z","source_metadata":{"database":"db","table":"t","change_type":"INSERT","log_file":"mysql-bin.000003","log_position":154},"payload":{"id":2,"name":"b"}}`+"\n")
+	position := filepath.Join(t.TempDir(), "position.json")
+	assert.Nil(t, common.SnapshotPosition{Driver: constants.MYSQL, LogFile: "mysql-bin.000003", LogPos: 154}.Save(position))
+	conv := applyConv()
+	var written []*sp.Mutation
+	write := func(m []*sp.Mutation) error {
+		written = append(written, m...)
+		return nil
+	}
+	cfg := LocalApplyCfg{Dir: dir, Once: true, PositionFile: position}
+	assert.Nil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(conv, fakeConverter{}, write)))
+	assert.Equal(t, []*sp.Mutation{sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(2), "b"})}, written)
+
+	cfg.PositionFile = filepath.Join(t.TempDir(), "missing.json")
+	assert.NotNil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(conv, fakeConverter{}, write)))
+}
+
+func TestVerifyAndUpdateCfg_Snapshot(t *testing.T) {
+	cfg := StreamingCfg{LocalApplyCfg: LocalApplyCfg{Dir: "/tmp/events", Format: DebeziumFormat}, SnapshotCfg: SnapshotCfg{PositionFile: "/tmp/position.json"}}
+	assert.Nil(t, VerifyAndUpdateCfg(&cfg, "db"))
+	assert.Equal(t, defaultSnapshotReaders, cfg.SnapshotCfg.Readers)
+	assert.Equal(t, "/tmp/position.json", cfg.LocalApplyCfg.PositionFile)
+
+	cfg.SnapshotCfg.Readers = -1
+	assert.NotNil(t, VerifyAndUpdateCfg(&cfg, "db"))
+
+	// A Dataflow job can't start at the position of the snapshot.
+	cfg = StreamingCfg{SnapshotCfg: SnapshotCfg{PositionFile: "/tmp/position.json"}}
+	err := VerifyAndUpdateCfg(&cfg, "db")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "SnapshotCfg needs a LocalApplyCfg.Dir")
+}
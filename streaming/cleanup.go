@@ -0,0 +1,540 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	dataflow "cloud.google.com/go/dataflow/apiv1beta3"
+	datastream "cloud.google.com/go/datastream/apiv1"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
+	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+)
+
+// MigrationLabel is the label carrying the migration request id on the
+// streams and the Dataflow jobs launched by HarbourBridge.
+const MigrationLabel = "harbourbridge-migration-id"
+
+// Prefixes of the names generated by HarbourBridge for streams and jobs.
+const (
+	streamPrefix = "hb-stream-"
+	jobPrefix    = "hb-dataflow-"
+)
+
+var (
+	// migrationBucketRegexp matches the buckets named after a migration
+	// request id, which the web UI creates for its migrations.
+	migrationBucketRegexp = regexp.MustCompile(`^hb-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	// generatedDatabaseRegexp matches the database names generated by
+	// utils.GetDatabaseName, e.g. mysql_2022-11-01_0a1b-2c3d.
+	generatedDatabaseRegexp = regexp.MustCompile(`^[a-z_]+_\d{4}-\d{2}-\d{2}_[0-9a-f]{4}-[0-9a-f]{4}$`)
+	// orphanedStreamStates are the states of streams that don't run anymore.
+	orphanedStreamStates = map[string]bool{"NOT_STARTED": true, "PAUSED": true, "FAILED": true, "FAILED_PERMANENTLY": true}
+	// doneJobStates are the terminal states of Dataflow jobs. Such jobs
+	// can't be deleted, but the other resources of their migration are left
+	// behind.
+	doneJobStates = map[string]bool{"DONE": true, "FAILED": true, "CANCELLED": true, "UPDATED": true, "DRAINED": true}
+)
+
+// migrationLabels returns the labels of the resources launched for the
+// migration. Label values can't have upper case letters.
+func migrationLabels(migrationRequestId string) map[string]string {
+	if migrationRequestId == "" {
+		return nil
+	}
+	return map[string]string{MigrationLabel: strings.ToLower(migrationRequestId)}
+}
+
+// ResourceKind is the kind of a Google Cloud resource created by a migration.
+type ResourceKind string
+
+// Kinds of resources, in the order in which they are deleted: jobs stop
+// reading the output of streams before the streams and their output are
+// deleted.
+const (
+	JobResource      ResourceKind = "Dataflow job"
+	StreamResource   ResourceKind = "Datastream stream"
+	PrefixResource   ResourceKind = "GCS prefix"
+	BucketResource   ResourceKind = "GCS bucket"
+	DatabaseResource ResourceKind = "Spanner database"
+)
+
+var resourceOrder = map[ResourceKind]int{JobResource: 0, StreamResource: 1, PrefixResource: 2, BucketResource: 3, DatabaseResource: 4}
+
+// Resource is a Google Cloud resource that may have been created by a
+// migration.
+type Resource struct {
+	Kind   ResourceKind
+	Id     string // Full name of streams and databases, id of jobs, name of buckets, gs:// path of prefixes.
+	Name   string // Stream id, job name, bucket name, gs:// path or database name.
+	State  string
+	Labels map[string]string // Labels of streams and jobs, and of the stream or job of prefixes.
+	// Group is shared by the stream, the job and the GCS prefixes of a
+	// migration: its label, or the full name of the stream for resources
+	// launched without label.
+	Group string
+	// Users are the groups of all the streams and jobs of the region whose
+	// files are under a GCS prefix or bucket, or above the prefix.
+	Users []string
+	Empty bool // Set for databases without tables.
+}
+
+// orphaned returns whether the stream, the job or the database doesn't run
+// anymore or was never completely created.
+func (r Resource) orphaned() bool {
+	switch r.Kind {
+	case StreamResource:
+		return orphanedStreamStates[r.State]
+	case JobResource:
+		return doneJobStates[r.State] || r.State == "STOPPED"
+	case DatabaseResource:
+		// Databases being created may be those of a migration that is
+		// starting.
+		if r.State == "CREATING" {
+			return false
+		}
+		return (r.State != "READY" && r.State != "READY_OPTIMIZING") || r.Empty
+	}
+	return false
+}
+
+// ResourceCleaner lists and deletes the resources of a project that may have
+// been created by migrations. It is implemented with the Google Cloud clients
+// by GCloudCleaner, and by fakes in tests.
+type ResourceCleaner interface {
+	List(ctx context.Context) ([]Resource, error)
+	Delete(ctx context.Context, r Resource) error
+}
+
+// SelectResources returns the resources created by HarbourBridge that were
+// left behind, in the order in which they should be deleted:
+//   - the streams and the jobs of the migrations whose stream or job failed,
+//     stopped or is done,
+//   - the GCS prefixes and the buckets of the web UI that are only used by
+//     such migrations,
+//   - the databases that are ready but have no tables, or that failed.
+//
+// If all is set, the resources of running migrations are returned as well,
+// but GCS prefixes and buckets also used by running streams or jobs that
+// weren't launched by the selected migrations never are. If
+// migrationRequestId is empty, resources are recognized by their migration
+// label or by their generated name. Otherwise, only the resources of that
+// migration are returned: Spanner databases have no labels, so none are
+// returned. Jobs that are done can't be deleted, so they are never returned.
+func SelectResources(resources []Resource, migrationRequestId string, all bool) []Resource {
+	// Groups are orphaned if one of their streams or jobs is, whether or not
+	// HarbourBridge launched them.
+	orphaned := make(map[string]bool)
+	for _, r := range resources {
+		if (r.Kind == StreamResource || r.Kind == JobResource) && r.orphaned() {
+			orphaned[r.Group] = true
+		}
+	}
+	var candidates []Resource
+	launched := make(map[string]bool)
+	for _, r := range resources {
+		if !createdBy(r, strings.ToLower(migrationRequestId)) {
+			continue
+		}
+		candidates = append(candidates, r)
+		if r.Kind == StreamResource || r.Kind == JobResource {
+			launched[r.Group] = true
+		}
+	}
+	// unused returns whether all the users of a prefix or a bucket are
+	// deleted. Buckets without users may be those of migrations that are
+	// starting, so they are only deleted with all.
+	unused := func(r Resource) bool {
+		if len(r.Users) == 0 {
+			return all
+		}
+		for _, g := range r.Users {
+			if !orphaned[g] && !(all && launched[g]) {
+				return false
+			}
+		}
+		return true
+	}
+	var selected []Resource
+	for _, r := range candidates {
+		if r.Kind == JobResource && doneJobStates[r.State] {
+			continue
+		}
+		keep := false
+		switch r.Kind {
+		case StreamResource, JobResource:
+			keep = all || orphaned[r.Group]
+		case PrefixResource, BucketResource:
+			keep = unused(r)
+		case DatabaseResource:
+			keep = all || r.orphaned()
+		}
+		if keep {
+			selected = append(selected, r)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		return resourceOrder[selected[i].Kind] < resourceOrder[selected[j].Kind]
+	})
+	return selected
+}
+
+// createdBy returns whether r was created by the migration id, or by any
+// migration if id is empty.
+func createdBy(r Resource, id string) bool {
+	switch r.Kind {
+	case StreamResource, JobResource:
+		if label, ok := r.Labels[MigrationLabel]; ok {
+			return id == "" || label == id
+		}
+		prefix := streamPrefix
+		if r.Kind == JobResource {
+			prefix = jobPrefix
+		}
+		return id == "" && strings.HasPrefix(r.Name, prefix)
+	case PrefixResource:
+		// Prefixes are only listed for the streams and jobs of migrations.
+		return id == "" || r.Labels[MigrationLabel] == id
+	case BucketResource:
+		if id != "" {
+			return r.Name == id
+		}
+		return migrationBucketRegexp.MatchString(r.Name)
+	case DatabaseResource:
+		return id == "" && generatedDatabaseRegexp.MatchString(r.Name)
+	}
+	return false
+}
+
+// PrintResources writes the list of resources to w.
+func PrintResources(w io.Writer, resources []Resource) {
+	for _, r := range resources {
+		fmt.Fprintf(w, "  %-18s %s", r.Kind, r.Name)
+		if r.Id != r.Name {
+			fmt.Fprintf(w, " (%s)", r.Id)
+		}
+		if r.State != "" {
+			fmt.Fprintf(w, " %s", r.State)
+		}
+		if r.Empty {
+			fmt.Fprint(w, " without tables")
+		}
+		if label, ok := r.Labels[MigrationLabel]; ok {
+			fmt.Fprintf(w, " migration: %s", label)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// CleanUp deletes the resources with c, and reports each deletion to w. A
+// failed deletion doesn't stop the deletion of the other resources.
+func CleanUp(ctx context.Context, c ResourceCleaner, resources []Resource, w io.Writer) error {
+	failed := 0
+	for _, r := range resources {
+		if err := c.Delete(ctx, r); err != nil {
+			fmt.Fprintf(w, "Could not delete %s %s: %v\n", r.Kind, r.Name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "Deleted %s %s\n", r.Kind, r.Name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("could not delete %d of %d resources", failed, len(resources))
+	}
+	return nil
+}
+
+// jobOption returns a pipeline option of a Dataflow job, which holds the
+// parameters of the template it was launched from.
+func jobOption(job *dataflowpb.Job, name string) string {
+	options := job.GetEnvironment().GetSdkPipelineOptions().GetFields()["options"]
+	return options.GetStructValue().GetFields()[name].GetStringValue()
+}
+
+// gcsPrefix splits a gs:// path into its bucket and its object prefix. Paths
+// of whole buckets aren't prefixes of a migration.
+func gcsPrefix(path string) (bucket, prefix string, ok bool) {
+	rest := strings.TrimPrefix(path, "gs://")
+	i := strings.Index(rest, "/")
+	if rest == path || i <= 0 || i == len(rest)-1 {
+		return "", "", false
+	}
+	return rest[:i], rest[i+1:], true
+}
+
+// GCloudCleaner lists and deletes the streams and the Dataflow jobs of a
+// region with their GCS output and session files, the buckets of a project
+// and the databases of a Spanner instance, with the Google Cloud clients.
+type GCloudCleaner struct {
+	project  string
+	region   string
+	instance string
+	ds       *datastream.Client
+	jobs     *dataflow.JobsV1Beta3Client
+	gcs      *storage.Client
+	admin    *database.DatabaseAdminClient
+}
+
+// NewGCloudCleaner returns a GCloudCleaner of the project. Databases are only
+// listed if instance isn't empty.
+func NewGCloudCleaner(ctx context.Context, project, region, instance string) (*GCloudCleaner, error) {
+	c := &GCloudCleaner{project: project, region: region, instance: instance}
+	var err error
+	if c.ds, err = datastream.NewClient(ctx); err != nil {
+		return nil, fmt.Errorf("datastream client can not be created: %v", err)
+	}
+	if c.jobs, err = dataflow.NewJobsV1Beta3Client(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("could not create job client: %v", err)
+	}
+	if c.gcs, err = storage.NewClient(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	if instance != "" {
+		if c.admin, err = utils.NewDatabaseAdminClient(ctx); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("can't create admin client: %v", err)
+		}
+	}
+	return c, nil
+}
+
+// Close closes the clients of the cleaner.
+func (c *GCloudCleaner) Close() {
+	if c.ds != nil {
+		c.ds.Close()
+	}
+	if c.jobs != nil {
+		c.jobs.Close()
+	}
+	if c.gcs != nil {
+		c.gcs.Close()
+	}
+	if c.admin != nil {
+		c.admin.Close()
+	}
+}
+
+// gcsRef is a GCS path used by a stream or a job.
+type gcsRef struct {
+	path  string
+	user  Resource
+	owned bool // Set if the stream or the job was launched by HarbourBridge.
+}
+
+// List implements the ResourceCleaner interface. The Datastream output of
+// the streams of migrations, and the output and the session file of their
+// jobs, are listed as GCS prefixes. Jobs that are done are listed too, since
+// the other resources of their migration are left behind. The GCS paths of
+// the other streams and jobs that still run are read as well, so that the
+// prefixes and buckets they use are listed with all their users.
+func (c *GCloudCleaner) List(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	var refs []gcsRef
+	addRef := func(path string, user Resource) {
+		if path != "" {
+			refs = append(refs, gcsRef{path: path, user: user, owned: createdBy(user, "")})
+		}
+	}
+	streams := c.ds.ListStreams(ctx, &datastreampb.ListStreamsRequest{Parent: fmt.Sprintf("projects/%s/locations/%s", c.project, c.region)})
+	for {
+		s, err := streams.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list streams: %v", err)
+		}
+		r := Resource{Kind: StreamResource, Id: s.GetName(), Name: s.GetName()[strings.LastIndex(s.GetName(), "/")+1:], State: s.GetState().String(), Labels: s.GetLabels(), Group: s.GetName()}
+		if label, ok := r.Labels[MigrationLabel]; ok {
+			r.Group = label
+		}
+		resources = append(resources, r)
+		if !createdBy(r, "") && r.orphaned() {
+			continue
+		}
+		dst := s.GetDestinationConfig()
+		profile, err := c.ds.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: dst.GetDestinationConnectionProfile()})
+		if err != nil {
+			return nil, fmt.Errorf("could not get connection profiles: %v", err)
+		}
+		if gcsProfile := profile.GetGcsProfile(); gcsProfile != nil {
+			addRef("gs://"+gcsProfile.Bucket+"/"+outputPrefix(gcsProfile.RootPath, dst.GetGcsDestinationConfig().GetPath()), r)
+		}
+	}
+	jobs := c.jobs.ListJobs(ctx, &dataflowpb.ListJobsRequest{ProjectId: c.project, Location: c.region, Filter: dataflowpb.ListJobsRequest_ALL})
+	for {
+		j, err := jobs.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list Dataflow jobs: %v", err)
+		}
+		r := Resource{Kind: JobResource, Id: j.GetId(), Name: j.GetName(), State: strings.TrimPrefix(j.GetCurrentState().String(), "JOB_STATE_"), Labels: j.GetLabels(), Group: j.GetId()}
+		if !createdBy(r, "") && r.orphaned() {
+			continue
+		}
+		// The parameters of the template are only returned with the whole job.
+		job, err := c.jobs.GetJob(ctx, &dataflowpb.GetJobRequest{ProjectId: c.project, JobId: j.GetId(), Location: c.region, View: dataflowpb.JobView_JOB_VIEW_ALL})
+		if err != nil {
+			return nil, fmt.Errorf("could not get Dataflow job %s: %v", j.GetId(), err)
+		}
+		if label, ok := r.Labels[MigrationLabel]; ok {
+			r.Group = label
+		} else if stream := jobOption(job, "streamName"); stream != "" {
+			r.Group = stream
+		}
+		resources = append(resources, r)
+		addRef(jobOption(job, "inputFilePattern"), r)
+		addRef(jobOption(job, "sessionFilePath"), r)
+	}
+	resources = append(resources, refPrefixes(refs)...)
+	buckets := c.gcs.Buckets(ctx, c.project)
+	buckets.Prefix = "hb-"
+	for {
+		b, err := buckets.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list buckets: %v", err)
+		}
+		resources = append(resources, Resource{Kind: BucketResource, Id: b.Name, Name: b.Name, Labels: b.Labels, Group: b.Name, Users: refUsers(refs, "gs://"+b.Name+"/")})
+	}
+	if c.admin == nil {
+		return resources, nil
+	}
+	dbs := c.admin.ListDatabases(ctx, &adminpb.ListDatabasesRequest{Parent: fmt.Sprintf("projects/%s/instances/%s", c.project, c.instance)})
+	for {
+		db, err := dbs.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not list databases: %v", err)
+		}
+		_, _, dbName := utils.ParseDbURI(db.GetName())
+		r := Resource{Kind: DatabaseResource, Id: db.GetName(), Name: dbName, State: db.GetState().String()}
+		if createdBy(r, "") {
+			ddl, err := c.admin.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: db.GetName()})
+			if err != nil {
+				return nil, fmt.Errorf("can't fetch database ddl of %s: %v", dbName, err)
+			}
+			r.Empty = len(ddl.GetStatements()) == 0
+		}
+		resources = append(resources, r)
+	}
+	return resources, nil
+}
+
+// refPrefixes returns the GCS prefixes used by the streams and jobs launched
+// by HarbourBridge, with the groups of all the streams and jobs that use them.
+func refPrefixes(refs []gcsRef) []Resource {
+	var prefixes []Resource
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if _, _, ok := gcsPrefix(ref.path); !ok || !ref.owned || seen[ref.path] {
+			continue
+		}
+		seen[ref.path] = true
+		prefixes = append(prefixes, Resource{Kind: PrefixResource, Id: ref.path, Name: ref.path, Labels: ref.user.Labels, Group: ref.user.Group, Users: refUsers(refs, ref.path)})
+	}
+	return prefixes
+}
+
+// refUsers returns the groups of the streams and jobs whose paths are under
+// path, or above it.
+func refUsers(refs []gcsRef, path string) []string {
+	var users []string
+	seen := make(map[string]bool)
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref.path, path) && !strings.HasPrefix(path, ref.path) {
+			continue
+		}
+		if !seen[ref.user.Group] {
+			seen[ref.user.Group] = true
+			users = append(users, ref.user.Group)
+		}
+	}
+	return users
+}
+
+// Delete implements the ResourceCleaner interface. Dataflow jobs are
+// cancelled, and buckets are emptied before they are deleted.
+func (c *GCloudCleaner) Delete(ctx context.Context, r Resource) error {
+	switch r.Kind {
+	case JobResource:
+		_, err := c.jobs.UpdateJob(ctx, &dataflowpb.UpdateJobRequest{
+			ProjectId: c.project,
+			JobId:     r.Id,
+			Location:  c.region,
+			Job:       &dataflowpb.Job{Id: r.Id, ProjectId: c.project, RequestedState: dataflowpb.JobState_JOB_STATE_CANCELLED},
+		})
+		return err
+	case StreamResource:
+		op, err := c.ds.DeleteStream(ctx, &datastreampb.DeleteStreamRequest{Name: r.Id})
+		if err != nil {
+			return err
+		}
+		return op.Wait(ctx)
+	case PrefixResource:
+		bucket, prefix, ok := gcsPrefix(r.Id)
+		if !ok {
+			return fmt.Errorf("%s isn't a prefix of a bucket", r.Id)
+		}
+		return c.deleteObjects(ctx, bucket, prefix)
+	case BucketResource:
+		if err := c.deleteObjects(ctx, r.Id, ""); err != nil {
+			return err
+		}
+		return c.gcs.Bucket(r.Id).Delete(ctx)
+	case DatabaseResource:
+		return c.admin.DropDatabase(ctx, &adminpb.DropDatabaseRequest{Database: r.Id})
+	}
+	return fmt.Errorf("unknown resource kind %s", r.Kind)
+}
+
+// deleteObjects deletes the objects of the bucket whose names start with
+// prefix.
+func (c *GCloudCleaner) deleteObjects(ctx context.Context, bucket, prefix string) error {
+	b := c.gcs.Bucket(bucket)
+	it := b.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := b.Object(attrs.Name).Delete(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakeCleaner lists the resources set by the test, and records deletions.
+type fakeCleaner struct {
+	resources []Resource
+	deleted   []string
+	failing   map[string]bool
+}
+
+func (f *fakeCleaner) List(ctx context.Context) ([]Resource, error) { return f.resources, nil }
+
+func (f *fakeCleaner) Delete(ctx context.Context, r Resource) error {
+	if f.failing[r.Name] {
+		return fmt.Errorf("permission denied")
+	}
+	f.deleted = append(f.deleted, r.Name)
+	return nil
+}
+
+const (
+	failedMigration  = "hb-0f9e8d7c-1234-4abc-8def-0123456789ab"
+	runningMigration = "hb-11111111-1234-4abc-8def-0123456789ab"
+	bulkMigration    = "hb-22222222-1234-4abc-8def-0123456789ab"
+)
+
+func labels(migration string) map[string]string {
+	return map[string]string{MigrationLabel: migration}
+}
+
+var cleanupResources = []Resource{
+	// A migration whose job failed: its stream and files are left behind.
+	{Kind: StreamResource, Name: "orders-stream", State: "RUNNING", Labels: labels(failedMigration), Group: failedMigration},
+	{Kind: JobResource, Name: "orders-job", State: "FAILED", Labels: labels(failedMigration), Group: failedMigration},
+	{Kind: PrefixResource, Name: "gs://my-bucket/data/", Labels: labels(failedMigration), Group: failedMigration, Users: []string{failedMigration}},
+	{Kind: BucketResource, Name: failedMigration, Group: failedMigration, Users: []string{failedMigration}},
+	// Its session file is in a prefix that a running stream writes to.
+	{Kind: PrefixResource, Name: "gs://my-bucket/shared/", Labels: labels(failedMigration), Group: failedMigration, Users: []string{failedMigration, "cdc-live"}},
+	// A running migration.
+	{Kind: StreamResource, Name: "hb-stream-shop_0a1b-2c3d", State: "RUNNING", Labels: labels(runningMigration), Group: runningMigration},
+	{Kind: JobResource, Name: "hb-dataflow-shop-0a1b-2c3d", State: "RUNNING", Labels: labels(runningMigration), Group: runningMigration},
+	{Kind: PrefixResource, Name: "gs://my-bucket/shop/", Labels: labels(runningMigration), Group: runningMigration, Users: []string{runningMigration}},
+	{Kind: BucketResource, Name: runningMigration, Group: runningMigration, Users: []string{runningMigration}},
+	// A running stream that wasn't launched by HarbourBridge.
+	{Kind: StreamResource, Name: "cdc-live", State: "RUNNING", Group: "cdc-live"},
+	// The bucket of a bulk migration of the web UI, which no stream uses.
+	{Kind: BucketResource, Name: bulkMigration, Group: bulkMigration},
+	// A paused stream launched without label, and its job.
+	{Kind: StreamResource, Name: "hb-stream-old_0a1b-2c3d", State: "PAUSED", Group: "projects/p/locations/l/streams/hb-stream-old_0a1b-2c3d"},
+	{Kind: JobResource, Name: "hb-dataflow-old-0a1b-2c3d", State: "RUNNING", Group: "projects/p/locations/l/streams/hb-stream-old_0a1b-2c3d"},
+	{Kind: DatabaseResource, Name: "mysql_2022-11-01_0a1b-2c3d", State: "READY"},
+	{Kind: DatabaseResource, Name: "pg_dump_2022-11-01_0a1b-2c3d", State: "READY", Empty: true},
+	{Kind: DatabaseResource, Name: "mysql_2022-11-02_0a1b-2c3d", State: "CREATING"},
+	{Kind: DatabaseResource, Name: "orders", State: "READY", Empty: true},
+	{Kind: BucketResource, Name: "hb-logs"},
+	{Kind: StreamResource, Name: "cdc-stream", State: "FAILED", Group: "cdc-stream"},
+	{Kind: JobResource, Name: "nightly-export", State: "STOPPED", Group: "nightly-export"},
+}
+
+func names(resources []Resource) []string {
+	var names []string
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestSelectResources(t *testing.T) {
+	// Only the resources left behind are selected, jobs first and databases
+	// last. Failed jobs can't be deleted, and files still used by a running
+	// stream, buckets that no stream uses and databases being created are
+	// kept.
+	assert.Equal(t, []string{
+		"hb-dataflow-old-0a1b-2c3d",
+		"orders-stream",
+		"hb-stream-old_0a1b-2c3d",
+		"gs://my-bucket/data/",
+		failedMigration,
+		"pg_dump_2022-11-01_0a1b-2c3d",
+	}, names(SelectResources(cleanupResources, "", false)))
+	// With all, running migrations, unused buckets and databases with tables
+	// are selected, but not the files of streams that HarbourBridge didn't
+	// launch.
+	assert.Equal(t, []string{
+		"hb-dataflow-shop-0a1b-2c3d",
+		"hb-dataflow-old-0a1b-2c3d",
+		"orders-stream",
+		"hb-stream-shop_0a1b-2c3d",
+		"hb-stream-old_0a1b-2c3d",
+		"gs://my-bucket/data/",
+		"gs://my-bucket/shop/",
+		failedMigration,
+		runningMigration,
+		bulkMigration,
+		"mysql_2022-11-01_0a1b-2c3d",
+		"pg_dump_2022-11-01_0a1b-2c3d",
+		"mysql_2022-11-02_0a1b-2c3d",
+	}, names(SelectResources(cleanupResources, "", true)))
+	// Resources of a migration are found by their label, or by the name of
+	// their bucket.
+	assert.Equal(t, []string{"orders-stream", "gs://my-bucket/data/", failedMigration},
+		names(SelectResources(cleanupResources, strings.ToUpper(failedMigration[:2])+failedMigration[2:], false)))
+	assert.Empty(t, SelectResources(cleanupResources, runningMigration, false))
+	assert.Equal(t, []string{"hb-dataflow-shop-0a1b-2c3d", "hb-stream-shop_0a1b-2c3d", "gs://my-bucket/shop/", runningMigration},
+		names(SelectResources(cleanupResources, runningMigration, true)))
+}
+
+func TestCleanUp(t *testing.T) {
+	c := &fakeCleaner{resources: cleanupResources, failing: map[string]bool{"orders-stream": true}}
+	resources := SelectResources(cleanupResources, failedMigration, false)
+	var out bytes.Buffer
+	err := CleanUp(context.Background(), c, resources, &out)
+	assert.EqualError(t, err, "could not delete 1 of 3 resources")
+	// A failed deletion doesn't stop the others.
+	assert.Equal(t, []string{"gs://my-bucket/data/", failedMigration}, c.deleted)
+	assert.Equal(t, "Could not delete Datastream stream orders-stream: permission denied\n"+
+		"Deleted GCS prefix gs://my-bucket/data/\n"+
+		"Deleted GCS bucket "+failedMigration+"\n", out.String())
+}
+
+func TestRefPrefixes(t *testing.T) {
+	stream := Resource{Kind: StreamResource, Name: "hb-stream", Labels: labels(failedMigration), Group: failedMigration}
+	job := Resource{Kind: JobResource, Name: "hb-job", Labels: labels(failedMigration), Group: failedMigration}
+	other := Resource{Kind: StreamResource, Name: "cdc-live", Group: "cdc-live"}
+	refs := []gcsRef{
+		{path: "gs://my-bucket/data/", user: stream, owned: true},
+		{path: "gs://my-bucket/data/", user: job, owned: true},
+		{path: "gs://my-bucket/data/session.json", user: job, owned: true},
+		{path: "gs://my-bucket/", user: other},
+		{path: "gs://other-bucket/cdc/", user: other},
+	}
+	// Prefixes are listed once, with the streams and jobs whose paths are
+	// above or under them.
+	assert.Equal(t, []Resource{
+		{Kind: PrefixResource, Id: "gs://my-bucket/data/", Name: "gs://my-bucket/data/", Labels: labels(failedMigration), Group: failedMigration, Users: []string{failedMigration, "cdc-live"}},
+		{Kind: PrefixResource, Id: "gs://my-bucket/data/session.json", Name: "gs://my-bucket/data/session.json", Labels: labels(failedMigration), Group: failedMigration, Users: []string{failedMigration, "cdc-live"}},
+	}, refPrefixes(refs))
+	assert.Equal(t, []string{"cdc-live"}, refUsers(refs, "gs://other-bucket/"))
+	assert.Empty(t, refUsers(refs, "gs://hb-bucket/"))
+}
+
+func TestJobOption(t *testing.T) {
+	options, err := structpb.NewStruct(map[string]interface{}{
+		"options": map[string]interface{}{"sessionFilePath": "gs://my-bucket/tmp/session.json"},
+	})
+	assert.Nil(t, err)
+	job := &dataflowpb.Job{Environment: &dataflowpb.Environment{SdkPipelineOptions: options}}
+	assert.Equal(t, "gs://my-bucket/tmp/session.json", jobOption(job, "sessionFilePath"))
+	assert.Equal(t, "", jobOption(job, "streamName"))
+	assert.Equal(t, "", jobOption(&dataflowpb.Job{}, "streamName"))
+}
+
+func TestGCSPrefix(t *testing.T) {
+	bucket, prefix, ok := gcsPrefix("gs://my-bucket/tmp/session.json")
+	assert.True(t, ok)
+	assert.Equal(t, "my-bucket", bucket)
+	assert.Equal(t, "tmp/session.json", prefix)
+	// Whole buckets aren't prefixes.
+	for _, path := range []string{"gs://my-bucket/", "gs://my-bucket", "my-bucket/data/", ""} {
+		_, _, ok := gcsPrefix(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestPrintResources(t *testing.T) {
+	var out bytes.Buffer
+	PrintResources(&out, []Resource{
+		{Kind: StreamResource, Id: "projects/p/locations/l/streams/orders-stream", Name: "orders-stream", State: "RUNNING", Labels: map[string]string{MigrationLabel: "hb-0a1b"}},
+		{Kind: BucketResource, Id: "hb-0a1b", Name: "hb-0a1b"},
+		{Kind: DatabaseResource, Id: "projects/p/instances/i/databases/mysql_2022-11-01_0a1b-2c3d", Name: "mysql_2022-11-01_0a1b-2c3d", State: "READY", Empty: true},
+	})
+	assert.Equal(t, "  Datastream stream  orders-stream (projects/p/locations/l/streams/orders-stream) RUNNING migration: hb-0a1b\n"+
+		"  GCS bucket         hb-0a1b\n"+
+		"  Spanner database   mysql_2022-11-01_0a1b-2c3d (projects/p/instances/i/databases/mysql_2022-11-01_0a1b-2c3d) READY without tables\n", out.String())
+}
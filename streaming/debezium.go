@@ -0,0 +1,288 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+// Layouts of the temporal values given to the row converters, which parse
+// them like the values of mysqldump and pg_dump.
+const (
+	dateLayout        = "2006-01-02"
+	timestampLayout   = "2006-01-02 15:04:05.999999999"
+	timestampTzLayout = "2006-01-02 15:04:05.999999999Z07"
+	timeLayout        = "15:04:05.999999999"
+)
+
+// debeziumEnvelope is the value of a Debezium change event.
+type debeziumEnvelope struct {
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+	Op     string                 `json:"op"`
+	TsMs   json.Number            `json:"ts_ms"`
+	Source struct {
+		Connector string      `json:"connector"` // mysql or postgresql.
+		Db        string      `json:"db"`
+		Schema    string      `json:"schema"` // Set for PostgreSQL.
+		Table     string      `json:"table"`
+		TsMs      json.Number `json:"ts_ms"`
+		File      string      `json:"file"` // Binlog file, set for MySQL.
+		Pos       int64       `json:"pos"`  // Binlog position, set for MySQL.
+		TxId      uint64      `json:"txId"` // Set for PostgreSQL.
+	} `json:"source"`
+}
+
+// debeziumField is the schema of a field of a Debezium event. Events have a
+// schema when the JSON converter of Kafka Connect has schemas enabled.
+type debeziumField struct {
+	Field      string            `json:"field"`
+	Type       string            `json:"type"`
+	Name       string            `json:"name"` // Logical type, e.g. io.debezium.time.Date.
+	Parameters map[string]string `json:"parameters"`
+	Fields     []debeziumField   `json:"fields"`
+}
+
+// debeziumKinds maps Debezium operations to the kinds of change of the
+// report. Snapshot reads (r) are applied like inserts, so that the initial
+// load and the changes that follow go through the same pipeline.
+var debeziumKinds = map[string]string{
+	"c": "INSERT",
+	"r": "INSERT",
+	"u": "UPDATE",
+	"d": "DELETE",
+	"t": "TRUNCATE",
+	"m": "MESSAGE",
+}
+
+// parseDebeziumEvent parses a Debezium change event, with or without its
+// schema. It returns nil for tombstones.
+func parseDebeziumEvent(raw []byte) (*changeEvent, error) {
+	raw = bytes.TrimSpace(raw)
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &top); err != nil {
+		return nil, err
+	}
+	var rowSchema *debeziumField
+	if payload, ok := top["payload"]; ok {
+		if _, ok := top["schema"]; !ok {
+			return nil, fmt.Errorf("not a Debezium change event: payload without schema")
+		}
+		if err := json.Unmarshal(top["schema"], &rowSchema); err != nil {
+			return nil, err
+		}
+		raw = bytes.TrimSpace(payload)
+		if string(raw) == "null" {
+			return nil, nil
+		}
+	}
+	env := &debeziumEnvelope{}
+	if err := decodeJSON(raw, env); err != nil {
+		return nil, err
+	}
+	if env.Op == "" {
+		return nil, fmt.Errorf("not a Debezium change event: no op")
+	}
+	kind, ok := debeziumKinds[env.Op]
+	if !ok {
+		kind = strings.ToUpper(env.Op)
+	}
+	e := &changeEvent{schema: env.Source.Schema, table: env.Source.Table, kind: kind, row: env.After}
+	e.source = common.ChangeSource{LogFile: env.Source.File, LogPos: env.Source.Pos, TxId: env.Source.TxId}
+	if e.schema == "" {
+		e.schema = env.Source.Db
+	}
+	rowField := "after"
+	if kind == "DELETE" {
+		e.row = env.Before
+		rowField = "before"
+	}
+	ts := env.Source.TsMs
+	if ts == "" {
+		ts = env.TsMs
+	}
+	if ts != "" {
+		ms, err := ts.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("can't parse ts_ms %s", ts)
+		}
+		e.timestamp = time.UnixMilli(ms).UTC()
+	}
+	if rowSchema == nil {
+		return e, nil
+	}
+	fields := make(map[string]debeziumField)
+	for _, f := range rowSchema.Fields {
+		if f.Field == rowField {
+			for _, col := range f.Fields {
+				fields[col.Field] = col
+			}
+		}
+	}
+	for col, v := range e.row {
+		f, ok := fields[col]
+		if !ok {
+			continue
+		}
+		cv, err := debeziumValue(env.Source.Connector, f, v)
+		if err != nil {
+			// The event is reported as a bad record when it is applied.
+			e.err = fmt.Errorf("can't decode column %s: %v", col, err)
+			continue
+		}
+		e.row[col] = cv
+	}
+	return e, nil
+}
+
+// debeziumValue converts a value of a Debezium event to the text that the row
+// converters expect, using the type of its field. Dates and times, decimals
+// and binary values are encoded by Debezium, the other values are unchanged.
+func debeziumValue(connector string, f debeziumField, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch f.Name {
+	case "io.debezium.time.Date", "org.apache.kafka.connect.data.Date":
+		days, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(days*24*60*60, 0).UTC().Format(dateLayout), nil
+	case "io.debezium.time.Timestamp", "org.apache.kafka.connect.data.Timestamp":
+		ms, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(ms).UTC().Format(timestampLayout), nil
+	case "io.debezium.time.MicroTimestamp":
+		us, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMicro(us).UTC().Format(timestampLayout), nil
+	case "io.debezium.time.NanoTimestamp":
+		ns, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(0, ns).UTC().Format(timestampLayout), nil
+	case "io.debezium.time.ZonedTimestamp":
+		s, _ := v.(string)
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse zoned timestamp %v", v)
+		}
+		// The MySQL converter adds the time zone offset itself.
+		if connector == "postgresql" {
+			return t.UTC().Format(timestampTzLayout), nil
+		}
+		return t.UTC().Format(timestampLayout), nil
+	case "io.debezium.time.Time", "org.apache.kafka.connect.data.Time":
+		ms, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMilli(ms).UTC().Format(timeLayout), nil
+	case "io.debezium.time.MicroTime":
+		us, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.UnixMicro(us).UTC().Format(timeLayout), nil
+	case "io.debezium.time.NanoTime":
+		ns, err := intValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(0, ns).UTC().Format(timeLayout), nil
+	case "org.apache.kafka.connect.data.Decimal":
+		s, _ := v.(string)
+		scale, err := strconv.Atoi(f.Parameters["scale"])
+		if err != nil {
+			return nil, fmt.Errorf("decimal field without scale")
+		}
+		return decodeDecimal(s, scale)
+	case "io.debezium.data.VariableScaleDecimal":
+		m, _ := v.(map[string]interface{})
+		s, _ := m["value"].(string)
+		scale, err := intValue(m["scale"])
+		if err != nil {
+			return nil, err
+		}
+		return decodeDecimal(s, int(scale))
+	}
+	if f.Type == "bytes" {
+		s, _ := v.(string)
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("can't decode bytes: %v", err)
+		}
+		// The PostgreSQL converter expects the hex format of pg_dump.
+		if connector == "postgresql" {
+			return `\x` + hex.EncodeToString(b), nil
+		}
+		return string(b), nil
+	}
+	return v, nil
+}
+
+// intValue returns the integer of a JSON number.
+func intValue(v interface{}) (int64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, found %v", v)
+	}
+	return n.Int64()
+}
+
+// decodeDecimal decodes a Kafka Connect decimal: the base64 encoding of its
+// unscaled value, a big-endian two's complement integer.
+func decodeDecimal(s string, scale int) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", fmt.Errorf("can't decode decimal: %v", err)
+	}
+	n := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	if scale <= 0 {
+		return n.Mul(n, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-scale)), nil)).String(), nil
+	}
+	sign := ""
+	if n.Sign() < 0 {
+		sign = "-"
+		n.Neg(n)
+	}
+	digits := n.String()
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:], nil
+}
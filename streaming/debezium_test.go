@@ -0,0 +1,210 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+func TestParseDebeziumEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		expected *changeEvent
+	}{
+		{
+			name: "snapshot read without schema",
+			raw:  `{"before":null,"after":{"id":1,"name":"a"},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296800000,"file":"mysql-bin.000003","pos":154},"op":"r","ts_ms":1667296800500}`,
+			expected: &changeEvent{
+				timestamp: time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC),
+				schema:    "db",
+				table:     "t",
+				kind:      "INSERT",
+				row:       map[string]interface{}{"id": json.Number("1"), "name": "a"},
+				source:    common.ChangeSource{LogFile: "mysql-bin.000003", LogPos: 154},
+			},
+		},
+		{
+			name: "delete of a PostgreSQL row",
+			raw:  `{"before":{"id":2,"name":"b"},"after":null,"source":{"connector":"postgresql","db":"db","schema":"public","table":"t","ts_ms":1667296801000,"txId":742},"op":"d"}`,
+			expected: &changeEvent{
+				timestamp: time.Date(2022, 11, 1, 10, 0, 1, 0, time.UTC),
+				schema:    "public",
+				table:     "t",
+				kind:      "DELETE",
+				row:       map[string]interface{}{"id": json.Number("2"), "name": "b"},
+				source:    common.ChangeSource{TxId: 742},
+			},
+		},
+		{
+			name: "update with schema",
+			raw: `{"schema":{"type":"struct","fields":[{"type":"struct","field":"before","fields":[]},{"type":"struct","field":"after","fields":[` +
+				`{"type":"int32","field":"id"},` +
+				`{"type":"int32","name":"io.debezium.time.Date","field":"d"},` +
+				`{"type":"int64","name":"io.debezium.time.MicroTimestamp","field":"ts"},` +
+				`{"type":"string","name":"io.debezium.time.ZonedTimestamp","field":"zts"},` +
+				`{"type":"bytes","name":"org.apache.kafka.connect.data.Decimal","parameters":{"scale":"2"},"field":"price"},` +
+				`{"type":"bytes","field":"blob"}]}]},` +
+				`"payload":{"before":null,"after":{"id":3,"d":19297,"ts":1667296800123456,"zts":"2022-11-01T12:00:00+02:00","price":"/zg=","blob":"aGk="},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296802000},"op":"u"}}`,
+			expected: &changeEvent{
+				timestamp: time.Date(2022, 11, 1, 10, 0, 2, 0, time.UTC),
+				schema:    "db",
+				table:     "t",
+				kind:      "UPDATE",
+				row: map[string]interface{}{
+					"id":    json.Number("3"),
+					"d":     "2022-11-01",
+					"ts":    "2022-11-01 10:00:00.123456",
+					"zts":   "2022-11-01 10:00:00",
+					"price": "-2.00",
+					"blob":  "hi",
+				},
+			},
+		},
+		{
+			name:     "tombstone",
+			raw:      `null`,
+			expected: nil,
+		},
+		{
+			name:     "tombstone with schema",
+			raw:      `{"schema":null,"payload":null}`,
+			expected: nil,
+		},
+		{
+			name: "truncate",
+			raw:  `{"before":null,"after":null,"source":{"connector":"postgresql","db":"db","schema":"s","table":"t","ts_ms":1667296803000},"op":"t"}`,
+			expected: &changeEvent{
+				timestamp: time.Date(2022, 11, 1, 10, 0, 3, 0, time.UTC),
+				schema:    "s",
+				table:     "t",
+				kind:      "TRUNCATE",
+			},
+		},
+	}
+	for _, tc := range tests {
+		e, err := parseDebeziumEvent([]byte(tc.raw))
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.expected, e, tc.name)
+	}
+
+	_, err := parseDebeziumEvent([]byte(`{"id":1}`))
+	assert.NotNil(t, err)
+}
+
+func TestDebeziumValue(t *testing.T) {
+	bytesField := debeziumField{Type: "bytes"}
+	v, err := debeziumValue("postgresql", bytesField, "aGk=")
+	assert.Nil(t, err)
+	assert.Equal(t, `\x6869`, v)
+
+	zoned := debeziumField{Type: "string", Name: "io.debezium.time.ZonedTimestamp"}
+	v, err = debeziumValue("postgresql", zoned, "2022-11-01T12:00:00.5+02:00")
+	assert.Nil(t, err)
+	assert.Equal(t, "2022-11-01 10:00:00.5Z", v)
+
+	v, err = debeziumValue("mysql", debeziumField{Name: "io.debezium.time.MicroTime"}, json.Number("45296000001"))
+	assert.Nil(t, err)
+	assert.Equal(t, "12:34:56.000001", v)
+
+	v, err = debeziumValue("mysql", debeziumField{Name: "io.debezium.data.VariableScaleDecimal"}, map[string]interface{}{"scale": json.Number("3"), "value": "AQ=="})
+	assert.Nil(t, err)
+	assert.Equal(t, "0.001", v)
+
+	_, err = debeziumValue("mysql", debeziumField{Name: "io.debezium.time.Date"}, "2022-11-01")
+	assert.NotNil(t, err)
+}
+
+func TestDecodeDecimal(t *testing.T) {
+	tests := []struct {
+		b64      string
+		scale    int
+		expected string
+	}{
+		{"MDk=", 2, "123.45"},   // 12345
+		{"z8c=", 2, "-123.45"},  // -12345
+		{"BQ==", 3, "0.005"},    // 5
+		{"+w==", 1, "-0.5"},     // -5
+		{"MDk=", 0, "12345"},    // 12345
+		{"AA==", 2, "0.00"},     // 0
+		{"AIA=", 0, "128"},      // 128, with a leading zero byte
+		{"MDk=", -2, "1234500"}, // 12345, negative scale
+	}
+	for _, tc := range tests {
+		s, err := decodeDecimal(tc.b64, tc.scale)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, s, tc.b64)
+	}
+}
+
+func TestApplyFiles_Debezium(t *testing.T) {
+	dir := t.TempDir()
+	// The snapshot and the changes that follow are applied by the same
+	// pipeline, in the order of their source timestamps.
+	writeFile(t, filepath.Join(dir, "snapshot.jsonl"),
+		`{"before":null,"after":{"id":1,"name":"a"},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296800000},"op":"r"}`+"\n"+
+			`{"before":null,"after":{"id":2,"name":"b"},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296800000},"op":"r"}`+"\n")
+	writeFile(t, filepath.Join(dir, "changes.jsonl"),
+		`{"before":{"id":1,"name":"a"},"after":{"id":1,"name":null},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296801000},"op":"u"}`+"\n"+
+			`{"before":{"id":2,"name":"b"},"after":null,"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296802000},"op":"d"}`+"\n"+
+			"null\n"+
+			`{"before":null,"after":{"id":"x","name":"c"},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296803000},"op":"c"}`+"\n"+
+			`{"before":null,"after":null,"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296804000},"op":"t"}`+"\n"+
+			`{"schema":{"type":"struct","fields":[{"type":"struct","field":"after","fields":[{"type":"int32","field":"id"},{"type":"bytes","field":"name"}]}]},`+
+			`"payload":{"before":null,"after":{"id":4,"name":"not base64"},"source":{"connector":"mysql","db":"db","table":"t","ts_ms":1667296805000},"op":"c"}}`+"\n")
+
+	conv := applyConv()
+	var batches [][]*sp.Mutation
+	write := func(m []*sp.Mutation) error {
+		batches = append(batches, m)
+		return nil
+	}
+	cfg := LocalApplyCfg{Dir: dir, Format: DebeziumFormat, Once: true}
+	assert.Nil(t, applyFiles(context.Background(), localStore{dir: dir}, cfg, newApplier(conv, fakeConverter{}, write)))
+
+	assert.Equal(t, [][]*sp.Mutation{{
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(1), "a"}),
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(2), "b"}),
+		sp.InsertOrUpdate("T", []string{"Id", "Name"}, []interface{}{int64(1), nil}),
+		sp.Delete("T", sp.Key{int64(2)}),
+	}}, batches)
+	stats := conv.Audit.StreamingStats
+	assert.Equal(t, map[string]int64{"INSERT": 4, "UPDATE": 1, "DELETE": 1}, stats.TotalRecords["t"])
+	// The inserts with a bad id and with bad bytes are reported, and the
+	// truncate is skipped.
+	assert.Equal(t, map[string]int64{"INSERT": 2}, stats.BadRecords["t"])
+	assert.Len(t, stats.SampleBadRecords, 2)
+	assert.Equal(t, int64(1), conv.Stats.Unexpected["Can't apply TRUNCATE event for table t"])
+}
+
+func TestVerifyAndUpdateCfg_LocalApplyFormat(t *testing.T) {
+	cfg := StreamingCfg{LocalApplyCfg: LocalApplyCfg{Dir: "/tmp/events", Format: DebeziumFormat}}
+	assert.Nil(t, VerifyAndUpdateCfg(&cfg, "db"))
+
+	cfg.DatastreamCfg.StreamLocation = "us-central1"
+	assert.NotNil(t, VerifyAndUpdateCfg(&cfg, "db"))
+
+	cfg = StreamingCfg{LocalApplyCfg: LocalApplyCfg{Dir: "/tmp/events", Format: "maxwell"}}
+	assert.NotNil(t, VerifyAndUpdateCfg(&cfg, "db"))
+}
@@ -0,0 +1,529 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	dataflow "cloud.google.com/go/dataflow/apiv1beta3"
+	datastream "cloud.google.com/go/datastream/apiv1"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
+	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
+
+	"github.com/cloudspannerecosystem/harbourbridge/sources/common"
+)
+
+// dlqDir is the directory of the dead letter queue of the Dataflow job,
+// under the Datastream output directory.
+const dlqDir = "dlq"
+
+// Counters of the Datastream to Spanner Dataflow template.
+var (
+	// processedCounters count the events processed: the events applied,
+	// and those skipped because Spanner has a newer change of their row.
+	processedCounters = map[string]bool{"Successful events": true, "Skipped events": true}
+	// errorCounters count the events that couldn't be applied. Retryable
+	// errors aren't counted, since their events are applied again.
+	errorCounters = map[string]bool{"Other permanent errors": true}
+)
+
+// dataWatermarkSuffix ends the names of the service metrics of a streaming
+// Dataflow job that give the data watermark of each of its stages, in
+// microseconds since the epoch.
+const dataWatermarkSuffix = "-data-watermark"
+
+// StreamStatus is the state of a Datastream stream.
+type StreamStatus struct {
+	State  string   // e.g. RUNNING, PAUSED or FAILED.
+	Errors []string // Errors of the stream, and of the tables it reads.
+}
+
+// JobStatus is the state of the Dataflow job applying the change events.
+type JobStatus struct {
+	State  string // e.g. RUNNING, DRAINING or FAILED.
+	Events int64  // Count of change events processed since the start of the job.
+	Errors int64  // Count of change events that couldn't be applied.
+	// Watermark is the data watermark of the job: the change events before
+	// it are processed. It is zero if the job doesn't report it.
+	Watermark time.Time
+}
+
+// OutputFile is a file written by Datastream.
+type OutputFile struct {
+	Name    string // Full name of the object, e.g. data/mydb_orders/2022/11/01/10/05/f.avro.
+	Table   string // Directory of the table in the Datastream output, e.g. mydb_orders.
+	Size    int64
+	Created time.Time
+}
+
+// Poller reads the state of a streaming migration through Datastream and
+// Dataflow. It is implemented with the Google Cloud clients by
+// DataflowPoller, and by fakes in tests.
+type Poller interface {
+	Stream(ctx context.Context) (StreamStatus, error)
+	Job(ctx context.Context) (JobStatus, error)
+	// Tables returns the table directories of the Datastream output.
+	Tables(ctx context.Context) ([]string, error)
+	// Files returns the output files of a table whose names are at least
+	// startOffset, in increasing order of names.
+	Files(ctx context.Context, table, startOffset string) ([]OutputFile, error)
+}
+
+// TableThroughput is the output of Datastream for a table during a poll
+// interval.
+type TableThroughput struct {
+	Files int64
+	Bytes int64
+}
+
+// MonitorReport is the state of a streaming migration at a poll.
+type MonitorReport struct {
+	Time            time.Time
+	StreamState     string
+	StreamErrors    []string
+	JobState        string
+	Events          int64                      // Count of change events processed by the job.
+	NewEvents       int64                      // Count of change events processed since the previous poll.
+	JobErrors       int64                      // Count of change events that couldn't be applied.
+	NewJobErrors    int64                      // Count of job errors since the previous poll.
+	Tables          map[string]TableThroughput // Files written for each table since the previous poll.
+	Watermark       time.Time                  // Data watermark of the job, if it reports it.
+	BacklogFiles    int64                      // Files written after the data watermark of the job.
+	BacklogBytes    int64
+	ReadyForCutover bool
+	Reasons         []string // Why the moment isn't optimum for cutover.
+}
+
+// Monitor turns the successive polls of a streaming migration into reports
+// of throughput, backlog and errors, and decides whether the moment is
+// optimum for switching to Cloud Spanner, the same way as for DynamoDB
+// streaming migrations.
+type Monitor struct {
+	poller       Poller
+	tracker      common.ReadinessTracker
+	polls        int64
+	tables       map[string]*tableFiles
+	backlog      []OutputFile // Files that may not be processed yet.
+	lastEvents   int64
+	lastErrors   int64
+	lastProgress time.Time // Time of the last poll in which the job processed events.
+}
+
+// tableFiles is the high-water mark of the output files of a table already
+// reported. Datastream writes the files of a table under directories named
+// after the minute they were written in (yyyy/mm/dd/hh/mm), so only the
+// files of the last directory can be followed by files with smaller names.
+type tableFiles struct {
+	dir  string          // Directory of the last file reported.
+	seen map[string]bool // Files of dir already reported.
+}
+
+// NewMonitor returns a Monitor of the streaming migration polled by p.
+func NewMonitor(p Poller) *Monitor {
+	return &Monitor{poller: p, tables: make(map[string]*tableFiles)}
+}
+
+// Poll polls the stream and the job, and reports their state at now. The
+// first poll only sets the baseline of the throughput. The Dataflow job
+// doesn't report which files it processed: the backlog is estimated as the
+// files written after the data watermark of the job, or if the job doesn't
+// report it, since the last poll in which the job processed events.
+func (m *Monitor) Poll(ctx context.Context, now time.Time) (MonitorReport, error) {
+	stream, err := m.poller.Stream(ctx)
+	if err != nil {
+		return MonitorReport{}, fmt.Errorf("can't get the state of the stream: %v", err)
+	}
+	job, err := m.poller.Job(ctx)
+	if err != nil {
+		return MonitorReport{}, fmt.Errorf("can't get the state of the Dataflow job: %v", err)
+	}
+	files, err := m.newFiles(ctx)
+	if err != nil {
+		return MonitorReport{}, fmt.Errorf("can't list the Datastream output files: %v", err)
+	}
+	r := MonitorReport{
+		Time:         now,
+		StreamState:  stream.State,
+		StreamErrors: stream.Errors,
+		JobState:     job.State,
+		Events:       job.Events,
+		NewEvents:    job.Events - m.lastEvents,
+		JobErrors:    job.Errors,
+		NewJobErrors: job.Errors - m.lastErrors,
+		Watermark:    job.Watermark,
+		Tables:       make(map[string]TableThroughput),
+	}
+	first := m.polls == 0
+	m.polls++
+	if r.NewEvents > 0 {
+		m.lastProgress = now
+	}
+	for _, f := range files {
+		if !first {
+			t := r.Tables[f.Table]
+			t.Files++
+			t.Bytes += f.Size
+			r.Tables[f.Table] = t
+		}
+	}
+	processed := m.lastProgress
+	if !job.Watermark.IsZero() {
+		processed = job.Watermark
+	}
+	m.backlog = append(m.backlog, files...)
+	backlog := m.backlog[:0]
+	for _, f := range m.backlog {
+		if f.Created.After(processed) {
+			backlog = append(backlog, f)
+			r.BacklogFiles++
+			r.BacklogBytes += f.Size
+		}
+	}
+	m.backlog = backlog
+	m.lastEvents, m.lastErrors = job.Events, job.Errors
+
+	optimum := false
+	if first {
+		// The events processed before the first poll aren't part of the
+		// throughput of any interval.
+		m.tracker.Start(job.Events)
+		r.Reasons = append(r.Reasons, "the throughput is measured from the next poll")
+	} else {
+		optimum, _ = m.tracker.Update(job.Events)
+	}
+	if stream.State != "RUNNING" {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("the stream is %s", stream.State))
+	}
+	if job.State != "RUNNING" {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("the Dataflow job is %s", job.State))
+	}
+	if len(stream.Errors) > 0 {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("the stream has %d errors", len(stream.Errors)))
+	}
+	if r.NewJobErrors > 0 {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%d change events couldn't be applied since the last poll", r.NewJobErrors))
+	}
+	if r.BacklogFiles > 0 {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("%d files are waiting to be processed", r.BacklogFiles))
+	}
+	if !first && !optimum {
+		r.Reasons = append(r.Reasons, "the change events haven't slowed down yet")
+	}
+	r.ReadyForCutover = len(r.Reasons) == 0
+	return r, nil
+}
+
+// newFiles returns the output files written since the last poll. Only the
+// files after the high-water mark of each table are listed.
+func (m *Monitor) newFiles(ctx context.Context) ([]OutputFile, error) {
+	tables, err := m.poller.Tables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var files []OutputFile
+	for _, table := range tables {
+		hw, ok := m.tables[table]
+		if !ok {
+			hw = &tableFiles{seen: make(map[string]bool)}
+			m.tables[table] = hw
+		}
+		listed, err := m.poller.Files(ctx, table, hw.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range listed {
+			if hw.seen[f.Name] {
+				continue
+			}
+			if dir := path.Dir(f.Name) + "/"; dir > hw.dir {
+				hw.dir = dir
+				hw.seen = make(map[string]bool)
+			}
+			hw.seen[f.Name] = true
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// Print writes the report to w.
+func (r MonitorReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "\n%s\n", r.Time.Format(time.RFC3339))
+	fmt.Fprintf(w, "Stream: %s, Dataflow job: %s\n", r.StreamState, r.JobState)
+	fmt.Fprintf(w, "Change events processed: %d (+%d)\n", r.Events, r.NewEvents)
+	fmt.Fprintf(w, "Change events that couldn't be applied: %d (+%d)\n", r.JobErrors, r.NewJobErrors)
+	if !r.Watermark.IsZero() {
+		fmt.Fprintf(w, "Data watermark of the job: %s (%s behind)\n", r.Watermark.Format(time.RFC3339), r.Time.Sub(r.Watermark).Round(time.Second))
+	}
+	fmt.Fprintf(w, "Backlog: %d files (%d bytes)\n", r.BacklogFiles, r.BacklogBytes)
+	if len(r.Tables) > 0 {
+		fmt.Fprintf(w, "Files written since the last poll:\n")
+		var tables []string
+		for t := range r.Tables {
+			tables = append(tables, t)
+		}
+		sort.Strings(tables)
+		for _, t := range tables {
+			fmt.Fprintf(w, "  %s: %d files (%d bytes)\n", t, r.Tables[t].Files, r.Tables[t].Bytes)
+		}
+	}
+	for _, e := range r.StreamErrors {
+		fmt.Fprintf(w, "Stream error: %s\n", e)
+	}
+	fmt.Fprintf(w, "Optimum time for switching to Cloud Spanner: %t\n", r.ReadyForCutover)
+	if len(r.Reasons) > 0 {
+		fmt.Fprintf(w, "  (%s)\n", strings.Join(r.Reasons, "; "))
+	}
+}
+
+// RunMonitor prints a report of the streaming migration polled by p every
+// interval, until Ctrl+C or SIGTERM. The cutover readiness counts the events
+// of each interval like those of a minute for DynamoDB.
+func RunMonitor(ctx context.Context, p Poller, interval time.Duration, w io.Writer) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	m := NewMonitor(p)
+	for {
+		r, err := m.Poll(ctx, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		r.Print(w)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// DataflowPoller polls a Datastream stream and the Dataflow job applying its
+// output files, with the Google Cloud clients.
+type DataflowPoller struct {
+	stream      string // Full name of the stream: projects/<project>/locations/<location>/streams/<id>.
+	project     string
+	jobId       string
+	jobLocation string
+	ds          *datastream.Client
+	jobs        *dataflow.JobsV1Beta3Client
+	metrics     *dataflow.MetricsV1Beta3Client
+	gcs         *storage.Client
+	bucket      string
+	prefix      string // Prefix of the Datastream output files in bucket.
+}
+
+// NewDataflowPoller returns a DataflowPoller of the stream, whose full name
+// is printed when the stream is launched, and of the Dataflow job jobId. The
+// location of the output files is read from the stream.
+func NewDataflowPoller(ctx context.Context, stream, jobId, jobLocation string) (*DataflowPoller, error) {
+	parts := strings.Split(stream, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "streams" {
+		return nil, fmt.Errorf("invalid stream %s: expected projects/<project>/locations/<location>/streams/<id>", stream)
+	}
+	p := &DataflowPoller{stream: stream, project: parts[1], jobId: jobId, jobLocation: jobLocation}
+	var err error
+	if p.ds, err = datastream.NewClient(ctx); err != nil {
+		return nil, fmt.Errorf("datastream client can not be created: %v", err)
+	}
+	if p.jobs, err = dataflow.NewJobsV1Beta3Client(ctx); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("could not create job client: %v", err)
+	}
+	if p.metrics, err = dataflow.NewMetricsV1Beta3Client(ctx); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("could not create metrics client: %v", err)
+	}
+	if p.gcs, err = storage.NewClient(ctx); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("failed to create GCS client: %v", err)
+	}
+	s, err := p.ds.GetStream(ctx, &datastreampb.GetStreamRequest{Name: stream})
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("could not get stream %s: %v", stream, err)
+	}
+	res, err := p.ds.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: s.GetDestinationConfig().GetDestinationConnectionProfile()})
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("could not get connection profiles: %v", err)
+	}
+	gcsProfile := res.GetGcsProfile()
+	if gcsProfile == nil {
+		p.Close()
+		return nil, fmt.Errorf("the destination of stream %s isn't GCS", stream)
+	}
+	p.bucket = gcsProfile.Bucket
+	p.prefix = outputPrefix(gcsProfile.RootPath, s.GetDestinationConfig().GetGcsDestinationConfig().GetPath())
+	return p, nil
+}
+
+// Close closes the clients of the poller.
+func (p *DataflowPoller) Close() {
+	if p.ds != nil {
+		p.ds.Close()
+	}
+	if p.jobs != nil {
+		p.jobs.Close()
+	}
+	if p.metrics != nil {
+		p.metrics.Close()
+	}
+	if p.gcs != nil {
+		p.gcs.Close()
+	}
+}
+
+// Stream implements the Poller interface.
+func (p *DataflowPoller) Stream(ctx context.Context) (StreamStatus, error) {
+	s, err := p.ds.GetStream(ctx, &datastreampb.GetStreamRequest{Name: p.stream})
+	if err != nil {
+		return StreamStatus{}, err
+	}
+	status := StreamStatus{State: s.GetState().String()}
+	for _, e := range s.GetErrors() {
+		status.Errors = append(status.Errors, e.GetMessage())
+	}
+	it := p.ds.ListStreamObjects(ctx, &datastreampb.ListStreamObjectsRequest{Parent: p.stream})
+	for {
+		o, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return StreamStatus{}, err
+		}
+		for _, e := range o.GetErrors() {
+			status.Errors = append(status.Errors, fmt.Sprintf("%s: %s", o.GetDisplayName(), e.GetMessage()))
+		}
+	}
+	return status, nil
+}
+
+// Job implements the Poller interface.
+func (p *DataflowPoller) Job(ctx context.Context) (JobStatus, error) {
+	job, err := p.jobs.GetJob(ctx, &dataflowpb.GetJobRequest{ProjectId: p.project, JobId: p.jobId, Location: p.jobLocation})
+	if err != nil {
+		return JobStatus{}, err
+	}
+	metrics, err := p.metrics.GetJobMetrics(ctx, &dataflowpb.GetJobMetricsRequest{ProjectId: p.project, JobId: p.jobId, Location: p.jobLocation})
+	if err != nil {
+		return JobStatus{}, err
+	}
+	status := jobCounters(metrics.GetMetrics())
+	status.State = strings.TrimPrefix(job.GetCurrentState().String(), "JOB_STATE_")
+	return status, nil
+}
+
+// Tables implements the Poller interface.
+func (p *DataflowPoller) Tables(ctx context.Context) ([]string, error) {
+	var tables []string
+	it := p.gcs.Bucket(p.bucket).Objects(ctx, &storage.Query{Prefix: p.prefix, Delimiter: "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if table, ok := outputTable(p.prefix, attrs.Prefix); ok {
+			tables = append(tables, table)
+		}
+	}
+	return tables, nil
+}
+
+// Files implements the Poller interface.
+func (p *DataflowPoller) Files(ctx context.Context, table, startOffset string) ([]OutputFile, error) {
+	var files []OutputFile
+	it := p.gcs.Bucket(p.bucket).Objects(ctx, &storage.Query{Prefix: p.prefix + table + "/", StartOffset: startOffset})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasSuffix(attrs.Name, "/") {
+			continue
+		}
+		files = append(files, OutputFile{Name: attrs.Name, Table: table, Size: attrs.Size, Created: attrs.Created})
+	}
+	return files, nil
+}
+
+// outputPrefix returns the prefix of the Datastream output files in the
+// bucket, from the root path of the connection profile and the path of the
+// stream.
+func outputPrefix(rootPath, path string) string {
+	prefix := strings.TrimPrefix(rootPath+path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// outputTable returns the table of a directory of the Datastream output. The
+// dead letter queue of the Dataflow job isn't a table.
+func outputTable(prefix, dir string) (string, bool) {
+	rest := strings.TrimSuffix(strings.TrimPrefix(dir, prefix), "/")
+	if dir == "" || rest == "" || strings.Contains(rest, "/") || rest == dlqDir {
+		return "", false
+	}
+	return rest, true
+}
+
+// jobCounters sums the user counters of the Dataflow template listed in
+// processedCounters and errorCounters, and takes the data watermark of the
+// job as the smallest watermark of its stages.
+func jobCounters(metrics []*dataflowpb.MetricUpdate) JobStatus {
+	var status JobStatus
+	for _, m := range metrics {
+		name := m.GetName()
+		if name.GetContext()["tentative"] == "true" {
+			continue
+		}
+		n := int64(m.GetScalar().GetNumberValue())
+		switch {
+		case name.GetOrigin() == "user" && processedCounters[name.GetName()]:
+			status.Events += n
+		case name.GetOrigin() == "user" && errorCounters[name.GetName()]:
+			status.Errors += n
+		case name.GetOrigin() != "user" && strings.HasSuffix(name.GetName(), dataWatermarkSuffix):
+			// Stages that haven't started, or that are done, have watermarks
+			// at the start or the end of time.
+			wm := time.UnixMicro(n).UTC()
+			if n <= 0 || wm.Year() > 9999 {
+				continue
+			}
+			if status.Watermark.IsZero() || wm.Before(status.Watermark) {
+				status.Watermark = wm
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,261 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// fakePoller returns the state set by the test, and records the offsets
+// of the listings of files.
+type fakePoller struct {
+	stream  StreamStatus
+	job     JobStatus
+	files   []OutputFile
+	offsets []string
+}
+
+func (f *fakePoller) Stream(ctx context.Context) (StreamStatus, error) { return f.stream, nil }
+func (f *fakePoller) Job(ctx context.Context) (JobStatus, error)       { return f.job, nil }
+
+func (f *fakePoller) Tables(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	var tables []string
+	for _, file := range f.files {
+		if !seen[file.Table] {
+			seen[file.Table] = true
+			tables = append(tables, file.Table)
+		}
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+func (f *fakePoller) Files(ctx context.Context, table, startOffset string) ([]OutputFile, error) {
+	f.offsets = append(f.offsets, startOffset)
+	var files []OutputFile
+	for _, file := range f.files {
+		if file.Table == table && file.Name >= startOffset {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// outputFile returns a file of the table written at created, in the
+// directory of its minute like Datastream.
+func outputFile(name, table string, size int64, created time.Time) OutputFile {
+	return OutputFile{Name: table + created.Format("/2006/01/02/15/04/") + name, Table: table, Size: size, Created: created}
+}
+
+func TestMonitor(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	file := outputFile
+	p := &fakePoller{
+		stream: StreamStatus{State: "RUNNING"},
+		job:    JobStatus{State: "RUNNING", Events: 100},
+		files:  []OutputFile{file("a1", "db_a", 10, t0.Add(-time.Minute)), file("b1", "db_b", 10, t0.Add(-time.Minute))},
+	}
+	m := NewMonitor(p)
+
+	// The first poll sets the baseline.
+	r, err := m.Poll(ctx, t0)
+	assert.Nil(t, err)
+	assert.Empty(t, r.Tables)
+	assert.Equal(t, int64(0), r.BacklogFiles)
+	assert.False(t, r.ReadyForCutover)
+	assert.Equal(t, []string{"the throughput is measured from the next poll"}, r.Reasons)
+
+	p.job.Events = 300
+	p.files = append(p.files, file("a2", "db_a", 10, t0.Add(30*time.Second)), file("a3", "db_a", 20, t0.Add(40*time.Second)), file("b2", "db_b", 5, t0.Add(50*time.Second)))
+	r, err = m.Poll(ctx, t0.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(200), r.NewEvents)
+	assert.Equal(t, map[string]TableThroughput{"db_a": {Files: 2, Bytes: 30}, "db_b": {Files: 1, Bytes: 5}}, r.Tables)
+	assert.Equal(t, int64(0), r.BacklogFiles)
+	assert.Equal(t, []string{"the change events haven't slowed down yet"}, r.Reasons)
+
+	// The job stops processing events: the new file is in the backlog.
+	p.job.Errors = 2
+	p.files = append(p.files, file("a4", "db_a", 7, t0.Add(90*time.Second)))
+	r, err = m.Poll(ctx, t0.Add(2*time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), r.NewEvents)
+	assert.Equal(t, int64(1), r.BacklogFiles)
+	assert.Equal(t, int64(7), r.BacklogBytes)
+	assert.Equal(t, []string{"2 change events couldn't be applied since the last poll", "1 files are waiting to be processed"}, r.Reasons)
+
+	p.job.Events = 305
+	r, err = m.Poll(ctx, t0.Add(3*time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), r.BacklogFiles)
+	assert.Equal(t, int64(0), r.NewJobErrors)
+	assert.Equal(t, []string{"the change events haven't slowed down yet"}, r.Reasons)
+
+	// A poll without events is optimum for cutover.
+	r, err = m.Poll(ctx, t0.Add(4*time.Minute))
+	assert.Nil(t, err)
+	assert.True(t, r.ReadyForCutover)
+	assert.Empty(t, r.Reasons)
+
+	var out bytes.Buffer
+	r.Print(&out)
+	assert.Contains(t, out.String(), "Change events processed: 305 (+0)\n")
+	assert.Contains(t, out.String(), "Optimum time for switching to Cloud Spanner: true\n")
+}
+
+func TestMonitor_Watermark(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	p := &fakePoller{
+		stream: StreamStatus{State: "RUNNING"},
+		job:    JobStatus{State: "RUNNING", Events: 100, Watermark: t0.Add(-time.Minute)},
+		files:  []OutputFile{outputFile("a1", "db_a", 10, t0.Add(-2*time.Minute)), outputFile("a2", "db_a", 20, t0.Add(-30*time.Second))},
+	}
+	m := NewMonitor(p)
+
+	// The job processes events, but the files written after its watermark
+	// are still in the backlog.
+	r, err := m.Poll(ctx, t0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), r.BacklogFiles)
+	assert.Equal(t, int64(20), r.BacklogBytes)
+
+	p.job.Events = 200
+	p.job.Watermark = t0
+	p.files = append(p.files, outputFile("a3", "db_a", 5, t0.Add(30*time.Second)))
+	r, err = m.Poll(ctx, t0.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), r.BacklogFiles)
+	assert.Equal(t, int64(5), r.BacklogBytes)
+
+	var out bytes.Buffer
+	r.Print(&out)
+	assert.Contains(t, out.String(), "Data watermark of the job: 2022-11-01T10:00:00Z (1m0s behind)\n")
+}
+
+func TestMonitor_Failures(t *testing.T) {
+	p := &fakePoller{
+		stream: StreamStatus{State: "FAILED", Errors: []string{"db.a: table not found"}},
+		job:    JobStatus{State: "CANCELLED"},
+	}
+	m := NewMonitor(p)
+	_, err := m.Poll(context.Background(), time.Now())
+	assert.Nil(t, err)
+	r, err := m.Poll(context.Background(), time.Now())
+	assert.Nil(t, err)
+	assert.False(t, r.ReadyForCutover)
+	assert.Equal(t, []string{"the stream is FAILED", "the Dataflow job is CANCELLED", "the stream has 1 errors"}, r.Reasons)
+
+	var out bytes.Buffer
+	r.Print(&out)
+	assert.Contains(t, out.String(), "Stream error: db.a: table not found\n")
+}
+
+func TestMonitor_SteadyLoad(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	// The job processed many events before the monitor started, and keeps
+	// processing as many events each minute.
+	p := &fakePoller{stream: StreamStatus{State: "RUNNING"}, job: JobStatus{State: "RUNNING", Events: 1000000}}
+	m := NewMonitor(p)
+	for i := 0; i < 20; i++ {
+		r, err := m.Poll(ctx, t0.Add(time.Duration(i)*time.Minute))
+		assert.Nil(t, err)
+		assert.False(t, r.ReadyForCutover, i)
+		p.job.Events += 500
+	}
+}
+
+func TestMonitor_ListsNewFiles(t *testing.T) {
+	ctx := context.Background()
+	t0 := time.Date(2022, 11, 1, 10, 0, 0, 0, time.UTC)
+	p := &fakePoller{
+		stream: StreamStatus{State: "RUNNING"},
+		job:    JobStatus{State: "RUNNING", Events: 10},
+		files:  []OutputFile{outputFile("f1", "db_a", 1, t0.Add(-2*time.Minute)), outputFile("f2", "db_a", 1, t0.Add(-time.Minute))},
+	}
+	m := NewMonitor(p)
+	_, err := m.Poll(ctx, t0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{""}, p.offsets)
+
+	// A file with a smaller name is written in the directory of the last
+	// file, and another in the next minute.
+	p.job.Events = 20
+	p.files = append(p.files, outputFile("e1", "db_a", 2, t0.Add(-time.Minute)), outputFile("a1", "db_a", 3, t0.Add(30*time.Second)))
+	r, err := m.Poll(ctx, t0.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]TableThroughput{"db_a": {Files: 2, Bytes: 5}}, r.Tables)
+	assert.Equal(t, "db_a/2022/11/01/09/59/", p.offsets[1])
+	assert.Equal(t, map[string]bool{"db_a/2022/11/01/10/00/a1": true}, m.tables["db_a"].seen)
+
+	p.job.Events = 30
+	r, err = m.Poll(ctx, t0.Add(2*time.Minute))
+	assert.Nil(t, err)
+	assert.Empty(t, r.Tables)
+	assert.Equal(t, "db_a/2022/11/01/10/00/", p.offsets[2])
+}
+
+func TestOutputTable(t *testing.T) {
+	prefix := outputPrefix("/root", "/data")
+	assert.Equal(t, "root/data/", prefix)
+	assert.Equal(t, "", outputPrefix("", ""))
+	for dir, want := range map[string]string{
+		"root/data/db_orders/": "db_orders",
+		"root/data/dlq/":       "",
+		"root/data/":           "",
+		"":                     "",
+	} {
+		table, ok := outputTable(prefix, dir)
+		assert.Equal(t, want, table, dir)
+		assert.Equal(t, want != "", ok, dir)
+	}
+}
+
+func TestJobCounters(t *testing.T) {
+	counter := func(origin, name string, tentative bool, v float64) *dataflowpb.MetricUpdate {
+		ctx := map[string]string{}
+		if tentative {
+			ctx["tentative"] = "true"
+		}
+		return &dataflowpb.MetricUpdate{Name: &dataflowpb.MetricStructuredName{Origin: origin, Name: name, Context: ctx}, Scalar: structpb.NewNumberValue(v)}
+	}
+	status := jobCounters([]*dataflowpb.MetricUpdate{
+		counter("user", "Successful events", false, 120),
+		counter("user", "Successful events", true, 125),
+		counter("user", "Skipped events", false, 3),
+		counter("user", "Retryable errors", false, 2),
+		counter("user", "Other permanent errors", false, 1),
+		counter("user", "Total events", false, 200),
+		counter("dataflow/v1b3", "ElementCount", false, 1000),
+		// The watermark of the job is the smallest of its stages, ignoring
+		// those that haven't started.
+		counter("dataflow/v1b3", "F12-windmill-data-watermark", false, 1667296800000000),
+		counter("dataflow/v1b3", "F15-windmill-data-watermark", false, 1667296790000000),
+		counter("dataflow/v1b3", "F18-windmill-data-watermark", false, -9223372036854775808),
+	})
+	assert.Equal(t, JobStatus{Events: 123, Errors: 1, Watermark: time.Date(2022, 11, 1, 9, 59, 50, 0, time.UTC)}, status)
+}
@@ -0,0 +1,329 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	datastream "cloud.google.com/go/datastream/apiv1"
+	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
+	dataflowpb "google.golang.org/genproto/googleapis/dataflow/v1beta3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/common/utils"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+)
+
+var (
+	// datastreamIdRe matches the ids of streams and connection profiles.
+	datastreamIdRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+	// regionRe matches the Google Cloud regions, e.g. us-central1.
+	regionRe = regexp.MustCompile(`^[a-z]+(-[a-z]+)+[0-9]+$`)
+	// jobNameRe matches the names of Dataflow jobs.
+	jobNameRe = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+)
+
+// StreamedTables is implemented by the InfoSchema of the sources whose stream
+// only reads some of their tables, e.g. the tables of the publication of a
+// PostgreSQL stream. It returns the names of the streamed tables, as in the
+// session.
+type StreamedTables interface {
+	StreamedTables(ctx context.Context, datastreamCfg DatastreamCfg) ([]string, error)
+}
+
+// StreamingPlan is what a streaming migration would launch: the Datastream
+// stream and the Dataflow job, or the local apply of the change event files.
+type StreamingPlan struct {
+	Cfg          StreamingCfg
+	CreateStream *datastreampb.CreateStreamRequest     // Nil if no stream is launched.
+	LaunchJob    *dataflowpb.LaunchFlexTemplateRequest // Nil if the files are applied by HarbourBridge.
+	Errors       []string                              // Problems that would make the streaming migration fail.
+	Warnings     []string
+}
+
+func (p *StreamingPlan) errorf(format string, a ...interface{}) {
+	p.Errors = append(p.Errors, fmt.Sprintf(format, a...))
+}
+
+func (p *StreamingPlan) warnf(format string, a ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, a...))
+}
+
+// PlanStreamingMigration validates the streaming config of sourceProfile
+// against the session, and returns the requests that the streaming migration
+// would send to Datastream and Dataflow, without calling their APIs. If
+// sourceProfile.CheckResources is set, it also checks that the resources
+// referenced by the config exist, and that the source streams the tables of
+// the session. The config problems are returned in the plan.
+func PlanStreamingMigration(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, conv *internal.Conv, source StreamedTables) (*StreamingPlan, error) {
+	file, err := streamingConfigFile(sourceProfile)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadStreamingConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	plan := &StreamingPlan{}
+	project, instance, dbName := targetProfile.Conn.Sp.Project, targetProfile.Conn.Sp.Instance, targetProfile.Conn.Sp.Dbname
+	if project == "" {
+		if project, err = utils.GetProject(); err != nil {
+			return nil, fmt.Errorf("can't get project: %v", err)
+		}
+	}
+	if instance == "" {
+		plan.warnf("the Spanner instance isn't set in the target profile: it is chosen when the migration starts")
+		instance = "<instance>"
+	}
+	if dbName == "" {
+		plan.warnf("the Spanner database isn't set in the target profile: its name is generated when the migration starts")
+		dbName = "<database>"
+	}
+	given := cfg
+	if err := verifyAndUpdateCfg(&cfg, dbName); err != nil {
+		plan.errorf("%v", err)
+		return plan, nil
+	}
+	plan.Cfg = cfg
+	plan.validateCfg(given)
+	plan.validateSession(conv, sourceProfile)
+
+	dsCfg := cfg.DatastreamCfg
+	if dsCfg.StreamLocation != "" {
+		plan.CreateStream, err = getCreateStreamRequest(sourceProfile, project, dsCfg, cfg.SnapshotCfg.PositionFile == "", conv.Audit.MigrationRequestId)
+		if err != nil {
+			plan.errorf("%v", err)
+		}
+	}
+	var dsClient *datastream.Client
+	if sourceProfile.CheckResources && dsCfg.StreamLocation != "" {
+		dsClient, err = datastream.NewClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("datastream client can not be created: %v", err)
+		}
+		defer dsClient.Close()
+	}
+	if cfg.LocalApplyCfg.Dir == "" {
+		// The GCS path of the output files is only known from the destination
+		// connection profile.
+		inputFilePattern := inputFilePatternOf(fmt.Sprintf("<bucket and root path of %s>", dsCfg.DestinationConnectionConfig.Name), "/", dsCfg.DestinationConnectionConfig.Prefix)
+		if dsClient != nil {
+			if inputFilePattern, err = getInputFilePattern(ctx, dsClient, project, dsCfg); err != nil {
+				plan.errorf("%v", err)
+			}
+		}
+		plan.LaunchJob, err = getLaunchFlexTemplateRequest(project, instance, dbName, cfg, inputFilePattern, conv.Audit.MigrationRequestId)
+		if err != nil {
+			plan.errorf("%v", err)
+		}
+	}
+	if sourceProfile.CheckResources {
+		plan.checkResources(ctx, dsClient, project, conv, source)
+	}
+	return plan, nil
+}
+
+// validateCfg checks the ids and the regions of the config. The names that
+// aren't given in the config file are generated, and not checked.
+func (p *StreamingPlan) validateCfg(given StreamingCfg) {
+	dsCfg := p.Cfg.DatastreamCfg
+	if dsCfg.StreamLocation != "" {
+		ids := map[string]string{
+			"DatastreamCfg.SourceConnectionConfig.Name":      dsCfg.SourceConnectionConfig.Name,
+			"DatastreamCfg.DestinationConnectionConfig.Name": dsCfg.DestinationConnectionConfig.Name,
+		}
+		if given.DatastreamCfg.StreamId != "" || given.DatastreamCfg.StreamDisplayName != "" {
+			ids["DatastreamCfg.StreamId"] = dsCfg.StreamId
+		}
+		for field, id := range ids {
+			if !datastreamIdRe.MatchString(id) {
+				p.errorf("%s %q isn't a valid id: ids have at most 63 lowercase letters, digits, hyphens and underscores", field, id)
+			}
+		}
+		p.validateRegion("DatastreamCfg.StreamLocation", dsCfg.StreamLocation)
+		for field, location := range map[string]string{
+			"DatastreamCfg.SourceConnectionConfig.Location":      dsCfg.SourceConnectionConfig.Location,
+			"DatastreamCfg.DestinationConnectionConfig.Location": dsCfg.DestinationConnectionConfig.Location,
+		} {
+			if p.validateRegion(field, location) && location != dsCfg.StreamLocation {
+				p.errorf("%s is %s, but the connection profiles of a stream must be in its region %s", field, location, dsCfg.StreamLocation)
+			}
+		}
+	}
+	if p.Cfg.LocalApplyCfg.Dir == "" {
+		dfCfg := p.Cfg.DataflowCfg
+		if given.DataflowCfg.JobName != "" && !jobNameRe.MatchString(dfCfg.JobName) {
+			p.errorf("DataflowCfg.JobName %q isn't a valid Dataflow job name: it must start with a lowercase letter, followed by lowercase letters, digits and hyphens", dfCfg.JobName)
+		}
+		p.validateRegion("DataflowCfg.Location", dfCfg.Location)
+	}
+	sort.Strings(p.Errors)
+}
+
+func (p *StreamingPlan) validateRegion(field, location string) bool {
+	if !regionRe.MatchString(location) {
+		p.errorf("%s %q isn't a Google Cloud region, e.g. us-central1", field, location)
+		return false
+	}
+	return true
+}
+
+// validateSession checks that the tables of the session can be streamed.
+func (p *StreamingPlan) validateSession(conv *internal.Conv, sourceProfile profiles.SourceProfile) {
+	if len(conv.SrcSchema) == 0 {
+		p.errorf("the session has no tables")
+		return
+	}
+	// The stream reads the tables of one MySQL database or Oracle schema.
+	streamed := ""
+	if p.Cfg.DatastreamCfg.StreamLocation != "" {
+		switch sourceProfile.Driver {
+		case constants.MYSQL:
+			streamed = sourceProfile.Conn.Mysql.Db
+		case constants.ORACLE:
+			streamed = sourceProfile.Conn.Oracle.User
+		}
+	}
+	for _, srcTable := range sortedTables(conv) {
+		t := conv.SrcSchema[srcTable]
+		if streamed != "" && t.Schema != "" && !strings.EqualFold(t.Schema, streamed) {
+			p.errorf("table %s of the session is in %s, but the stream reads %s", srcTable, t.Schema, streamed)
+		}
+		if _, ok := conv.SyntheticPKeys[conv.ToSpanner[srcTable].Name]; ok {
+			p.warnf("table %s has no primary key: the updates and deletes of its rows can't be applied", srcTable)
+		}
+	}
+}
+
+// checkResources checks that the resources referenced by the config exist,
+// and that the source streams the tables of the session.
+func (p *StreamingPlan) checkResources(ctx context.Context, dsClient *datastream.Client, project string, conv *internal.Conv, source StreamedTables) {
+	dsCfg := p.Cfg.DatastreamCfg
+	if dsClient != nil {
+		for _, name := range []string{
+			fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", project, dsCfg.SourceConnectionConfig.Location, dsCfg.SourceConnectionConfig.Name),
+			fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", project, dsCfg.DestinationConnectionConfig.Location, dsCfg.DestinationConnectionConfig.Name),
+		} {
+			if _, err := dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: name}); err != nil {
+				p.errorf("can't get connection profile %s: %v", name, err)
+			}
+		}
+		name := fmt.Sprintf("projects/%s/locations/%s/streams/%s", project, dsCfg.StreamLocation, dsCfg.StreamId)
+		_, err := dsClient.GetStream(ctx, &datastreampb.GetStreamRequest{Name: name})
+		switch {
+		case err == nil:
+			p.errorf("stream %s already exists", name)
+		case status.Code(err) != codes.NotFound:
+			p.errorf("can't check that stream %s doesn't exist: %v", name, err)
+		}
+	}
+	if p.LaunchJob != nil {
+		if err := checkBucket(ctx, p.Cfg.TmpDir); err != nil {
+			p.errorf("TmpDir %s: %v", p.Cfg.TmpDir, err)
+		}
+	}
+	if dir := p.Cfg.LocalApplyCfg.Dir; dir != "" {
+		var err error
+		if strings.HasPrefix(dir, "gs://") {
+			err = checkBucket(ctx, dir)
+		} else {
+			_, err = os.Stat(dir)
+		}
+		if err != nil {
+			p.errorf("LocalApplyCfg.Dir %s: %v", dir, err)
+		}
+	}
+	if source != nil && dsCfg.StreamLocation != "" {
+		p.checkStreamedTables(ctx, conv, source)
+	}
+}
+
+// checkStreamedTables checks that the source streams the tables of the
+// session.
+func (p *StreamingPlan) checkStreamedTables(ctx context.Context, conv *internal.Conv, source StreamedTables) {
+	tables, err := source.StreamedTables(ctx, p.Cfg.DatastreamCfg)
+	if err != nil {
+		p.errorf("can't get the tables read by the stream: %v", err)
+		return
+	}
+	streamed := make(map[string]bool)
+	for _, t := range tables {
+		streamed[t] = true
+		if _, ok := conv.SrcSchema[t]; !ok {
+			p.warnf("table %s is streamed, but isn't in the session: its change events will be dropped", t)
+		}
+	}
+	for _, t := range sortedTables(conv) {
+		if !streamed[t] {
+			p.errorf("table %s of the session isn't streamed: its changes after the snapshot would be missed", t)
+		}
+	}
+}
+
+func sortedTables(conv *internal.Conv) []string {
+	var tables []string
+	for t := range conv.SrcSchema {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// Print writes the plan to w.
+func (p *StreamingPlan) Print(w io.Writer) {
+	fmt.Fprintf(w, "\nStreaming migration plan (dry run: nothing is launched)\n")
+	marshal := protojson.MarshalOptions{Multiline: true, Indent: "  "}
+	for _, r := range []struct {
+		title string
+		req   proto.Message
+		set   bool
+	}{
+		{"Datastream stream to create", p.CreateStream, p.CreateStream != nil},
+		{"Dataflow job to launch", p.LaunchJob, p.LaunchJob != nil},
+	} {
+		if !r.set {
+			continue
+		}
+		b, err := marshal.Marshal(r.req)
+		if err != nil {
+			fmt.Fprintf(w, "%s: can't encode the request: %v\n", r.title, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n%s\n", r.title, b)
+	}
+	if dir := p.Cfg.LocalApplyCfg.Dir; dir != "" {
+		fmt.Fprintf(w, "The change event files in %s are applied by HarbourBridge.\n", dir)
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning)
+	}
+	for _, e := range p.Errors {
+		fmt.Fprintf(w, "Error: %s\n", e)
+	}
+	if len(p.Errors) == 0 {
+		fmt.Fprintf(w, "The streaming config is valid.\n")
+	}
+}
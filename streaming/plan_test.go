@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	datastreampb "google.golang.org/genproto/googleapis/cloud/datastream/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/common/constants"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/profiles"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+func validStreamingCfg() StreamingCfg {
+	return StreamingCfg{
+		DatastreamCfg: DatastreamCfg{
+			StreamId:                    "shop-stream",
+			StreamLocation:              "us-central1",
+			SourceConnectionConfig:      SrcConnCfg{Name: "mysql-src", Location: "us-central1"},
+			DestinationConnectionConfig: DstConnCfg{Name: "gcs-dst", Location: "us-central1", Prefix: "data/"},
+		},
+		DataflowCfg: DataflowCfg{JobName: "shop-job", Location: "us-central1"},
+		TmpDir:      "gs://my-bucket/tmp/",
+	}
+}
+
+// planProfiles returns the profiles of a MySQL streaming migration with the
+// streaming config cfg.
+func planProfiles(t *testing.T, cfg StreamingCfg) (profiles.SourceProfile, profiles.TargetProfile) {
+	b, err := json.Marshal(cfg)
+	assert.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "streaming.json")
+	assert.Nil(t, os.WriteFile(path, b, 0644))
+	sourceProfile := profiles.SourceProfile{
+		Driver: constants.MYSQL,
+		Conn: profiles.SourceProfileConnection{
+			Ty:        profiles.SourceProfileConnectionTypeMySQL,
+			Streaming: true,
+			Mysql:     profiles.SourceProfileConnectionMySQL{Db: "shop", StreamingConfig: path},
+		},
+	}
+	targetProfile := profiles.TargetProfile{Conn: profiles.TargetProfileConnection{Sp: profiles.TargetProfileConnectionSpanner{Project: "my-project", Instance: "my-instance", Dbname: "shop"}}}
+	return sourceProfile, targetProfile
+}
+
+func planConv(tables ...string) *internal.Conv {
+	conv := internal.MakeConv()
+	for _, name := range tables {
+		conv.SrcSchema[name] = schema.Table{Name: name, Schema: "shop"}
+		conv.ToSpanner[name] = internal.NameAndCols{Name: name}
+	}
+	return conv
+}
+
+func TestPlanStreamingMigration(t *testing.T) {
+	sourceProfile, targetProfile := planProfiles(t, validStreamingCfg())
+	conv := planConv("orders")
+	conv.Audit.MigrationRequestId = "HB-0A1B"
+	plan, err := PlanStreamingMigration(context.Background(), sourceProfile, targetProfile, conv, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, plan.Errors)
+	assert.Empty(t, plan.Warnings)
+
+	assert.Equal(t, "projects/my-project/locations/us-central1", plan.CreateStream.Parent)
+	assert.Equal(t, "shop-stream", plan.CreateStream.StreamId)
+	assert.Equal(t, "projects/my-project/locations/us-central1/connectionProfiles/mysql-src", plan.CreateStream.Stream.SourceConfig.SourceConnectionProfile)
+	assert.Equal(t, "shop", plan.CreateStream.Stream.SourceConfig.GetMysqlSourceConfig().IncludeObjects.MysqlDatabases[0].Database)
+	assert.NotNil(t, plan.CreateStream.Stream.GetBackfillAll())
+	assert.Equal(t, map[string]string{MigrationLabel: "hb-0a1b"}, plan.CreateStream.Stream.Labels)
+
+	assert.Equal(t, "us-central1", plan.LaunchJob.Location)
+	params := plan.LaunchJob.LaunchParameter.Parameters
+	assert.Equal(t, "gs://<bucket and root path of gcs-dst>/data/", params["inputFilePattern"])
+	assert.Equal(t, "projects/my-project/locations/us-central1/streams/shop-stream", params["streamName"])
+	assert.Equal(t, "my-instance", params["instanceId"])
+	assert.Equal(t, "gs://my-bucket/tmp/session.json", params["sessionFilePath"])
+	assert.Equal(t, map[string]string{MigrationLabel: "hb-0a1b"}, plan.LaunchJob.LaunchParameter.Environment.AdditionalUserLabels)
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	assert.Contains(t, out.String(), "Datastream stream to create:\n")
+	// protojson randomizes the spaces of its output.
+	assert.Regexp(t, `"jobName":\s+"shop-job"`, out.String())
+	assert.Contains(t, out.String(), "The streaming config is valid.\n")
+}
+
+func TestPlanStreamingMigration_Snapshot(t *testing.T) {
+	cfg := validStreamingCfg()
+	cfg.SnapshotCfg.PositionFile = "position.json"
+	cfg.LocalApplyCfg.Dir = "gs://my-bucket/data/"
+	sourceProfile, targetProfile := planProfiles(t, cfg)
+	plan, err := PlanStreamingMigration(context.Background(), sourceProfile, targetProfile, planConv("orders"), nil)
+	assert.Nil(t, err)
+	assert.Empty(t, plan.Errors)
+	assert.IsType(t, &datastreampb.Stream_BackfillNone{}, plan.CreateStream.Stream.BackfillStrategy)
+	assert.Nil(t, plan.LaunchJob)
+
+	// A Dataflow job can't start at the position of the snapshot.
+	cfg.LocalApplyCfg.Dir = ""
+	sourceProfile, targetProfile = planProfiles(t, cfg)
+	plan, err = PlanStreamingMigration(context.Background(), sourceProfile, targetProfile, planConv("orders"), nil)
+	assert.Nil(t, err)
+	assert.Len(t, plan.Errors, 1)
+	assert.Contains(t, plan.Errors[0], "SnapshotCfg needs a LocalApplyCfg.Dir")
+}
+
+func TestPlanStreamingMigration_Errors(t *testing.T) {
+	cfg := validStreamingCfg()
+	cfg.DatastreamCfg.SourceConnectionConfig.Name = "MySQL_Source"
+	cfg.DatastreamCfg.DestinationConnectionConfig.Location = "us-east1"
+	cfg.DataflowCfg.JobName = "shop_job"
+	cfg.DataflowCfg.Location = "uscentral"
+	cfg.DataflowCfg.Network = "my-network"
+	sourceProfile, targetProfile := planProfiles(t, cfg)
+	conv := planConv("orders", "items")
+	conv.SrcSchema["items"] = schema.Table{Name: "items", Schema: "archive"}
+	conv.SyntheticPKeys["orders"] = internal.SyntheticPKey{Col: "synth_id"}
+	plan, err := PlanStreamingMigration(context.Background(), sourceProfile, targetProfile, conv, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		`DataflowCfg.JobName "shop_job" isn't a valid Dataflow job name: it must start with a lowercase letter, followed by lowercase letters, digits and hyphens`,
+		`DataflowCfg.Location "uscentral" isn't a Google Cloud region, e.g. us-central1`,
+		"DatastreamCfg.DestinationConnectionConfig.Location is us-east1, but the connection profiles of a stream must be in its region us-central1",
+		`DatastreamCfg.SourceConnectionConfig.Name "MySQL_Source" isn't a valid id: ids have at most 63 lowercase letters, digits, hyphens and underscores`,
+		"table items of the session is in archive, but the stream reads shop",
+		"if network is specified, subnetwork cannot be empty",
+	}, plan.Errors)
+	assert.Equal(t, []string{"table orders has no primary key: the updates and deletes of its rows can't be applied"}, plan.Warnings)
+
+	cfg.DatastreamCfg.StreamLocation = ""
+	sourceProfile, targetProfile = planProfiles(t, cfg)
+	plan, err = PlanStreamingMigration(context.Background(), sourceProfile, targetProfile, conv, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"please specify DatastreamCfg.StreamLocation in the streaming config"}, plan.Errors)
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	assert.Contains(t, out.String(), "Error: please specify DatastreamCfg.StreamLocation in the streaming config\n")
+	assert.NotContains(t, out.String(), "The streaming config is valid.")
+}
+
+func TestPlanStreamingMigration_LocalApply(t *testing.T) {
+	cfg := StreamingCfg{LocalApplyCfg: LocalApplyCfg{Dir: filepath.Join(t.TempDir(), "missing"), Format: DebeziumFormat}}
+	sourceProfile, targetProfile := planProfiles(t, cfg)
+	targetProfile.Conn.Sp.Instance = ""
+	sourceProfile.CheckResources = true
+	plan, err := PlanStreamingMigration(context.Background(), sourceProfile, targetProfile, planConv("orders"), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, plan.CreateStream)
+	assert.Nil(t, plan.LaunchJob)
+	assert.Equal(t, 1, len(plan.Errors))
+	assert.Contains(t, plan.Errors[0], "LocalApplyCfg.Dir")
+	assert.Equal(t, []string{"the Spanner instance isn't set in the target profile: it is chosen when the migration starts"}, plan.Warnings)
+}
+
+// fakeStreamedTables returns the tables set by the test.
+type fakeStreamedTables []string
+
+func (f fakeStreamedTables) StreamedTables(ctx context.Context, datastreamCfg DatastreamCfg) ([]string, error) {
+	return f, nil
+}
+
+func TestCheckStreamedTables(t *testing.T) {
+	plan := &StreamingPlan{Cfg: validStreamingCfg()}
+	plan.checkStreamedTables(context.Background(), planConv("orders", "items"), fakeStreamedTables{"orders", "logs"})
+	assert.Equal(t, []string{"table items of the session isn't streamed: its changes after the snapshot would be missed"}, plan.Errors)
+	assert.Equal(t, []string{"table logs is streamed, but isn't in the session: its change events will be dropped"}, plan.Warnings)
+}
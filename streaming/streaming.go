@@ -36,6 +36,9 @@ import (
 
 const (
 	maxWorkers int32 = 50
+	// defaultSnapshotReaders is the default number of parallel readers of
+	// a consistent snapshot.
+	defaultSnapshotReaders = 4
 )
 
 type SrcConnCfg struct {
@@ -59,33 +62,83 @@ type DatastreamCfg struct {
 }
 
 type DataflowCfg struct {
-	JobName  string
-	Location string
+	JobName       string
+	Location      string
 	HostProjectId string
-	Network string
-	Subnetwork string
+	Network       string
+	Subnetwork    string
+}
+
+// SnapshotCfg configures HarbourBridge to read the snapshot of a MySQL or
+// PostgreSQL database itself, under a consistent snapshot, instead of the
+// Datastream backfill. The position of the change log at the snapshot is
+// recorded, so that the local apply of the change events starts exactly
+// there: the binlog position for MySQL, and the txid snapshot for
+// PostgreSQL. It needs a LocalApplyCfg, since a Dataflow job can't be
+// started at that position.
+type SnapshotCfg struct {
+	PositionFile string // File where the position of the snapshot is recorded. The snapshot is read by HarbourBridge if it is set.
+	Readers      int    // Number of parallel readers sharing the snapshot (default 4).
 }
 
 type StreamingCfg struct {
 	DatastreamCfg DatastreamCfg
 	DataflowCfg   DataflowCfg
 	TmpDir        string
+	LocalApplyCfg LocalApplyCfg
+	SnapshotCfg   SnapshotCfg
 }
 
 // VerifyAndUpdateCfg checks the fields and errors out if certain fields are empty.
 // It then auto-populates certain empty fields like StreamId and Dataflow JobName.
+// When the Datastream output files are applied by HarbourBridge itself, the
+// Dataflow config isn't needed, and the stream is only launched if DatastreamCfg
+// is specified. Debezium change event files don't need a stream. When the
+// snapshot is read by HarbourBridge, its position is used by the local apply.
 func VerifyAndUpdateCfg(streamingCfg *StreamingCfg, dbName string) error {
-	dsCfg := streamingCfg.DatastreamCfg
-	if dsCfg.StreamLocation == "" {
-		return fmt.Errorf("please specify DatastreamCfg.StreamLocation in the streaming config")
+	if err := verifyAndUpdateCfg(streamingCfg, dbName); err != nil {
+		return err
 	}
-	srcCfg := dsCfg.SourceConnectionConfig
-	if srcCfg.Name == "" || srcCfg.Location == "" {
-		return fmt.Errorf("please specify Name and Location under DatastreamCfg.SourceConnectionConfig in the streaming config")
+	if streamingCfg.LocalApplyCfg.Dir != "" {
+		return nil
 	}
-	dstCfg := dsCfg.DestinationConnectionConfig
-	if dstCfg.Name == "" || dstCfg.Location == "" {
-		return fmt.Errorf("please specify Name and Location under DatastreamCfg.DestinationConnectionConfig in the streaming config")
+	return checkBucket(context.Background(), streamingCfg.TmpDir)
+}
+
+// verifyAndUpdateCfg is VerifyAndUpdateCfg without the checks that call
+// Google Cloud APIs.
+func verifyAndUpdateCfg(streamingCfg *StreamingCfg, dbName string) error {
+	if snapshotCfg := &streamingCfg.SnapshotCfg; snapshotCfg.PositionFile != "" {
+		if streamingCfg.LocalApplyCfg.Dir == "" {
+			return fmt.Errorf("SnapshotCfg needs a LocalApplyCfg.Dir: the changes can only be applied from the snapshot position by HarbourBridge")
+		}
+		if snapshotCfg.Readers < 0 {
+			return fmt.Errorf("SnapshotCfg.Readers must be positive")
+		}
+		if snapshotCfg.Readers == 0 {
+			snapshotCfg.Readers = defaultSnapshotReaders
+		}
+		if streamingCfg.LocalApplyCfg.PositionFile == "" {
+			streamingCfg.LocalApplyCfg.PositionFile = snapshotCfg.PositionFile
+		}
+	}
+	if streamingCfg.LocalApplyCfg.Dir != "" {
+		switch streamingCfg.LocalApplyCfg.Format {
+		case "", DatastreamFormat:
+		case DebeziumFormat:
+			if streamingCfg.DatastreamCfg.StreamLocation != "" {
+				return fmt.Errorf("DatastreamCfg can't be specified when LocalApplyCfg.Format is %s", DebeziumFormat)
+			}
+		default:
+			return fmt.Errorf("unknown LocalApplyCfg.Format %s: supported formats are %s and %s", streamingCfg.LocalApplyCfg.Format, DatastreamFormat, DebeziumFormat)
+		}
+		if streamingCfg.DatastreamCfg.StreamLocation == "" {
+			return nil
+		}
+		return verifyAndUpdateDatastreamCfg(streamingCfg, dbName)
+	}
+	if err := verifyAndUpdateDatastreamCfg(streamingCfg, dbName); err != nil {
+		return err
 	}
 
 	dfCfg := streamingCfg.DataflowCfg
@@ -93,22 +146,6 @@ func VerifyAndUpdateCfg(streamingCfg *StreamingCfg, dbName string) error {
 		return fmt.Errorf("please specify the Location under DataflowCfg in the streaming config")
 	}
 
-	// If both ID and Display name are empty, generate a new one for both.
-	// If either is present, assign it to the other one.
-	if dsCfg.StreamId == "" && dsCfg.StreamDisplayName == "" {
-		// TODO: Update names to have more info like dbname.
-		streamId, err := utils.GenerateName("hb-stream-" + dbName)
-		if err != nil {
-			return fmt.Errorf("error generating stream name: %v", err)
-		}
-		streamingCfg.DatastreamCfg.StreamId = streamId
-		streamingCfg.DatastreamCfg.StreamDisplayName = streamId
-	} else if dsCfg.StreamId == "" {
-		streamingCfg.DatastreamCfg.StreamId = streamingCfg.DatastreamCfg.StreamDisplayName
-	} else if dsCfg.StreamDisplayName == "" {
-		streamingCfg.DatastreamCfg.StreamDisplayName = streamingCfg.DatastreamCfg.StreamId
-	}
-
 	if dfCfg.JobName == "" {
 		// Update names to have more info like dbname.
 		jobName, err := utils.GenerateName("hb-dataflow-" + dbName)
@@ -126,23 +163,77 @@ func VerifyAndUpdateCfg(streamingCfg *StreamingCfg, dbName string) error {
 	}
 	// We update the TmpDir in case any '/' were added in ParseGCSFilePath().
 	streamingCfg.TmpDir = u.String()
-	bucketName := u.Host
-	ctx := context.Background()
+	return nil
+}
+
+// checkBucket checks that the bucket of a GCS path exists.
+func checkBucket(ctx context.Context, path string) error {
+	u, err := utils.ParseGCSFilePath(path)
+	if err != nil {
+		return fmt.Errorf("parseFilePath: unable to parse file path: %v", err)
+	}
 	client, err := storage.NewClient(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to create GCS client")
 	}
 	defer client.Close()
-	bucket := client.Bucket(bucketName)
-	_, err = bucket.Attrs(ctx)
+	_, err = client.Bucket(u.Host).Attrs(ctx)
 	if err != nil {
-		return fmt.Errorf("bucket %s does not exist", bucketName)
+		return fmt.Errorf("bucket %s does not exist", u.Host)
+	}
+	return nil
+}
+
+// verifyAndUpdateDatastreamCfg checks the Datastream config, and generates the
+// stream name if it is empty.
+func verifyAndUpdateDatastreamCfg(streamingCfg *StreamingCfg, dbName string) error {
+	dsCfg := streamingCfg.DatastreamCfg
+	if dsCfg.StreamLocation == "" {
+		return fmt.Errorf("please specify DatastreamCfg.StreamLocation in the streaming config")
+	}
+	srcCfg := dsCfg.SourceConnectionConfig
+	if srcCfg.Name == "" || srcCfg.Location == "" {
+		return fmt.Errorf("please specify Name and Location under DatastreamCfg.SourceConnectionConfig in the streaming config")
+	}
+	dstCfg := dsCfg.DestinationConnectionConfig
+	if dstCfg.Name == "" || dstCfg.Location == "" {
+		return fmt.Errorf("please specify Name and Location under DatastreamCfg.DestinationConnectionConfig in the streaming config")
+	}
+
+	// If both ID and Display name are empty, generate a new one for both.
+	// If either is present, assign it to the other one.
+	if dsCfg.StreamId == "" && dsCfg.StreamDisplayName == "" {
+		// TODO: Update names to have more info like dbname.
+		streamId, err := utils.GenerateName("hb-stream-" + dbName)
+		if err != nil {
+			return fmt.Errorf("error generating stream name: %v", err)
+		}
+		streamingCfg.DatastreamCfg.StreamId = streamId
+		streamingCfg.DatastreamCfg.StreamDisplayName = streamId
+	} else if dsCfg.StreamId == "" {
+		streamingCfg.DatastreamCfg.StreamId = streamingCfg.DatastreamCfg.StreamDisplayName
+	} else if dsCfg.StreamDisplayName == "" {
+		streamingCfg.DatastreamCfg.StreamDisplayName = streamingCfg.DatastreamCfg.StreamId
 	}
 	return nil
 }
 
 // ReadStreamingConfig reads the file and unmarshalls it into the StreamingCfg struct.
 func ReadStreamingConfig(file, dbName string) (StreamingCfg, error) {
+	streamingCfg, err := loadStreamingConfig(file)
+	if err != nil {
+		return streamingCfg, err
+	}
+	err = VerifyAndUpdateCfg(&streamingCfg, dbName)
+	if err != nil {
+		return streamingCfg, fmt.Errorf("streaming config is incomplete: %v", err)
+	}
+	return streamingCfg, nil
+}
+
+// loadStreamingConfig reads the file and unmarshalls it into the StreamingCfg
+// struct, without verifying it.
+func loadStreamingConfig(file string) (StreamingCfg, error) {
 	streamingCfg := StreamingCfg{}
 	cfgFile, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -152,10 +243,6 @@ func ReadStreamingConfig(file, dbName string) (StreamingCfg, error) {
 	if err != nil {
 		return streamingCfg, fmt.Errorf("unable to unmarshall json due to: %v", err)
 	}
-	err = VerifyAndUpdateCfg(&streamingCfg, dbName)
-	if err != nil {
-		return streamingCfg, fmt.Errorf("streaming config is incomplete: %v", err)
-	}
 	return streamingCfg, nil
 }
 
@@ -224,7 +311,8 @@ func getSourceStreamConfig(srcCfg *datastreampb.SourceConfig, sourceProfile prof
 }
 
 // LaunchStream populates the parameters from the streaming config and triggers a stream on Cloud Datastream.
-func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, projectID string, datastreamCfg DatastreamCfg) error {
+// The stream doesn't backfill the tables if their snapshot is read by HarbourBridge.
+func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, projectID string, datastreamCfg DatastreamCfg, backfill bool, migrationRequestId string) error {
 	fmt.Println("Launching stream ", fmt.Sprintf("projects/%s/locations/%s", projectID, datastreamCfg.StreamLocation))
 	dsClient, err := datastream.NewClient(ctx)
 	if err != nil {
@@ -233,35 +321,11 @@ func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, pro
 	defer dsClient.Close()
 	fmt.Println("Created client...")
 
-	gcsDstCfg := &datastreampb.GcsDestinationConfig{
-		Path:       datastreamCfg.DestinationConnectionConfig.Prefix,
-		FileFormat: &datastreampb.GcsDestinationConfig_AvroFileFormat{},
-	}
-	srcCfg := &datastreampb.SourceConfig{
-		SourceConnectionProfile: fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", projectID, datastreamCfg.SourceConnectionConfig.Location, datastreamCfg.SourceConnectionConfig.Name),
-	}
-	err = getSourceStreamConfig(srcCfg, sourceProfile, datastreamCfg)
+	createStreamRequest, err := getCreateStreamRequest(sourceProfile, projectID, datastreamCfg, backfill, migrationRequestId)
 	if err != nil {
-		return fmt.Errorf("could not get source stream config: %v", err)
-	}
-
-	dstCfg := &datastreampb.DestinationConfig{
-		DestinationConnectionProfile: fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", projectID, datastreamCfg.DestinationConnectionConfig.Location, datastreamCfg.DestinationConnectionConfig.Name),
-		DestinationStreamConfig:      &datastreampb.DestinationConfig_GcsDestinationConfig{GcsDestinationConfig: gcsDstCfg},
-	}
-	streamInfo := &datastreampb.Stream{
-		DisplayName:       datastreamCfg.StreamDisplayName,
-		SourceConfig:      srcCfg,
-		DestinationConfig: dstCfg,
-		State:             datastreampb.Stream_RUNNING,
-		BackfillStrategy:  &datastreampb.Stream_BackfillAll{BackfillAll: &datastreampb.Stream_BackfillAllStrategy{}},
-	}
-	createStreamRequest := &datastreampb.CreateStreamRequest{
-		Parent:   fmt.Sprintf("projects/%s/locations/%s", projectID, datastreamCfg.StreamLocation),
-		StreamId: datastreamCfg.StreamId,
-		Stream:   streamInfo,
+		return err
 	}
-
+	streamInfo := createStreamRequest.Stream
 	fmt.Println("Created stream request..")
 
 	dsOp, err := dsClient.CreateStream(ctx, createStreamRequest)
@@ -295,6 +359,44 @@ func LaunchStream(ctx context.Context, sourceProfile profiles.SourceProfile, pro
 	return nil
 }
 
+// getCreateStreamRequest returns the request that creates the stream of
+// datastreamCfg. The stream doesn't backfill the tables if backfill is false,
+// and is labelled with the migration request id.
+func getCreateStreamRequest(sourceProfile profiles.SourceProfile, projectID string, datastreamCfg DatastreamCfg, backfill bool, migrationRequestId string) (*datastreampb.CreateStreamRequest, error) {
+	gcsDstCfg := &datastreampb.GcsDestinationConfig{
+		Path:       datastreamCfg.DestinationConnectionConfig.Prefix,
+		FileFormat: &datastreampb.GcsDestinationConfig_AvroFileFormat{},
+	}
+	srcCfg := &datastreampb.SourceConfig{
+		SourceConnectionProfile: fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", projectID, datastreamCfg.SourceConnectionConfig.Location, datastreamCfg.SourceConnectionConfig.Name),
+	}
+	err := getSourceStreamConfig(srcCfg, sourceProfile, datastreamCfg)
+	if err != nil {
+		return nil, fmt.Errorf("could not get source stream config: %v", err)
+	}
+
+	dstCfg := &datastreampb.DestinationConfig{
+		DestinationConnectionProfile: fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", projectID, datastreamCfg.DestinationConnectionConfig.Location, datastreamCfg.DestinationConnectionConfig.Name),
+		DestinationStreamConfig:      &datastreampb.DestinationConfig_GcsDestinationConfig{GcsDestinationConfig: gcsDstCfg},
+	}
+	streamInfo := &datastreampb.Stream{
+		DisplayName:       datastreamCfg.StreamDisplayName,
+		Labels:            migrationLabels(migrationRequestId),
+		SourceConfig:      srcCfg,
+		DestinationConfig: dstCfg,
+		State:             datastreampb.Stream_RUNNING,
+		BackfillStrategy:  &datastreampb.Stream_BackfillAll{BackfillAll: &datastreampb.Stream_BackfillAllStrategy{}},
+	}
+	if !backfill {
+		streamInfo.BackfillStrategy = &datastreampb.Stream_BackfillNone{BackfillNone: &datastreampb.Stream_BackfillNoneStrategy{}}
+	}
+	return &datastreampb.CreateStreamRequest{
+		Parent:   fmt.Sprintf("projects/%s/locations/%s", projectID, datastreamCfg.StreamLocation),
+		StreamId: datastreamCfg.StreamId,
+		Stream:   streamInfo,
+	}, nil
+}
+
 func CleanUpStreamingJobs(ctx context.Context, conv *internal.Conv, projectID, region string) error {
 	c, err := dataflow.NewJobsV1Beta3Client(ctx)
 	if err != nil {
@@ -356,49 +458,72 @@ func LaunchDataflowJob(ctx context.Context, targetProfile profiles.TargetProfile
 	}
 	defer dsClient.Close()
 
-	// Fetch the GCS path from the destination connection profile.
+	inputFilePattern, err := getInputFilePattern(ctx, dsClient, project, datastreamCfg)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Reading files from datastream destination ", inputFilePattern)
+	req, err := getLaunchFlexTemplateRequest(project, instance, dbName, streamingCfg, inputFilePattern, conv.Audit.MigrationRequestId)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Created flex template request body...")
+
+	respDf, err := c.LaunchFlexTemplate(ctx, req)
+	if err != nil {
+		fmt.Printf("flexTemplateRequest: %+v\n", req)
+		return fmt.Errorf("unable to launch template: %v", err)
+	}
+	printDataflowJob(conv, datastreamCfg, respDf, project)
+	return nil
+}
+
+// getInputFilePattern returns the GCS path of the Datastream output files,
+// from the destination connection profile.
+func getInputFilePattern(ctx context.Context, dsClient *datastream.Client, project string, datastreamCfg DatastreamCfg) (string, error) {
 	dstProf := fmt.Sprintf("projects/%s/locations/%s/connectionProfiles/%s", project, datastreamCfg.DestinationConnectionConfig.Location, datastreamCfg.DestinationConnectionConfig.Name)
 	res, err := dsClient.GetConnectionProfile(ctx, &datastreampb.GetConnectionProfileRequest{Name: dstProf})
 	if err != nil {
-		return fmt.Errorf("could not get connection profiles: %v", err)
+		return "", fmt.Errorf("could not get connection profiles: %v", err)
 	}
-	gcsProfile := res.Profile.(*datastreampb.ConnectionProfile_GcsProfile).GcsProfile
-	inputFilePattern := "gs://" + gcsProfile.Bucket + gcsProfile.RootPath + datastreamCfg.DestinationConnectionConfig.Prefix
+	gcsProfile := res.GetGcsProfile()
+	if gcsProfile == nil {
+		return "", fmt.Errorf("connection profile %s isn't a GCS profile", dstProf)
+	}
+	return inputFilePatternOf(gcsProfile.Bucket, gcsProfile.RootPath, datastreamCfg.DestinationConnectionConfig.Prefix), nil
+}
+
+// inputFilePatternOf returns the GCS path of the Datastream output files
+// under the root path of a bucket.
+func inputFilePatternOf(bucket, rootPath, prefix string) string {
+	inputFilePattern := "gs://" + bucket + rootPath + prefix
 	if inputFilePattern[len(inputFilePattern)-1] != '/' {
 		inputFilePattern = inputFilePattern + "/"
 	}
-	fmt.Println("Reading files from datastream destination ", inputFilePattern)
-	var dataflowHostProjectId string
-	if dataflowCfg.HostProjectId == "" {
-		dataflowHostProjectId, _ = utils.GetProject()
-	} else {
-		dataflowHostProjectId = dataflowCfg.HostProjectId
-	}
+	return inputFilePattern
+}
+
+// getLaunchFlexTemplateRequest returns the request that launches the Dataflow
+// job applying the Datastream output files under inputFilePattern. The job is
+// labelled with the migration request id.
+func getLaunchFlexTemplateRequest(project, instance, dbName string, streamingCfg StreamingCfg, inputFilePattern, migrationRequestId string) (*dataflowpb.LaunchFlexTemplateRequest, error) {
+	dataflowCfg := streamingCfg.DataflowCfg
 	dataflowSubnetwork := ""
 	if dataflowCfg.Network != "" {
 		if dataflowCfg.Subnetwork == "" {
-			return fmt.Errorf("if network is specified, subnetwork cannot be empty")
-		} else {
-			dataflowSubnetwork = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s/subnetworks/%s", dataflowHostProjectId, dataflowCfg.Location, dataflowCfg.Subnetwork)
+			return nil, fmt.Errorf("if network is specified, subnetwork cannot be empty")
 		}
+		dataflowHostProjectId := dataflowCfg.HostProjectId
+		if dataflowHostProjectId == "" {
+			dataflowHostProjectId, _ = utils.GetProject()
+		}
+		dataflowSubnetwork = fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/regions/%s/subnetworks/%s", dataflowHostProjectId, dataflowCfg.Location, dataflowCfg.Subnetwork)
 	}
-	
-	launchParameters := createLaunchParameters(dataflowCfg, inputFilePattern, project, datastreamCfg, instance, dbName, streamingCfg, dataflowSubnetwork)
-
-	req := &dataflowpb.LaunchFlexTemplateRequest{
+	return &dataflowpb.LaunchFlexTemplateRequest{
 		ProjectId:       project,
-		LaunchParameter: launchParameters,
+		LaunchParameter: createLaunchParameters(dataflowCfg, inputFilePattern, project, streamingCfg.DatastreamCfg, instance, dbName, streamingCfg, dataflowSubnetwork, migrationRequestId),
 		Location:        dataflowCfg.Location,
-	}
-	fmt.Println("Created flex template request body...")
-
-	respDf, err := c.LaunchFlexTemplate(ctx, req)
-	if err != nil {
-		fmt.Printf("flexTemplateRequest: %+v\n", req)
-		return fmt.Errorf("unable to launch template: %v", err)
-	}
-	printDataflowJob(conv, datastreamCfg, respDf, project)
-	return nil
+	}, nil
 }
 
 func printDataflowJob(conv *internal.Conv, datastreamCfg DatastreamCfg, respDf *dataflowpb.LaunchFlexTemplateResponse, project string) {
@@ -408,10 +533,11 @@ func printDataflowJob(conv *internal.Conv, datastreamCfg DatastreamCfg, respDf *
 	dfJobDetails := fmt.Sprintf("project: %s, location: %s, name: %s, id: %s", project, respDf.Job.Location, respDf.Job.Name, respDf.Job.Id)
 	fmt.Println("\n------------------------------------------\n" +
 		"The Datastream job: " + fullStreamName + "and the Dataflow job: " + dfJobDetails +
-		" will have to be manually cleaned up via the UI. HarbourBridge will not delete them post completion of the migration.")
+		" will have to be cleaned up via the UI or `harbourbridge cleanup`. HarbourBridge will not delete them post completion of the migration.")
+	fmt.Printf("Use `harbourbridge monitor -stream=%s -job-id=%s -job-location=%s` to follow the migration and the readiness for cutover.\n", fullStreamName, respDf.Job.Id, respDf.Job.Location)
 }
 
-func createLaunchParameters(dataflowCfg DataflowCfg, inputFilePattern string, project string, datastreamCfg DatastreamCfg, instance string, dbName string, streamingCfg StreamingCfg, dataflowSubnetwork string) *dataflowpb.LaunchFlexTemplateParameter {
+func createLaunchParameters(dataflowCfg DataflowCfg, inputFilePattern string, project string, datastreamCfg DatastreamCfg, instance string, dbName string, streamingCfg StreamingCfg, dataflowSubnetwork string, migrationRequestId string) *dataflowpb.LaunchFlexTemplateParameter {
 	return &dataflowpb.LaunchFlexTemplateParameter{
 		JobName:  dataflowCfg.JobName,
 		Template: &dataflowpb.LaunchFlexTemplateParameter_ContainerSpecGcsPath{ContainerSpecGcsPath: "gs://dataflow-templates-southamerica-west1/2023-01-29-00_RC00/flex/Cloud_Datastream_to_Spanner"},
@@ -429,30 +555,46 @@ func createLaunchParameters(dataflowCfg DataflowCfg, inputFilePattern string, pr
 			EnableStreamingEngine: true,
 			Network:               dataflowCfg.Network,
 			Subnetwork:            dataflowSubnetwork,
+			AdditionalUserLabels:  migrationLabels(migrationRequestId),
 		},
 	}
 }
 
 func getStreamingConfig(sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile) (StreamingCfg, error) {
+	file, err := streamingConfigFile(sourceProfile)
+	if err != nil {
+		return StreamingCfg{}, err
+	}
+	return ReadStreamingConfig(file, targetProfile.Conn.Sp.Dbname)
+}
+
+// streamingConfigFile returns the streaming config file of the source profile.
+func streamingConfigFile(sourceProfile profiles.SourceProfile) (string, error) {
 	switch sourceProfile.Conn.Ty {
 	case profiles.SourceProfileConnectionTypeMySQL:
-		return ReadStreamingConfig(sourceProfile.Conn.Mysql.StreamingConfig, targetProfile.Conn.Sp.Dbname)
+		return sourceProfile.Conn.Mysql.StreamingConfig, nil
 	case profiles.SourceProfileConnectionTypeOracle:
-		return ReadStreamingConfig(sourceProfile.Conn.Oracle.StreamingConfig, targetProfile.Conn.Sp.Dbname)
+		return sourceProfile.Conn.Oracle.StreamingConfig, nil
 	case profiles.SourceProfileConnectionTypePostgreSQL:
-		return ReadStreamingConfig(sourceProfile.Conn.Pg.StreamingConfig, targetProfile.Conn.Sp.Dbname)
+		return sourceProfile.Conn.Pg.StreamingConfig, nil
 	default:
-		return StreamingCfg{}, fmt.Errorf("only MySQL, Oracle and PostgreSQL are supported as source streams")
+		return "", fmt.Errorf("only MySQL, Oracle and PostgreSQL are supported as source streams")
 	}
 }
 
-func StartDatastream(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile) (StreamingCfg, error) {
+func StartDatastream(ctx context.Context, sourceProfile profiles.SourceProfile, targetProfile profiles.TargetProfile, migrationRequestId string) (StreamingCfg, error) {
 	streamingCfg, err := getStreamingConfig(sourceProfile, targetProfile)
 	if err != nil {
 		return streamingCfg, fmt.Errorf("error reading streaming config: %v", err)
 	}
+	// The Datastream output files of an existing stream can be applied locally.
+	if streamingCfg.LocalApplyCfg.Dir != "" && streamingCfg.DatastreamCfg.StreamLocation == "" {
+		return streamingCfg, nil
+	}
 
-	err = LaunchStream(ctx, sourceProfile, targetProfile.Conn.Sp.Project, streamingCfg.DatastreamCfg)
+	// The stream is launched before the snapshot is read, so that it doesn't
+	// miss the changes that follow the snapshot.
+	err = LaunchStream(ctx, sourceProfile, targetProfile.Conn.Sp.Project, streamingCfg.DatastreamCfg, streamingCfg.SnapshotCfg.PositionFile == "", migrationRequestId)
 	if err != nil {
 		return streamingCfg, fmt.Errorf("error launching stream: %v", err)
 	}